@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/toutaio/toutago-ritual-grove/internal/cli"
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
 	"github.com/toutaio/toutago-ritual-grove/internal/registry"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
@@ -48,7 +49,7 @@ func main() {
 		}
 	case "create":
 		if len(os.Args) < 3 {
-			fmt.Println("Usage: ritual create <ritual-name> [project-path] [--yes] [--dry-run]")
+			fmt.Println("Usage: ritual create <ritual-name> [project-path] [--yes] [--dry-run] [--on-error=<policy>]")
 			os.Exit(1)
 		}
 
@@ -60,6 +61,7 @@ func main() {
 
 		dryRun := false
 		useDefaults := false
+		onError := ""
 		for _, arg := range os.Args[3:] {
 			if arg == "--dry-run" {
 				dryRun = true
@@ -67,9 +69,18 @@ func main() {
 			if arg == "--yes" {
 				useDefaults = true
 			}
+			if strings.HasPrefix(arg, "--on-error=") {
+				onError = strings.TrimPrefix(arg, "--on-error=")
+			}
+		}
+
+		policy, err := generator.ParseErrorPolicy(onError)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
 
-		if err := runCreateCommand(ritualName, projectPath, dryRun, useDefaults); err != nil {
+		if err := runCreateCommand(ritualName, projectPath, dryRun, useDefaults, policy); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			os.Exit(1)
 		}
@@ -112,6 +123,7 @@ func printUsage() {
 	fmt.Println("Flags:")
 	fmt.Println("  --json                          Output in JSON format")
 	fmt.Println("  --path <dir>                    Custom ritual search path")
+	fmt.Println("  --on-error=<policy>             On create failure: rollback (default), abort, leave-partial, ask")
 	fmt.Println()
 	fmt.Println("For more information, see:")
 	fmt.Println("  https://github.com/toutaio/toutago-ritual-grove")
@@ -226,7 +238,7 @@ func runListCommandJSON(customPaths []string) error {
 }
 
 // runCreateCommand creates a project from a ritual
-func runCreateCommand(ritualName, projectPath string, dryRun, useDefaults bool) error {
+func runCreateCommand(ritualName, projectPath string, dryRun, useDefaults bool, onError generator.ErrorPolicy) error {
 	// Find ritual
 	reg := registry.NewRegistry()
 	if err := reg.Scan(); err != nil {
@@ -248,8 +260,7 @@ func runCreateCommand(ritualName, projectPath string, dryRun, useDefaults bool)
 	var answers map[string]interface{}
 	if useDefaults {
 		// Use default answers
-		loader := ritual.NewLoader(meta.Path)
-		manifest, err := loader.Load(meta.Path)
+		manifest, err := meta.RitualSource().Load()
 		if err != nil {
 			return fmt.Errorf("failed to load ritual: %w", err)
 		}
@@ -263,7 +274,14 @@ func runCreateCommand(ritualName, projectPath string, dryRun, useDefaults bool)
 	}
 
 	// Execute workflow
-	return cli.Execute(meta.Path, projectPath, answers, dryRun)
+	return cli.NewCreateWorkflow().ExecuteWithOptions(cli.CreateOptions{
+		RitualPath: meta.Path,
+		Source:     meta.RitualSource(),
+		TargetPath: projectPath,
+		Answers:    answers,
+		DryRun:     dryRun,
+		OnError:    onError,
+	})
 }
 
 // runCleanCommand clears the ritual cache
@@ -308,7 +326,6 @@ return fmt.Errorf("failed to clear embedded cache: %w", err)
 }
 
 fmt.Println("✓ Embedded ritual cache cleared successfully")
-fmt.Println("  Next scan will re-extract embedded rituals from the binary")
 }
 
 return nil
@@ -2,12 +2,15 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"time"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/deployment"
 	"github.com/toutaio/toutago-ritual-grove/internal/generator"
 	"github.com/toutaio/toutago-ritual-grove/internal/questionnaire"
 	"github.com/toutaio/toutago-ritual-grove/internal/storage"
@@ -17,21 +20,25 @@ import (
 // CreateOptions holds options for project creation
 type CreateOptions struct {
 	RitualPath string
+	Source     *ritual.Source // Ritual files (default: the directory at RitualPath)
 	TargetPath string
 	Answers    map[string]interface{}
 	DryRun     bool
 	InitGit    bool
+	OnError    generator.ErrorPolicy // What to do with a partly created project (default: rollback)
 }
 
 // CreateWorkflow manages the project creation process
 type CreateWorkflow struct {
 	scaffolder *generator.ProjectScaffolder
+	stdin      io.Reader // Answers the rollback prompt for --on-error=ask
 }
 
 // NewCreateWorkflow creates a new create workflow
 func NewCreateWorkflow() *CreateWorkflow {
 	return &CreateWorkflow{
 		scaffolder: generator.NewProjectScaffolder(),
+		stdin:      os.Stdin,
 	}
 }
 
@@ -55,8 +62,11 @@ func (w *CreateWorkflow) Execute(ritualPath, targetPath string, answers map[stri
 // ExecuteWithOptions runs the complete project creation workflow with options
 func (w *CreateWorkflow) ExecuteWithOptions(opts CreateOptions) error {
 	// Load ritual
-	loader := ritual.NewLoader(opts.RitualPath)
-	manifest, err := loader.Load(opts.RitualPath)
+	src := opts.Source
+	if src == nil {
+		src = ritual.DirSource(opts.RitualPath)
+	}
+	manifest, err := src.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load ritual: %w", err)
 	}
@@ -81,43 +91,86 @@ func (w *CreateWorkflow) ExecuteWithOptions(opts CreateOptions) error {
 	for key, value := range answers {
 		vars.Set(key, value)
 	}
+	run := &generator.Run{
+		Manifest:   manifest,
+		Source:     src,
+		OutputPath: opts.TargetPath,
+		Variables:  vars,
+	}
 
-	// Dry run mode - just validate, don't create files
+	// Dry run mode - render the project in memory, don't create files
 	if opts.DryRun {
 		fmt.Println("DRY RUN MODE - No files will be created")
 		fmt.Printf("Would create project at: %s\n", opts.TargetPath)
 		fmt.Printf("Using ritual: %s v%s\n", manifest.Ritual.Name, manifest.Ritual.Version)
 		fmt.Println("\nAnswers:")
-		for key, value := range answers {
-			fmt.Printf("  %s: %v\n", key, value)
+		masked := vars.MaskSecrets(deployment.SecretQuestions(manifest))
+		for key := range answers {
+			fmt.Printf("  %s: %v\n", key, masked[key])
 		}
+
+		output := generator.NewMemoryFS()
+		w.scaffolder.SetOutput(output)
+		if err := w.scaffolder.Generate(context.Background(), run); err != nil {
+			return fmt.Errorf("failed to render project: %w", err)
+		}
+		fmt.Println()
+		fmt.Print(vars.MaskSecretValues(formatDryRun(opts.TargetPath, output)))
+
 		if opts.InitGit {
 			fmt.Println("\nWould initialize git repository")
 		}
 		return nil
 	}
 
+	journal := w.scaffolder.Journal()
+	state := &storage.State{
+		RitualName:    manifest.Ritual.Name,
+		RitualVersion: manifest.Ritual.Version,
+		InstalledAt:   time.Now(),
+	}
+	run.State = state
+
 	// Create target directory if it doesn't exist
-	if err := os.MkdirAll(opts.TargetPath, 0750); err != nil {
+	if err := journal.MkdirAll(opts.TargetPath, 0750); err != nil {
 		return fmt.Errorf("failed to create target directory: %w", err)
 	}
 
-	// Generate project
-	if err := w.scaffolder.GenerateFromRitual(opts.TargetPath, opts.RitualPath, manifest, vars); err != nil {
-		return fmt.Errorf("failed to generate project: %w", err)
+	// State and snapshot files are written outside the generator
+	if err := journal.Track(filepath.Join(opts.TargetPath, ".ritual")); err != nil {
+		return fmt.Errorf("failed to record project state directory: %w", err)
 	}
 
-	// Save state
-	state := &storage.State{
-		RitualName:    manifest.Ritual.Name,
-		RitualVersion: manifest.Ritual.Version,
-		InstalledAt:   time.Now(),
+	// Render into a staging directory, then move the files into place
+	staging, err := generator.NewStagingFS(opts.TargetPath)
+	if err != nil {
+		return w.recover(opts, state, err)
 	}
+	defer func() { _ = staging.Discard() }()
+	w.scaffolder.SetOutput(staging)
 
+	if err := w.scaffolder.Generate(context.Background(), run); err != nil {
+		// Nothing rendered reaches the project; only what is already on disk is recovered
+		_ = staging.Discard()
+		return w.recover(opts, state, fmt.Errorf("failed to generate project: %w", err))
+	}
+	if err := staging.Commit(journal); err != nil {
+		return w.recover(opts, state, fmt.Errorf("failed to commit generated files: %w", err))
+	}
+
+	// Save state
 	if err := state.Save(opts.TargetPath); err != nil {
-		return fmt.Errorf("failed to save state: %w", err)
+		return w.recover(opts, state, fmt.Errorf("failed to save state: %w", err))
+	}
+
+	// Keep the ritual and answers so updates can regenerate and merge files
+	if err := deployment.SaveSnapshotFrom(opts.TargetPath, src, manifest, answers); err != nil {
+		return w.recover(opts, state, fmt.Errorf("failed to save ritual snapshot: %w", err))
 	}
 
+	// The project is complete; a failing git init no longer undoes it
+	journal.Commit()
+
 	// Initialize git repository if requested
 	if opts.InitGit {
 		if err := initGitRepository(opts.TargetPath); err != nil {
@@ -132,6 +185,29 @@ func (w *CreateWorkflow) ExecuteWithOptions(opts CreateOptions) error {
 	return nil
 }
 
+// saveState records every file the scaffolder has written in state and saves it to .ritual/state.yaml
+func (w *CreateWorkflow) saveState(targetPath string, state *storage.State) error {
+	if err := w.scaffolder.RecordState(state, targetPath); err != nil {
+		return fmt.Errorf("failed to record generated files: %w", err)
+	}
+	if err := state.Save(targetPath); err != nil {
+		return fmt.Errorf("failed to save state: %w", err)
+	}
+	return nil
+}
+
+// recover handles a partly created project according to opts.OnError
+func (w *CreateWorkflow) recover(opts CreateOptions, state *storage.State, cause error) error {
+	return generator.Recovery{
+		Journal:   w.scaffolder.Journal(),
+		Policy:    opts.OnError,
+		Confirm:   generator.ConfirmRollback(w.stdin, os.Stdout, cause),
+		SaveState: func() error { return w.saveState(opts.TargetPath, state) },
+		Out:       os.Stdout,
+		Path:      opts.TargetPath,
+	}.Recover(cause)
+}
+
 // initGitRepository initializes a git repository in the target directory
 func initGitRepository(targetPath string) error {
 	// Check if git is available
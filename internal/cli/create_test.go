@@ -3,7 +3,11 @@ package cli
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
 )
 
 func TestCreateWorkflow(t *testing.T) {
@@ -167,3 +171,93 @@ func TestCreateWorkflow_WithGitInit(t *testing.T) {
 		t.Error("Expected .git/config file to be created")
 	}
 }
+
+func TestCreateWorkflow_OnError(t *testing.T) {
+	ritualDir := t.TempDir()
+	ritualYAML := `ritual:
+  name: broken
+  version: 1.0.0
+  template_engine: go-template
+
+files:
+  templates:
+    - src: "broken.txt"
+      dest: "go.mod/broken.txt"
+`
+	if err := os.WriteFile(filepath.Join(ritualDir, "ritual.yaml"), []byte(ritualYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// Planning succeeds; writing fails once go.mod is already there
+	if err := os.MkdirAll(filepath.Join(ritualDir, "templates"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ritualDir, "templates", "broken.txt"), []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	answers := map[string]interface{}{"project_name": "broken"}
+
+	t.Run("rollback", func(t *testing.T) {
+		targetDir := filepath.Join(t.TempDir(), "project")
+		err := NewCreateWorkflow().ExecuteWithOptions(CreateOptions{
+			RitualPath: ritualDir,
+			TargetPath: targetDir,
+			Answers:    answers,
+			OnError:    generator.OnErrorRollback,
+		})
+		if err == nil {
+			t.Fatal("Expected error for unwritable file")
+		}
+		if _, err := os.Stat(targetDir); !os.IsNotExist(err) {
+			t.Errorf("Expected target directory to be rolled back, stat error = %v", err)
+		}
+		if entries, _ := os.ReadDir(filepath.Dir(targetDir)); len(entries) != 0 {
+			t.Errorf("Expected the staging directory to be removed, found %v", entries)
+		}
+	})
+
+	t.Run("leave-partial", func(t *testing.T) {
+		targetDir := filepath.Join(t.TempDir(), "project")
+		err := NewCreateWorkflow().ExecuteWithOptions(CreateOptions{
+			RitualPath: ritualDir,
+			TargetPath: targetDir,
+			Answers:    answers,
+			OnError:    generator.OnErrorLeavePartial,
+		})
+		if err == nil {
+			t.Fatal("Expected error for unwritable file")
+		}
+
+		state, err := storage.LoadState(targetDir)
+		if err != nil {
+			t.Fatalf("Expected state for the partial project: %v", err)
+		}
+		// Files rendered before the failure were staged and never reach the project
+		if _, err := os.Stat(filepath.Join(targetDir, "go.mod")); !os.IsNotExist(err) {
+			t.Errorf("Expected go.mod not to be committed, stat error = %v", err)
+		}
+		if state.IsFileGenerated("go.mod") {
+			t.Error("Expected go.mod not to be recorded in state")
+		}
+	})
+
+	t.Run("ask declined", func(t *testing.T) {
+		targetDir := filepath.Join(t.TempDir(), "project")
+		workflow := NewCreateWorkflow()
+		workflow.stdin = strings.NewReader("n\n")
+		err := workflow.ExecuteWithOptions(CreateOptions{
+			RitualPath: ritualDir,
+			TargetPath: targetDir,
+			Answers:    answers,
+			OnError:    generator.OnErrorAsk,
+		})
+		if err == nil {
+			t.Fatal("Expected error for unwritable file")
+		}
+		if _, err := os.Stat(targetDir); err != nil {
+			t.Errorf("Expected partial project to be kept: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(targetDir, "go.mod")); !os.IsNotExist(err) {
+			t.Errorf("Expected go.mod not to be committed, stat error = %v", err)
+		}
+	})
+}
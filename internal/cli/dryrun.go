@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
+)
+
+// formatDryRun describes a project rendered into memory: its file tree, then
+// the content of every file. Files that already exist in targetPath are marked.
+func formatDryRun(targetPath string, output *generator.MemoryFS) string {
+	files := output.Files(targetPath)
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Files that would be created (%d):\n\n", len(paths))
+	b.WriteString(formatTree(filepath.Base(filepath.Clean(targetPath)), output.Dirs(targetPath), paths, func(p string) string {
+		if _, err := os.Stat(filepath.Join(targetPath, filepath.FromSlash(p))); err == nil {
+			return " (overwrites existing file)"
+		}
+		return ""
+	}))
+
+	for _, p := range paths {
+		content := string(files[p])
+		fmt.Fprintf(&b, "\n==> %s <==\n%s", p, content)
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// treeNode is a directory or file in a formatted tree
+type treeNode struct {
+	name     string
+	path     string
+	isDir    bool
+	children map[string]*treeNode
+}
+
+// formatTree draws slash-separated directories and files as an indented tree.
+// note returns a suffix for a file line.
+func formatTree(rootName string, dirs, files []string, note func(string) string) string {
+	root := &treeNode{name: rootName, isDir: true, children: map[string]*treeNode{}}
+	add := func(p string, isDir bool) {
+		node := root
+		parts := strings.Split(p, "/")
+		for i, part := range parts {
+			child, ok := node.children[part]
+			if !ok {
+				child = &treeNode{
+					name:     part,
+					path:     path.Join(parts[:i+1]...),
+					isDir:    isDir || i < len(parts)-1,
+					children: map[string]*treeNode{},
+				}
+				node.children[part] = child
+			}
+			node = child
+		}
+	}
+	for _, d := range dirs {
+		add(d, true)
+	}
+	for _, f := range files {
+		add(f, false)
+	}
+
+	var b strings.Builder
+	b.WriteString(root.name + "/\n")
+	var walk func(node *treeNode, prefix string)
+	walk = func(node *treeNode, prefix string) {
+		names := make([]string, 0, len(node.children))
+		for name := range node.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			child := node.children[name]
+			branch, indent := "├── ", "│   "
+			if i == len(names)-1 {
+				branch, indent = "└── ", "    "
+			}
+			if child.isDir {
+				b.WriteString(prefix + branch + child.name + "/\n")
+				walk(child, prefix+indent)
+			} else {
+				b.WriteString(prefix + branch + child.name + note(child.path) + "\n")
+			}
+		}
+	}
+	walk(root, "")
+	return b.String()
+}
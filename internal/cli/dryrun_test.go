@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
+)
+
+func TestFormatDryRun(t *testing.T) {
+	targetPath := filepath.Join(t.TempDir(), "my-app")
+	if err := os.MkdirAll(targetPath, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(targetPath, "README.md"), []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	output := generator.NewMemoryFS()
+	_ = output.MkdirAll(filepath.Join(targetPath, "docs"), 0750)
+	_ = output.WriteFile(filepath.Join(targetPath, "cmd", "server", "main.go"), []byte("package main\n"), 0600)
+	_ = output.WriteFile(filepath.Join(targetPath, "README.md"), []byte("# My App"), 0600)
+
+	got := formatDryRun(targetPath, output)
+	want := `Files that would be created (2):
+
+my-app/
+├── README.md (overwrites existing file)
+├── cmd/
+│   └── server/
+│       └── main.go
+└── docs/
+
+==> README.md <==
+# My App
+
+==> cmd/server/main.go <==
+package main
+`
+	if got != want {
+		t.Errorf("formatDryRun() =\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCreateWorkflow_DryRunRendersInMemory(t *testing.T) {
+	ritualDir := t.TempDir()
+	ritualYAML := `ritual:
+  name: dry
+  version: 1.0.0
+  template_engine: go-template
+
+files:
+  templates:
+    - src: "hello.txt.tmpl"
+      dest: "hello.txt"
+`
+	if err := os.WriteFile(filepath.Join(ritualDir, "ritual.yaml"), []byte(ritualYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(ritualDir, "templates"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ritualDir, "templates", "hello.txt.tmpl"), []byte("Hello [[ .project_name ]]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	targetDir := filepath.Join(t.TempDir(), "project")
+	err := NewCreateWorkflow().ExecuteWithOptions(CreateOptions{
+		RitualPath: ritualDir,
+		TargetPath: targetDir,
+		Answers:    map[string]interface{}{"project_name": "dry"},
+		DryRun:     true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error in dry-run: %v", err)
+	}
+	if _, err := os.Stat(targetDir); !os.IsNotExist(err) {
+		t.Error("Expected dry-run not to create the target directory")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(targetDir)); len(entries) != 0 {
+		t.Errorf("Expected dry-run to leave no staging files, found %v", entries)
+	}
+}
@@ -0,0 +1,258 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/deployment"
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// DiffOptions contains options for the diff command
+type DiffOptions struct {
+	ToVersion  string   // Compare against this ritual version instead of the installed one
+	RitualPath string   // Path to the ritual to compare against (default: snapshot or registry)
+	Paths      []string // Only show files matching these paths or glob patterns
+	Stat       bool     // Show a diffstat instead of patches
+	NameOnly   bool     // Show only the names of changed files
+	JSON       bool     // Output in JSON format
+}
+
+// fileChange is a changed file in the diff output
+type fileChange struct {
+	Path      string `json:"path"`
+	Status    string `json:"status"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+	Patch     string `json:"patch,omitempty"`
+}
+
+// NewDiffCommand creates the diff command
+func NewDiffCommand() *cobra.Command {
+	var projectPath string
+	opts := DiffOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "diff [paths...]",
+		Short: "Show differences between the project and the ritual output",
+		Long: `Render the ritual with the project's saved answers and compare the result
+with the working tree.
+
+Without --to or --ritual the installed ritual version (from .ritual/) is
+rendered, showing what you changed. With --to, the target version is rendered,
+showing how your project differs from what that version would generate.
+
+Lines starting with - are what the ritual generates, lines starting with +
+are what is in your project.
+
+Example:
+  touta ritual diff
+  touta ritual diff --stat
+  touta ritual diff --name-only internal/
+  touta ritual diff --to 1.2.0 --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Paths = args
+			return runDiff(cmd.OutOrStdout(), projectPath, opts)
+		},
+	}
+
+	cmd.Flags().StringVarP(&projectPath, "path", "p", ".", "Project directory")
+	cmd.Flags().StringVar(&opts.ToVersion, "to", "", "Ritual version to compare against (default: installed version)")
+	cmd.Flags().StringVar(&opts.RitualPath, "ritual", "", "Path to the ritual to compare against")
+	cmd.Flags().BoolVar(&opts.Stat, "stat", false, "Show a diffstat instead of patches")
+	cmd.Flags().BoolVar(&opts.NameOnly, "name-only", false, "Show only names of changed files")
+	cmd.Flags().BoolVar(&opts.JSON, "json", false, "Output in JSON format")
+
+	return cmd
+}
+
+func runDiff(out io.Writer, projectPath string, opts DiffOptions) error {
+	state, err := storage.LoadState(projectPath)
+	if err != nil {
+		return fmt.Errorf("failed to load project state: %w", err)
+	}
+
+	src, err := diffRitualSource(projectPath, state, opts)
+	if err != nil {
+		return fmt.Errorf("failed to open ritual: %w", err)
+	}
+	manifest, err := src.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load ritual: %w", err)
+	}
+	if opts.ToVersion != "" && manifest.Ritual.Version != opts.ToVersion {
+		return fmt.Errorf("ritual %s at %s is version %s, not %s",
+			state.RitualName, src.Path, manifest.Ritual.Version, opts.ToVersion)
+	}
+
+	answers, err := deployment.LoadSnapshotAnswers(projectPath)
+	if err != nil {
+		return fmt.Errorf("failed to load saved answers: %w", err)
+	}
+
+	rendered, err := renderRitual(manifest, src, answers)
+	if err != nil {
+		return fmt.Errorf("failed to render ritual %s: %w", manifest.Ritual.Version, err)
+	}
+
+	// Files tracked in state but no longer generated by the ritual show up as additions
+	tracked := make(map[string]string)
+	for _, f := range state.GeneratedFiles {
+		if generator.FromRitual(f.Source) {
+			tracked[f.Path] = ""
+		}
+	}
+	working, err := readProjectFiles(projectPath, rendered, tracked)
+	if err != nil {
+		return err
+	}
+
+	rendered = filterPaths(rendered, opts.Paths)
+	working = filterPaths(working, opts.Paths)
+
+	changes := collectChanges(rendered, working)
+
+	switch {
+	case opts.JSON:
+		return outputDiffJSON(out, state, manifest, changes)
+	case opts.NameOnly:
+		for _, c := range changes {
+			_, _ = fmt.Fprintln(out, c.Path)
+		}
+	case opts.Stat:
+		writeDiffStat(out, changes)
+	default:
+		for _, c := range changes {
+			_, _ = fmt.Fprint(out, c.Patch)
+		}
+	}
+
+	return nil
+}
+
+// diffRitualSource returns the ritual to render: the project's snapshot unless
+// another ritual or version is asked for
+func diffRitualSource(projectPath string, state *storage.State, opts DiffOptions) (*ritual.Source, error) {
+	if opts.RitualPath == "" && opts.ToVersion == "" && deployment.HasSnapshot(projectPath) {
+		return snapshotSource(projectPath)
+	}
+	return resolveRitualSource(state.RitualName, opts.RitualPath), nil
+}
+
+// collectChanges classifies files with DiffGenerator and builds a patch for each change
+func collectChanges(rendered, working map[string]string) []fileChange {
+	differ := deployment.NewDiffGenerator()
+	diff := differ.GenerateDiff(rendered, working)
+
+	var changes []fileChange
+	add := func(paths []string, status string) {
+		for _, p := range paths {
+			oldLabel, newLabel := "a/"+p, "b/"+p
+			if _, ok := rendered[p]; !ok {
+				oldLabel = "/dev/null"
+			}
+			if _, ok := working[p]; !ok {
+				newLabel = "/dev/null"
+			}
+			patch := differ.UnifiedDiff(p, oldLabel, newLabel, rendered[p], working[p])
+			changes = append(changes, fileChange{
+				Path:      p,
+				Status:    status,
+				Additions: patch.Additions,
+				Deletions: patch.Deletions,
+				Binary:    patch.Binary,
+				Patch:     patch.Text,
+			})
+		}
+	}
+	add(diff.Modified, "modified")
+	add(diff.Deleted, "deleted")
+	add(diff.Added, "added")
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// filterPaths keeps files equal to, below, or matching one of the given paths
+func filterPaths(files map[string]string, filters []string) map[string]string {
+	if len(filters) == 0 {
+		return files
+	}
+
+	filtered := make(map[string]string)
+	for p, content := range files {
+		for _, f := range filters {
+			f = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(f)), "/")
+			matched, _ := path.Match(f, p)
+			if matched || p == f || f == "." || strings.HasPrefix(p, f+"/") {
+				filtered[p] = content
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// maxStatBar is the widest +/- bar printed by --stat
+const maxStatBar = 50
+
+// writeDiffStat writes a git-style diffstat
+func writeDiffStat(out io.Writer, changes []fileChange) {
+	width := 0
+	for _, c := range changes {
+		if len(c.Path) > width {
+			width = len(c.Path)
+		}
+	}
+
+	additions, deletions := 0, 0
+	for _, c := range changes {
+		additions += c.Additions
+		deletions += c.Deletions
+		if c.Binary {
+			_, _ = fmt.Fprintf(out, " %-*s | Bin\n", width, c.Path)
+			continue
+		}
+		plus, minus := c.Additions, c.Deletions
+		if total := plus + minus; total > maxStatBar {
+			plus = plus * maxStatBar / total
+			minus = maxStatBar - plus
+		}
+		_, _ = fmt.Fprintf(out, " %-*s | %d %s%s\n", width, c.Path, c.Additions+c.Deletions,
+			strings.Repeat("+", plus), strings.Repeat("-", minus))
+	}
+	_, _ = fmt.Fprintf(out, " %d file(s) changed, %d insertion(s)(+), %d deletion(s)(-)\n",
+		len(changes), additions, deletions)
+}
+
+func outputDiffJSON(out io.Writer, state *storage.State, manifest *ritual.Manifest, changes []fileChange) error {
+	if changes == nil {
+		changes = []fileChange{}
+	}
+	output := map[string]interface{}{
+		"ritual":           state.RitualName,
+		"project_version":  state.RitualVersion,
+		"compared_version": manifest.Ritual.Version,
+		"files":            changes,
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	_, _ = fmt.Fprintln(out, string(data))
+	return nil
+}
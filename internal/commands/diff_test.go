@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/deployment"
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// setupDiffProject generates a project from a v1 ritual and edits main.go
+func setupDiffProject(t *testing.T) (projectDir, newRitual string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	oldRitual := filepath.Join(tmpDir, "v1", "merge-ritual")
+	newRitual = filepath.Join(tmpDir, "v2", "merge-ritual")
+	projectDir = filepath.Join(tmpDir, "project")
+
+	writeTestRitual(t, oldRitual, "1.0.0", "package main\n\n// [[ .app_name ]]\nfunc main() {\n}\n")
+	writeTestRitual(t, newRitual, "1.1.0", "package main\n\n// [[ .app_name ]] v2\nfunc main() {\n}\n")
+
+	manifest, err := ritual.NewLoader(oldRitual).Load(oldRitual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answers := map[string]interface{}{"app_name": "demo"}
+	files, err := renderRitual(manifest, ritual.DirSource(oldRitual), answers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(projectDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(files["main.go"], "func main() {\n", "func main() {\n\tprintln(\"hi\")\n", 1)
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte(edited), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &storage.State{RitualName: "merge-ritual", RitualVersion: "1.0.0"}
+	if err := state.Save(projectDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := deployment.SaveSnapshot(projectDir, oldRitual, manifest, answers); err != nil {
+		t.Fatal(err)
+	}
+
+	return projectDir, newRitual
+}
+
+func runDiffCommand(t *testing.T, args ...string) string {
+	t.Helper()
+	cmd := NewDiffCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs(args)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("diff %v error = %v", args, err)
+	}
+	return out.String()
+}
+
+func TestDiffCommand_AgainstInstalledVersion(t *testing.T) {
+	projectDir, _ := setupDiffProject(t)
+
+	output := runDiffCommand(t, "--path", projectDir)
+
+	want := "--- a/main.go\n+++ b/main.go\n@@ -2,4 +2,5 @@\n \n // demo\n func main() {\n+\tprintln(\"hi\")\n }\n"
+	if output != want {
+		t.Errorf("diff output =\n%s\nwant:\n%s", output, want)
+	}
+}
+
+func TestDiffCommand_StatAndNameOnly(t *testing.T) {
+	projectDir, _ := setupDiffProject(t)
+
+	stat := runDiffCommand(t, "--path", projectDir, "--stat")
+	if !strings.Contains(stat, " main.go | 1 +\n") || !strings.Contains(stat, "1 file(s) changed, 1 insertion(s)(+), 0 deletion(s)(-)") {
+		t.Errorf("Unexpected --stat output:\n%s", stat)
+	}
+
+	names := runDiffCommand(t, "--path", projectDir, "--name-only")
+	if names != "main.go\n" {
+		t.Errorf("--name-only = %q, want %q", names, "main.go\n")
+	}
+
+	filtered := runDiffCommand(t, "--path", projectDir, "--name-only", "internal/")
+	if filtered != "" {
+		t.Errorf("Expected path filter to exclude main.go, got %q", filtered)
+	}
+}
+
+func TestDiffCommand_TargetVersionJSON(t *testing.T) {
+	projectDir, newRitual := setupDiffProject(t)
+
+	output := runDiffCommand(t, "--path", projectDir, "--to", "1.1.0", "--ritual", newRitual, "--json")
+
+	var result struct {
+		ComparedVersion string       `json:"compared_version"`
+		Files           []fileChange `json:"files"`
+	}
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, output)
+	}
+	if result.ComparedVersion != "1.1.0" {
+		t.Errorf("compared_version = %s, want 1.1.0", result.ComparedVersion)
+	}
+	if len(result.Files) != 1 || result.Files[0].Status != "modified" {
+		t.Fatalf("Unexpected files: %+v", result.Files)
+	}
+	if result.Files[0].Additions != 2 || result.Files[0].Deletions != 1 {
+		t.Errorf("Additions/Deletions = %d/%d, want 2/1", result.Files[0].Additions, result.Files[0].Deletions)
+	}
+}
+
+func TestDiffCommand_VersionMismatch(t *testing.T) {
+	projectDir, newRitual := setupDiffProject(t)
+
+	cmd := NewDiffCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--path", projectDir, "--to", "2.0.0", "--ritual", newRitual})
+	if err := cmd.Execute(); err == nil {
+		t.Error("Expected error when ritual version does not match --to")
+	}
+}
@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/modfile"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/deployment"
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+)
+
+// NewGenerateCommand creates the generate command that adds a component to an existing project
+func NewGenerateCommand() *cobra.Command {
+	var projectPath string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:     "generate <kind> <name> [field:type...]",
+		Aliases: []string{"g"},
+		Short:   "Add a model, handler, middleware, route, test or mock to the project",
+		Long: `Generate a component into a project created by a ritual.
+
+Kinds:
+  model       model, migration and tested repository of an entity with the given fields
+  handler     CRUD handler of an entity
+  route       RESTful routes of an entity's handler
+  test        table-driven tests of an entity's handler, with the given fields
+  middleware  HTTP middleware
+  mock        mock of an interface declared in the project, next to it
+
+Fields are name:type[:required][:unique], with type one of string, text,
+int, float, bool or time. The module path is read from go.mod and the
+packages go where the project already keeps its models, handlers, etc.
+Existing files are only overwritten with --force.
+
+Example:
+  touta ritual generate model Post title:string:required body:text
+  touta ritual generate handler Post
+  touta ritual generate route Post
+  touta ritual generate test Post title:string:required body:text
+  touta ritual generate middleware RateLimit
+  touta ritual generate mock PostRepository`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGenerate(cmd.OutOrStdout(), projectPath, args[0], args[1], args[2:], force)
+		},
+	}
+
+	cmd.Flags().StringVarP(&projectPath, "path", "p", ".", "Project directory")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite existing files")
+
+	return cmd
+}
+
+func runGenerate(out io.Writer, projectPath, kind, name string, args []string, force bool) error {
+	state, err := storage.LoadState(projectPath)
+	if err != nil {
+		return fmt.Errorf("failed to load project state: %w", err)
+	}
+	module, err := projectModule(projectPath)
+	if err != nil {
+		return err
+	}
+	answers, err := deployment.LoadSnapshotAnswers(projectPath)
+	if err != nil {
+		return fmt.Errorf("failed to load saved answers: %w", err)
+	}
+	dialect, _ := answers["database_type"].(string)
+
+	paths := make([]string, 0, len(state.GeneratedFiles))
+	for _, f := range state.GeneratedFiles {
+		paths = append(paths, f.Path)
+	}
+	gen := generator.NewComponentGenerator(projectPath, module, dialect, generator.InferLayout(paths))
+	files, err := gen.Generate(kind, name, args)
+	if err != nil {
+		return fmt.Errorf("failed to generate %s %s: %w", kind, name, err)
+	}
+
+	if !force {
+		var existing []string
+		for _, f := range files {
+			if _, err := os.Stat(filepath.Join(projectPath, filepath.FromSlash(f.Path))); err == nil {
+				existing = append(existing, f.Path)
+			}
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", strings.Join(existing, ", "))
+		}
+	}
+
+	// Written through a journal so a failure leaves the project as it was
+	journal := generator.NewJournal()
+	for _, f := range files {
+		fullPath := filepath.Join(projectPath, filepath.FromSlash(f.Path))
+		if err := journal.WriteFile(fullPath, f.Content, 0600); err != nil {
+			return rollbackGenerate(journal, fmt.Errorf("failed to write %s: %w", f.Path, err))
+		}
+		state.RecordGeneratedContent(f.Path, generator.ComponentSourcePrefix+kind, f.Content, 0600)
+	}
+
+	if err := state.Save(projectPath); err != nil {
+		return rollbackGenerate(journal, fmt.Errorf("failed to save project state: %w", err))
+	}
+	journal.Commit()
+
+	for _, f := range files {
+		_, _ = fmt.Fprintf(out, "  create  %s\n", f.Path)
+	}
+	return nil
+}
+
+// rollbackGenerate undoes the files written by a failed generate
+func rollbackGenerate(journal *generator.Journal, cause error) error {
+	if err := journal.Rollback(); err != nil {
+		return fmt.Errorf("%w (rollback failed: %v)", cause, err)
+	}
+	return cause
+}
+
+// projectModule returns the module path declared in the go.mod of the project
+func projectModule(projectPath string) (string, error) {
+	// #nosec G304 - the go.mod of the project being generated into
+	data, err := os.ReadFile(filepath.Join(projectPath, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	module := modfile.ModulePath(data)
+	if module == "" {
+		return "", fmt.Errorf("go.mod declares no module path")
+	}
+	return module, nil
+}
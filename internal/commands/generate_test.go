@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+)
+
+func setupGenerateProject(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"go.mod":                  "module example.com/shop\n\ngo 1.24\n",
+		"app/handlers/health.go":  "package handlers\n",
+		"migrations/001_init.sql": "SELECT 1;\n",
+		"app/models/.gitkeep":     "",
+	}
+	state := &storage.State{RitualName: "test-ritual", RitualVersion: "1.0.0"}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := state.RecordGeneratedFile(tmpDir, name, name+".tmpl"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := state.Save(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	return tmpDir
+}
+
+func TestGenerateCommand(t *testing.T) {
+	projectDir := setupGenerateProject(t)
+
+	cmd := NewGenerateCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"model", "Product", "name:string:required", "price:float", "--path", projectDir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	created := []string{
+		"app/models/product.go",
+		"migrations/002_create_products.sql",
+		"internal/repository/product_repository.go",
+		"internal/repository/product_repository_test.go",
+	}
+	for _, path := range created {
+		if !strings.Contains(out.String(), "create  "+path) {
+			t.Errorf("Expected output to list %s, got:\n%s", path, out.String())
+		}
+	}
+
+	repo, err := os.ReadFile(filepath.Join(projectDir, "internal", "repository", "product_repository.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(repo), `"example.com/shop/app/models"`) {
+		t.Errorf("The repository should import the models of the project, got:\n%s", repo)
+	}
+
+	state, err := storage.LoadState(projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range created {
+		file, ok := state.GetGeneratedFile(path)
+		if !ok || file.Source != generator.ComponentSourcePrefix+"model" || file.SHA256 == "" {
+			t.Errorf("%s should be recorded in state, got %+v", path, file)
+		}
+	}
+
+	handler := NewGenerateCommand()
+	handler.SetOut(&out)
+	handler.SetArgs([]string{"handler", "Product", "--path", projectDir})
+	if err := handler.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "app", "handlers", "product_handler.go")); err != nil {
+		t.Errorf("The handler should be generated next to the project's handlers: %v", err)
+	}
+}
+
+func TestGenerateCommand_Force(t *testing.T) {
+	projectDir := setupGenerateProject(t)
+	middleware := filepath.Join(projectDir, "internal", "middleware", "rate_limit.go")
+	if err := os.MkdirAll(filepath.Dir(middleware), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(middleware, []byte("package middleware\n// mine\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewGenerateCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"middleware", "RateLimit", "--path", projectDir})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("Execute() error = %v, want a refusal to overwrite", err)
+	}
+	if content, _ := os.ReadFile(middleware); string(content) != "package middleware\n// mine\n" {
+		t.Errorf("The existing file should be kept, got:\n%s", content)
+	}
+
+	cmd = NewGenerateCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"middleware", "RateLimit", "--force", "--path", projectDir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if content, _ := os.ReadFile(middleware); !strings.Contains(string(content), "func RateLimit(next http.HandlerFunc) http.HandlerFunc") {
+		t.Errorf("The file should be overwritten with --force, got:\n%s", content)
+	}
+}
+
+func TestGenerateCommand_RollsBackOnFailure(t *testing.T) {
+	projectDir := setupGenerateProject(t)
+	blocker := filepath.Join(projectDir, "internal", "repository", "product_repository_test.go")
+	if err := os.MkdirAll(blocker, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewGenerateCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"model", "Product", "name:string", "--force", "--path", projectDir})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("Execute() should fail when a file can't be written")
+	}
+
+	for _, path := range []string{"app/models/product.go", "migrations/002_create_products.sql", "internal/repository/product_repository.go"} {
+		if _, err := os.Stat(filepath.Join(projectDir, filepath.FromSlash(path))); !os.IsNotExist(err) {
+			t.Errorf("%s should be rolled back, got %v", path, err)
+		}
+	}
+	state, err := storage.LoadState(projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.GetGeneratedFile("app/models/product.go"); ok {
+		t.Error("Nothing should be recorded in state after a rollback")
+	}
+
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	cmd = NewGenerateCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"model", "Product", "name:string", "--path", projectDir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Running again without --force should succeed, got %v", err)
+	}
+}
+
+func TestGenerateCommand_NoState(t *testing.T) {
+	cmd := NewGenerateCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"handler", "Product", "--path", t.TempDir()})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "project state") {
+		t.Errorf("Execute() error = %v, want a missing state error", err)
+	}
+}
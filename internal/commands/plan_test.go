@@ -28,8 +28,8 @@ func TestPlanCommand(t *testing.T) {
 	state := &storage.State{
 		RitualName:    "test-ritual",
 		RitualVersion: "1.0.0",
-		GeneratedFiles: []string{
-			"main.go",
+		GeneratedFiles: []storage.GeneratedFile{
+			{Path: "main.go"},
 		},
 	}
 	if err := state.Save(tmpDir); err != nil {
@@ -134,7 +134,7 @@ func TestPlanCommand_EmptyRitualName(t *testing.T) {
 	}
 }
 
-func TestPlanCommand_JSONOutputNotImplemented(t *testing.T) {
+func TestPlanCommand_JSONOutput(t *testing.T) {
 	// Create temp project directory
 	tmpDir := t.TempDir()
 	oldDir, _ := os.Getwd()
@@ -177,14 +177,9 @@ func TestPlanCommand_JSONOutputNotImplemented(t *testing.T) {
 	cmd.SetOut(&buf)
 	cmd.SetErr(&buf)
 
-	err := cmd.Execute()
-
-	// Should error since JSON output is not implemented
-	if err == nil {
-		t.Error("Expected error for JSON output not implemented")
-	}
-	if !strings.Contains(err.Error(), "JSON output not yet implemented") {
-		t.Errorf("Expected 'JSON output not yet implemented' error, got: %v", err)
+	// The plan against the built-in ritual is printed as JSON
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("Expected JSON plan output, got error: %v", err)
 	}
 }
 
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+)
+
+// NewStatusCommand creates the status command that reports drift of generated files
+func NewStatusCommand() *cobra.Command {
+	var projectPath string
+	var jsonOutput bool
+	var showAll bool
+
+	cmd := &cobra.Command{
+		Use:     "status",
+		Aliases: []string{"drift"},
+		Short:   "Show which generated files were modified or deleted",
+		Long: `Compare the files generated by the ritual with their recorded hashes.
+
+Each generated file is reported as:
+  modified   - content or permissions changed since generation
+  deleted    - the file no longer exists
+  unchanged  - the file is exactly as the ritual generated it
+  unknown    - no hash was recorded (project created by an older version)
+
+Example:
+  touta ritual status
+  touta ritual drift --all
+  touta ritual status --json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatus(cmd.OutOrStdout(), projectPath, jsonOutput, showAll)
+		},
+	}
+
+	cmd.Flags().StringVarP(&projectPath, "path", "p", ".", "Project directory")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output status in JSON format")
+	cmd.Flags().BoolVarP(&showAll, "all", "a", false, "Also list unchanged files")
+
+	return cmd
+}
+
+// statusSection is a group of files printed by the status command
+type statusSection struct {
+	status storage.FileStatus
+	title  string
+}
+
+func runStatus(out io.Writer, projectPath string, jsonOutput, showAll bool) error {
+	state, err := storage.LoadState(projectPath)
+	if err != nil {
+		return fmt.Errorf("failed to load project state: %w", err)
+	}
+
+	report, err := storage.DetectDrift(projectPath, state)
+	if err != nil {
+		return fmt.Errorf("failed to detect drift: %w", err)
+	}
+
+	if jsonOutput {
+		return outputStatusJSON(out, state, report)
+	}
+
+	_, _ = fmt.Fprintf(out, "Ritual: %s v%s\n", state.RitualName, state.RitualVersion)
+	_, _ = fmt.Fprintf(out, "Generated files: %d\n", len(report.Files))
+
+	sections := []statusSection{
+		{storage.FileModified, "Modified"},
+		{storage.FileDeleted, "Deleted"},
+		{storage.FileUnknown, "Not tracked by hash"},
+	}
+	if showAll {
+		sections = append(sections, statusSection{storage.FileUnchanged, "Unchanged"})
+	}
+
+	for _, section := range sections {
+		files := report.WithStatus(section.status)
+		if len(files) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "\n%s (%d):\n", section.title, len(files))
+		for _, f := range files {
+			line := "  " + f.Path
+			if f.ModeChanged {
+				line += " (mode changed)"
+			}
+			_, _ = fmt.Fprintln(out, line)
+		}
+	}
+
+	if !report.HasDrift() {
+		_, _ = fmt.Fprintf(out, "\n✓ All generated files are unchanged (%d)\n", len(report.WithStatus(storage.FileUnchanged)))
+	}
+
+	return nil
+}
+
+func outputStatusJSON(out io.Writer, state *storage.State, report *storage.DriftReport) error {
+	output := map[string]interface{}{
+		"ritual":         state.RitualName,
+		"ritual_version": state.RitualVersion,
+		"files":          report.Files,
+		"summary": map[string]int{
+			"modified":  len(report.WithStatus(storage.FileModified)),
+			"deleted":   len(report.WithStatus(storage.FileDeleted)),
+			"unchanged": len(report.WithStatus(storage.FileUnchanged)),
+			"unknown":   len(report.WithStatus(storage.FileUnknown)),
+		},
+		"has_drift": report.HasDrift(),
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	_, _ = fmt.Fprintln(out, string(data))
+	return nil
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+)
+
+func setupStatusProject(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"main.go":     "package main\n",
+		"handler.go":  "package handlers\n",
+		"removed.txt": "bye\n",
+	} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := &storage.State{RitualName: "test-ritual", RitualVersion: "1.0.0"}
+	for _, name := range []string{"main.go", "handler.go", "removed.txt"} {
+		if err := state.RecordGeneratedFile(tmpDir, name, name+".tmpl"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := state.Save(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "handler.go"), []byte("package handlers\n// edited\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "removed.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	return tmpDir
+}
+
+func TestStatusCommand(t *testing.T) {
+	projectDir := setupStatusProject(t)
+
+	cmd := NewStatusCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--path", projectDir, "--all"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	output := out.String()
+	for _, want := range []string{"Modified (1):\n  handler.go", "Deleted (1):\n  removed.txt", "Unchanged (1):\n  main.go"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, output)
+		}
+	}
+}
+
+func TestStatusCommand_JSON(t *testing.T) {
+	projectDir := setupStatusProject(t)
+
+	cmd := NewStatusCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--path", projectDir, "--json"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	var result struct {
+		HasDrift bool           `json:"has_drift"`
+		Summary  map[string]int `json:"summary"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, out.String())
+	}
+	if !result.HasDrift {
+		t.Error("Expected has_drift to be true")
+	}
+	if result.Summary["modified"] != 1 || result.Summary["deleted"] != 1 || result.Summary["unchanged"] != 1 {
+		t.Errorf("Unexpected summary: %v", result.Summary)
+	}
+}
+
+func TestStatusCommand_NoState(t *testing.T) {
+	cmd := NewStatusCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--path", t.TempDir()})
+
+	if err := cmd.Execute(); err == nil {
+		t.Error("Expected error for project without state")
+	}
+}
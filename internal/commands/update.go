@@ -2,20 +2,28 @@ package commands
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 
 	"github.com/toutaio/toutago-ritual-grove/internal/deployment"
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
 	"github.com/toutaio/toutago-ritual-grove/internal/migration"
+	"github.com/toutaio/toutago-ritual-grove/internal/questionnaire"
+	"github.com/toutaio/toutago-ritual-grove/internal/registry"
 	"github.com/toutaio/toutago-ritual-grove/internal/storage"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
 
 // UpdateOptions contains options for the update command
 type UpdateOptions struct {
-	ToVersion string
-	DryRun    bool
-	Force     bool
+	ToVersion  string
+	RitualPath string // Path to the new ritual version (default: resolved from the registry)
+	DryRun     bool
+	Force      bool
 }
 
 // UpdateHandler handles ritual updates
@@ -63,15 +71,25 @@ func (h *UpdateHandler) Execute(projectPath string, opts UpdateOptions) error {
 		return err
 	}
 
-	newManifest, err := h.loadNewRitual(state.RitualName)
+	newRitual := resolveRitualSource(state.RitualName, opts.RitualPath)
+	newManifest, err := h.loadNewRitual(newRitual)
 	if err != nil {
 		return err
 	}
 
+	if newManifest.Ritual.Version != targetVersion {
+		return fmt.Errorf("ritual %s at %s is version %s, not %s",
+			state.RitualName, newRitual.Path, newManifest.Ritual.Version, targetVersion)
+	}
+
 	if err := h.runMigrations(projectPath, state, newManifest, backupPath, opts.Force); err != nil {
 		return err
 	}
 
+	if err := h.mergeRitualFiles(projectPath, state, newRitual, newManifest); err != nil {
+		return h.handleMergeError(err, backupPath, projectPath, opts.Force)
+	}
+
 	return h.saveUpdatedState(state, targetVersion, projectPath)
 }
 
@@ -120,15 +138,225 @@ func (h *UpdateHandler) createBackup(projectPath string) (string, error) {
 	return backupPath, nil
 }
 
-func (h *UpdateHandler) loadNewRitual(ritualName string) (*ritual.Manifest, error) {
-	loader := ritual.NewLoader(ritualName)
-	newManifest, err := loader.Load(ritualName)
+// resolveRitualSource finds the files of the new ritual version.
+// An explicit path wins; otherwise the ritual is looked up in the registry by name.
+func resolveRitualSource(ritualName, ritualPath string) *ritual.Source {
+	if ritualPath != "" {
+		return ritual.DirSource(ritualPath)
+	}
+
+	reg := registry.NewRegistry()
+	if err := reg.Scan(); err == nil {
+		if meta, err := reg.Get(ritualName); err == nil {
+			return meta.RitualSource()
+		}
+	}
+
+	return ritual.DirSource(ritualName)
+}
+
+// snapshotSource returns the ritual snapshot kept in a project.
+// The snapshot holds its own copy of _shared.
+func snapshotSource(projectPath string) (*ritual.Source, error) {
+	snapshotPath := deployment.SnapshotPath(projectPath)
+	return ritual.NewSource(os.DirFS(snapshotPath), ".", snapshotPath)
+}
+
+func (h *UpdateHandler) loadNewRitual(src *ritual.Source) (*ritual.Manifest, error) {
+	newManifest, err := src.Load()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load new ritual: %w", err)
 	}
 	return newManifest, nil
 }
 
+// mergeRitualFiles regenerates the project files with a three-way merge.
+// The snapshot of the installed ritual rendered with the stored answers is the
+// base, the new ritual version rendered with the same answers is theirs, and
+// the files currently in the project are ours.
+func (h *UpdateHandler) mergeRitualFiles(
+	projectPath string,
+	state *storage.State,
+	newRitual *ritual.Source,
+	newManifest *ritual.Manifest,
+) error {
+	if !deployment.HasSnapshot(projectPath) {
+		fmt.Println("\n⚠️  No ritual snapshot found in .ritual/, skipping file regeneration")
+		return nil
+	}
+
+	answers, err := deployment.LoadSnapshotAnswers(projectPath)
+	if err != nil {
+		return fmt.Errorf("failed to load saved answers: %w", err)
+	}
+
+	snapshot, err := snapshotSource(projectPath)
+	if err != nil {
+		return fmt.Errorf("failed to open ritual snapshot: %w", err)
+	}
+	baseManifest, err := snapshot.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load ritual snapshot: %w", err)
+	}
+
+	base, err := renderRitual(baseManifest, snapshot, answers)
+	if err != nil {
+		return fmt.Errorf("failed to render ritual %s: %w", baseManifest.Ritual.Version, err)
+	}
+
+	theirs, err := renderRitual(newManifest, newRitual, answers)
+	if err != nil {
+		return fmt.Errorf("failed to render ritual %s: %w", newManifest.Ritual.Version, err)
+	}
+
+	ours, err := readProjectFiles(projectPath, base, theirs)
+	if err != nil {
+		return err
+	}
+
+	protection := storage.NewProtectedFileManager(state)
+	for _, p := range newManifest.Files.Protected {
+		protection.AddProtectedFile(p)
+	}
+	if _, err := protection.LoadUserProtectedFiles(projectPath); err != nil {
+		return err
+	}
+
+	merger := deployment.NewThreeWayMerger()
+	merger.OursLabel = "local"
+	merger.BaseLabel = "ritual " + baseManifest.Ritual.Version
+	merger.TheirsLabel = "ritual " + newManifest.Ritual.Version
+
+	merge := merger.MergeProject(base, ours, theirs, protection.IsProtected)
+	merge.Plan.CurrentVersion = baseManifest.Ritual.Version
+	merge.Plan.TargetVersion = newManifest.Ritual.Version
+
+	if err := applyProjectMerge(projectPath, merge); err != nil {
+		return err
+	}
+
+	recordRegeneratedFiles(projectPath, state, theirs, newManifest.Ritual.Version)
+	h.displayMergeResult(merge.Plan)
+
+	if err := deployment.SaveSnapshotFrom(projectPath, newRitual, newManifest, answers); err != nil {
+		return fmt.Errorf("failed to update ritual snapshot: %w", err)
+	}
+
+	return nil
+}
+
+func (h *UpdateHandler) displayMergeResult(plan *deployment.DeploymentPlan) {
+	fmt.Printf("\nRegenerated files: %d added, %d updated, %d removed\n",
+		len(plan.FilesAdded), len(plan.FilesModified), len(plan.FilesDeleted))
+
+	if len(plan.Conflicts) > 0 {
+		fmt.Printf("\n⚠️  %d file(s) need attention:\n", len(plan.Conflicts))
+		for _, c := range plan.Conflicts {
+			fmt.Printf("  • %s: %s\n", c.File, c.Reason)
+		}
+	}
+}
+
+// renderRitual renders a ritual into memory with the given answers. A secret
+// answer still masked is a secret, which only protected files get.
+func renderRitual(manifest *ritual.Manifest, src *ritual.Source, answers map[string]interface{}) (map[string]string, error) {
+	gen := generator.NewFileGenerator(manifest.Ritual.TemplateEngine)
+	vars := generator.NewVariables()
+	vars.SetFromAnswers(answers)
+	for name, value := range answers {
+		if questionnaire.IsMaskedSecret(value) {
+			vars.SetSecret(name, value)
+		}
+	}
+	gen.SetVariables(vars)
+
+	return gen.RenderToMap(manifest, src)
+}
+
+// readProjectFiles reads the project's current version of every rendered file
+func readProjectFiles(projectPath string, rendered ...map[string]string) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, set := range rendered {
+		for path := range set {
+			if _, seen := files[path]; seen {
+				continue
+			}
+			// #nosec G304 - path is a ritual destination inside the project
+			content, err := os.ReadFile(filepath.Join(projectPath, filepath.FromSlash(path)))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			files[path] = string(content)
+		}
+	}
+	return files, nil
+}
+
+// applyProjectMerge writes merged files and removes files dropped by the ritual
+func applyProjectMerge(projectPath string, merge *deployment.ProjectMerge) error {
+	for path, content := range merge.Writes {
+		fullPath := filepath.Join(projectPath, filepath.FromSlash(path))
+
+		mode := os.FileMode(0600)
+		if info, err := os.Stat(fullPath); err == nil {
+			mode = info.Mode().Perm()
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0750); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+
+	for _, path := range merge.Deletes {
+		if err := os.Remove(filepath.Join(projectPath, filepath.FromSlash(path))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// recordRegeneratedFiles records the hashes of the new ritual output in state so
+// that drift is measured against the version the project now follows
+func recordRegeneratedFiles(projectPath string, state *storage.State, rendered map[string]string, version string) {
+	for _, existing := range append([]storage.GeneratedFile(nil), state.GeneratedFiles...) {
+		if !generator.FromRitual(existing.Source) {
+			continue // Scaffolder and generate command output is not part of the ritual render
+		}
+		if _, ok := rendered[existing.Path]; !ok {
+			state.RemoveGeneratedFile(existing.Path)
+		}
+	}
+
+	paths := make([]string, 0, len(rendered))
+	for path := range rendered {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		content := rendered[path]
+		entry := storage.GeneratedFile{Path: path}
+		if existing, ok := state.GetGeneratedFile(path); ok {
+			entry = *existing
+		}
+		entry.SHA256 = storage.HashContent([]byte(content))
+		entry.RitualVersion = version
+		if entry.Mode == "" {
+			if info, err := os.Stat(filepath.Join(projectPath, filepath.FromSlash(path))); err == nil {
+				entry.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+			}
+		}
+		state.SetGeneratedFile(entry)
+	}
+}
+
 func (h *UpdateHandler) runMigrations(
 	projectPath string,
 	state *storage.State,
@@ -151,15 +379,27 @@ func (h *UpdateHandler) runMigrations(
 }
 
 func (h *UpdateHandler) handleMigrationError(err error, backupPath, projectPath string, force bool) error {
+	return h.restoreAfter("Migration", err, backupPath, projectPath, force)
+}
+
+// handleMergeError restores the backup after the project files failed to
+// merge, undoing the migrations already run and any files already written
+func (h *UpdateHandler) handleMergeError(err error, backupPath, projectPath string, force bool) error {
+	return h.restoreAfter("File merge", err, backupPath, projectPath, force)
+}
+
+// restoreAfter restores the project from its backup after step failed, unless forced
+func (h *UpdateHandler) restoreAfter(step string, err error, backupPath, projectPath string, force bool) error {
+	failed := strings.ToLower(step) + " failed"
 	if !force {
-		fmt.Println("\n⚠️  Migration failed, rolling back...")
+		fmt.Printf("\n⚠️  %s failed, rolling back...\n", step)
 		rollbackMgr := deployment.NewRollbackManager()
 		if rbErr := rollbackMgr.RestoreFromBackup(backupPath, projectPath); rbErr != nil {
-			return fmt.Errorf("migration failed and rollback failed: %w (rollback error: %v)", err, rbErr)
+			return fmt.Errorf("%s and rollback failed: %w (rollback error: %v)", failed, err, rbErr)
 		}
-		return fmt.Errorf("migration failed, changes rolled back: %w", err)
+		return fmt.Errorf("%s, changes rolled back: %w", failed, err)
 	}
-	return fmt.Errorf("migration failed: %w", err)
+	return fmt.Errorf("%s: %w", failed, err)
 }
 
 func (h *UpdateHandler) saveUpdatedState(state *storage.State, targetVersion, projectPath string) error {
@@ -3,11 +3,13 @@ package commands
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Masterminds/semver/v3"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/deployment"
 	"github.com/toutaio/toutago-ritual-grove/internal/storage"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
@@ -25,7 +27,7 @@ func TestUpdateHandler_Execute(t *testing.T) {
 	state := &storage.State{
 		RitualName:     "test-ritual",
 		RitualVersion:  "1.0.0",
-		GeneratedFiles: []string{"main.go"},
+		GeneratedFiles: []storage.GeneratedFile{{Path: "main.go"}},
 	}
 	if err := state.Save(tmpDir); err != nil {
 		t.Fatal(err)
@@ -234,7 +236,7 @@ func TestUpdateHandler_LoadNewRitual(t *testing.T) {
 	handler := NewUpdateHandler()
 
 	// Try to load a non-existent ritual
-	_, err := handler.loadNewRitual("nonexistent-ritual-xyz")
+	_, err := handler.loadNewRitual(ritual.DirSource("nonexistent-ritual-xyz"))
 	if err == nil {
 		t.Error("Expected error when loading non-existent ritual")
 	}
@@ -315,3 +317,246 @@ func TestUpdateHandler_DisplayUpdateInfo(t *testing.T) {
 	// With same version
 	handler.displayUpdateInfo("1.0.0", "1.0.0", v1, v1)
 }
+
+func writeTestRitual(t *testing.T, dir, version, mainTmpl string) {
+	t.Helper()
+	manifest := `ritual:
+  name: merge-ritual
+  version: ` + version + `
+  template_engine: go-template
+files:
+  templates:
+    - src: main.go.tmpl
+      dest: main.go
+`
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ritual.yaml"), []byte(manifest), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "templates", "main.go.tmpl"), []byte(mainTmpl), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateHandler_Execute_ThreeWayMerge(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldRitual := filepath.Join(tmpDir, "v1", "merge-ritual")
+	newRitual := filepath.Join(tmpDir, "v2", "merge-ritual")
+	projectDir := filepath.Join(tmpDir, "project")
+
+	writeTestRitual(t, oldRitual, "1.0.0",
+		"package main\n\n// [[ .app_name ]]\nfunc main() {\n}\n")
+	writeTestRitual(t, newRitual, "1.1.0",
+		"package main\n\n// [[ .app_name ]]\nfunc main() {\n}\n\nfunc helper() {}\n")
+
+	// Generate the project from the old version and record the snapshot
+	manifest, err := ritual.NewLoader(oldRitual).Load(oldRitual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answers := map[string]interface{}{"app_name": "demo"}
+	files, err := renderRitual(manifest, ritual.DirSource(oldRitual), answers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(projectDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	// The user edits the generated file in a region the ritual does not touch
+	userMain := strings.Replace(files["main.go"], "func main() {\n", "func main() {\n\tprintln(\"hi\")\n", 1)
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte(userMain), 0600); err != nil {
+		t.Fatal(err)
+	}
+	state := &storage.State{RitualName: "merge-ritual", RitualVersion: "1.0.0"}
+	if err := state.Save(projectDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := deployment.SaveSnapshot(projectDir, oldRitual, manifest, answers); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewUpdateHandler()
+	if err := handler.Execute(projectDir, UpdateOptions{ToVersion: "1.1.0", RitualPath: newRitual}); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	// #nosec G304 - test file
+	merged, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package main\n\n// demo\nfunc main() {\n\tprintln(\"hi\")\n}\n\nfunc helper() {}\n"
+	if string(merged) != want {
+		t.Errorf("main.go =\n%s\nwant:\n%s", merged, want)
+	}
+
+	// State tracks the hash of the new ritual output, so the local edit shows as drift
+	updated, err := storage.LoadState(projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, ok := updated.GetGeneratedFile("main.go")
+	if !ok {
+		t.Fatal("Expected main.go to be recorded in state")
+	}
+	if entry.RitualVersion != "1.1.0" {
+		t.Errorf("main.go ritual version = %s, want 1.1.0", entry.RitualVersion)
+	}
+	if entry.SHA256 == storage.HashContent(merged) {
+		t.Error("Expected recorded hash to be the ritual output, not the merged file")
+	}
+
+	// The snapshot now reflects the new version so the next update merges from it
+	snapshot, err := ritual.NewLoader(deployment.SnapshotPath(projectDir)).Load(deployment.SnapshotPath(projectDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Ritual.Version != "1.1.0" {
+		t.Errorf("snapshot version = %s, want 1.1.0", snapshot.Ritual.Version)
+	}
+}
+
+func TestUpdateHandler_Execute_MergeFailureRollsBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldRitual := filepath.Join(tmpDir, "v1", "merge-ritual")
+	newRitual := filepath.Join(tmpDir, "v2", "merge-ritual")
+	projectDir := filepath.Join(tmpDir, "project")
+
+	writeTestRitual(t, oldRitual, "1.0.0", "package main\n\n// [[ .app_name ]]\nfunc main() {\n}\n")
+	// The new version fails to render after its migration has changed the project
+	writeTestRitual(t, newRitual, "1.1.0", "package main\n\n// [[ .app_name \n")
+	migration := "migrations:\n  - from_version: 1.0.0\n    to_version: 1.1.0\n    up:\n      script: migrate.sh\n"
+	manifestPath := filepath.Join(newRitual, "ritual.yaml")
+	// #nosec G304 - test file
+	manifestData, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifestPath, append(manifestData, migration...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := ritual.NewLoader(oldRitual).Load(oldRitual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answers := map[string]interface{}{"app_name": "demo"}
+	files, err := renderRitual(manifest, ritual.DirSource(oldRitual), answers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(projectDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "main.go"), []byte(files["main.go"]), 0600); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho migrated > main.go\n"
+	// #nosec G306 - migration scripts are executable
+	if err := os.WriteFile(filepath.Join(projectDir, "migrate.sh"), []byte(script), 0750); err != nil {
+		t.Fatal(err)
+	}
+	state := &storage.State{RitualName: "merge-ritual", RitualVersion: "1.0.0"}
+	if err := state.Save(projectDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := deployment.SaveSnapshot(projectDir, oldRitual, manifest, answers); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewUpdateHandler()
+	err = handler.Execute(projectDir, UpdateOptions{ToVersion: "1.1.0", RitualPath: newRitual})
+	if err == nil || !strings.Contains(err.Error(), "changes rolled back") {
+		t.Fatalf("Execute() error = %v, want the changes rolled back", err)
+	}
+
+	// #nosec G304 - test file
+	restored, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(restored) != files["main.go"] {
+		t.Errorf("main.go = %q, want the file from before the update", restored)
+	}
+}
+
+func TestRenderRitual_MaskedSecrets(t *testing.T) {
+	tmpDir := t.TempDir()
+	ritualDir := filepath.Join(tmpDir, "secret-ritual")
+	projectDir := filepath.Join(tmpDir, "project")
+
+	manifestYAML := `ritual:
+  name: secret-ritual
+  version: 1.0.0
+  template_engine: go-template
+questions:
+  - name: db_password
+    type: password
+    prompt: Database password
+files:
+  templates:
+    - src: main.go.tmpl
+      dest: main.go
+    - src: app.env.tmpl
+      dest: app.env
+  protected:
+    - app.env
+`
+	templates := map[string]string{
+		"main.go.tmpl": "package main\n\n// [[ .db_password ]]\nfunc main() {\n}\n",
+		"app.env.tmpl": "DB_PASSWORD=[[ .db_password ]]\n",
+	}
+	if err := os.MkdirAll(filepath.Join(ritualDir, "templates"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ritualDir, "ritual.yaml"), []byte(manifestYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range templates {
+		if err := os.WriteFile(filepath.Join(ritualDir, "templates", name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manifest, err := ritual.NewLoader(ritualDir).Load(ritualDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := deployment.SaveSnapshot(projectDir, ritualDir, manifest, map[string]interface{}{"db_password": "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("masked", func(t *testing.T) {
+		answers, err := deployment.LoadSnapshotAnswers(projectDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := renderRitual(manifest, ritual.DirSource(ritualDir), answers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(files["main.go"], "SECRET_FROM_ENV") || !strings.Contains(files["main.go"], "// change-me") {
+			t.Errorf("main.go = %q, want the secret placeholder", files["main.go"])
+		}
+		if !strings.Contains(files["app.env"], "SECRET_FROM_ENV") {
+			t.Errorf("app.env = %q, want the masked secret", files["app.env"])
+		}
+	})
+
+	t.Run("restored from environment", func(t *testing.T) {
+		t.Setenv("DB_PASSWORD", "s3cret")
+		answers, err := deployment.LoadSnapshotAnswers(projectDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := renderRitual(manifest, ritual.DirSource(ritualDir), answers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if files["app.env"] != "DB_PASSWORD=s3cret\n" {
+			t.Errorf("app.env = %q, want the secret from the environment", files["app.env"])
+		}
+	})
+}
@@ -0,0 +1,136 @@
+// Package condition evaluates the conditions rituals attach to files, questions and hook tasks.
+//
+// Conditions are Fíth expressions:
+//
+//	enable_comments
+//	database == "postgres" && port > 1024
+//	!(frontend_type in ["htmx", "inertia-vue"]) or length(models) > 0
+//
+// Conditions written as Go templates with [[ ]] delimiters, such as
+// `[[ eq .frontend_type "htmx" ]]`, are still accepted for older rituals.
+package condition
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/fith"
+)
+
+// Condition is a parsed condition
+type Condition struct {
+	source string
+	expr   *fith.Expression
+	legacy *template.Template
+}
+
+// Parse parses a condition expression
+func Parse(condition string) (*Condition, error) {
+	source := strings.TrimSpace(condition)
+	if source == "" {
+		return nil, fmt.Errorf("empty condition")
+	}
+
+	if IsLegacy(source) {
+		tmpl, err := template.New("condition").Delims("[[", "]]").Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition %q: %w", source, err)
+		}
+		return &Condition{source: source, legacy: tmpl}, nil
+	}
+
+	expr, err := fith.ParseExpression("condition", source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", source, err)
+	}
+	return &Condition{source: source, expr: expr}, nil
+}
+
+// IsLegacy reports whether a condition uses the Go-template syntax of older rituals
+func IsLegacy(condition string) bool {
+	return strings.HasPrefix(strings.TrimSpace(condition), "[[")
+}
+
+// Validate reports whether a condition parses. Empty conditions are valid.
+func Validate(condition string) error {
+	if strings.TrimSpace(condition) == "" {
+		return nil
+	}
+	_, err := Parse(condition)
+	return err
+}
+
+// ValidateNames reports whether a condition parses and reads only declared
+// variables, so an unquoted string such as `db == postgres` is caught rather
+// than read as a variable that is never set. A nil declared checks no names.
+func ValidateNames(condition string, declared map[string]bool) error {
+	if strings.TrimSpace(condition) == "" {
+		return nil
+	}
+	c, err := Parse(condition)
+	if err != nil {
+		return err
+	}
+	if declared == nil {
+		return nil
+	}
+	for _, name := range c.Names() {
+		if !declared[name] {
+			return fmt.Errorf("condition %q reads unknown variable %q (quote it to compare with a string)", c.source, name)
+		}
+	}
+	return nil
+}
+
+// Evaluate parses and evaluates a condition. Empty conditions are true.
+func Evaluate(condition string, variables map[string]interface{}) (bool, error) {
+	if strings.TrimSpace(condition) == "" {
+		return true, nil
+	}
+	c, err := Parse(condition)
+	if err != nil {
+		return false, err
+	}
+	return c.Evaluate(variables)
+}
+
+// String returns the condition source
+func (c *Condition) String() string {
+	return c.source
+}
+
+// Names returns the variables the condition reads. Go-template conditions report none.
+func (c *Condition) Names() []string {
+	if c.legacy != nil {
+		return nil
+	}
+	return c.expr.Names()
+}
+
+// Evaluate evaluates the condition against variables
+func (c *Condition) Evaluate(variables map[string]interface{}) (bool, error) {
+	if c.legacy != nil {
+		return c.evaluateLegacy(variables)
+	}
+
+	result, err := c.expr.Bool(variables)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate condition %q: %w", c.source, err)
+	}
+	return result, nil
+}
+
+// evaluateLegacy renders a Go-template condition and interprets the output as a boolean
+func (c *Condition) evaluateLegacy(variables map[string]interface{}) (bool, error) {
+	var buf strings.Builder
+	if err := c.legacy.Execute(&buf, variables); err != nil {
+		return false, fmt.Errorf("failed to evaluate condition %q: %w", c.source, err)
+	}
+
+	switch strings.TrimSpace(buf.String()) {
+	case "", "false", "0", "no", "<no value>":
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,118 @@
+package condition
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	vars := map[string]interface{}{
+		"enable_comments": false,
+		"enable_docker":   true,
+		"database_type":   "postgres",
+		"db_port":         5432,
+		"workers":         "8",
+		"features":        []interface{}{"auth", "search"},
+		"answer":          "no",
+	}
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{"", true},
+		{"enable_docker", true},
+		{"enable_comments", false},
+		{"enable_comments == true", false},
+		{"enable_comments == false", true},
+		{"missing", false},
+		{"missing == 'x'", false},
+		{"missing != 'x'", true},
+		{"database_type == 'postgres' && enable_docker", true},
+		{"database_type == \"mysql\" || !enable_comments", true},
+		{"!(enable_docker && enable_comments)", true},
+		{"database_type in ['postgres', 'mysql']", true},
+		{"database_type not in ['postgres', 'mysql']", false},
+		{"'search' in features", true},
+		{"db_port > 1024 and db_port <= 65535", true},
+		{"workers >= 4", true},
+		{"length(features) == 2", true},
+		{"startswith(database_type, 'post')", true},
+		{"answer", false},
+		{`[[ eq .database_type "postgres" ]]`, true},
+		{`[[ .enable_comments ]]`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			got, err := Evaluate(tt.condition, vars)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.condition, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		condition string
+		message   string
+	}{
+		{"enable_docker &&", "condition:1:17"},
+		{"(a || b", `expected ")"`},
+		{"nope(a)", `unknown function "nope"`},
+		{"{{.enable_tags}}", "invalid condition"},
+		{"[[ if ]]", "invalid condition"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			err := Validate(tt.condition)
+			if err == nil {
+				t.Fatal("Validate() expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("Validate() error = %v, want it to contain %q", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestEvaluateError(t *testing.T) {
+	_, err := Evaluate("db_port / 0 > 1", map[string]interface{}{"db_port": 1})
+	if err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("Evaluate() error = %v, want division by zero", err)
+	}
+}
+
+func TestValidateNames(t *testing.T) {
+	declared := map[string]bool{"db": true, "features": true, "enable_docker": true}
+
+	valid := []string{
+		"",
+		"db == 'postgres'",
+		"enable_docker && 'auth' in features",
+		"length(features) > 0 and db is defined",
+		"db != none && enable_docker == true",
+		`[[ eq .db "postgres" ]]`,
+	}
+	for _, cond := range valid {
+		if err := ValidateNames(cond, declared); err != nil {
+			t.Errorf("ValidateNames(%q) error = %v", cond, err)
+		}
+	}
+
+	err := ValidateNames("enable_docker && db == postgres", declared)
+	if err == nil || !strings.Contains(err.Error(), `unknown variable "postgres"`) {
+		t.Errorf("ValidateNames() error = %v, want unknown variable postgres", err)
+	}
+	if err := ValidateNames("db == postgres", nil); err != nil {
+		t.Errorf("ValidateNames() without declared names error = %v", err)
+	}
+	if err := ValidateNames("db ==", declared); err == nil {
+		t.Error("ValidateNames() should report a parse error")
+	}
+}
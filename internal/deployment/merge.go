@@ -0,0 +1,311 @@
+package deployment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MergeResult holds the outcome of a three-way merge of a single file
+type MergeResult struct {
+	Content   string
+	Conflicts int
+}
+
+// HasConflicts reports whether the merged content contains conflict markers
+func (r *MergeResult) HasConflicts() bool {
+	return r.Conflicts > 0
+}
+
+// ThreeWayMerger merges a user's files with a regenerated ritual version using diff3 semantics
+type ThreeWayMerger struct {
+	OursLabel   string
+	BaseLabel   string
+	TheirsLabel string
+}
+
+// NewThreeWayMerger creates a new three-way merger with default conflict labels
+func NewThreeWayMerger() *ThreeWayMerger {
+	return &ThreeWayMerger{
+		OursLabel:   "ours",
+		BaseLabel:   "base",
+		TheirsLabel: "theirs",
+	}
+}
+
+// Merge merges ours and theirs, both derived from base, line by line.
+// Regions changed on only one side are taken from that side; regions changed
+// differently on both sides are written with diff3-style conflict markers.
+func (m *ThreeWayMerger) Merge(base, ours, theirs string) *MergeResult {
+	switch {
+	case ours == theirs, base == theirs:
+		return &MergeResult{Content: ours}
+	case base == ours:
+		return &MergeResult{Content: theirs}
+	}
+
+	// Binary content cannot be merged line by line; keep the user's version
+	if isBinary(base) || isBinary(ours) || isBinary(theirs) {
+		return &MergeResult{Content: ours, Conflicts: 1}
+	}
+
+	b, o, t := splitLines(base), splitLines(ours), splitLines(theirs)
+	matchOurs := lcsMatches(b, o)
+	matchTheirs := lcsMatches(b, t)
+
+	var out strings.Builder
+	result := &MergeResult{}
+	i, a, c := 0, 0, 0
+
+	for i < len(b) || a < len(o) || c < len(t) {
+		// Line unchanged on both sides
+		if i < len(b) && matchOurs[i] == a && matchTheirs[i] == c {
+			out.WriteString(b[i])
+			i++
+			a++
+			c++
+			continue
+		}
+
+		// Find the next base line both sides kept; everything before it is a changed chunk
+		j := i
+		for j < len(b) && (matchOurs[j] < 0 || matchTheirs[j] < 0) {
+			j++
+		}
+		oEnd, tEnd := len(o), len(t)
+		if j < len(b) {
+			oEnd, tEnd = matchOurs[j], matchTheirs[j]
+		}
+
+		m.resolveChunk(&out, result, b[i:j], o[a:oEnd], t[c:tEnd])
+		i, a, c = j, oEnd, tEnd
+	}
+
+	result.Content = out.String()
+	return result
+}
+
+// resolveChunk writes the merged form of a changed region
+func (m *ThreeWayMerger) resolveChunk(out *strings.Builder, result *MergeResult, base, ours, theirs []string) {
+	switch {
+	case equalLines(ours, theirs), equalLines(base, theirs):
+		writeLines(out, ours)
+	case equalLines(base, ours):
+		writeLines(out, theirs)
+	default:
+		result.Conflicts++
+		out.WriteString("<<<<<<< " + m.OursLabel + "\n")
+		writeConflictSide(out, ours)
+		out.WriteString("||||||| " + m.BaseLabel + "\n")
+		writeConflictSide(out, base)
+		out.WriteString("=======\n")
+		writeConflictSide(out, theirs)
+		out.WriteString(">>>>>>> " + m.TheirsLabel + "\n")
+	}
+}
+
+// ProjectMerge is the outcome of merging regenerated ritual output into a project
+type ProjectMerge struct {
+	Plan    *DeploymentPlan
+	Writes  map[string]string // destination path -> content to write
+	Deletes []string          // destination paths to remove
+}
+
+// MergeProject merges every generated file of a project.
+// base is the old ritual version rendered with the stored answers, theirs is
+// the new version rendered with the same answers, and ours holds the files
+// currently in the project. Protected files are never written.
+func (m *ThreeWayMerger) MergeProject(base, ours, theirs map[string]string, isProtected func(string) bool) *ProjectMerge {
+	result := &ProjectMerge{
+		Plan:   &DeploymentPlan{},
+		Writes: make(map[string]string),
+	}
+
+	paths := make(map[string]bool)
+	for p := range base {
+		paths[p] = true
+	}
+	for p := range theirs {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	for _, path := range sorted {
+		baseContent, hasBase := base[path]
+		oursContent, hasOurs := ours[path]
+		theirsContent, hasTheirs := theirs[path]
+
+		if hasBase && hasTheirs && baseContent == theirsContent {
+			continue // Ritual did not change this file
+		}
+
+		if isProtected != nil && isProtected(path) {
+			if hasOurs && (!hasTheirs || oursContent != theirsContent) {
+				result.addConflict(path, "Protected file changed in the new ritual version",
+					"Review the ritual changes and apply them manually")
+			}
+			continue
+		}
+
+		switch {
+		case hasTheirs && !hasOurs && !hasBase:
+			result.Writes[path] = theirsContent
+			result.Plan.FilesAdded = append(result.Plan.FilesAdded, path)
+
+		case hasTheirs && !hasOurs:
+			result.addConflict(path, "File was deleted locally but changed in the new ritual version",
+				"Restore the file from the ritual or keep it deleted")
+
+		case hasTheirs:
+			if oursContent == theirsContent {
+				continue
+			}
+			if !hasBase {
+				baseContent = ""
+			}
+			merged := m.Merge(baseContent, oursContent, theirsContent)
+			if merged.Content != oursContent {
+				result.Writes[path] = merged.Content
+			}
+			if merged.HasConflicts() {
+				result.addConflict(path,
+					fmt.Sprintf("%d conflicting change(s) between local edits and the new ritual version", merged.Conflicts),
+					"Resolve the conflict markers and remove them")
+			} else if merged.Content != oursContent {
+				result.Plan.FilesModified = append(result.Plan.FilesModified, path)
+			}
+
+		case hasOurs && oursContent == baseContent:
+			result.Deletes = append(result.Deletes, path)
+			result.Plan.FilesDeleted = append(result.Plan.FilesDeleted, path)
+
+		case hasOurs:
+			result.addConflict(path, "File was removed from the new ritual version but modified locally",
+				"Delete the file or keep it as a local file")
+		}
+	}
+
+	return result
+}
+
+func (r *ProjectMerge) addConflict(file, reason, resolution string) {
+	r.Plan.Conflicts = append(r.Plan.Conflicts, Conflict{
+		File:       file,
+		Reason:     reason,
+		Resolution: resolution,
+	})
+}
+
+// splitLines splits content into lines, keeping line terminators
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lcsMatches returns, for every line of a, the index of the line of b it is
+// matched with in a longest common subsequence, or -1 if it is unmatched.
+func lcsMatches(a, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	// Common prefix and suffix are matched directly to keep the table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		matches[prefix] = prefix
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		matches[len(a)-1-suffix] = len(b) - 1 - suffix
+		suffix++
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	n, m := len(midA), len(midB)
+	if n == 0 || m == 0 {
+		return matches
+	}
+
+	// lengths[i][j] is the LCS length of midA[i:] and midB[j:]
+	lengths := make([][]int32, n+1)
+	for i := range lengths {
+		lengths[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			switch {
+			case midA[i] == midB[j]:
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			case lengths[i+1][j] >= lengths[i][j+1]:
+				lengths[i][j] = lengths[i+1][j]
+			default:
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case midA[i] == midB[j]:
+			matches[prefix+i] = prefix + j
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return matches
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func writeLines(out *strings.Builder, lines []string) {
+	for _, line := range lines {
+		out.WriteString(line)
+	}
+}
+
+// writeConflictSide writes one side of a conflict, making sure the marker that
+// follows starts on its own line
+func writeConflictSide(out *strings.Builder, lines []string) {
+	writeLines(out, lines)
+	if len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		out.WriteString("\n")
+	}
+}
+
+// isBinary reports whether content looks like binary data
+func isBinary(s string) bool {
+	sample := s
+	if len(sample) > 8000 {
+		sample = sample[:8000]
+	}
+	return strings.IndexByte(sample, 0) >= 0
+}
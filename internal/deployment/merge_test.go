@@ -0,0 +1,169 @@
+package deployment
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThreeWayMerger_CleanMerge(t *testing.T) {
+	merger := NewThreeWayMerger()
+
+	base := "package main\n\nfunc a() {}\n\nfunc b() {}\n"
+	ours := "package main\n\n// local comment\nfunc a() {}\n\nfunc b() {}\n"
+	theirs := "package main\n\nfunc a() {}\n\nfunc b() {}\n\nfunc c() {}\n"
+
+	result := merger.Merge(base, ours, theirs)
+
+	if result.HasConflicts() {
+		t.Fatalf("Expected clean merge, got %d conflicts:\n%s", result.Conflicts, result.Content)
+	}
+	want := "package main\n\n// local comment\nfunc a() {}\n\nfunc b() {}\n\nfunc c() {}\n"
+	if result.Content != want {
+		t.Errorf("Merged content mismatch\ngot:\n%s\nwant:\n%s", result.Content, want)
+	}
+}
+
+func TestThreeWayMerger_OneSidedChanges(t *testing.T) {
+	merger := NewThreeWayMerger()
+
+	if got := merger.Merge("a\n", "a\n", "b\n").Content; got != "b\n" {
+		t.Errorf("Expected upstream change, got %q", got)
+	}
+	if got := merger.Merge("a\n", "b\n", "a\n").Content; got != "b\n" {
+		t.Errorf("Expected local change, got %q", got)
+	}
+	if got := merger.Merge("a\n", "b\n", "b\n").Content; got != "b\n" {
+		t.Errorf("Expected identical change, got %q", got)
+	}
+}
+
+func TestThreeWayMerger_Conflict(t *testing.T) {
+	merger := NewThreeWayMerger()
+	merger.OursLabel = "local"
+	merger.TheirsLabel = "ritual 1.1.0"
+
+	base := "port: 8080\nhost: localhost\n"
+	ours := "port: 9000\nhost: localhost\n"
+	theirs := "port: 3000\nhost: localhost\n"
+
+	result := merger.Merge(base, ours, theirs)
+
+	if result.Conflicts != 1 {
+		t.Fatalf("Expected 1 conflict, got %d", result.Conflicts)
+	}
+	want := "<<<<<<< local\nport: 9000\n||||||| base\nport: 8080\n=======\nport: 3000\n>>>>>>> ritual 1.1.0\nhost: localhost\n"
+	if result.Content != want {
+		t.Errorf("Conflict content mismatch\ngot:\n%s\nwant:\n%s", result.Content, want)
+	}
+}
+
+func TestThreeWayMerger_ConflictWithoutTrailingNewline(t *testing.T) {
+	merger := NewThreeWayMerger()
+
+	result := merger.Merge("a", "b", "c")
+
+	if !result.HasConflicts() {
+		t.Fatal("Expected a conflict")
+	}
+	for _, marker := range []string{"\n||||||| base\n", "\n=======\n", "\n>>>>>>> theirs\n"} {
+		if !strings.Contains(result.Content, marker) {
+			t.Errorf("Expected marker %q on its own line in:\n%s", marker, result.Content)
+		}
+	}
+}
+
+func TestThreeWayMerger_Binary(t *testing.T) {
+	merger := NewThreeWayMerger()
+
+	result := merger.Merge("\x00a", "\x00b", "\x00c")
+
+	if !result.HasConflicts() {
+		t.Error("Expected binary divergence to be reported as a conflict")
+	}
+	if result.Content != "\x00b" {
+		t.Error("Expected local binary content to be kept")
+	}
+}
+
+func TestThreeWayMerger_MergeProject(t *testing.T) {
+	merger := NewThreeWayMerger()
+
+	base := map[string]string{
+		"unchanged.go":      "package a\n",
+		"upstream.go":       "package a\n// v1\n",
+		"both.go":           "line1\nline2\nline3\n",
+		"conflict.go":       "value := 1\n",
+		"removed.go":        "package old\n",
+		"removed_edited.go": "package old\n",
+		"deleted_local.go":  "package a\n",
+		".env":              "KEY=1\n",
+	}
+	ours := map[string]string{
+		"unchanged.go":      "package a\n// mine\n",
+		"upstream.go":       "package a\n// v1\n",
+		"both.go":           "line1 local\nline2\nline3\n",
+		"conflict.go":       "value := 2\n",
+		"removed.go":        "package old\n",
+		"removed_edited.go": "package old\n// mine\n",
+		".env":              "KEY=secret\n",
+	}
+	theirs := map[string]string{
+		"unchanged.go":     "package a\n",
+		"upstream.go":      "package a\n// v2\n",
+		"both.go":          "line1\nline2\nline3 upstream\n",
+		"conflict.go":      "value := 3\n",
+		"added.go":         "package added\n",
+		"deleted_local.go": "package a\n// v2\n",
+		".env":             "KEY=1\nNEW=2\n",
+	}
+
+	result := merger.MergeProject(base, ours, theirs, func(path string) bool {
+		return path == ".env"
+	})
+
+	if _, ok := result.Writes["unchanged.go"]; ok {
+		t.Error("Expected file unchanged upstream not to be written")
+	}
+	if got := result.Writes["upstream.go"]; got != "package a\n// v2\n" {
+		t.Errorf("upstream.go = %q", got)
+	}
+	if got := result.Writes["both.go"]; got != "line1 local\nline2\nline3 upstream\n" {
+		t.Errorf("both.go = %q", got)
+	}
+	if got := result.Writes["added.go"]; got != "package added\n" {
+		t.Errorf("added.go = %q", got)
+	}
+	if !strings.Contains(result.Writes["conflict.go"], "<<<<<<< ours") {
+		t.Errorf("Expected conflict markers in conflict.go, got %q", result.Writes["conflict.go"])
+	}
+	if _, ok := result.Writes[".env"]; ok {
+		t.Error("Expected protected file not to be written")
+	}
+	if _, ok := result.Writes["deleted_local.go"]; ok {
+		t.Error("Expected locally deleted file not to be restored")
+	}
+
+	if len(result.Deletes) != 1 || result.Deletes[0] != "removed.go" {
+		t.Errorf("Deletes = %v, want [removed.go]", result.Deletes)
+	}
+
+	conflicts := make(map[string]bool)
+	for _, c := range result.Plan.Conflicts {
+		conflicts[c.File] = true
+	}
+	for _, file := range []string{"conflict.go", "removed_edited.go", "deleted_local.go", ".env"} {
+		if !conflicts[file] {
+			t.Errorf("Expected conflict for %s", file)
+		}
+	}
+	if len(result.Plan.Conflicts) != 4 {
+		t.Errorf("Expected 4 conflicts, got %d: %v", len(result.Plan.Conflicts), result.Plan.Conflicts)
+	}
+
+	if len(result.Plan.FilesAdded) != 1 || result.Plan.FilesAdded[0] != "added.go" {
+		t.Errorf("FilesAdded = %v", result.Plan.FilesAdded)
+	}
+	if len(result.Plan.FilesModified) != 2 {
+		t.Errorf("FilesModified = %v, want both.go and upstream.go", result.Plan.FilesModified)
+	}
+}
@@ -0,0 +1,120 @@
+package deployment
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/questionnaire"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// snapshotEntries are the parts of a ritual copied into a project's snapshot
+var snapshotEntries = []string{"ritual.yaml", "templates", "static"}
+
+// SnapshotPath returns the directory holding the ritual version a project was generated from.
+// The snapshot lives directly in .ritual/ so that .ritual/ritual.yaml is the current manifest.
+func SnapshotPath(projectPath string) string {
+	return filepath.Join(projectPath, ".ritual")
+}
+
+// HasSnapshot checks whether a project has a ritual snapshot
+func HasSnapshot(projectPath string) bool {
+	_, err := os.Stat(filepath.Join(SnapshotPath(projectPath), "ritual.yaml"))
+	return err == nil
+}
+
+// answersPath returns the path of the saved questionnaire answers
+func answersPath(projectPath string) string {
+	return filepath.Join(SnapshotPath(projectPath), "answers.yaml")
+}
+
+// SaveSnapshot records what an update needs to regenerate a project later:
+// a copy of the ritual source (including _shared templates) and the answers
+// the project was rendered with. Secret answers are masked before saving.
+func SaveSnapshot(projectPath, ritualPath string, manifest *ritual.Manifest, answers map[string]interface{}) error {
+	return SaveSnapshotFrom(projectPath, ritual.DirSource(ritualPath), manifest, answers)
+}
+
+// SaveSnapshotFrom is SaveSnapshot for a ritual read from any source, such as
+// an embedded ritual or a tarball
+func SaveSnapshotFrom(projectPath string, src *ritual.Source, manifest *ritual.Manifest, answers map[string]interface{}) error {
+	snapshotDir := SnapshotPath(projectPath)
+	if err := os.MkdirAll(snapshotDir, 0750); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	// Replace any previous snapshot
+	for _, entry := range append(snapshotEntries, ritual.SharedDir) {
+		if err := os.RemoveAll(filepath.Join(snapshotDir, entry)); err != nil {
+			return fmt.Errorf("failed to remove old snapshot %s: %w", entry, err)
+		}
+	}
+
+	for _, entry := range snapshotEntries {
+		if _, err := fs.Stat(src.FS, entry); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := copyFromFS(src.FS, entry, filepath.Join(snapshotDir, entry)); err != nil {
+			return fmt.Errorf("failed to snapshot %s: %w", entry, err)
+		}
+	}
+
+	if src.Shared != nil {
+		if err := copyFromFS(src.Shared, ".", filepath.Join(snapshotDir, ritual.SharedDir)); err != nil {
+			return fmt.Errorf("failed to snapshot _shared: %w", err)
+		}
+	}
+
+	persistence := questionnaire.NewAnswerPersistence(answersPath(projectPath))
+	if err := persistence.SaveWithSecrets(answers, SecretQuestions(manifest)); err != nil {
+		return fmt.Errorf("failed to save answers: %w", err)
+	}
+
+	return nil
+}
+
+// LoadSnapshotAnswers loads the answers a project was generated with, restoring
+// secret answers from their environment variables. A secret without one stays
+// masked, so it must only be rendered into protected files.
+func LoadSnapshotAnswers(projectPath string) (map[string]interface{}, error) {
+	persistence := questionnaire.NewAnswerPersistence(answersPath(projectPath))
+	if !persistence.Exists() {
+		return map[string]interface{}{}, nil
+	}
+	return persistence.LoadRestoringSecrets()
+}
+
+// SecretQuestions returns the names of questions whose answers must not be
+// stored in plain text: passwords and generated secrets
+func SecretQuestions(manifest *ritual.Manifest) []string {
+	var secrets []string
+	for _, q := range manifest.Questions {
+		if q.Type == ritual.QuestionTypePassword || q.Generate != nil {
+			secrets = append(secrets, q.Name)
+		}
+	}
+	return secrets
+}
+
+// copyFromFS copies a file or directory tree out of fsys
+func copyFromFS(fsys fs.FS, name, dst string) error {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		sub, err := fs.Sub(fsys, name)
+		if err != nil {
+			return err
+		}
+		return os.CopyFS(dst, sub)
+	}
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, content, 0600)
+}
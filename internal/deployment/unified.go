@@ -0,0 +1,149 @@
+package deployment
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultContextLines is the number of unchanged lines shown around each change
+const DefaultContextLines = 3
+
+// FilePatch is the unified diff of a single file
+type FilePatch struct {
+	Path      string
+	Additions int
+	Deletions int
+	Binary    bool
+	Text      string
+}
+
+// diffOp is one line of an edit script
+type diffOp struct {
+	kind    byte // ' ', '-' or '+'
+	line    string
+	oldLine int // 1-based line number in the old content (0 for additions)
+	newLine int // 1-based line number in the new content (0 for deletions)
+}
+
+// UnifiedDiff returns a unified diff that turns oldContent into newContent.
+// oldLabel and newLabel name the two sides in the ---/+++ headers, for example
+// "a/main.go" and "b/main.go", or "/dev/null" for a missing side.
+func (g *DiffGenerator) UnifiedDiff(path, oldLabel, newLabel, oldContent, newContent string) *FilePatch {
+	patch := &FilePatch{Path: path}
+	if oldContent == newContent {
+		return patch
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldLabel, newLabel)
+
+	if isBinary(oldContent) || isBinary(newContent) {
+		patch.Binary = true
+		fmt.Fprintf(&out, "Binary files %s and %s differ\n", oldLabel, newLabel)
+		patch.Text = out.String()
+		return patch
+	}
+
+	ops := editScript(splitLines(oldContent), splitLines(newContent))
+	for _, op := range ops {
+		switch op.kind {
+		case '+':
+			patch.Additions++
+		case '-':
+			patch.Deletions++
+		}
+	}
+
+	context := DefaultContextLines
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are close enough to share context
+		end := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				end = i
+			} else if i-end > 2*context {
+				break
+			}
+		}
+
+		from := start - context
+		if from < 0 {
+			from = 0
+		}
+		to := end + context + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+		writeHunk(&out, ops[from:to])
+		start = to
+	}
+
+	patch.Text = out.String()
+	return patch
+}
+
+// editScript computes a line edit script from a to b
+func editScript(a, b []string) []diffOp {
+	matches := lcsMatches(a, b)
+	ops := make([]diffOp, 0, len(a)+len(b))
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && matches[i] == j:
+			ops = append(ops, diffOp{kind: ' ', line: a[i], oldLine: i + 1, newLine: j + 1})
+			i++
+			j++
+		case i < len(a) && matches[i] < 0:
+			ops = append(ops, diffOp{kind: '-', line: a[i], oldLine: i + 1})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', line: b[j], newLine: j + 1})
+			j++
+		}
+	}
+
+	return ops
+}
+
+// writeHunk writes a single @@ hunk
+func writeHunk(out *strings.Builder, ops []diffOp) {
+	oldStart, newStart, oldCount, newCount := 0, 0, 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			if oldStart == 0 {
+				oldStart = op.oldLine
+			}
+			oldCount++
+		}
+		if op.kind != '-' {
+			if newStart == 0 {
+				newStart = op.newLine
+			}
+			newCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+	for _, op := range ops {
+		out.WriteByte(op.kind)
+		out.WriteString(op.line)
+		if !strings.HasSuffix(op.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,95 @@
+package deployment
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffGenerator_UnifiedDiff(t *testing.T) {
+	generator := NewDiffGenerator()
+
+	oldContent := "line1\nline2\nline3\nline4\nline5\nline6\nline7\nline8\nline9\nline10\n"
+	newContent := "line1\nline2 changed\nline3\nline4\nline5\nline6\nline7\nline8\nline9\nline10\nline11\n"
+
+	patch := generator.UnifiedDiff("file.txt", "a/file.txt", "b/file.txt", oldContent, newContent)
+
+	want := `--- a/file.txt
++++ b/file.txt
+@@ -1,5 +1,5 @@
+ line1
+-line2
++line2 changed
+ line3
+ line4
+ line5
+@@ -8,3 +8,4 @@
+ line8
+ line9
+ line10
++line11
+`
+	if patch.Text != want {
+		t.Errorf("UnifiedDiff() =\n%s\nwant:\n%s", patch.Text, want)
+	}
+	if patch.Additions != 2 || patch.Deletions != 1 {
+		t.Errorf("Additions/Deletions = %d/%d, want 2/1", patch.Additions, patch.Deletions)
+	}
+}
+
+func TestDiffGenerator_UnifiedDiff_MergesNearbyHunks(t *testing.T) {
+	generator := NewDiffGenerator()
+
+	patch := generator.UnifiedDiff("f", "a/f", "b/f", "a\nb\nc\nd\ne\n", "A\nb\nc\nd\nE\n")
+
+	if strings.Count(patch.Text, "@@ -") != 1 {
+		t.Errorf("Expected a single hunk, got:\n%s", patch.Text)
+	}
+	if !strings.Contains(patch.Text, "@@ -1,5 +1,5 @@") {
+		t.Errorf("Unexpected hunk header:\n%s", patch.Text)
+	}
+}
+
+func TestDiffGenerator_UnifiedDiff_NewAndDeletedFiles(t *testing.T) {
+	generator := NewDiffGenerator()
+
+	added := generator.UnifiedDiff("new.go", "/dev/null", "b/new.go", "", "package new\n")
+	if !strings.Contains(added.Text, "--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package new\n") {
+		t.Errorf("Unexpected patch for added file:\n%s", added.Text)
+	}
+
+	deleted := generator.UnifiedDiff("old.go", "a/old.go", "/dev/null", "package old\n", "")
+	if !strings.Contains(deleted.Text, "@@ -1 +0,0 @@\n-package old\n") {
+		t.Errorf("Unexpected patch for deleted file:\n%s", deleted.Text)
+	}
+}
+
+func TestDiffGenerator_UnifiedDiff_NoTrailingNewline(t *testing.T) {
+	generator := NewDiffGenerator()
+
+	patch := generator.UnifiedDiff("f", "a/f", "b/f", "a\nb", "a\nc")
+
+	if !strings.Contains(patch.Text, "-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n") {
+		t.Errorf("Expected no-newline markers, got:\n%s", patch.Text)
+	}
+}
+
+func TestDiffGenerator_UnifiedDiff_Identical(t *testing.T) {
+	generator := NewDiffGenerator()
+
+	patch := generator.UnifiedDiff("f", "a/f", "b/f", "same\n", "same\n")
+	if patch.Text != "" {
+		t.Errorf("Expected empty patch, got:\n%s", patch.Text)
+	}
+}
+
+func TestDiffGenerator_UnifiedDiff_Binary(t *testing.T) {
+	generator := NewDiffGenerator()
+
+	patch := generator.UnifiedDiff("img.png", "a/img.png", "b/img.png", "\x00\x01", "\x00\x02")
+	if !patch.Binary {
+		t.Error("Expected binary patch")
+	}
+	if !strings.Contains(patch.Text, "Binary files a/img.png and b/img.png differ") {
+		t.Errorf("Unexpected binary patch text:\n%s", patch.Text)
+	}
+}
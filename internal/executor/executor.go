@@ -18,6 +18,8 @@ type ExecutionContext struct {
 	Variables  *generator.Variables
 	DryRun     bool
 	Logger     *log.Logger
+	OnError    generator.ErrorPolicy // What to do with partial output on failure (default: rollback)
+	Confirm    func() bool           // Asked whether to roll back when OnError is "ask"
 }
 
 // Executor executes ritual installation steps
@@ -50,26 +52,34 @@ func (e *Executor) Execute(manifest *ritual.Manifest) error {
 		return fmt.Errorf("dependency validation failed: %w", err)
 	}
 
+	// Hooks and go get change the output outside the generator, so record it as a whole
+	if !e.context.DryRun {
+		if err := e.generator.Journal().Track(e.context.OutputPath); err != nil {
+			return fmt.Errorf("failed to record output directory: %w", err)
+		}
+	}
+
 	// Step 2: Run pre-install hooks
 	if err := e.runHooks(manifest.Hooks.PreInstall, "pre-install"); err != nil {
-		return fmt.Errorf("pre-install hooks failed: %w", err)
+		return e.recover(fmt.Errorf("pre-install hooks failed: %w", err))
 	}
 
 	// Step 3: Generate files
 	if err := e.generateFiles(manifest); err != nil {
-		return fmt.Errorf("file generation failed: %w", err)
+		return e.recover(fmt.Errorf("file generation failed: %w", err))
 	}
 
 	// Step 4: Install Go dependencies
 	if err := e.installPackages(manifest); err != nil {
-		return fmt.Errorf("package installation failed: %w", err)
+		return e.recover(fmt.Errorf("package installation failed: %w", err))
 	}
 
 	// Step 5: Run post-install hooks
 	if err := e.runHooks(manifest.Hooks.PostInstall, "post-install"); err != nil {
-		return fmt.Errorf("post-install hooks failed: %w", err)
+		return e.recover(fmt.Errorf("post-install hooks failed: %w", err))
 	}
 
+	e.generator.Journal().Commit()
 	e.context.Logger.Printf("Ritual installation completed successfully")
 	return nil
 }
@@ -176,7 +186,27 @@ func (e *Executor) executeCommand(command string) error {
 	return cmd.Run()
 }
 
-// Rollback attempts to rollback a failed installation
+// recover applies the OnError policy after a failed step and returns the step's error
+func (e *Executor) recover(cause error) error {
+	policy := e.context.OnError
+	if policy == "" {
+		policy = generator.OnErrorRollback
+	}
+	if policy != generator.OnErrorRollback && policy != generator.OnErrorAsk {
+		e.context.Logger.Printf("Leaving partial installation in %s (on-error: %s)", e.context.OutputPath, policy)
+		return cause
+	}
+	if policy == generator.OnErrorAsk && e.context.Confirm != nil && !e.context.Confirm() {
+		e.context.Logger.Printf("Leaving partial installation in %s", e.context.OutputPath)
+		return cause
+	}
+	if err := e.Rollback(); err != nil {
+		return fmt.Errorf("%w (rollback failed: %v)", cause, err)
+	}
+	return cause
+}
+
+// Rollback undoes every change made by the installation so far
 func (e *Executor) Rollback() error {
 	e.context.Logger.Println("Rolling back installation...")
 
@@ -185,9 +215,12 @@ func (e *Executor) Rollback() error {
 		return nil
 	}
 
-	// For now, just log
-	// TODO: Implement proper rollback (remove generated files, restore backups)
-	e.context.Logger.Println("Rollback not yet implemented")
+	journal := e.generator.Journal()
+	changes := journal.Len()
+	if err := journal.Rollback(); err != nil {
+		return fmt.Errorf("failed to rollback installation: %w", err)
+	}
+	e.context.Logger.Printf("Rolled back %d change(s)", changes)
 
 	return nil
 }
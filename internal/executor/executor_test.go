@@ -191,12 +191,71 @@ func TestExecutor_Rollback(t *testing.T) {
 
 	executor := NewExecutor(context)
 
-	// Should not error (even though not fully implemented)
+	// Nothing to undo yet
 	if err := executor.Rollback(); err != nil {
 		t.Errorf("Rollback failed: %v", err)
 	}
 }
 
+func TestExecutor_Execute_FailureRollsBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	ritualDir := filepath.Join(tmpDir, "ritual")
+
+	os.MkdirAll(filepath.Join(ritualDir, "templates"), 0750)
+	os.WriteFile(filepath.Join(ritualDir, "templates", "main.go"), []byte("package main"), 0600)
+	os.WriteFile(filepath.Join(ritualDir, "templates", "broken.go"), []byte("package broken"), 0600)
+
+	manifest := &ritual.Manifest{
+		Ritual: ritual.RitualMeta{
+			Name:    "test-ritual",
+			Version: "1.0.0",
+		},
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "main.go", Destination: "cmd/app/main.go"},
+				// Fails to write once main.go is written, since it is not a directory
+				{Source: "broken.go", Destination: "cmd/app/main.go/broken.go"},
+			},
+		},
+	}
+
+	tests := []struct {
+		policy   generator.ErrorPolicy
+		keepsDir bool
+	}{
+		{policy: "", keepsDir: false},
+		{policy: generator.OnErrorRollback, keepsDir: false},
+		{policy: generator.OnErrorAbort, keepsDir: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			outputDir := filepath.Join(t.TempDir(), "output")
+			executor := NewExecutor(&ExecutionContext{
+				RitualPath: ritualDir,
+				OutputPath: outputDir,
+				Variables:  generator.NewVariables(),
+				Logger:     log.New(os.Stdout, "[test] ", 0),
+				OnError:    tt.policy,
+			})
+
+			if err := executor.Execute(manifest); err == nil {
+				t.Fatal("Expected error for broken template, got nil")
+			}
+
+			_, err := os.Stat(filepath.Join(outputDir, "cmd", "app", "main.go"))
+			if kept := err == nil; kept != tt.keepsDir {
+				t.Errorf("main.go kept = %v, want %v", kept, tt.keepsDir)
+			}
+			if !tt.keepsDir {
+				if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+					t.Errorf("output directory should be rolled back, stat error = %v", err)
+				}
+			}
+		})
+	}
+}
+
 func TestExecutor_Execute_WithStaticFiles(t *testing.T) {
 	tmpDir := t.TempDir()
 	ritualDir := filepath.Join(tmpDir, "ritual")
@@ -0,0 +1,59 @@
+package fith
+
+import (
+	"fmt"
+)
+
+// Error is a parse or render error with its position in the template
+type Error struct {
+	Name    string // Template name, usually the source path
+	Line    int    // 1-based line
+	Column  int    // 1-based column
+	Message string
+	// Key is the undefined name a strict engine failed on, and Keys the
+	// names that were defined where it was looked up
+	Key  string
+	Keys []string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("fith: %s:%d:%d: %s", e.Name, e.Line, e.Column, e.Message)
+}
+
+// source maps byte offsets in a template to lines and columns
+type source struct {
+	name       string
+	text       string
+	lineStarts []int
+}
+
+func newSource(name, text string) *source {
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &source{name: name, text: text, lineStarts: starts}
+}
+
+// position returns the 1-based line and column of an offset
+func (s *source) position(offset int) (int, int) {
+	line := 0
+	for line+1 < len(s.lineStarts) && s.lineStarts[line+1] <= offset {
+		line++
+	}
+	return line + 1, offset - s.lineStarts[line] + 1
+}
+
+// errorf creates an Error located at offset
+func (s *source) errorf(offset int, format string, args ...interface{}) *Error {
+	line, col := s.position(offset)
+	return &Error{
+		Name:    s.name,
+		Line:    line,
+		Column:  col,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,909 @@
+package fith
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// undefined is the value of a name or field that does not exist.
+// It renders as an empty string and is falsy.
+type undefined struct {
+	name    string   // Path of the value, like "post.author.name"
+	missing string   // The first name along the path that was not found
+	keys    []string // Names defined where missing was looked up
+	pos     int      // Offset of missing in the template; -1 if unknown
+}
+
+// undefinedName is a name or field missing from a scope or value holding keys
+func undefinedName(name string, keys []string) undefined {
+	return undefined{name: name, missing: name, keys: keys, pos: -1}
+}
+
+// scope is a chain of variable frames, innermost last
+type scope struct {
+	frames []map[string]interface{}
+}
+
+func (s *scope) lookup(name string) (interface{}, bool) {
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		if v, ok := s.frames[i][name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// names lists the names defined in any frame, sorted
+func (s *scope) names() []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, frame := range s.frames {
+		for name := range frame {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *scope) push(frame map[string]interface{}) {
+	s.frames = append(s.frames, frame)
+}
+
+func (s *scope) pop() {
+	s.frames = s.frames[:len(s.frames)-1]
+}
+
+func (s *scope) set(name string, value interface{}) {
+	s.frames[len(s.frames)-1][name] = value
+}
+
+// renderer executes a parsed template
+type renderer struct {
+	src      *source
+	funcs    map[string]interface{}
+	partials map[string]*Template
+	blocks   map[string]blockDef // Block overrides from the child templates being rendered
+	depth    int                 // Nesting of includes
+	scope    *scope
+	out      *strings.Builder
+	truth    func(interface{}) bool // Truthiness of conditions, truthy unless set
+	strict   bool                   // Undefined values are an error in output, loops and calls
+}
+
+// blockDef is a block body with the template source that defines it
+type blockDef struct {
+	body []node
+	src  *source
+}
+
+// renderTemplate renders a template. A child template renders its layout with
+// its own blocks in place of the layout's; the most derived block wins.
+func (r *renderer) renderTemplate(t *Template) error {
+	for depth := 0; t.extends != nil; depth++ {
+		if depth >= maxNesting {
+			return t.src.errorf(t.extends.pos, "layouts nested more than %d levels deep", maxNesting)
+		}
+		if r.blocks == nil {
+			r.blocks = make(map[string]blockDef)
+		}
+		for name, b := range t.blocks {
+			if _, ok := r.blocks[name]; !ok {
+				r.blocks[name] = blockDef{body: b.body, src: t.src}
+			}
+		}
+
+		r.src = t.src
+		layout, err := r.partial(t.extends.name, t.extends.pos)
+		if err != nil {
+			return err
+		}
+		t = layout
+	}
+
+	r.src = t.src
+	return r.renderNodes(t.nodes)
+}
+
+// partial evaluates a partial name and looks the partial up
+func (r *renderer) partial(name expr, pos int) (*Template, error) {
+	v, err := r.eval(name)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, r.src.errorf(pos, "partial name must be a string, got %s", toString(v))
+	}
+	tmpl, ok := r.partials[s]
+	if !ok {
+		return nil, r.src.errorf(pos, "unknown partial %q", s)
+	}
+	return tmpl, nil
+}
+
+// renderInclude renders a partial in place, sharing the current variables
+func (r *renderer) renderInclude(n *includeNode) error {
+	if r.depth >= maxNesting {
+		return r.src.errorf(n.pos, "includes nested more than %d levels deep", maxNesting)
+	}
+	partial, err := r.partial(n.name, n.pos)
+	if err != nil {
+		return err
+	}
+
+	// The partial does not see the blocks of the template including it
+	included := &renderer{
+		funcs:    r.funcs,
+		partials: r.partials,
+		depth:    r.depth + 1,
+		scope:    r.scope,
+		out:      r.out,
+		truth:    r.truth,
+		strict:   r.strict,
+	}
+	return included.renderTemplate(partial)
+}
+
+// truthy applies the renderer's truthiness to a condition value
+func (r *renderer) truthy(v interface{}) bool {
+	if r.truth != nil {
+		return r.truth(v)
+	}
+	return truthy(v)
+}
+
+func (r *renderer) renderNodes(nodes []node) error {
+	for _, n := range nodes {
+		if err := r.renderNode(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *renderer) renderNode(n node) error {
+	switch n := n.(type) {
+	case *textNode:
+		r.out.WriteString(n.text)
+
+	case *outputNode:
+		v, err := r.eval(n.expr)
+		if err != nil {
+			return err
+		}
+		if err := r.checkDefined(v, n.expr.position()); err != nil {
+			return err
+		}
+		r.out.WriteString(toString(v))
+
+	case *ifNode:
+		for i, cond := range n.conds {
+			v, err := r.eval(cond)
+			if err != nil {
+				return err
+			}
+			if r.truthy(v) {
+				return r.renderNodes(n.bodies[i])
+			}
+		}
+		return r.renderNodes(n.elseBody)
+
+	case *forNode:
+		return r.renderFor(n)
+
+	case *setNode:
+		v, err := r.eval(n.value)
+		if err != nil {
+			return err
+		}
+		r.scope.set(n.name, v)
+
+	case *includeNode:
+		return r.renderInclude(n)
+
+	case *blockNode:
+		if def, ok := r.blocks[n.name]; ok {
+			src := r.src
+			r.src = def.src
+			defer func() { r.src = src }()
+			return r.renderNodes(def.body)
+		}
+		return r.renderNodes(n.body)
+	}
+	return nil
+}
+
+// exprPath returns the dotted path of a name or attribute expression, like
+// "post.author", or "" for any other expression
+func exprPath(e expr) string {
+	switch e := e.(type) {
+	case *nameExpr:
+		return e.name
+	case *attrExpr:
+		if path := exprPath(e.target); path != "" {
+			return path + "." + e.name
+		}
+	}
+	return ""
+}
+
+// checkDefined fails on an undefined value if the renderer is strict
+func (r *renderer) checkDefined(v interface{}, pos int) error {
+	u, ok := v.(undefined)
+	if !ok || !r.strict {
+		return nil
+	}
+	if u.pos >= 0 {
+		pos = u.pos
+	}
+	err := r.src.errorf(pos, "undefined variable %q", u.name)
+	err.Key, err.Keys = u.missing, u.keys
+	return err
+}
+
+// renderScoped renders nodes in a new variable frame, as for loop bodies do
+func (r *renderer) renderScoped(nodes []node, frame map[string]interface{}) error {
+	if frame == nil {
+		frame = map[string]interface{}{}
+	}
+	r.scope.push(frame)
+	defer r.scope.pop()
+	return r.renderNodes(nodes)
+}
+
+func (r *renderer) renderFor(n *forNode) error {
+	iterable, err := r.eval(n.iter)
+	if err != nil {
+		return err
+	}
+
+	if err := r.checkDefined(iterable, n.iter.position()); err != nil {
+		return err
+	}
+	keys, values, err := iterate(iterable)
+	if err != nil {
+		return r.src.errorf(n.iter.position(), "cannot loop over %s", err)
+	}
+	if len(values) == 0 {
+		return r.renderScoped(n.elseBody, nil)
+	}
+
+	for i := range values {
+		frame := map[string]interface{}{
+			"loop": map[string]interface{}{
+				"index":  i + 1,
+				"index0": i,
+				"first":  i == 0,
+				"last":   i == len(values)-1,
+				"length": len(values),
+			},
+		}
+		if n.key != "" {
+			frame[n.key] = keys[i]
+		}
+		frame[n.value] = values[i]
+		if err := r.renderScoped(n.body, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iterate returns the keys and values of a list or map. Maps iterate in key order.
+func iterate(v interface{}) ([]interface{}, []interface{}, error) {
+	if v == nil {
+		return nil, nil, nil
+	}
+	if _, ok := v.(undefined); ok {
+		return nil, nil, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		keys := make([]interface{}, rv.Len())
+		values := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			keys[i] = i
+			values[i] = rv.Index(i).Interface()
+		}
+		return keys, values, nil
+
+	case reflect.Map:
+		mapKeys := rv.MapKeys()
+		sort.Slice(mapKeys, func(i, j int) bool {
+			return fmt.Sprint(mapKeys[i].Interface()) < fmt.Sprint(mapKeys[j].Interface())
+		})
+		keys := make([]interface{}, len(mapKeys))
+		values := make([]interface{}, len(mapKeys))
+		for i, k := range mapKeys {
+			keys[i] = k.Interface()
+			values[i] = rv.MapIndex(k).Interface()
+		}
+		return keys, values, nil
+
+	case reflect.String:
+		s := rv.String()
+		var keys, values []interface{}
+		for i, c := range s {
+			keys = append(keys, i)
+			values = append(values, string(c))
+		}
+		return keys, values, nil
+	}
+
+	return nil, nil, fmt.Errorf("a value of type %T", v)
+}
+
+func (r *renderer) eval(e expr) (interface{}, error) {
+	switch e := e.(type) {
+	case *literalExpr:
+		return e.value, nil
+
+	case *nameExpr:
+		if v, ok := r.scope.lookup(e.name); ok {
+			return v, nil
+		}
+		u := undefinedName(e.name, r.scope.names())
+		u.pos = e.pos
+		return u, nil
+
+	case *attrExpr:
+		target, err := r.eval(e.target)
+		if err != nil {
+			return nil, err
+		}
+		v := field(target, e.name)
+		if u, ok := v.(undefined); ok && u.pos < 0 {
+			u.pos = e.pos
+			if path := exprPath(e.target); path != "" {
+				u.name = path + "." + u.name
+			}
+			return u, nil
+		}
+		return v, nil
+
+	case *indexExpr:
+		target, err := r.eval(e.target)
+		if err != nil {
+			return nil, err
+		}
+		index, err := r.eval(e.index)
+		if err != nil {
+			return nil, err
+		}
+		return field(target, index), nil
+
+	case *listExpr:
+		items := make([]interface{}, len(e.items))
+		for i, item := range e.items {
+			v, err := r.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = v
+		}
+		return items, nil
+
+	case *callExpr:
+		return r.call(e)
+
+	case *unaryExpr:
+		x, err := r.eval(e.x)
+		if err != nil {
+			return nil, err
+		}
+		if e.op == "not" {
+			return !r.truthy(x), nil
+		}
+		n, ok := toNumber(x)
+		if !ok {
+			return nil, r.src.errorf(e.pos, "cannot negate %s", typeName(x))
+		}
+		return normalizeNumber(-n), nil
+
+	case *testExpr:
+		x, err := r.eval(e.x)
+		if err != nil {
+			return nil, err
+		}
+		result := runTest(e.name, x)
+		if e.negate {
+			result = !result
+		}
+		return result, nil
+
+	case *binaryExpr:
+		return r.evalBinary(e)
+	}
+
+	return nil, r.src.errorf(e.position(), "cannot evaluate expression")
+}
+
+func (r *renderer) evalBinary(e *binaryExpr) (interface{}, error) {
+	left, err := r.eval(e.left)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit logical operators
+	switch e.op {
+	case "and":
+		if !r.truthy(left) {
+			return false, nil
+		}
+		right, err := r.eval(e.right)
+		if err != nil {
+			return nil, err
+		}
+		return r.truthy(right), nil
+	case "or":
+		if r.truthy(left) {
+			return true, nil
+		}
+		right, err := r.eval(e.right)
+		if err != nil {
+			return nil, err
+		}
+		return r.truthy(right), nil
+	}
+
+	right, err := r.eval(e.right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		cmp, ok := compare(left, right)
+		if !ok {
+			return nil, r.src.errorf(e.pos, "cannot compare %s with %s", typeName(left), typeName(right))
+		}
+		switch e.op {
+		case "<":
+			return cmp < 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">":
+			return cmp > 0, nil
+		default:
+			return cmp >= 0, nil
+		}
+	case "in", "not in":
+		found, ok := contains(right, left)
+		if !ok {
+			return nil, r.src.errorf(e.pos, "cannot use \"in\" with %s", typeName(right))
+		}
+		return found == (e.op == "in"), nil
+	case "~":
+		return toString(left) + toString(right), nil
+	case "+":
+		if ls, ok := left.(string); ok {
+			return ls + toString(right), nil
+		}
+	}
+
+	// Arithmetic
+	a, okA := toNumber(left)
+	b, okB := toNumber(right)
+	if !okA || !okB {
+		return nil, r.src.errorf(e.pos, "cannot apply %q to %s and %s", e.op, typeName(left), typeName(right))
+	}
+	switch e.op {
+	case "+":
+		return normalizeNumber(a + b), nil
+	case "-":
+		return normalizeNumber(a - b), nil
+	case "*":
+		return normalizeNumber(a * b), nil
+	case "/":
+		if b == 0 {
+			return nil, r.src.errorf(e.pos, "division by zero")
+		}
+		return normalizeNumber(a / b), nil
+	case "%":
+		if b == 0 {
+			return nil, r.src.errorf(e.pos, "division by zero")
+		}
+		return normalizeNumber(math.Mod(a, b)), nil
+	}
+
+	return nil, r.src.errorf(e.pos, "unknown operator %q", e.op)
+}
+
+// call invokes a registered function with reflection, converting arguments
+func (r *renderer) call(e *callExpr) (interface{}, error) {
+	kind := "function"
+	if e.filter {
+		kind = "filter"
+	}
+
+	fn := reflect.ValueOf(r.funcs[e.name])
+	fnType := fn.Type()
+
+	args := make([]interface{}, len(e.args))
+	for i, a := range e.args {
+		v, err := r.eval(a)
+		if err != nil {
+			return nil, err
+		}
+		// default() and coalesce() are how a template gives an undefined value a fallback
+		if e.name != "default" && e.name != "coalesce" {
+			if err := r.checkDefined(v, a.position()); err != nil {
+				return nil, err
+			}
+		}
+		args[i] = v
+	}
+
+	numIn := fnType.NumIn()
+	if fnType.IsVariadic() {
+		if len(args) < numIn-1 {
+			return nil, r.src.errorf(e.pos, "%s %q expects at least %d argument(s), got %d", kind, e.name, numIn-1, len(args))
+		}
+	} else if len(args) != numIn {
+		return nil, r.src.errorf(e.pos, "%s %q expects %d argument(s), got %d", kind, e.name, numIn, len(args))
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var paramType reflect.Type
+		if fnType.IsVariadic() && i >= numIn-1 {
+			paramType = fnType.In(numIn - 1).Elem()
+		} else {
+			paramType = fnType.In(i)
+		}
+		v, err := convertArg(arg, paramType)
+		if err != nil {
+			return nil, r.src.errorf(e.pos, "%s %q argument %d: %v", kind, e.name, i+1, err)
+		}
+		in[i] = v
+	}
+
+	out := fn.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return nil, r.src.errorf(e.pos, "%s %q: %v", kind, e.name, out[1].Interface())
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out[0].Interface(), nil
+}
+
+// convertArg converts a template value to a function parameter type
+func convertArg(v interface{}, t reflect.Type) (reflect.Value, error) {
+	if _, ok := v.(undefined); ok {
+		v = nil
+	}
+	if t.Kind() == reflect.Interface {
+		if v == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(v), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(toString(v)).Convert(t), nil
+	case reflect.Bool:
+		return reflect.ValueOf(truthy(v)).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := toNumber(v)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected a number, got %s", typeName(v))
+		}
+		return reflect.ValueOf(int64(n)).Convert(t), nil
+	case reflect.Float32, reflect.Float64:
+		n, ok := toNumber(v)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("expected a number, got %s", typeName(v))
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Slice:
+		_, values, err := iterate(v)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected a list, got %s", typeName(v))
+		}
+		slice := reflect.MakeSlice(t, len(values), len(values))
+		for i, item := range values {
+			elem, err := convertArg(item, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			slice.Index(i).Set(elem)
+		}
+		return slice, nil
+	}
+
+	if v != nil && reflect.TypeOf(v).AssignableTo(t) {
+		return reflect.ValueOf(v), nil
+	}
+	return reflect.Value{}, fmt.Errorf("expected %s, got %s", t, typeName(v))
+}
+
+// field looks up a map key, struct field or list index
+func field(target interface{}, key interface{}) interface{} {
+	name := toString(key)
+	if target == nil {
+		return undefinedName(name, nil)
+	}
+	if u, ok := target.(undefined); ok {
+		u.name += "." + name
+		return u
+	}
+
+	rv := reflect.ValueOf(target)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return undefinedName(name, nil)
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		k := reflect.ValueOf(key)
+		if rv.Type().Key().Kind() == reflect.String {
+			k = reflect.ValueOf(name).Convert(rv.Type().Key())
+		}
+		if !k.IsValid() || !k.Type().AssignableTo(rv.Type().Key()) {
+			return undefinedName(name, nil)
+		}
+		if v := rv.MapIndex(k); v.IsValid() {
+			return v.Interface()
+		}
+	case reflect.Slice, reflect.Array, reflect.String:
+		n, ok := toNumber(key)
+		if !ok {
+			if s, isString := key.(string); isString {
+				if parsed, err := parseNumber(s); err == nil {
+					n, ok = toNumber(parsed)
+				}
+			}
+		}
+		if ok {
+			i := int(n)
+			if i < 0 {
+				i += rv.Len()
+			}
+			if i >= 0 && i < rv.Len() {
+				if rv.Kind() == reflect.String {
+					return string(rv.String()[i])
+				}
+				return rv.Index(i).Interface()
+			}
+		}
+	case reflect.Struct:
+		if f := rv.FieldByName(name); f.IsValid() && f.CanInterface() {
+			return f.Interface()
+		}
+	}
+
+	return undefinedName(name, fieldNames(rv))
+}
+
+// fieldNames lists the keys of a map with string keys or the fields of a struct, sorted
+func fieldNames(rv reflect.Value) []string {
+	var names []string
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		for _, k := range rv.MapKeys() {
+			names = append(names, k.String())
+		}
+	case reflect.Struct:
+		for i := range rv.NumField() {
+			if f := rv.Type().Field(i); f.IsExported() {
+				names = append(names, f.Name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func runTest(name string, v interface{}) bool {
+	_, isUndefined := v.(undefined)
+	switch name {
+	case "defined":
+		return !isUndefined
+	case "undefined":
+		return isUndefined
+	case "none":
+		return v == nil
+	case "empty":
+		return !truthy(v)
+	case "even", "odd":
+		n, ok := toNumber(v)
+		if !ok {
+			return false
+		}
+		return (int64(n)%2 == 0) == (name == "even")
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := toNumber(v)
+		_, isBool := v.(bool)
+		_, isString := v.(string)
+		return ok && !isBool && !isString
+	}
+	return false
+}
+
+// truthy reports whether a value counts as true in a condition
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case nil, undefined:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+
+	if n, ok := toNumber(v); ok {
+		return n != 0
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !rv.IsNil()
+	}
+	return true
+}
+
+// toNumber converts numeric values to float64. Strings are not numbers.
+func toNumber(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// numberLike converts v to a number, parsing numeric strings when other is a number
+func numberLike(v, other interface{}) (float64, bool) {
+	if n, ok := toNumber(v); ok {
+		return n, true
+	}
+	s, isString := v.(string)
+	if _, otherNumber := toNumber(other); !isString || !otherNumber {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return n, err == nil
+}
+
+// normalizeNumber returns whole numbers as int so they render without a fraction
+func normalizeNumber(n float64) interface{} {
+	if n == math.Trunc(n) && math.Abs(n) < 1<<53 {
+		return int(n)
+	}
+	return n
+}
+
+func equal(a, b interface{}) bool {
+	_, aUndef := a.(undefined)
+	_, bUndef := b.(undefined)
+	if aUndef || bUndef {
+		return aUndef && bUndef || aUndef && b == nil || bUndef && a == nil
+	}
+
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	// Answers loaded from YAML or flags may be strings; compare by text
+	_, aString := a.(string)
+	_, bString := b.(string)
+	if aString != bString && a != nil && b != nil {
+		return toString(a) == toString(b)
+	}
+	return false
+}
+
+// compare orders numbers numerically and strings lexically.
+// A numeric string compared with a number is compared as a number.
+func compare(a, b interface{}) (int, bool) {
+	if x, ok := numberLike(a, b); ok {
+		if y, ok := numberLike(b, a); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y), true
+		}
+	}
+	return 0, false
+}
+
+// contains reports whether needle is an element of a list, a key of a map or a substring
+func contains(haystack, needle interface{}) (bool, bool) {
+	if haystack == nil {
+		return false, true
+	}
+	if _, ok := haystack.(undefined); ok {
+		return false, true
+	}
+
+	rv := reflect.ValueOf(haystack)
+	switch rv.Kind() {
+	case reflect.String:
+		return strings.Contains(rv.String(), toString(needle)), true
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if equal(rv.Index(i).Interface(), needle) {
+				return true, true
+			}
+		}
+		return false, true
+	case reflect.Map:
+		_, missing := field(haystack, needle).(undefined)
+		return !missing, true
+	}
+	return false, false
+}
+
+// toString renders a value as template output
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil, undefined:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = toString(item)
+		}
+		return strings.Join(parts, ", ")
+	case []string:
+		return strings.Join(v, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+// typeName describes a value's type for error messages
+func typeName(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "none"
+	case undefined:
+		return fmt.Sprintf("undefined %q", v.name)
+	}
+	return fmt.Sprintf("%T", v)
+}
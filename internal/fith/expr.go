@@ -0,0 +1,136 @@
+package fith
+
+import (
+	"strconv"
+	"strings"
+)
+
+// exprKind identifies a token inside a tag
+type exprKind int
+
+const (
+	exprEOF exprKind = iota
+	exprIdent
+	exprNumber
+	exprString
+	exprOp
+)
+
+// exprToken is a token of an expression
+type exprToken struct {
+	kind exprKind
+	text string
+	pos  int
+}
+
+// operators lists multi-character operators before single-character ones
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "+", "-", "*", "/", "%", "~", "|", ".", ",", "(", ")", "[", "]", "=", "!"}
+
+// lexExpr tokenizes the inside of a tag. base is the offset of s in the template.
+func lexExpr(src *source, s string, base int) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case isIdentStart(c):
+			start := i
+			for i < len(s) && isIdentChar(s[i]) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: exprIdent, text: s[start:i], pos: base + start})
+
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '_') {
+				i++
+			}
+			// A fraction needs a digit after the dot, so "items.0" style access still lexes
+			if i+1 < len(s) && s[i] == '.' && s[i+1] >= '0' && s[i+1] <= '9' {
+				i++
+				for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+					i++
+				}
+			}
+			tokens = append(tokens, exprToken{kind: exprNumber, text: s[start:i], pos: base + start})
+
+		case c == '"' || c == '\'':
+			start := i
+			value, n, ok := unquote(s[i:])
+			if !ok {
+				return nil, src.errorf(base+start, "unterminated string")
+			}
+			i += n
+			tokens = append(tokens, exprToken{kind: exprString, text: value, pos: base + start})
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, src.errorf(base+i, "unexpected character %q", c)
+			}
+			tokens = append(tokens, exprToken{kind: exprOp, text: op, pos: base + i})
+			i += len(op)
+		}
+	}
+
+	tokens = append(tokens, exprToken{kind: exprEOF, pos: base + len(s)})
+	return tokens, nil
+}
+
+// unquote reads a quoted string at the start of s and returns its value and length
+func unquote(s string) (string, int, bool) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			return b.String(), i + 1, true
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, false
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
+
+// parseNumber converts a number token to int or float64
+func parseNumber(text string) (interface{}, error) {
+	clean := strings.ReplaceAll(text, "_", "")
+	if strings.Contains(clean, ".") {
+		return strconv.ParseFloat(clean, 64)
+	}
+	n, err := strconv.ParseInt(clean, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return int(n), nil
+}
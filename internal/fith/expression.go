@@ -0,0 +1,123 @@
+package fith
+
+import (
+	"strings"
+)
+
+// Expression is a standalone Fíth expression, such as a ritual condition
+type Expression struct {
+	src   *source
+	expr  expr
+	funcs map[string]interface{}
+	truth func(interface{}) bool
+}
+
+// ParseExpression parses an expression using the built-in function library
+func ParseExpression(name, text string) (*Expression, error) {
+	return New().ParseExpression(name, text)
+}
+
+// ParseExpression parses an expression such as `database == "postgres" and port > 1024`.
+// name is used in error messages.
+func (e *Engine) ParseExpression(name, text string) (*Expression, error) {
+	src := newSource(name, text)
+	toks, err := lexExpr(src, text, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{src: src, toks: toks, funcs: e.funcs}
+	x, err := p.parseFull()
+	if err != nil {
+		return nil, err
+	}
+	return &Expression{src: src, expr: x, funcs: e.funcs}, nil
+}
+
+// String returns the source of the expression
+func (x *Expression) String() string {
+	return x.src.text
+}
+
+// Evaluate returns the value of the expression. Unknown names evaluate to none.
+func (x *Expression) Evaluate(data map[string]interface{}) (interface{}, error) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	r := &renderer{
+		src:   x.src,
+		funcs: x.funcs,
+		scope: &scope{frames: []map[string]interface{}{data}},
+		truth: x.truth,
+	}
+	v, err := r.eval(x.expr)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := v.(undefined); ok {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// Bool evaluates the expression as a condition.
+//
+// Besides the usual truthiness, the strings "false", "no", "off" and "0" are
+// false, so boolean answers given as text behave like booleans.
+func (x *Expression) Bool(data map[string]interface{}) (bool, error) {
+	cond := *x
+	cond.truth = conditionTruth
+	v, err := cond.Evaluate(data)
+	if err != nil {
+		return false, err
+	}
+	return conditionTruth(v), nil
+}
+
+func conditionTruth(v interface{}) bool {
+	if s, ok := v.(string); ok {
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "", "false", "no", "off", "0":
+			return false
+		}
+		return true
+	}
+	return truthy(v)
+}
+
+// Names returns the variables the expression reads, in order of first use
+func (x *Expression) Names() []string {
+	var names []string
+	seen := make(map[string]bool)
+	var walk func(e expr)
+	walk = func(e expr) {
+		switch e := e.(type) {
+		case *nameExpr:
+			if !seen[e.name] {
+				seen[e.name] = true
+				names = append(names, e.name)
+			}
+		case *attrExpr:
+			walk(e.target)
+		case *indexExpr:
+			walk(e.target)
+			walk(e.index)
+		case *callExpr:
+			for _, arg := range e.args {
+				walk(arg)
+			}
+		case *unaryExpr:
+			walk(e.x)
+		case *binaryExpr:
+			walk(e.left)
+			walk(e.right)
+		case *testExpr:
+			walk(e.x)
+		case *listExpr:
+			for _, item := range e.items {
+				walk(item)
+			}
+		}
+	}
+	walk(x.expr)
+	return names
+}
@@ -0,0 +1,175 @@
+// Package fith implements Fíth, the Jinja-style template language used by rituals.
+//
+// Fíth templates use {{ expression }} for output, {% statement %} for control
+// flow ({% if %}/{% elif %}/{% else %}/{% endif %}, {% for x in list %}/{% endfor %},
+// {% set name = value %}) and {# ... #} for comments. {% raw %}...{% endraw %}
+// emits its content verbatim. Values are transformed with filters
+// ({{ name | upper }}) or function calls ({{ upper(name) }}).
+//
+// Partials registered with Engine.AddPartial are rendered in place with
+// {% include "name" %}. A template starting with {% extends "layout" %} renders
+// that layout, replacing each {% block name %}...{% endblock %} of the layout
+// with the child's block of the same name.
+//
+// Engine.Delims gives an engine that parses templates with other delimiters,
+// for output that itself contains {{ }} or {% %}. An engine set strict with
+// Engine.SetStrict fails on undefined values in output, loops and function
+// calls instead of rendering nothing; conditions, tests like
+// {% if x is defined %}, default() and coalesce() still accept them.
+package fith
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Engine parses and renders Fíth templates with a function library and partials
+type Engine struct {
+	funcs    map[string]interface{}
+	partials map[string]*Template
+	lexer    *lexer
+	strict   bool
+}
+
+// Template is a parsed Fíth template
+type Template struct {
+	src      *source
+	nodes    []node
+	funcs    map[string]interface{}
+	partials map[string]*Template
+	blocks   map[string]*blockNode
+	extends  *extendsNode
+	strict   bool
+}
+
+// maxNesting limits how deeply includes and layouts nest, so that a partial
+// including itself fails instead of recursing forever
+const maxNesting = 32
+
+// goTemplateAction matches Go-template actions with the [[ ]] delimiters used by
+// go-template rituals, which Fíth would otherwise copy into the output untouched
+var goTemplateAction = regexp.MustCompile(`\[\[-?\s*(\.|\$|if\s|range\s|end\s*-?\]\]|else\s*-?\]\]|with\s|template\s|define\s|block\s)`)
+
+// New creates an engine with the built-in function library
+func New() *Engine {
+	e := &Engine{funcs: make(map[string]interface{}), partials: make(map[string]*Template), lexer: defaultLexer}
+	for name, fn := range builtinFuncs() {
+		e.funcs[name] = fn
+	}
+	return e
+}
+
+// Delims returns an engine that parses templates with other delimiters. It shares
+// the functions and partials of e; partials keep the delimiters they were parsed with.
+func (e *Engine) Delims(delims Delimiters) (*Engine, error) {
+	if err := delims.validate(); err != nil {
+		return nil, err
+	}
+	return &Engine{funcs: e.funcs, partials: e.partials, lexer: newLexer(delims), strict: e.strict}, nil
+}
+
+// SetStrict makes rendering an undefined value an error
+func (e *Engine) SetStrict(strict bool) {
+	e.strict = strict
+}
+
+// RegisterFunc adds a function usable as {{ name(args) }} and as a filter {{ value | name }}.
+// fn must be a Go function returning one value, or a value and an error.
+func (e *Engine) RegisterFunc(name string, fn interface{}) {
+	e.funcs[name] = fn
+}
+
+// AddPartial makes a parsed template available to {% include %} and
+// {% extends %} under name. A partial added again under the same name replaces it.
+func (e *Engine) AddPartial(name string, tmpl *Template) {
+	e.partials[name] = tmpl
+}
+
+// ClearPartials removes every partial
+func (e *Engine) ClearPartials() {
+	clear(e.partials)
+}
+
+// Parse parses a template. name is used in error messages.
+func (e *Engine) Parse(name, text string) (*Template, error) {
+	src := newSource(name, text)
+	tmpl, err := parse(src, e.lexer, e.funcs)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkGoTemplateSyntax(src, tmpl.nodes); err != nil {
+		return nil, err
+	}
+	tmpl.partials = e.partials
+	tmpl.strict = e.strict
+	return tmpl, nil
+}
+
+// Render parses and executes a template in one step
+func (e *Engine) Render(name, text string, data map[string]interface{}) (string, error) {
+	tmpl, err := e.Parse(name, text)
+	if err != nil {
+		return "", err
+	}
+	return tmpl.Execute(data)
+}
+
+// Execute renders the template with data
+func (t *Template) Execute(data map[string]interface{}) (string, error) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	var out strings.Builder
+	r := &renderer{
+		src:      t.src,
+		funcs:    t.funcs,
+		partials: t.partials,
+		// {% set %} at the top level writes to its own frame, never to data
+		scope:  &scope{frames: []map[string]interface{}{data, {}}},
+		out:    &out,
+		strict: t.strict,
+	}
+	if err := r.renderTemplate(t); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// checkGoTemplateSyntax rejects templates written for the go-template engine
+func checkGoTemplateSyntax(src *source, nodes []node) error {
+	var err error
+	walkText(nodes, func(n *textNode) {
+		if err != nil || n.raw {
+			return
+		}
+		if loc := goTemplateAction.FindStringIndex(n.text); loc != nil {
+			action := n.text[loc[0]:loc[1]]
+			err = src.errorf(n.pos+loc[0],
+				"found Go-template syntax %q, but this ritual uses the fith engine; "+
+					"set `template_engine: go-template` in ritual.yaml or convert the template to {{ }} syntax",
+				strings.TrimSpace(action))
+		}
+	})
+	return err
+}
+
+// walkText calls fn for every text node in a node tree
+func walkText(nodes []node, fn func(*textNode)) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *textNode:
+			fn(n)
+		case *ifNode:
+			for _, body := range n.bodies {
+				walkText(body, fn)
+			}
+			walkText(n.elseBody, fn)
+		case *forNode:
+			walkText(n.body, fn)
+			walkText(n.elseBody, fn)
+		case *blockNode:
+			walkText(n.body, fn)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package fith
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]interface{}
+		want     string
+	}{
+		{"text only", "plain text", nil, "plain text"},
+		{"variable", "Hello {{ name }}!", map[string]interface{}{"name": "World"}, "Hello World!"},
+		{"undefined renders empty", "[{{ missing }}]", nil, "[]"},
+		{"attribute", "{{ user.name }}", map[string]interface{}{"user": map[string]interface{}{"name": "ada"}}, "ada"},
+		{"index", "{{ items[1] }} {{ items.0 }}", map[string]interface{}{"items": []string{"a", "b"}}, "b a"},
+		{"filter", "{{ name | upper }}", map[string]interface{}{"name": "touta"}, "TOUTA"},
+		{"filter args", "{{ items | join(\", \") }}", map[string]interface{}{"items": []string{"a", "b"}}, "a, b"},
+		{"function call", "{{ replace(name, \"-\", \"_\") }}", map[string]interface{}{"name": "my-app"}, "my_app"},
+		{"default", "{{ port | default(8080) }}", nil, "8080"},
+		{"arithmetic", "{{ (a + b) * 2 }} {{ 7 / 2 }} {{ 7 % 3 }}", map[string]interface{}{"a": 1, "b": 2}, "6 3.5 1"},
+		{"concat", "{{ \"v\" ~ version }}", map[string]interface{}{"version": 2}, "v2"},
+		{"comment", "a{# hidden #}b", nil, "ab"},
+		{"raw", "{% raw %}{{ name }}{% endraw %}", map[string]interface{}{"name": "x"}, "{{ name }}"},
+		{"trim markers", "a  {{- name -}}  b", map[string]interface{}{"name": "x"}, "axb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New().Render("test", tt.template, tt.data)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderConditions(t *testing.T) {
+	tmpl := `{% if database == "postgres" and not sqlite %}pg{% elif database in ["mysql", "mariadb"] %}my{% else %}other{% endif %}`
+
+	tests := []struct {
+		database string
+		want     string
+	}{
+		{"postgres", "pg"},
+		{"mariadb", "my"},
+		{"sqlite", "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.database, func(t *testing.T) {
+			got, err := New().Render("test", tmpl, map[string]interface{}{"database": tt.database})
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTests(t *testing.T) {
+	tmpl := "{% if port is defined %}{{ port }}{% endif %}{% if items is empty %}none{% endif %}{% if 3 is odd %}!{% endif %}"
+	got, err := New().Render("test", tmpl, map[string]interface{}{"port": 80, "items": []string{}})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got != "80none!" {
+		t.Errorf("Render() = %q", got)
+	}
+}
+
+func TestRenderLoops(t *testing.T) {
+	data := map[string]interface{}{
+		"models": []interface{}{"Post", "Comment"},
+		"ports":  map[string]interface{}{"web": 80, "db": 5432},
+		"empty":  []string{},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"list", "{% for m in models %}{{ loop.index }}.{{ m }}{% if not loop.last %},{% endif %}{% endfor %}", "1.Post,2.Comment"},
+		{"map sorted by key", "{% for k, v in ports %}{{ k }}={{ v }} {% endfor %}", "db=5432 web=80 "},
+		{"else on empty", "{% for m in empty %}{{ m }}{% else %}nothing{% endfor %}", "nothing"},
+		{"loop variables are scoped", "{% for m in models %}{% endfor %}[{{ m }}]", "[]"},
+		{"set", "{% set name = models | first | lower %}{{ name }}", "post"},
+		{"standalone tags remove their lines", "start\n{% for m in models %}\n- {{ m }}\n{% endfor %}\nend\n", "start\n- Post\n- Comment\nend\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New().Render("test", tt.template, data)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	engine := New()
+	engine.RegisterFunc("shout", func(s string) string { return strings.ToUpper(s) + "!" })
+
+	got, err := engine.Render("test", "{{ name | shout }} {{ shout(\"hi\") }}", map[string]interface{}{"name": "hey"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got != "HEY! HI!" {
+		t.Errorf("Render() = %q", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		line     int
+		column   int
+		message  string
+	}{
+		{"unclosed output", "line\n  {{ name", 2, 3, "unclosed"},
+		{"unknown filter", "{{ name | nope }}", 1, 11, `unknown filter "nope"`},
+		{"unknown function", "\n{{ nope() }}", 2, 4, `unknown function "nope"`},
+		{"unknown tag", "{% macro x %}", 1, 4, "macro"},
+		{"unknown partial", "a\n{% include \"nope\" %}", 2, 3, `unknown partial "nope"`},
+		{"nested extends", "{% if a %}{% extends \"base\" %}{% endif %}", 1, 13, "top level"},
+		{"duplicate block", "{% block a %}{% endblock %}{% block a %}{% endblock %}", 1, 30, `block "a" is defined twice`},
+		{"mismatched endblock", "{% block a %}{% endblock b %}", 1, 16, "closes block"},
+		{"missing endif", "{% if a %}\nx", 1, 3, "endif"},
+		{"stray endfor", "{% if a %}{% endfor %}", 1, 13, "endfor"},
+		{"go-template action", "ok\nHello [[ .name ]]", 2, 7, "template_engine: go-template"},
+		{"go-template field", "{{ .name }}", 1, 4, "template_engine: go-template"},
+		{"division by zero", "{{ 1 / 0 }}", 1, 6, "division by zero"},
+		{"filter arity", "{{ name | replace(\"a\") }}", 1, 11, "expects 3 argument(s)"},
+		{"range too long", "{{ range(1000000000) | length }}", 1, 4, "exceeds the limit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New().Render("page.html.tmpl", tt.template, map[string]interface{}{"name": "x", "a": true})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+
+			var fe *Error
+			if !errors.As(err, &fe) {
+				t.Fatalf("expected *Error, got %T: %v", err, err)
+			}
+			if fe.Name != "page.html.tmpl" {
+				t.Errorf("Name = %q", fe.Name)
+			}
+			if fe.Line != tt.line || fe.Column != tt.column {
+				t.Errorf("position = %d:%d, want %d:%d (%v)", fe.Line, fe.Column, tt.line, tt.column, err)
+			}
+			if !strings.Contains(fe.Message, tt.message) {
+				t.Errorf("Message = %q, want it to contain %q", fe.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	data := map[string]interface{}{
+		"name": "x",
+		"post": map[string]interface{}{"title": "T", "body": "B"},
+	}
+
+	lenient := New()
+	got, err := lenient.Render("t", "[{{ nmae }}]", data)
+	if err != nil || got != "[]" {
+		t.Errorf("lenient Render() = %q, %v; want undefined to render empty", got, err)
+	}
+
+	strict := New()
+	strict.SetStrict(true)
+	for _, ok := range []string{
+		"{% if nmae %}x{% endif %}",
+		"{% if nmae is defined %}x{% endif %}",
+		"{{ nmae | default(\"d\") }}",
+	} {
+		if _, err := strict.Render("t", ok, data); err != nil {
+			t.Errorf("Render(%q) error = %v", ok, err)
+		}
+	}
+
+	tests := []struct {
+		template string
+		column   int
+		message  string
+		key      string
+		keys     []string
+	}{
+		{"{{ nmae }}", 4, `undefined variable "nmae"`, "nmae", []string{"name", "post"}},
+		{"{{ post.titel | upper }}", 9, `undefined variable "post.titel"`, "titel", []string{"body", "title"}},
+		{"{{ author.name }}", 4, `undefined variable "author.name"`, "author", []string{"name", "post"}},
+		{"{% for t in tags %}{% endfor %}", 13, `undefined variable "tags"`, "tags", []string{"name", "post"}},
+	}
+	for _, tt := range tests {
+		_, err := strict.Render("t", tt.template, data)
+		var fe *Error
+		if !errors.As(err, &fe) {
+			t.Fatalf("Render(%q): expected *Error, got %v", tt.template, err)
+		}
+		if fe.Column != tt.column || fe.Message != tt.message || fe.Key != tt.key ||
+			strings.Join(fe.Keys, ",") != strings.Join(tt.keys, ",") {
+			t.Errorf("Render(%q) error = %+v", tt.template, fe)
+		}
+	}
+
+	// Engines with other delimiters stay strict
+	delimiters := DefaultDelimiters
+	delimiters.Output = [2]string{"<<", ">>"}
+	delims, err := strict.Delims(delimiters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := delims.Render("t", "<< nmae >>", data); err == nil {
+		t.Error("expected an error from an engine with other delimiters")
+	}
+}
+
+func TestPartials(t *testing.T) {
+	engine := New()
+	addPartial(t, engine, "license", "// Copyright {{ author }}\n")
+	addPartial(t, engine, "header", "{% include \"license\" %}// Package {{ pkg }}\n")
+
+	got, err := engine.Render("main.go.tmpl", "{% set pkg = \"main\" %}{% include \"header\" %}package {{ pkg }}\n",
+		map[string]interface{}{"author": "Ada"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := "// Copyright Ada\n// Package main\npackage main\n"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestPartials_ErrorsNamePartial(t *testing.T) {
+	engine := New()
+	addPartial(t, engine, "broken", "ok\n{{ 1 / 0 }}")
+
+	_, err := engine.Render("main.go.tmpl", "{% include \"broken\" %}", nil)
+	var fe *Error
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	if fe.Name != "partials/broken.tmpl" || fe.Line != 2 {
+		t.Errorf("error = %v, want it located in partials/broken.tmpl line 2", err)
+	}
+}
+
+func TestPartials_Recursion(t *testing.T) {
+	engine := New()
+	addPartial(t, engine, "loop", "{% include \"loop\" %}")
+
+	_, err := engine.Render("main.go.tmpl", "{% include \"loop\" %}", nil)
+	if err == nil || !strings.Contains(err.Error(), "nested more than") {
+		t.Errorf("expected a nesting error, got %v", err)
+	}
+}
+
+func TestLayouts(t *testing.T) {
+	engine := New()
+	addPartial(t, engine, "layouts/base",
+		"<title>{% block title %}{{ app }}{% endblock %}</title>\n<main>{% block content %}empty{% endblock %}</main>\n")
+	addPartial(t, engine, "layouts/page",
+		"{% extends \"layouts/base\" %}{% block content %}<article>{% block body %}{% endblock %}</article>{% endblock %}")
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"defaults", `{% extends "layouts/base" %}`, "<title>blog</title>\n<main>empty</main>\n"},
+		{"override", `{% extends "layouts/base" %}ignored{% block content %}Hi {{ app }}{% endblock %}`,
+			"<title>blog</title>\n<main>Hi blog</main>\n"},
+		{"two levels", `{% extends "layouts/page" %}{% block title %}Post{% endblock %}{% block body %}text{% endblock %}`,
+			"<title>Post</title>\n<main><article>text</article></main>\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.Render("page.html.tmpl", tt.template, map[string]interface{}{"app": "blog"})
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDelims(t *testing.T) {
+	engine := New()
+	addPartial(t, engine, "greeting", "Hello {{ name }}")
+
+	custom, err := engine.Delims(Delimiters{
+		Output:  [2]string{"<<", ">>"},
+		Tag:     [2]string{"<%", "%>"},
+		Comment: [2]string{"<#", "#>"},
+	})
+	if err != nil {
+		t.Fatalf("Delims() error = %v", err)
+	}
+
+	text := "<# comment #>\n<% if admin %>\n{{ user.name }}: << name | upper >>\n<% endif %>\n" +
+		"<% raw %><< name >><% endraw %>\n<% include \"greeting\" %>"
+	got, err := custom.Render("page.vue.tmpl", text, map[string]interface{}{"admin": true, "name": "ada"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	want := "{{ user.name }}: ADA\n<< name >>\nHello ada"
+	if got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+
+	// The original engine keeps the default delimiters
+	if got, _ := engine.Render("t", "<< name >>{{ name }}", map[string]interface{}{"name": "x"}); got != "<< name >>x" {
+		t.Errorf("default delimiters changed: %q", got)
+	}
+
+	for _, delims := range []Delimiters{
+		{Output: [2]string{"<<", ">>"}, Tag: [2]string{"<<", "%>"}, Comment: DefaultDelimiters.Comment},
+		{Output: [2]string{"", ">>"}, Tag: DefaultDelimiters.Tag, Comment: DefaultDelimiters.Comment},
+	} {
+		if _, err := engine.Delims(delims); err == nil {
+			t.Errorf("Delims(%v) should fail", delims)
+		}
+	}
+}
+
+func TestDelims_Overlapping(t *testing.T) {
+	// [[ ]] for output and [[% %]] for tags share a prefix; the longer one wins
+	engine, err := New().Delims(Delimiters{
+		Output:  [2]string{"[[", "]]"},
+		Tag:     [2]string{"[[%", "%]]"},
+		Comment: DefaultDelimiters.Comment,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := engine.Render("t", "[[% for i in range(2) %]][[ i ]][[% endfor %]]", nil)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if got != "01" {
+		t.Errorf("Render() = %q, want %q", got, "01")
+	}
+}
+
+func addPartial(t *testing.T, engine *Engine, name, text string) {
+	t.Helper()
+	tmpl, err := engine.Parse("partials/"+name+".tmpl", text)
+	if err != nil {
+		t.Fatalf("Parse(%s) error = %v", name, err)
+	}
+	engine.AddPartial(name, tmpl)
+}
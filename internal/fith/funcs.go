@@ -0,0 +1,205 @@
+package fith
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// builtinFuncs returns the functions available to every template
+func builtinFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		// Strings
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"capitalize": capitalize,
+		"title":      title,
+		"trim":       strings.TrimSpace,
+		"replace":    strings.ReplaceAll,
+		"startswith": strings.HasPrefix,
+		"endswith":   strings.HasSuffix,
+		"contains":   strings.Contains,
+		"split":      strings.Split,
+		"truncate":   truncate,
+		"repeat":     strings.Repeat,
+
+		// Lists and maps
+		"join":    join,
+		"length":  length,
+		"first":   first,
+		"last":    last,
+		"reverse": reverse,
+		"sort":    sortList,
+		"keys":    keys,
+		"range":   rangeList,
+
+		// Values
+		"default": defaultValue,
+		"string":  toString,
+		"int":     toInt,
+		"float":   toFloat,
+	}
+}
+
+// capitalize upper-cases the first letter and lower-cases the rest
+func capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + strings.ToLower(s[size:])
+}
+
+// title capitalizes every space-separated word
+func title(s string) string {
+	words := strings.Split(s, " ")
+	for i, w := range words {
+		words[i] = capitalize(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// truncate shortens s to at most n characters
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if n < 0 || len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+func join(items []interface{}, sep string) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = toString(item)
+	}
+	return strings.Join(parts, sep)
+}
+
+func length(v interface{}) (int, error) {
+	if v == nil {
+		return 0, nil
+	}
+	if s, ok := v.(string); ok {
+		return utf8.RuneCountInString(s), nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len(), nil
+	}
+	return 0, fmt.Errorf("cannot take the length of %s", typeName(v))
+}
+
+func first(items []interface{}) interface{} {
+	if len(items) == 0 {
+		return nil
+	}
+	return items[0]
+}
+
+func last(items []interface{}) interface{} {
+	if len(items) == 0 {
+		return nil
+	}
+	return items[len(items)-1]
+}
+
+func reverse(items []interface{}) []interface{} {
+	out := make([]interface{}, len(items))
+	for i, item := range items {
+		out[len(items)-1-i] = item
+	}
+	return out
+}
+
+// sortList sorts numbers numerically and everything else by its text
+func sortList(items []interface{}) []interface{} {
+	out := append([]interface{}(nil), items...)
+	sort.SliceStable(out, func(i, j int) bool {
+		c, ok := compare(out[i], out[j])
+		if !ok {
+			return toString(out[i]) < toString(out[j])
+		}
+		return c < 0
+	})
+	return out
+}
+
+// keys returns the sorted keys of a map
+func keys(v interface{}) ([]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return nil, fmt.Errorf("expected a map, got %s", typeName(v))
+	}
+	names, _, err := iterate(v)
+	return names, err
+}
+
+// maxRange limits how many numbers range returns, so a template cannot
+// exhaust memory with a huge bound
+const maxRange = 100000
+
+// rangeList returns [0, n) for one argument and [start, end) for two
+func rangeList(bounds ...int) ([]interface{}, error) {
+	var start, end int
+	switch len(bounds) {
+	case 1:
+		end = bounds[0]
+	case 2:
+		start, end = bounds[0], bounds[1]
+	default:
+		return nil, fmt.Errorf("expects 1 or 2 arguments, got %d", len(bounds))
+	}
+	// The difference is taken as unsigned, so it cannot overflow
+	if n := uint(end - start); end > start && n > maxRange {
+		return nil, fmt.Errorf("range of %d numbers exceeds the limit of %d", n, maxRange)
+	}
+	var out []interface{}
+	for i := start; i < end; i++ {
+		out = append(out, i)
+	}
+	return out, nil
+}
+
+// defaultValue returns fallback when v is undefined, none or empty
+func defaultValue(v, fallback interface{}) interface{} {
+	if v == nil || v == "" {
+		return fallback
+	}
+	return v
+}
+
+func toInt(v interface{}) (int, error) {
+	if s, ok := v.(string); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to int", s)
+		}
+		return n, nil
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		return 0, fmt.Errorf("cannot convert %s to int", typeName(v))
+	}
+	return int(n), nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+	if s, ok := v.(string); ok {
+		n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert %q to float", s)
+		}
+		return n, nil
+	}
+	n, ok := toNumber(v)
+	if !ok {
+		return 0, fmt.Errorf("cannot convert %s to float", typeName(v))
+	}
+	return n, nil
+}
@@ -0,0 +1,58 @@
+package fith
+
+import (
+	"testing"
+)
+
+func TestBuiltinFuncs(t *testing.T) {
+	data := map[string]interface{}{
+		"name":    "hello world",
+		"nums":    []int{3, 1, 2},
+		"config":  map[string]interface{}{"b": 2, "a": 1},
+		"version": "42",
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{{ name | capitalize }}", "Hello world"},
+		{"{{ name | title }}", "Hello World"},
+		{"{{ \"  x  \" | trim }}", "x"},
+		{"{{ name | truncate(5) }}", "hello"},
+		{"{{ name | startswith(\"hello\") }}", "true"},
+		{"{{ name | split(\" \") | last }}", "world"},
+		{"{{ nums | length }} {{ name | length }}", "3 11"},
+		{"{{ nums | sort | join(\",\") }}", "1,2,3"},
+		{"{{ nums | reverse | first }}", "2"},
+		{"{{ config | keys | join(\",\") }}", "a,b"},
+		{"{{ range(3) | join(\",\") }} {{ range(1, 3) | join(\",\") }}", "0,1,2 1,2"},
+		{"{{ version | int + 1 }}", "43"},
+		{"{{ \"1.5\" | float * 2 }}", "3"},
+		{"{{ \"ab\" | repeat(2) }}", "abab"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := New().Render("test", tt.template, data)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuiltinFuncErrors(t *testing.T) {
+	for _, tmpl := range []string{
+		"{{ \"abc\" | int }}",
+		"{{ 5 | length }}",
+		"{{ \"x\" | keys }}",
+	} {
+		if _, err := New().Render("test", tmpl, nil); err == nil {
+			t.Errorf("Render(%q) expected an error", tmpl)
+		}
+	}
+}
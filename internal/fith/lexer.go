@@ -0,0 +1,229 @@
+package fith
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// tokenKind identifies the kind of a template token
+type tokenKind int
+
+const (
+	tokenText   tokenKind = iota // Literal text
+	tokenRaw                     // Literal text from a {% raw %} block
+	tokenOutput                  // {{ expression }}
+	tokenTag                     // {% statement %}
+)
+
+// token is a piece of a template: literal text or the inside of a tag
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // Offset of text in the template source
+}
+
+// Delimiters are the markers around output expressions, statement tags and comments
+type Delimiters struct {
+	Output  [2]string // {{ }}
+	Tag     [2]string // {% %}
+	Comment [2]string // {# #}
+}
+
+// DefaultDelimiters are the Jinja-style markers templates use unless told otherwise
+var DefaultDelimiters = Delimiters{
+	Output:  [2]string{"{{", "}}"},
+	Tag:     [2]string{"{%", "%}"},
+	Comment: [2]string{"{#", "#}"},
+}
+
+// validate rejects empty markers and opening markers that cannot be told apart
+func (d Delimiters) validate() error {
+	pairs := [][2]string{d.Output, d.Tag, d.Comment}
+	for _, pair := range pairs {
+		if pair[0] == "" || pair[1] == "" {
+			return fmt.Errorf("delimiters must not be empty")
+		}
+	}
+	for i, a := range pairs {
+		for _, b := range pairs[i+1:] {
+			if a[0] == b[0] {
+				return fmt.Errorf("delimiter %q is used twice", a[0])
+			}
+		}
+	}
+	return nil
+}
+
+// lexer splits templates using one set of delimiters
+type lexer struct {
+	delims Delimiters
+	endRaw *regexp.Regexp // Matches the {% endraw %} tag
+}
+
+// newLexer creates a lexer for delims
+func newLexer(delims Delimiters) *lexer {
+	open, close := regexp.QuoteMeta(delims.Tag[0]), regexp.QuoteMeta(delims.Tag[1])
+	return &lexer{
+		delims: delims,
+		endRaw: regexp.MustCompile(open + `-?\s*endraw\s*-?` + close),
+	}
+}
+
+// defaultLexer lexes templates with the default delimiters
+var defaultLexer = newLexer(DefaultDelimiters)
+
+// lex splits a template into text and tag tokens.
+//
+// Comments are dropped. A {% %} or {# #} tag that is the only thing on its
+// line removes the whole line, so block statements do not leave blank lines.
+// A "-" next to a delimiter ({{- or -%}) trims whitespace on that side.
+func (l *lexer) lex(src *source) ([]token, error) {
+	text := src.text
+	var tokens []token
+
+	emitText := func(kind tokenKind, s string, pos int) {
+		if s != "" {
+			tokens = append(tokens, token{kind: kind, text: s, pos: pos})
+		}
+	}
+
+	scan := l.scanner()
+	pos := 0
+	for pos < len(text) {
+		start, kind := scan.next(text, pos)
+		if start < 0 {
+			emitText(tokenText, text[pos:], pos)
+			break
+		}
+
+		var openDelim, closeDelim string
+		switch kind {
+		case tokenOutput:
+			openDelim, closeDelim = l.delims.Output[0], l.delims.Output[1]
+		case tokenTag:
+			openDelim, closeDelim = l.delims.Tag[0], l.delims.Tag[1]
+		default:
+			openDelim, closeDelim = l.delims.Comment[0], l.delims.Comment[1]
+		}
+
+		innerStart := start + len(openDelim)
+		trimLeft := innerStart < len(text) && text[innerStart] == '-'
+		if trimLeft {
+			innerStart++
+		}
+		end := strings.Index(text[innerStart:], closeDelim)
+		if end < 0 {
+			return nil, src.errorf(start, "unclosed %q", openDelim)
+		}
+		end += innerStart
+		innerEnd := end
+		trimRight := innerEnd > innerStart && text[innerEnd-1] == '-'
+		if trimRight {
+			innerEnd--
+		}
+		after := end + len(closeDelim)
+
+		textEnd := start
+		if kind != tokenOutput && !trimLeft && !trimRight {
+			if lineStart, lineEnd, ok := standalone(text, pos, start, after); ok {
+				textEnd, after = lineStart, lineEnd
+			}
+		}
+		preceding := text[pos:textEnd]
+		if trimLeft {
+			preceding = strings.TrimRight(preceding, " \t\r\n")
+		}
+		emitText(tokenText, preceding, pos)
+
+		inner := text[innerStart:innerEnd]
+		switch kind {
+		case tokenOutput:
+			tokens = append(tokens, token{kind: tokenOutput, text: inner, pos: innerStart})
+		case tokenTag:
+			if strings.TrimSpace(inner) != "raw" {
+				tokens = append(tokens, token{kind: tokenTag, text: inner, pos: innerStart})
+				break
+			}
+			loc := l.endRaw.FindStringIndex(text[after:])
+			if loc == nil {
+				return nil, src.errorf(start, "unclosed raw block, expected %s endraw %s", l.delims.Tag[0], l.delims.Tag[1])
+			}
+			rawEnd, endTagEnd := after+loc[0], after+loc[1]
+			if lineStart, lineEnd, ok := standalone(text, after, rawEnd, endTagEnd); ok {
+				rawEnd, endTagEnd = lineStart, lineEnd
+			}
+			emitText(tokenRaw, text[after:rawEnd], after)
+			after = endTagEnd
+		}
+
+		if trimRight {
+			for after < len(text) && strings.IndexByte(" \t\r\n", text[after]) >= 0 {
+				after++
+			}
+		}
+		pos = after
+	}
+
+	return tokens, nil
+}
+
+// scanner finds opening delimiters, remembering where each one next occurs so
+// that a delimiter missing from the rest of the template is searched for once
+type scanner struct {
+	opens [3]string
+	kinds [3]tokenKind
+	found [3]int // Offset of the next occurrence; -1 if none, -2 if not searched yet
+}
+
+// scanner creates a scanner over the opening delimiters
+func (l *lexer) scanner() *scanner {
+	return &scanner{
+		opens: [3]string{l.delims.Output[0], l.delims.Tag[0], l.delims.Comment[0]},
+		// Comments are reported as tokenText, as they produce no token
+		kinds: [3]tokenKind{tokenOutput, tokenTag, tokenText},
+		found: [3]int{-2, -2, -2},
+	}
+}
+
+// next returns the offset and kind of the next opening delimiter at or after pos.
+// When opening delimiters overlap, the longest one wins.
+func (s *scanner) next(text string, pos int) (int, tokenKind) {
+	best, bestKind, bestLen := -1, tokenText, 0
+	for i, open := range s.opens {
+		if s.found[i] == -2 || (s.found[i] >= 0 && s.found[i] < pos) {
+			s.found[i] = strings.Index(text[pos:], open)
+			if s.found[i] >= 0 {
+				s.found[i] += pos
+			}
+		}
+		at := s.found[i]
+		if at < 0 {
+			continue
+		}
+		if best < 0 || at < best || (at == best && len(open) > bestLen) {
+			best, bestKind, bestLen = at, s.kinds[i], len(open)
+		}
+	}
+	return best, bestKind
+}
+
+// standalone reports whether the tag spanning [start, end) is alone on its line.
+// If so, it returns the start of the line and the offset just past its newline.
+// Text before min is already consumed and never counts as part of the line.
+func standalone(text string, min, start, end int) (int, int, bool) {
+	lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+	if lineStart < min || strings.Trim(text[lineStart:start], " \t") != "" {
+		return 0, 0, false
+	}
+
+	lineEnd := len(text)
+	if nl := strings.IndexByte(text[end:], '\n'); nl >= 0 {
+		lineEnd = end + nl + 1
+	}
+	if strings.Trim(text[end:lineEnd], " \t\r\n") != "" {
+		return 0, 0, false
+	}
+
+	return lineStart, lineEnd, true
+}
@@ -0,0 +1,785 @@
+package fith
+
+import (
+	"strings"
+)
+
+// node is a statement of a parsed template
+type node interface{}
+
+type textNode struct {
+	text string
+	raw  bool
+	pos  int
+}
+
+type outputNode struct {
+	expr expr
+}
+
+type ifNode struct {
+	conds    []expr
+	bodies   [][]node
+	elseBody []node
+}
+
+type forNode struct {
+	key      string // Set only for "for key, value in ..."
+	value    string
+	iter     expr
+	body     []node
+	elseBody []node
+}
+
+type setNode struct {
+	name  string
+	value expr
+}
+
+// includeNode renders a partial with the current variables
+type includeNode struct {
+	name expr
+	pos  int
+}
+
+// extendsNode makes a template a child of the layout it names
+type extendsNode struct {
+	name expr
+	pos  int
+}
+
+// blockNode is a named section that a child template can override
+type blockNode struct {
+	name string
+	body []node
+	pos  int
+}
+
+// expr is a node of an expression
+type expr interface {
+	position() int
+}
+
+type literalExpr struct {
+	value interface{}
+	pos   int
+}
+
+type nameExpr struct {
+	name string
+	pos  int
+}
+
+type attrExpr struct {
+	target expr
+	name   string
+	pos    int
+}
+
+type indexExpr struct {
+	target expr
+	index  expr
+	pos    int
+}
+
+// callExpr is a function call; filters are calls with the filtered value first
+type callExpr struct {
+	name   string
+	args   []expr
+	filter bool
+	pos    int
+}
+
+type unaryExpr struct {
+	op  string
+	x   expr
+	pos int
+}
+
+type binaryExpr struct {
+	op          string
+	left, right expr
+	pos         int
+}
+
+type testExpr struct {
+	x      expr
+	name   string
+	negate bool
+	pos    int
+}
+
+type listExpr struct {
+	items []expr
+	pos   int
+}
+
+func (e *literalExpr) position() int { return e.pos }
+func (e *nameExpr) position() int    { return e.pos }
+func (e *attrExpr) position() int    { return e.pos }
+func (e *indexExpr) position() int   { return e.pos }
+func (e *callExpr) position() int    { return e.pos }
+func (e *unaryExpr) position() int   { return e.pos }
+func (e *binaryExpr) position() int  { return e.pos }
+func (e *testExpr) position() int    { return e.pos }
+func (e *listExpr) position() int    { return e.pos }
+
+// tests lists the names accepted after "is"
+var tests = map[string]bool{
+	"defined": true, "undefined": true, "none": true, "empty": true,
+	"even": true, "odd": true, "string": true, "number": true,
+}
+
+// parser builds the node tree of a template
+type parser struct {
+	src    *source
+	tokens []token
+	i      int
+	funcs  map[string]interface{}
+	nested int                   // Depth of the statement being parsed
+	blocks map[string]*blockNode // Every block, by name
+	extend *extendsNode          // The {% extends %} tag, if any
+}
+
+// parse parses a whole template
+func parse(src *source, lx *lexer, funcs map[string]interface{}) (*Template, error) {
+	tokens, err := lx.lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{src: src, tokens: tokens, funcs: funcs, blocks: make(map[string]*blockNode)}
+	nodes, _, err := p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+	return &Template{src: src, nodes: nodes, funcs: funcs, blocks: p.blocks, extends: p.extend}, nil
+}
+
+// parseBody parses nodes until a tag whose keyword is one of terminators.
+// It returns the terminating tag, or nil at the end of the template.
+func (p *parser) parseBody(terminators ...string) ([]node, *token, error) {
+	var nodes []node
+
+	for p.i < len(p.tokens) {
+		tok := p.tokens[p.i]
+		p.i++
+
+		switch tok.kind {
+		case tokenText, tokenRaw:
+			nodes = append(nodes, &textNode{text: tok.text, raw: tok.kind == tokenRaw, pos: tok.pos})
+
+		case tokenOutput:
+			e, err := p.parseTagExpr(tok.text, tok.pos)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, &outputNode{expr: e})
+
+		case tokenTag:
+			keyword := tagKeyword(tok.text)
+			for _, t := range terminators {
+				if keyword == t {
+					return nodes, &tok, nil
+				}
+			}
+
+			n, err := p.parseStatement(tok, keyword)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes = append(nodes, n)
+		}
+	}
+
+	return nodes, nil, nil
+}
+
+// parseStatement parses a {% %} tag
+func (p *parser) parseStatement(tok token, keyword string) (node, error) {
+	switch keyword {
+	case "if":
+		p.nested++
+		defer func() { p.nested-- }()
+		return p.parseIf(tok)
+	case "for":
+		p.nested++
+		defer func() { p.nested-- }()
+		return p.parseFor(tok)
+	case "set":
+		return p.parseSet(tok)
+	case "include":
+		argsSrc, argsPos := tagArgs(tok)
+		name, err := p.parseTagExpr(argsSrc, argsPos)
+		if err != nil {
+			return nil, err
+		}
+		return &includeNode{name: name, pos: tok.pos}, nil
+	case "extends":
+		return p.parseExtends(tok)
+	case "block":
+		p.nested++
+		defer func() { p.nested-- }()
+		return p.parseBlock(tok)
+	case "elif", "else", "endif", "endfor", "endblock":
+		return nil, p.src.errorf(tok.pos, "unexpected {%% %s %%}", strings.TrimSpace(tok.text))
+	case "":
+		return nil, p.src.errorf(tok.pos, "empty tag")
+	default:
+		return nil, p.src.errorf(tok.pos+strings.Index(tok.text, keyword), "unknown tag %q", keyword)
+	}
+}
+
+func (p *parser) parseIf(tok token) (node, error) {
+	n := &ifNode{}
+	current := tok
+
+	for {
+		keyword := tagKeyword(current.text)
+		if keyword == "else" {
+			body, end, err := p.parseBody("endif")
+			if err != nil {
+				return nil, err
+			}
+			if end == nil {
+				return nil, p.src.errorf(tok.pos, "unclosed {%% if %%}, expected {%% endif %%}")
+			}
+			n.elseBody = body
+			return n, nil
+		}
+
+		condSrc, condPos := tagArgs(current)
+		cond, err := p.parseTagExpr(condSrc, condPos)
+		if err != nil {
+			return nil, err
+		}
+
+		body, end, err := p.parseBody("elif", "else", "endif")
+		if err != nil {
+			return nil, err
+		}
+		if end == nil {
+			return nil, p.src.errorf(tok.pos, "unclosed {%% if %%}, expected {%% endif %%}")
+		}
+		n.conds = append(n.conds, cond)
+		n.bodies = append(n.bodies, body)
+
+		if tagKeyword(end.text) == "endif" {
+			return n, nil
+		}
+		current = *end
+	}
+}
+
+func (p *parser) parseFor(tok token) (node, error) {
+	argsSrc, argsPos := tagArgs(tok)
+	toks, err := lexExpr(p.src, argsSrc, argsPos)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &forNode{}
+	ep := &exprParser{src: p.src, toks: toks, funcs: p.funcs}
+
+	first, err := ep.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	n.value = first
+	if ep.acceptOp(",") {
+		second, err := ep.expectIdent()
+		if err != nil {
+			return nil, err
+		}
+		n.key, n.value = first, second
+	}
+	if !ep.acceptKeyword("in") {
+		return nil, p.src.errorf(ep.peek().pos, "expected \"in\" in for loop")
+	}
+	if n.iter, err = ep.parseFull(); err != nil {
+		return nil, err
+	}
+
+	body, end, err := p.parseBody("else", "endfor")
+	if err != nil {
+		return nil, err
+	}
+	if end == nil {
+		return nil, p.src.errorf(tok.pos, "unclosed {%% for %%}, expected {%% endfor %%}")
+	}
+	n.body = body
+
+	if tagKeyword(end.text) == "else" {
+		elseBody, end, err := p.parseBody("endfor")
+		if err != nil {
+			return nil, err
+		}
+		if end == nil {
+			return nil, p.src.errorf(tok.pos, "unclosed {%% for %%}, expected {%% endfor %%}")
+		}
+		n.elseBody = elseBody
+	}
+
+	return n, nil
+}
+
+func (p *parser) parseSet(tok token) (node, error) {
+	argsSrc, argsPos := tagArgs(tok)
+	toks, err := lexExpr(p.src, argsSrc, argsPos)
+	if err != nil {
+		return nil, err
+	}
+
+	ep := &exprParser{src: p.src, toks: toks, funcs: p.funcs}
+	name, err := ep.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if !ep.acceptOp("=") {
+		return nil, p.src.errorf(ep.peek().pos, "expected \"=\" after {%% set %s", name)
+	}
+	value, err := ep.parseFull()
+	if err != nil {
+		return nil, err
+	}
+
+	return &setNode{name: name, value: value}, nil
+}
+
+func (p *parser) parseExtends(tok token) (node, error) {
+	if p.nested > 0 {
+		return nil, p.src.errorf(tok.pos, "{%% extends %%} must be at the top level of a template")
+	}
+	if p.extend != nil {
+		return nil, p.src.errorf(tok.pos, "a template can only extend one layout")
+	}
+
+	argsSrc, argsPos := tagArgs(tok)
+	name, err := p.parseTagExpr(argsSrc, argsPos)
+	if err != nil {
+		return nil, err
+	}
+	p.extend = &extendsNode{name: name, pos: tok.pos}
+	return p.extend, nil
+}
+
+func (p *parser) parseBlock(tok token) (node, error) {
+	argsSrc, argsPos := tagArgs(tok)
+	toks, err := lexExpr(p.src, argsSrc, argsPos)
+	if err != nil {
+		return nil, err
+	}
+	ep := &exprParser{src: p.src, toks: toks, funcs: p.funcs}
+	name, err := ep.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if tok := ep.peek(); tok.kind != exprEOF {
+		return nil, p.src.errorf(tok.pos, "unexpected %s after block name", describe(tok))
+	}
+	if _, ok := p.blocks[name]; ok {
+		return nil, p.src.errorf(tok.pos, "block %q is defined twice", name)
+	}
+
+	n := &blockNode{name: name, pos: tok.pos}
+	p.blocks[name] = n
+
+	body, end, err := p.parseBody("endblock")
+	if err != nil {
+		return nil, err
+	}
+	if end == nil {
+		return nil, p.src.errorf(tok.pos, "unclosed {%% block %s %%}, expected {%% endblock %%}", name)
+	}
+	if endName := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(end.text), "endblock")); endName != "" && endName != name {
+		return nil, p.src.errorf(end.pos, "{%% endblock %s %%} closes block %q", endName, name)
+	}
+	n.body = body
+	return n, nil
+}
+
+// parseTagExpr parses the full source of a tag as one expression
+func (p *parser) parseTagExpr(s string, pos int) (expr, error) {
+	toks, err := lexExpr(p.src, s, pos)
+	if err != nil {
+		return nil, err
+	}
+	ep := &exprParser{src: p.src, toks: toks, funcs: p.funcs}
+	return ep.parseFull()
+}
+
+// tagKeyword returns the first word of a tag
+func tagKeyword(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// tagArgs returns the source after a tag's keyword and its offset
+func tagArgs(tok token) (string, int) {
+	keyword := tagKeyword(tok.text)
+	idx := strings.Index(tok.text, keyword) + len(keyword)
+	return tok.text[idx:], tok.pos + idx
+}
+
+// exprParser parses an expression with precedence climbing
+type exprParser struct {
+	src   *source
+	toks  []exprToken
+	i     int
+	funcs map[string]interface{}
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.toks[p.i]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.toks[p.i]
+	if tok.kind != exprEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *exprParser) acceptOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.kind != exprOp {
+		return false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.i++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) acceptKeyword(word string) bool {
+	tok := p.peek()
+	if tok.kind == exprIdent && tok.text == word {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.src.errorf(p.peek().pos, "expected %q, found %s", op, describe(p.peek()))
+	}
+	return nil
+}
+
+func (p *exprParser) expectIdent() (string, error) {
+	tok := p.next()
+	if tok.kind != exprIdent {
+		return "", p.src.errorf(tok.pos, "expected a name, found %s", describe(tok))
+	}
+	return tok.text, nil
+}
+
+// parseFull parses an expression that must use all remaining tokens
+func (p *exprParser) parseFull() (expr, error) {
+	if p.peek().kind == exprEOF {
+		return nil, p.src.errorf(p.peek().pos, "expected an expression")
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != exprEOF {
+		return nil, p.src.errorf(tok.pos, "unexpected %s", describe(tok))
+	}
+	return e, nil
+}
+
+func (p *exprParser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		pos := p.peek().pos
+		if !p.acceptKeyword("or") && !p.acceptOp("||") {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "or", left: left, right: right, pos: pos}
+	}
+}
+
+func (p *exprParser) parseAnd() (expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		pos := p.peek().pos
+		if !p.acceptKeyword("and") && !p.acceptOp("&&") {
+			return left, nil
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: "and", left: left, right: right, pos: pos}
+	}
+}
+
+func (p *exprParser) parseNot() (expr, error) {
+	pos := p.peek().pos
+	if p.acceptKeyword("not") || p.acceptOp("!") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "not", x: x, pos: pos}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (expr, error) {
+	left, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		var op string
+		switch {
+		case tok.kind == exprOp && (tok.text == "==" || tok.text == "!=" || tok.text == "<" ||
+			tok.text == "<=" || tok.text == ">" || tok.text == ">="):
+			op = tok.text
+			p.i++
+		case tok.kind == exprIdent && tok.text == "in":
+			op = "in"
+			p.i++
+		case tok.kind == exprIdent && tok.text == "not" && p.toks[p.i+1].kind == exprIdent && p.toks[p.i+1].text == "in":
+			op = "not in"
+			p.i += 2
+		case tok.kind == exprIdent && tok.text == "is":
+			p.i++
+			negate := p.acceptKeyword("not")
+			nameTok := p.next()
+			if nameTok.kind != exprIdent || !tests[nameTok.text] {
+				return nil, p.src.errorf(nameTok.pos, "unknown test %s", describe(nameTok))
+			}
+			left = &testExpr{x: left, name: nameTok.text, negate: negate, pos: tok.pos}
+			continue
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: op, left: left, right: right, pos: tok.pos}
+	}
+}
+
+func (p *exprParser) parseConcat() (expr, error) {
+	return p.parseBinary(p.parseAdditive, "~")
+}
+
+func (p *exprParser) parseAdditive() (expr, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *exprParser) parseMultiplicative() (expr, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+// parseBinary parses a left-associative chain of the given operators
+func (p *exprParser) parseBinary(operand func() (expr, error), ops ...string) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if !p.acceptOp(ops...) {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: tok.text, left: left, right: right, pos: tok.pos}
+	}
+}
+
+func (p *exprParser) parseUnary() (expr, error) {
+	pos := p.peek().pos
+	if p.acceptOp("-") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "-", x: x, pos: pos}, nil
+	}
+	return p.parseFilter()
+}
+
+func (p *exprParser) parseFilter() (expr, error) {
+	x, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.acceptOp("|") {
+		nameTok := p.next()
+		if nameTok.kind != exprIdent {
+			return nil, p.src.errorf(nameTok.pos, "expected a filter name, found %s", describe(nameTok))
+		}
+		if _, ok := p.funcs[nameTok.text]; !ok {
+			return nil, p.src.errorf(nameTok.pos, "unknown filter %q", nameTok.text)
+		}
+		call := &callExpr{name: nameTok.text, args: []expr{x}, filter: true, pos: nameTok.pos}
+		if p.acceptOp("(") {
+			args, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, args...)
+		}
+		x = call
+	}
+
+	return x, nil
+}
+
+func (p *exprParser) parsePostfix() (expr, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.peek()
+		switch {
+		case p.acceptOp("."):
+			field := p.next()
+			if field.kind != exprIdent && field.kind != exprNumber {
+				return nil, p.src.errorf(field.pos, "expected a field name after \".\", found %s", describe(field))
+			}
+			x = &attrExpr{target: x, name: field.text, pos: field.pos}
+		case p.acceptOp("["):
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp("]"); err != nil {
+				return nil, err
+			}
+			x = &indexExpr{target: x, index: index, pos: tok.pos}
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (expr, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case exprNumber:
+		value, err := parseNumber(tok.text)
+		if err != nil {
+			return nil, p.src.errorf(tok.pos, "invalid number %q", tok.text)
+		}
+		return &literalExpr{value: value, pos: tok.pos}, nil
+
+	case exprString:
+		return &literalExpr{value: tok.text, pos: tok.pos}, nil
+
+	case exprIdent:
+		switch tok.text {
+		case "true", "True":
+			return &literalExpr{value: true, pos: tok.pos}, nil
+		case "false", "False":
+			return &literalExpr{value: false, pos: tok.pos}, nil
+		case "none", "None", "nil":
+			return &literalExpr{value: nil, pos: tok.pos}, nil
+		}
+		if p.acceptOp("(") {
+			if _, ok := p.funcs[tok.text]; !ok {
+				return nil, p.src.errorf(tok.pos, "unknown function %q", tok.text)
+			}
+			args, err := p.parseArgs(")")
+			if err != nil {
+				return nil, err
+			}
+			return &callExpr{name: tok.text, args: args, pos: tok.pos}, nil
+		}
+		return &nameExpr{name: tok.text, pos: tok.pos}, nil
+
+	case exprOp:
+		switch tok.text {
+		case "(":
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		case "[":
+			items, err := p.parseArgs("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listExpr{items: items, pos: tok.pos}, nil
+		case ".":
+			if next := p.peek(); next.kind == exprIdent {
+				return nil, p.src.errorf(tok.pos,
+					"unexpected \".%s\": Fíth refers to variables without a leading dot ({{ %s }}); "+
+						"templates written in Go-template syntax need `template_engine: go-template`", next.text, next.text)
+			}
+		}
+	}
+
+	return nil, p.src.errorf(tok.pos, "unexpected %s", describe(tok))
+}
+
+// parseArgs parses a comma-separated list up to the closing delimiter
+func (p *exprParser) parseArgs(closing string) ([]expr, error) {
+	var args []expr
+	if p.acceptOp(closing) {
+		return args, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.acceptOp(closing) {
+			return args, nil
+		}
+		if err := p.expectOp(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// describe names a token for error messages
+func describe(tok exprToken) string {
+	switch tok.kind {
+	case exprEOF:
+		return "end of expression"
+	case exprString:
+		return "string " + `"` + tok.text + `"`
+	default:
+		return `"` + tok.text + `"`
+	}
+}
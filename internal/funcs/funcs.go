@@ -0,0 +1,138 @@
+// Package funcs is the helper library rituals' templates share, and the
+// generators and hook tasks that build the same names in Go code.
+//
+// GoTemplate and Fith return the library for each engine. Functions that take
+// the value being transformed put it last for Go templates, so it can be piped
+// ([[ .name | default "app" ]]), and first for Fíth, where a filter passes it
+// first ({{ name | default("app") }}). env reads only the environment
+// variables given to Env; the engines start with none allowed.
+package funcs
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// GoTemplate returns the library for Go templates, value last
+func GoTemplate() map[string]interface{} {
+	return map[string]interface{}{
+		// Values
+		"default": func(fallback, value interface{}) interface{} {
+			return Default(value, fallback)
+		},
+		"coalesce": Coalesce,
+		"empty":    Empty,
+		"ternary": func(yes, no interface{}, cond bool) interface{} {
+			return Ternary(cond, yes, no)
+		},
+
+		// Strings
+		"pluralize":   Pluralize,
+		"singularize": Singularize,
+		"quote":       Quote,
+		"indent": func(n int, s string) string {
+			return Indent(s, n)
+		},
+		"nindent": func(n int, s string) string {
+			return Nindent(s, n)
+		},
+		"join":  Join,
+		"split": Split,
+
+		// Lists and maps
+		"list":   List,
+		"dict":   Dict,
+		"hasKey": HasKey,
+
+		// Encoding and the rest
+		"toJson":        ToJSON,
+		"toYaml":        ToYAML,
+		"semverCompare": SemverCompare,
+		"uuid":          UUID,
+		"env":           Env(nil),
+	}
+}
+
+// Fith returns the library for Fíth templates, value first. Fíth's own
+// default, join and split already take the value first and are kept.
+func Fith() map[string]interface{} {
+	return map[string]interface{}{
+		"coalesce":    Coalesce,
+		"ternary":     Ternary,
+		"pluralize":   Pluralize,
+		"singularize": Singularize,
+		"quote":       Quote,
+		"indent":      Indent,
+		"nindent":     Nindent,
+		"list":        List,
+		"dict":        Dict,
+		"hasKey":      HasKey,
+		"toJson":      ToJSON,
+		"toYaml":      ToYAML,
+		"semverCompare": func(version, constraint string) (bool, error) {
+			return SemverCompare(constraint, version)
+		},
+		"uuid": UUID,
+		"env":  Env(nil),
+	}
+}
+
+// Env returns an env function reading only the environment variables named in allowed
+func Env(allowed []string) func(name string) (string, error) {
+	allowed = slices.Clone(allowed)
+	return func(name string) (string, error) {
+		if !slices.Contains(allowed, name) {
+			return "", fmt.Errorf("environment variable %s is not allowed; list it under ritual.env", name)
+		}
+		return os.Getenv(name), nil
+	}
+}
+
+// ToJSON encodes v as compact JSON
+func ToJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// ToYAML encodes v as YAML, without the final newline
+func ToYAML(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// SemverCompare reports whether version satisfies constraint, like ">= 1.2, < 2"
+func SemverCompare(constraint, version string) (bool, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return false, fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false, fmt.Errorf("invalid version %q: %w", version, err)
+	}
+	return c.Check(v), nil
+}
+
+// UUID returns a random (version 4) UUID
+func UUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate UUID: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
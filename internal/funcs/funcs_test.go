@@ -0,0 +1,92 @@
+package funcs
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestGoTemplate(t *testing.T) {
+	data := map[string]interface{}{
+		"name":    "blog",
+		"empty":   "",
+		"admin":   true,
+		"tags":    []string{"go", "web"},
+		"config":  map[string]interface{}{"port": 8080, "hosts": []string{"a", "b"}},
+		"version": "1.4.2",
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{{ .empty | default "app" }} {{ .name | default "app" }} {{ .missing | default 3 }}`, "app blog 3"},
+		{`{{ coalesce .empty .missing .name }}`, "blog"},
+		{`{{ ternary "on" "off" .admin }}`, "on"},
+		{`{{ pluralize "category" }} {{ singularize "BlogPosts" }}`, "categories BlogPost"},
+		{`{{ .name | quote }}`, `"blog"`},
+		{`{{ join ", " .tags }} {{ split "," "a,b" | len }}`, "go, web 2"},
+		{`{{ hasKey .config "port" }} {{ hasKey .config "host" }}`, "true false"},
+		{`{{ $l := list 1 "two" }}{{ len $l }}`, "2"},
+		{`{{ (dict "a" 1 "b" .name).b }}`, "blog"},
+		{`{{ toJson .config }}`, `{"hosts":["a","b"],"port":8080}`},
+		{`hosts:{{ .config.hosts | toYaml | nindent 2 }}`, "hosts:\n  - a\n  - b"},
+		{`{{ "a\nb" | indent 4 }}`, "    a\n    b"},
+		{`{{ semverCompare ">=1.4, <2" .version }} {{ semverCompare "^2" .version }}`, "true false"},
+		{`{{ empty .empty }} {{ empty .name }}`, "true false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, err := template.New("test").Funcs(GoTemplate()).Parse(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			if err := tmpl.Execute(&out, data); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("RITUAL_TEST_HOST", "db.local")
+	t.Setenv("RITUAL_TEST_SECRET", "hunter2")
+
+	env := Env([]string{"RITUAL_TEST_HOST"})
+	if got, err := env("RITUAL_TEST_HOST"); err != nil || got != "db.local" {
+		t.Errorf("env(allowed) = %q, %v", got, err)
+	}
+	if _, err := env("RITUAL_TEST_SECRET"); err == nil || !strings.Contains(err.Error(), "ritual.env") {
+		t.Errorf("env(not allowed) error = %v", err)
+	}
+	if _, err := GoTemplate()["env"].(func(string) (string, error))("RITUAL_TEST_HOST"); err == nil {
+		t.Error("the library should allow no environment variables by default")
+	}
+}
+
+func TestDict_OddArguments(t *testing.T) {
+	if _, err := Dict("a", 1, "b"); err == nil {
+		t.Error("expected an error for a key without a value")
+	}
+	if _, err := Dict(1, "a"); err == nil {
+		t.Error("expected an error for a key that is not a string")
+	}
+}
+
+func TestUUID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, err := UUID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := UUID()
+	if !pattern.MatchString(first) || first == second {
+		t.Errorf("UUID() = %s, %s", first, second)
+	}
+}
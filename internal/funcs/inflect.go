@@ -0,0 +1,179 @@
+package funcs
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// irregular maps singular words to plurals the suffix rules get wrong, and
+// is looked up the other way round to singularize them
+var irregular = map[string]string{
+	"person":    "people",
+	"man":       "men",
+	"woman":     "women",
+	"child":     "children",
+	"tooth":     "teeth",
+	"foot":      "feet",
+	"mouse":     "mice",
+	"goose":     "geese",
+	"ox":        "oxen",
+	"quiz":      "quizzes",
+	"alias":     "aliases",
+	"status":    "statuses",
+	"bus":       "buses",
+	"virus":     "viruses",
+	"campus":    "campuses",
+	"cache":     "caches",
+	"movie":     "movies",
+	"cookie":    "cookies",
+	"knife":     "knives",
+	"wife":      "wives",
+	"life":      "lives",
+	"leaf":      "leaves",
+	"wolf":      "wolves",
+	"half":      "halves",
+	"shelf":     "shelves",
+	"thief":     "thieves",
+	"hero":      "heroes",
+	"potato":    "potatoes",
+	"tomato":    "tomatoes",
+	"echo":      "echoes",
+	"axis":      "axes",
+	"crisis":    "crises",
+	"thesis":    "theses",
+	"basis":     "bases",
+	"index":     "indexes",
+	"vertex":    "vertices",
+	"matrix":    "matrices",
+	"medium":    "media",
+	"datum":     "data",
+	"criterion": "criteria",
+}
+
+// uncountable words are their own plural
+var uncountable = map[string]bool{
+	"equipment":   true,
+	"information": true,
+	"metadata":    true,
+	"feedback":    true,
+	"software":    true,
+	"news":        true,
+	"series":      true,
+	"species":     true,
+	"sheep":       true,
+	"fish":        true,
+	"money":       true,
+	"rice":        true,
+	"staff":       true,
+}
+
+// singulars reverses irregular
+var singulars = func() map[string]string {
+	m := make(map[string]string, len(irregular))
+	for singular, plural := range irregular {
+		m[plural] = singular
+	}
+	return m
+}()
+
+// Pluralize returns the English plural of word. Only the last word of a
+// compound like blog_post or BlogPost changes, keeping its case.
+func Pluralize(word string) string {
+	return inflect(word, func(w string) string {
+		if plural, ok := irregular[w]; ok {
+			return plural
+		}
+		if _, ok := singulars[w]; ok {
+			return w
+		}
+		switch {
+		case strings.HasSuffix(w, "sis"):
+			return w[:len(w)-2] + "es"
+		case endsWithConsonantY(w):
+			return w[:len(w)-1] + "ies"
+		case strings.HasSuffix(w, "s"), strings.HasSuffix(w, "x"), strings.HasSuffix(w, "z"),
+			strings.HasSuffix(w, "ch"), strings.HasSuffix(w, "sh"):
+			return w + "es"
+		}
+		return w + "s"
+	})
+}
+
+// Singularize returns the English singular of word, the reverse of Pluralize
+func Singularize(word string) string {
+	return inflect(word, func(w string) string {
+		if singular, ok := singulars[w]; ok {
+			return singular
+		}
+		if _, ok := irregular[w]; ok {
+			return w
+		}
+		switch {
+		case strings.HasSuffix(w, "yses"):
+			return w[:len(w)-2] + "is"
+		case strings.HasSuffix(w, "ies") && len(w) > 4:
+			return w[:len(w)-3] + "y"
+		case strings.HasSuffix(w, "sses"), strings.HasSuffix(w, "xes"), strings.HasSuffix(w, "zzes"),
+			strings.HasSuffix(w, "ches"), strings.HasSuffix(w, "shes"):
+			return w[:len(w)-2]
+		case strings.HasSuffix(w, "ss"), strings.HasSuffix(w, "us"), strings.HasSuffix(w, "is"):
+			return w
+		case strings.HasSuffix(w, "s"):
+			return w[:len(w)-1]
+		}
+		return w
+	})
+}
+
+// Capitalize upper-cases the first letter of s and keeps the rest
+func Capitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// inflect applies fn to the lower-cased last word of word, then restores its case
+func inflect(word string, fn func(string) string) string {
+	start := lastWordStart(word)
+	last := word[start:]
+	lower := strings.ToLower(last)
+	if lower == "" || uncountable[lower] {
+		return word
+	}
+
+	changed := fn(lower)
+	switch {
+	case last == strings.ToUpper(last) && len(last) > 1:
+		changed = strings.ToUpper(changed)
+	case unicode.IsUpper([]rune(last)[0]):
+		changed = Capitalize(changed)
+	}
+	return word[:start] + changed
+}
+
+// lastWordStart returns where the last word of a snake, kebab, spaced or
+// camel case compound starts
+func lastWordStart(word string) int {
+	start := strings.LastIndexAny(word, "_- ") + 1
+	rest := word[start:]
+	if rest == strings.ToUpper(rest) {
+		return start // All caps, like USERS
+	}
+	for i := len(rest) - 1; i > 0; i-- {
+		if unicode.IsUpper(rune(rest[i])) {
+			return start + i
+		}
+	}
+	return start
+}
+
+// endsWithConsonantY reports whether w ends in a y after a consonant, like city
+func endsWithConsonantY(w string) bool {
+	if len(w) < 2 || !strings.HasSuffix(w, "y") {
+		return false
+	}
+	return !strings.ContainsRune("aeiou", rune(w[len(w)-2]))
+}
@@ -0,0 +1,64 @@
+package funcs
+
+import "testing"
+
+func TestPluralizeSingularize(t *testing.T) {
+	tests := []struct {
+		singular string
+		plural   string
+	}{
+		{"post", "posts"},
+		{"category", "categories"},
+		{"day", "days"},
+		{"class", "classes"},
+		{"box", "boxes"},
+		{"branch", "branches"},
+		{"status", "statuses"},
+		{"address", "addresses"},
+		{"analysis", "analyses"},
+		{"person", "people"},
+		{"child", "children"},
+		{"knife", "knives"},
+		{"move", "moves"},
+		{"size", "sizes"},
+		{"response", "responses"},
+		{"news", "news"},
+		{"blog_post", "blog_posts"},
+		{"BlogPost", "BlogPosts"},
+		{"UserCategory", "UserCategories"},
+		{"user-person", "user-people"},
+		{"USER", "USERS"},
+		{"Person", "People"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.singular, func(t *testing.T) {
+			if got := Pluralize(tt.singular); got != tt.plural {
+				t.Errorf("Pluralize(%q) = %q, want %q", tt.singular, got, tt.plural)
+			}
+			if got := Singularize(tt.plural); got != tt.singular {
+				t.Errorf("Singularize(%q) = %q, want %q", tt.plural, got, tt.singular)
+			}
+		})
+	}
+}
+
+func TestInflect_KeepsWordsAlreadyInflected(t *testing.T) {
+	if got := Pluralize("people"); got != "people" {
+		t.Errorf("Pluralize(people) = %q", got)
+	}
+	if got := Singularize("status"); got != "status" {
+		t.Errorf("Singularize(status) = %q", got)
+	}
+	if got := Singularize("post"); got != "post" {
+		t.Errorf("Singularize(post) = %q", got)
+	}
+}
+
+func TestCapitalize(t *testing.T) {
+	for in, want := range map[string]string{"user": "User", "userProfile": "UserProfile", "": "", "élan": "Élan"} {
+		if got := Capitalize(in); got != want {
+			t.Errorf("Capitalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package funcs
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Empty reports whether v is nil, false, zero, or an empty string, list or map
+func Empty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
+
+// Default returns value, or fallback when value is empty
+func Default(value, fallback interface{}) interface{} {
+	if Empty(value) {
+		return fallback
+	}
+	return value
+}
+
+// Coalesce returns the first value that is not empty, or nil
+func Coalesce(values ...interface{}) interface{} {
+	for _, v := range values {
+		if !Empty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+// Ternary returns yes if cond holds and no otherwise
+func Ternary(cond bool, yes, no interface{}) interface{} {
+	if cond {
+		return yes
+	}
+	return no
+}
+
+// Quote returns v as a double-quoted string with Go escapes
+func Quote(v interface{}) string {
+	if v == nil {
+		return `""`
+	}
+	return strconv.Quote(fmt.Sprint(v))
+}
+
+// Indent puts n spaces before every line of s that is not empty
+func Indent(s string, n int) string {
+	pad := strings.Repeat(" ", max(n, 0))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Nindent is Indent on a new line, for a block after a YAML key
+func Nindent(s string, n int) string {
+	return "\n" + Indent(s, n)
+}
+
+// Join joins the items of a list with sep
+func Join(sep string, list interface{}) (string, error) {
+	items, err := toList(list)
+	if err != nil {
+		return "", err
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = fmt.Sprint(item)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// Split splits s around each sep
+func Split(sep, s string) []string {
+	return strings.Split(s, sep)
+}
+
+// List returns its arguments as a list
+func List(items ...interface{}) []interface{} {
+	return append([]interface{}{}, items...)
+}
+
+// Dict builds a map from alternating keys and values
+func Dict(pairs ...interface{}) (map[string]interface{}, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict expects key and value pairs, got %d arguments", len(pairs))
+	}
+	dict := make(map[string]interface{}, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("dict keys must be strings, got %T", pairs[i])
+		}
+		dict[key] = pairs[i+1]
+	}
+	return dict, nil
+}
+
+// HasKey reports whether the map m has key
+func HasKey(m interface{}, key string) bool {
+	rv := reflect.ValueOf(m)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return false
+	}
+	return rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key())).IsValid()
+}
+
+// toList returns the items of a slice or array
+func toList(list interface{}) ([]interface{}, error) {
+	if list == nil {
+		return nil, nil
+	}
+	rv := reflect.ValueOf(list)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a list, got %T", list)
+	}
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = rv.Index(i).Interface()
+	}
+	return items, nil
+}
@@ -0,0 +1,333 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// ComponentSourcePrefix marks generated files the generate command added to the project
+const ComponentSourcePrefix = "generate:"
+
+// ComponentKinds are the kinds of components ComponentGenerator generates
+var ComponentKinds = []string{"model", "handler", "route", "test", "middleware", "mock"}
+
+// Layout is where a project keeps the packages of its components and its
+// migrations, as slash-separated directories relative to its root
+type Layout struct {
+	Models     string
+	Repository string
+	Handlers   string
+	Routes     string
+	Middleware string
+	Migrations string
+}
+
+// DefaultLayout returns the layout of the files generated from entities
+func DefaultLayout() Layout {
+	return Layout{
+		Models:     "internal/models",
+		Repository: "internal/repository",
+		Handlers:   "internal/handlers",
+		Routes:     "internal/routes",
+		Middleware: "internal/middleware",
+		Migrations: "migrations",
+	}
+}
+
+// InferLayout returns the layout of a project from the slash-separated paths
+// of its files. A package lives in the first directory named after it, such
+// as app/models; one no file is in is where DefaultLayout puts it.
+func InferLayout(paths []string) Layout {
+	layout := DefaultLayout()
+	dirs := map[string]*string{
+		"models":     &layout.Models,
+		"repository": &layout.Repository,
+		"handlers":   &layout.Handlers,
+		"routes":     &layout.Routes,
+		"middleware": &layout.Middleware,
+		"migrations": &layout.Migrations,
+	}
+	for _, p := range paths {
+		dir := path.Dir(p)
+		if target, ok := dirs[path.Base(dir)]; ok {
+			*target = dir
+			delete(dirs, path.Base(dir))
+		}
+	}
+	return layout
+}
+
+// withDefaults returns the layout with its empty directories as in DefaultLayout
+func (l Layout) withDefaults() Layout {
+	defaults := DefaultLayout()
+	for _, dir := range []struct {
+		dir *string
+		def string
+	}{
+		{&l.Models, defaults.Models},
+		{&l.Repository, defaults.Repository},
+		{&l.Handlers, defaults.Handlers},
+		{&l.Routes, defaults.Routes},
+		{&l.Middleware, defaults.Middleware},
+		{&l.Migrations, defaults.Migrations},
+	} {
+		if *dir.dir == "" {
+			*dir.dir = dir.def
+		}
+	}
+	return l
+}
+
+// importPath returns the import path of the package in dir of module
+func importPath(module, dir string) string {
+	return path.Join(module, dir)
+}
+
+// FromRitual reports whether a file recorded in state with source is part of
+// the ritual's output, rather than added by the scaffolder or the generate command
+func FromRitual(source string) bool {
+	return !strings.HasPrefix(source, BuiltinSourcePrefix) && !strings.HasPrefix(source, ComponentSourcePrefix)
+}
+
+// ComponentFile is a file of a component
+type ComponentFile struct {
+	Path    string // Slash-separated, relative to the project
+	Content []byte
+}
+
+// ComponentGenerator generates a single component into an existing project,
+// following the project's layout
+type ComponentGenerator struct {
+	projectPath string
+	layout      Layout
+
+	entities   *EntityGenerator
+	middleware *MiddlewareGenerator
+	tests      *TestGenerator
+}
+
+// NewComponentGenerator creates a generator for the project at projectPath
+// with module path module. Its migrations are for dialect, as those of
+// NewEntityGenerator.
+func NewComponentGenerator(projectPath, module, dialect string, layout Layout) *ComponentGenerator {
+	layout = layout.withDefaults()
+	entities := NewEntityGenerator(module, dialect, nil)
+	entities.SetLayout(layout)
+	return &ComponentGenerator{
+		projectPath: projectPath,
+		layout:      layout,
+		entities:    entities,
+		middleware:  NewMiddlewareGenerator(),
+		tests:       NewTestGenerator(),
+	}
+}
+
+// Generate returns the formatted files of the component of kind called name:
+//
+//   - model: the model, migration, repository and repository tests of the entity name, with
+//     the fields in args in the name:type shorthand of ritual.ParseEntityField
+//   - handler, route: the CRUD handler or RESTful routes of the entity name
+//   - test: the handler tests of the entity name with the fields in args
+//   - middleware: the middleware name
+//   - mock: a mock of the interface name, next to its declaration
+func (g *ComponentGenerator) Generate(kind, name string, args []string) ([]ComponentFile, error) {
+	if kind != "model" && kind != "test" && len(args) > 0 {
+		return nil, fmt.Errorf("%s takes no fields, got %s", kind, strings.Join(args, " "))
+	}
+
+	var files []EntityFile
+	switch kind {
+	case "model", "handler", "route", "test":
+		entity := ritual.Entity{Name: name}
+		for _, arg := range args {
+			field, err := ritual.ParseEntityField(arg)
+			if err != nil {
+				return nil, err
+			}
+			entity.Fields = append(entity.Fields, field)
+		}
+		if err := entity.Validate(); err != nil {
+			return nil, err
+		}
+
+		switch kind {
+		case "model":
+			number, err := g.nextMigration()
+			if err != nil {
+				return nil, err
+			}
+			tests, err := g.entities.RepositoryTestFile(entity)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, g.entities.ModelFile(entity), g.entities.MigrationFile(entity, number), g.entities.RepositoryFile(entity), tests)
+		case "handler":
+			files = append(files, g.entities.HandlerFile(entity))
+		case "route":
+			files = append(files, g.entities.RoutesFile(entity))
+		case "test":
+			file, err := g.entities.HandlerTestFile(entity)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file)
+		}
+	case "middleware":
+		if !token.IsIdentifier(name) || !token.IsExported(name) {
+			return nil, fmt.Errorf("middleware %q: name must be an exported Go identifier", name)
+		}
+		content := g.middleware.generateCustomContent(MiddlewareSpec{
+			Name:        name,
+			Description: "runs before the next handler",
+			Logic:       "// TODO: Implement middleware logic",
+		})
+		files = append(files, EntityFile{name, path.Join(g.layout.Middleware, toSnakeCase(name)+".go"), content})
+	case "mock":
+		file, err := g.mockFile(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	default:
+		return nil, fmt.Errorf("unknown component kind %q (want %s)", kind, strings.Join(ComponentKinds, ", "))
+	}
+
+	components := make([]ComponentFile, 0, len(files))
+	for _, f := range files {
+		content := []byte(f.Content)
+		if strings.HasSuffix(f.Path, ".go") {
+			formatted, err := formatGo(f.Path, content, f.Path, f.Content, 1)
+			if err != nil {
+				return nil, err
+			}
+			content = formatted
+		}
+		components = append(components, ComponentFile{Path: f.Path, Content: content})
+	}
+	return components, nil
+}
+
+// nextMigration returns the number following those of the migrations of the project
+func (g *ComponentGenerator) nextMigration() (int, error) {
+	entries, err := os.ReadDir(filepath.Join(g.projectPath, filepath.FromSlash(g.layout.Migrations)))
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	last := 0
+	for _, entry := range entries {
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		if n, err := strconv.Atoi(prefix); err == nil && n > last {
+			last = n
+		}
+	}
+	return last + 1, nil
+}
+
+// mockFile returns a mock of the interface called name, in the package
+// declaring it. The interface must list its methods rather than embed others.
+func (g *ComponentGenerator) mockFile(name string) (EntityFile, error) {
+	var (
+		fset     = token.NewFileSet()
+		file     *ast.File
+		typeSpec *ast.TypeSpec
+		iface    *ast.InterfaceType
+		dir      string
+	)
+	err := filepath.WalkDir(g.projectPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != g.projectPath && (strings.HasPrefix(d.Name(), ".") || strings.HasPrefix(d.Name(), "_") ||
+				d.Name() == "vendor" || d.Name() == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+		parsed, err := parser.ParseFile(fset, p, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil // Not ours to fix; the interface is looked for elsewhere
+		}
+		for _, decl := range parsed.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if it, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.Name == name {
+					file, typeSpec, iface = parsed, ts, it
+					dir, _ = filepath.Rel(g.projectPath, filepath.Dir(p))
+					return filepath.SkipAll
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return EntityFile{}, fmt.Errorf("failed to look for interface %s: %w", name, err)
+	}
+	if iface == nil {
+		return EntityFile{}, fmt.Errorf("interface %s not found in the project", name)
+	}
+	if typeSpec.TypeParams != nil {
+		return EntityFile{}, fmt.Errorf("interface %s is generic; only plain interfaces can be mocked", name)
+	}
+
+	spec := MockSpec{PackageName: file.Name.Name, InterfaceName: name}
+	for _, imp := range file.Imports {
+		spec.Imports = append(spec.Imports, nodeString(fset, imp))
+	}
+	for _, m := range iface.Methods.List {
+		fn, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) == 0 {
+			return EntityFile{}, fmt.Errorf("interface %s embeds %s; only interfaces listing their methods can be mocked", name, nodeString(fset, m.Type))
+		}
+		method := MockMethod{Name: m.Names[0].Name}
+		for _, param := range fn.Params.List {
+			typ := nodeString(fset, param.Type)
+			if len(param.Names) == 0 {
+				method.Parameters = append(method.Parameters, fmt.Sprintf("arg%d %s", len(method.Parameters), typ))
+			}
+			for _, n := range param.Names {
+				method.Parameters = append(method.Parameters, n.Name+" "+typ)
+			}
+		}
+		if fn.Results != nil {
+			for _, result := range fn.Results.List {
+				for range max(1, len(result.Names)) {
+					method.Returns = append(method.Returns, nodeString(fset, result.Type))
+				}
+			}
+		}
+		spec.Methods = append(spec.Methods, method)
+	}
+
+	content, err := g.tests.GenerateMockInterface(spec)
+	if err != nil {
+		return EntityFile{}, err
+	}
+	return EntityFile{name, path.Join(filepath.ToSlash(dir), "mock_"+toSnakeCase(name)+".go"), content}, nil
+}
+
+// nodeString returns the source of an AST node
+func nodeString(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	_ = printer.Fprint(&buf, fset, node)
+	return buf.String()
+}
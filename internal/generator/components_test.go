@@ -0,0 +1,151 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInferLayout(t *testing.T) {
+	layout := InferLayout([]string{
+		"cmd/server/main.go",
+		"app/models/user.go",
+		"app/http/handlers/user_handler.go",
+		"app/http/handlers/admin/dashboard.go",
+		"db/migrations/001_create_users.sql",
+	})
+	want := Layout{
+		Models:     "app/models",
+		Repository: "internal/repository",
+		Handlers:   "app/http/handlers",
+		Routes:     "internal/routes",
+		Middleware: "internal/middleware",
+		Migrations: "db/migrations",
+	}
+	if layout != want {
+		t.Errorf("InferLayout() = %+v, want %+v", layout, want)
+	}
+}
+
+func TestComponentGenerator_Model(t *testing.T) {
+	project := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(project, "db", "migrations"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"001_create_users.sql", "007_add_index.sql", "README.md"} {
+		if err := os.WriteFile(filepath.Join(project, "db", "migrations", name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	layout := Layout{Models: "app/models", Migrations: "db/migrations"}
+
+	files, err := NewComponentGenerator(project, "example.com/blog", "mysql", layout).
+		Generate("model", "Post", []string{"title:string:required", "body:text"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	generated := make(map[string]string)
+	for _, f := range files {
+		generated[f.Path] = string(f.Content)
+	}
+
+	want := map[string][]string{
+		"app/models/post.go":                     {"package models", "\tTitle string  `json:\"title\" db:\"title\"`\n"},
+		"db/migrations/008_create_posts.sql":     {"id INT AUTO_INCREMENT PRIMARY KEY", "title VARCHAR(255) NOT NULL"},
+		"internal/repository/post_repository.go": {`"example.com/blog/app/models"`, "VALUES (?, ?)", "result.LastInsertId()"},
+		"internal/repository/post_repository_test.go": {
+			"id INTEGER PRIMARY KEY",
+			"repository.NewPostRepository(db)",
+		},
+	}
+	if len(files) != len(want) {
+		t.Errorf("Generate() returned %d files, want %d", len(files), len(want))
+	}
+	for path, parts := range want {
+		for _, part := range parts {
+			if !strings.Contains(generated[path], part) {
+				t.Errorf("%s should contain %q, got:\n%s", path, part, generated[path])
+			}
+		}
+	}
+}
+
+func TestComponentGenerator_Mock(t *testing.T) {
+	project := t.TempDir()
+	source := `package store
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Store keeps posts
+type Store interface {
+	Get(ctx context.Context, id int64) (*Post, error)
+	Find(context.Context, string) ([]Post, bool)
+	Touch(at time.Time)
+	Count() (n, max int)
+}
+
+var _ io.Reader
+`
+	if err := os.MkdirAll(filepath.Join(project, "pkg", "store"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "pkg", "store", "store.go"), []byte(source), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := NewComponentGenerator(project, "example.com/blog", "", Layout{}).Generate("mock", "Store", nil)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "pkg/store/mock_store.go" {
+		t.Fatalf("Generate() = %+v, want pkg/store/mock_store.go", files)
+	}
+	content := string(files[0].Content)
+	for _, part := range []string{
+		"package store",
+		"\t\"context\"\n\t\"time\"\n)",
+		"func (m *MockStore) Get(ctx context.Context, id int64) (*Post, error) {",
+		"func (m *MockStore) Find(arg0 context.Context, arg1 string) ([]Post, bool) {",
+		"return nil, false",
+		"func (m *MockStore) Count() (int, int) {",
+		"return 0, 0",
+	} {
+		if !strings.Contains(content, part) {
+			t.Errorf("mock should contain %q, got:\n%s", part, content)
+		}
+	}
+	if strings.Contains(content, `"io"`) {
+		t.Errorf("imports the methods do not use should be dropped, got:\n%s", content)
+	}
+}
+
+func TestComponentGenerator_Invalid(t *testing.T) {
+	gen := NewComponentGenerator(t.TempDir(), "example.com/blog", "", Layout{})
+	tests := []struct {
+		name      string
+		kind      string
+		component string
+		args      []string
+		want      string
+	}{
+		{"unknown kind", "widget", "Post", nil, "unknown component kind"},
+		{"fields of a handler", "handler", "Post", []string{"title:string"}, "handler takes no fields"},
+		{"invalid field", "model", "Post", []string{"title:varchar"}, "unknown type"},
+		{"lower case entity", "model", "post", nil, "PascalCase"},
+		{"unexported middleware", "middleware", "rateLimit", nil, "exported Go identifier"},
+		{"missing interface", "mock", "Store", nil, "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gen.Generate(tt.kind, tt.component, tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Generate() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -15,10 +15,10 @@ func NewDatabaseGenerator() *DatabaseGenerator {
 
 // DBConnectionConfig holds database connection configuration
 type DBConnectionConfig struct {
-	Type     string // "mysql" or "postgres"
+	Type     string // "mysql", "postgres" or "sqlite"
 	Host     string
 	Port     int
-	Database string
+	Database string // For SQLite, the path of the database file
 	Username string
 	Password string
 	SSLMode  string // For PostgreSQL
@@ -26,20 +26,22 @@ type DBConnectionConfig struct {
 
 // TableSchema defines a database table structure
 type TableSchema struct {
-	Name    string
-	Columns []ColumnSchema
+	Name       string
+	Columns    []ColumnSchema
+	PrimaryKey []string // Columns of a composite primary key, instead of a column's
 }
 
 // ColumnSchema defines a table column
 type ColumnSchema struct {
 	Name          string
-	Type          string // "int", "string", "timestamp", "text", "bool"
+	Type          string // "int", "bigint", "float", "string", "timestamp", "text", "bool"
 	Size          int    // For string types
 	PrimaryKey    bool
 	AutoIncrement bool
 	NotNull       bool
 	Unique        bool
 	Default       string
+	References    string // Table whose id the column refers to
 }
 
 // QuerySpec defines a database query
@@ -71,21 +73,30 @@ type FieldSpec struct {
 func (g *DatabaseGenerator) GenerateConnectionCode(config DBConnectionConfig) string {
 	var code strings.Builder
 
+	dbType := config.Type
+	if dbType == "sqlite3" {
+		dbType = "sqlite"
+	}
 	code.WriteString("import (\n")
 	code.WriteString("\t\"database/sql\"\n")
-	code.WriteString("\t\"fmt\"\n\n")
+	if dbType != "sqlite" {
+		code.WriteString("\t\"fmt\"\n")
+	}
+	code.WriteString("\t\"os\"\n\n")
 
-	switch config.Type {
+	switch dbType {
 	case "mysql":
 		code.WriteString("\t_ \"github.com/go-sql-driver/mysql\"\n")
 	case "postgres":
 		code.WriteString("\t_ \"github.com/lib/pq\"\n")
+	case "sqlite":
+		code.WriteString("\t_ \"github.com/mattn/go-sqlite3\"\n")
 	}
 
 	code.WriteString(")\n\n")
 	code.WriteString("func NewDatabase() (*sql.DB, error) {\n")
 
-	switch config.Type {
+	switch dbType {
 	case "mysql":
 		code.WriteString(fmt.Sprintf("\tdsn := fmt.Sprintf(\"%%s:%%s@tcp(%%s:%d)/%%s?parseTime=true&charset=utf8mb4\",\n", config.Port))
 		code.WriteString("\t\tos.Getenv(\"DB_USER\"),\n")
@@ -107,6 +118,18 @@ func (g *DatabaseGenerator) GenerateConnectionCode(config DBConnectionConfig) st
 		code.WriteString(fmt.Sprintf("\t\t\"%s\",\n", config.Database))
 		code.WriteString("\t)\n")
 		code.WriteString("\treturn sql.Open(\"postgres\", dsn)\n")
+
+	case "sqlite":
+		// A file next to the app: no server, user or password
+		path := config.Database
+		if path == "" {
+			path = "app.db"
+		}
+		code.WriteString("\tpath := os.Getenv(\"DB_PATH\")\n")
+		code.WriteString("\tif path == \"\" {\n")
+		code.WriteString(fmt.Sprintf("\t\tpath = %q\n", path))
+		code.WriteString("\t}\n")
+		code.WriteString("\treturn sql.Open(\"sqlite3\", \"file:\"+path+\"?_foreign_keys=on&_busy_timeout=5000\")\n")
 	}
 
 	code.WriteString("}\n")
@@ -147,21 +170,44 @@ func (g *DatabaseGenerator) GenerateMigrationSQL(dbType string, schema TableSche
 		}
 	}
 
+	if len(schema.PrimaryKey) > 0 {
+		sql.WriteString(fmt.Sprintf(",\n\tPRIMARY KEY (%s)", strings.Join(schema.PrimaryKey, ", ")))
+	}
+	for _, col := range schema.Columns {
+		if col.References != "" {
+			sql.WriteString(fmt.Sprintf(",\n\tFOREIGN KEY (%s) REFERENCES %s(id)", col.Name, col.References))
+		}
+	}
+
 	sql.WriteString("\n);\n")
 
 	return sql.String()
 }
 
 func (g *DatabaseGenerator) mapTypeToSQL(dbType string, col ColumnSchema) string {
+	dbType = sqlDialect(dbType)
 	switch col.Type {
 	case "int":
 		if col.AutoIncrement {
-			if dbType == "mysql" {
+			switch dbType {
+			case "mysql":
 				return "INT AUTO_INCREMENT"
+			case "sqlite":
+				return "INTEGER" // An INTEGER PRIMARY KEY is the rowid, numbered by SQLite
 			}
 			return "SERIAL"
 		}
 		return "INT"
+	case "bigint":
+		return "BIGINT"
+	case "float":
+		switch dbType {
+		case "mysql":
+			return "DOUBLE"
+		case "sqlite":
+			return "REAL"
+		}
+		return "DOUBLE PRECISION"
 	case "string":
 		size := col.Size
 		if size == 0 {
@@ -225,7 +271,10 @@ func (g *DatabaseGenerator) GenerateQueryCode(dbType string, query QuerySpec) st
 	return code.String()
 }
 
-// GenerateRepositoryCode generates a repository interface and implementation
+// GenerateRepositoryCode generates a repository interface and implementation stub
+//
+// Deprecated: ModelGenerator.GenerateRepository generates a complete repository
+// in the SQL of a dialect.
 func (g *DatabaseGenerator) GenerateRepositoryCode(model ModelSpec, dbType string) string {
 	var code strings.Builder
 
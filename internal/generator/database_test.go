@@ -56,6 +56,23 @@ func TestDatabaseGenerator_PostgreSQL(t *testing.T) {
 	}
 }
 
+func TestDatabaseGenerator_SQLite(t *testing.T) {
+	gen := NewDatabaseGenerator()
+
+	code := gen.GenerateConnectionCode(DBConnectionConfig{Type: "sqlite3", Database: "data/app.db"})
+
+	// Verify the SQLite driver opens a file, with no server credentials
+	if !contains(code, "github.com/mattn/go-sqlite3") {
+		t.Error("Expected go-sqlite3 driver import")
+	}
+	if !contains(code, `sql.Open("sqlite3"`) || !contains(code, `"data/app.db"`) {
+		t.Error("Expected the database file to be opened with the sqlite3 driver")
+	}
+	if contains(code, "DB_USER") || contains(code, `"fmt"`) {
+		t.Error("SQLite connection should not use a user or import fmt")
+	}
+}
+
 func TestDatabaseGenerator_MigrationSQL_MySQL(t *testing.T) {
 	gen := NewDatabaseGenerator()
 
@@ -110,6 +127,31 @@ func TestDatabaseGenerator_MigrationSQL_PostgreSQL(t *testing.T) {
 	}
 }
 
+func TestDatabaseGenerator_MigrationSQL_SQLite(t *testing.T) {
+	gen := NewDatabaseGenerator()
+
+	schema := TableSchema{
+		Name: "readings",
+		Columns: []ColumnSchema{
+			{Name: "id", Type: "int", PrimaryKey: true, AutoIncrement: true},
+			{Name: "value", Type: "float", NotNull: true},
+		},
+	}
+
+	sql := gen.GenerateMigrationSQL("sqlite", schema)
+
+	// Verify SQLite numbers its rowid itself
+	if !contains(sql, "id INTEGER PRIMARY KEY") {
+		t.Errorf("Expected an INTEGER PRIMARY KEY for SQLite, got:\n%s", sql)
+	}
+	if contains(sql, "SERIAL") || contains(sql, "AUTO_INCREMENT") {
+		t.Errorf("SQLite has no SERIAL or AUTO_INCREMENT, got:\n%s", sql)
+	}
+	if !contains(sql, "REAL") {
+		t.Errorf("Expected REAL for a float in SQLite, got:\n%s", sql)
+	}
+}
+
 func TestDatabaseGenerator_QueryCode_MySQL(t *testing.T) {
 	gen := NewDatabaseGenerator()
 
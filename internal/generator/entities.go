@@ -0,0 +1,413 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/funcs"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// EntitySourcePrefix marks generated files that come from the entities of the manifest
+const EntitySourcePrefix = "entity:"
+
+// entityTypes maps the type of an entity field to its Go type and to its
+// column type for DatabaseGenerator
+var entityTypes = map[ritual.FieldType]struct{ goType, column string }{
+	ritual.FieldString: {"string", "string"},
+	ritual.FieldText:   {"string", "text"},
+	ritual.FieldInt:    {"int64", "bigint"},
+	ritual.FieldFloat:  {"float64", "float"},
+	ritual.FieldBool:   {"bool", "bool"},
+	ritual.FieldTime:   {"time.Time", "timestamp"},
+}
+
+// initialisms are the words of a column name kept upper case in Go names
+var initialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "id": true, "ip": true,
+	"json": true, "sql": true, "url": true, "uuid": true,
+}
+
+// EntityGenerator generates the files of the entities of a manifest with the
+// model, database, handler, route and test generators, giving each the
+// configuration of the same entity so they agree on names and types
+type EntityGenerator struct {
+	module   string
+	dialect  string
+	entities []ritual.Entity
+	layout   Layout
+
+	models   *ModelGenerator
+	database *DatabaseGenerator
+	handlers *HandlerGenerator
+	routes   *RouteGenerator
+	tests    *TestGenerator
+}
+
+// EntityFile is a file generated for an entity
+type EntityFile struct {
+	Entity  string
+	Path    string // Slash-separated, relative to the project
+	Content string
+}
+
+// NewEntityGenerator creates a generator for the entities of the project with
+// module path module. Its migrations and repositories are for dialect:
+// postgres (default), mysql or sqlite.
+func NewEntityGenerator(module, dialect string, entities []ritual.Entity) *EntityGenerator {
+	return &EntityGenerator{
+		module:   module,
+		dialect:  sqlDialect(dialect),
+		entities: entities,
+		layout:   DefaultLayout(),
+		models:   NewModelGenerator(),
+		database: NewDatabaseGenerator(),
+		handlers: NewHandlerGenerator(),
+		routes:   NewRouteGenerator(),
+		tests:    NewTestGenerator(),
+	}
+}
+
+// SetLayout sets the directories the files are generated in
+func (g *EntityGenerator) SetLayout(layout Layout) {
+	g.layout = layout.withDefaults()
+}
+
+// Generate returns the files of every entity: its model, its migration,
+// numbered in manifest order, its repository and SQLite tests of it, its
+// CRUD handler, table-driven handler tests and routes
+func (g *EntityGenerator) Generate() ([]EntityFile, error) {
+	var files []EntityFile
+	for i, e := range g.entities {
+		repositoryTests, err := g.RepositoryTestFile(e)
+		if err != nil {
+			return nil, err
+		}
+		handlerTests, err := g.HandlerTestFile(e)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files,
+			g.ModelFile(e),
+			g.MigrationFile(e, i+1),
+			g.RepositoryFile(e),
+			repositoryTests,
+			g.HandlerFile(e),
+			handlerTests,
+			g.RoutesFile(e),
+		)
+	}
+	return files, nil
+}
+
+// ModelFile returns the model of e
+func (g *EntityGenerator) ModelFile(e ritual.Entity) EntityFile {
+	return g.file(e, g.layout.Models, ".go", g.models.generateModelContent(g.ModelConfig(e)))
+}
+
+// MigrationFile returns the migration creating the tables of e, with number
+// as the prefix of its name
+func (g *EntityGenerator) MigrationFile(e ritual.Entity, number int) EntityFile {
+	var migration strings.Builder
+	for i, schema := range g.TableSchemas(e) {
+		if i > 0 {
+			migration.WriteString("\n")
+		}
+		migration.WriteString(g.database.GenerateMigrationSQL(g.dialect, schema))
+	}
+	return EntityFile{e.Name, path.Join(g.layout.Migrations, fmt.Sprintf("%03d_create_%s.sql", number, EntityTable(e))), migration.String()}
+}
+
+// RepositoryFile returns the repository of e, in the SQL of the dialect
+func (g *EntityGenerator) RepositoryFile(e ritual.Entity) EntityFile {
+	config := g.ModelConfig(e)
+	// Has many helpers query the column of the other entity's belongs_to
+	config.Relationships = slices.DeleteFunc(config.Relationships, func(rel Relationship) bool {
+		return rel.Type == "HasMany" && !slices.ContainsFunc(g.entity(rel.Model).Relationships, func(other ritual.EntityRelationship) bool {
+			return other.Type == ritual.BelongsTo && other.Entity == e.Name
+		})
+	})
+	return g.file(e, g.layout.Repository, "_repository.go", g.models.generateRepositoryContent(config))
+}
+
+// RepositoryTestFile returns the tests of the repository of e, run against SQLite
+func (g *EntityGenerator) RepositoryTestFile(e ritual.Entity) (EntityFile, error) {
+	content, err := g.tests.GenerateRepositoryTest(g.TestSpec(e))
+	if err != nil {
+		return EntityFile{}, fmt.Errorf("failed to generate %s repository tests: %w", e.Name, err)
+	}
+	return g.file(e, g.layout.Repository, "_repository_test.go", content), nil
+}
+
+// HandlerFile returns the CRUD handler of e
+func (g *EntityGenerator) HandlerFile(e ritual.Entity) EntityFile {
+	content := g.handlers.generateHandlerContent(g.HandlerConfig(e), []string{"Create", "Get", "List", "Update", "Delete"})
+	return g.file(e, g.layout.Handlers, "_handler.go", content)
+}
+
+// HandlerTestFile returns the table-driven tests of the handler of e
+func (g *EntityGenerator) HandlerTestFile(e ritual.Entity) (EntityFile, error) {
+	content, err := g.tests.GenerateCRUDHandlerTest(g.TestSpec(e))
+	if err != nil {
+		return EntityFile{}, fmt.Errorf("failed to generate %s handler tests: %w", e.Name, err)
+	}
+	return g.file(e, g.layout.Handlers, "_handler_test.go", content), nil
+}
+
+// RoutesFile returns the RESTful routes of e
+func (g *EntityGenerator) RoutesFile(e ritual.Entity) EntityFile {
+	config := g.RouteConfig(e)
+	content := g.routes.generateRouteContent(config, g.routes.generateRESTfulRoutes(config.Resource, config.Handler, config.Model))
+	return g.file(e, g.layout.Routes, "_routes.go", content)
+}
+
+// file returns the file of e in dir named after it with suffix
+func (g *EntityGenerator) file(e ritual.Entity, dir, suffix, content string) EntityFile {
+	return EntityFile{e.Name, path.Join(dir, toSnakeCase(e.Name)+suffix), content}
+}
+
+// ModelConfig returns the configuration of the model of e
+func (g *EntityGenerator) ModelConfig(e ritual.Entity) ModelConfig {
+	config := ModelConfig{
+		Name:       e.Name,
+		Package:    "models",
+		Timestamps: e.Timestamps,
+		SoftDelete: e.SoftDelete,
+		Validation: true,
+		Module:     g.module,
+		Layout:     g.layout,
+		Table:      EntityTable(e),
+		Dialect:    g.dialect,
+	}
+	for _, f := range e.Fields {
+		field := Field{
+			Name:     goName(f.Name),
+			Type:     entityTypes[f.Type].goType,
+			Tags:     fmt.Sprintf(`json:"%s" db:"%s"`, f.Name, f.Name),
+			Required: f.Required,
+		}
+		if !f.Required {
+			field.Type = "*" + field.Type
+		}
+		if f.Type == ritual.FieldString {
+			field.MaxLength = stringSize(f)
+		}
+		config.Fields = append(config.Fields, field)
+	}
+	for _, rel := range e.Relationships {
+		kind := map[ritual.RelationshipType]string{
+			ritual.BelongsTo:  "BelongsTo",
+			ritual.HasMany:    "HasMany",
+			ritual.ManyToMany: "ManyToMany",
+		}[rel.Type]
+		other := g.entity(rel.Entity)
+		relationship := Relationship{Type: kind, Model: rel.Entity, Table: EntityTable(other), SoftDelete: other.SoftDelete}
+		if rel.Type == ritual.ManyToMany {
+			relationship.JoinTable = toSnakeCase(e.Name) + "_" + EntityTable(other)
+		}
+		config.Relationships = append(config.Relationships, relationship)
+	}
+	return config
+}
+
+// TableSchemas returns the table of e, followed by the join tables of its
+// many-to-many relationships
+func (g *EntityGenerator) TableSchemas(e ritual.Entity) []TableSchema {
+	table := TableSchema{
+		Name:    EntityTable(e),
+		Columns: []ColumnSchema{{Name: "id", Type: "int", PrimaryKey: true, AutoIncrement: true}},
+	}
+	for _, f := range e.Fields {
+		table.Columns = append(table.Columns, ColumnSchema{
+			Name:    f.Name,
+			Type:    entityTypes[f.Type].column,
+			Size:    f.Size,
+			NotNull: f.Required,
+			Unique:  f.Unique,
+			Default: f.Default,
+		})
+	}
+
+	schemas := []TableSchema{table}
+	for _, rel := range e.Relationships {
+		other := g.entity(rel.Entity)
+		switch rel.Type {
+		case ritual.BelongsTo:
+			schemas[0].Columns = append(schemas[0].Columns, foreignKey(other))
+		case ritual.ManyToMany:
+			own, theirs := foreignKey(e), foreignKey(other)
+			schemas = append(schemas, TableSchema{
+				Name:       toSnakeCase(e.Name) + "_" + EntityTable(other),
+				Columns:    []ColumnSchema{own, theirs},
+				PrimaryKey: []string{own.Name, theirs.Name},
+			})
+		}
+	}
+
+	if e.Timestamps {
+		schemas[0].Columns = append(schemas[0].Columns,
+			ColumnSchema{Name: "created_at", Type: "timestamp", NotNull: true, Default: "CURRENT_TIMESTAMP"},
+			ColumnSchema{Name: "updated_at", Type: "timestamp", NotNull: true, Default: "CURRENT_TIMESTAMP"})
+	}
+	if e.SoftDelete {
+		schemas[0].Columns = append(schemas[0].Columns, ColumnSchema{Name: "deleted_at", Type: "timestamp"})
+	}
+	return schemas
+}
+
+// HandlerConfig returns the configuration of the CRUD handler of e
+func (g *EntityGenerator) HandlerConfig(e ritual.Entity) HandlerConfig {
+	return HandlerConfig{
+		Name:       e.Name,
+		Package:    "handlers",
+		Model:      "models." + e.Name,
+		Repository: "repository." + e.Name + "Repository",
+		CRUD:       true,
+		Validation: true,
+		Imports:    []string{importPath(g.module, g.layout.Models), importPath(g.module, g.layout.Repository)},
+		NotFound:   "repository.Err" + e.Name + "NotFound",
+	}
+}
+
+// RouteConfig returns the configuration of the RESTful routes of e
+func (g *EntityGenerator) RouteConfig(e ritual.Entity) RouteConfig {
+	return RouteConfig{
+		Package:       "routes",
+		Resource:      EntityResource(e),
+		Model:         e.Name,
+		Handler:       "h",
+		RESTful:       true,
+		Documentation: true,
+		Register:      "Register" + e.Name + "Routes",
+		HandlerType:   "*handlers." + e.Name + "Handler",
+		Imports:       []string{importPath(g.module, g.layout.Handlers)},
+	}
+}
+
+// TestSpec returns the specification of the handler tests of e, with an item
+// holding a value for every field and, if a string field can be invalid, an
+// item Validate rejects
+func (g *EntityGenerator) TestSpec(e ritual.Entity) CRUDTestSpec {
+	valid := make(map[string]interface{})
+	invalid := ""
+	for _, f := range e.Fields {
+		switch f.Type {
+		case ritual.FieldString:
+			valid[f.Name] = "example"[:min(len("example"), stringSize(f))]
+			if f.Required {
+				invalid = "{}"
+			} else if invalid == "" {
+				invalid = fmt.Sprintf(`{"%s": "%s"}`, f.Name, strings.Repeat("x", stringSize(f)+1))
+			}
+		case ritual.FieldText:
+			valid[f.Name] = "example"
+		case ritual.FieldInt:
+			valid[f.Name] = 1
+		case ritual.FieldFloat:
+			valid[f.Name] = 1.5
+		case ritual.FieldBool:
+			valid[f.Name] = true
+		case ritual.FieldTime:
+			valid[f.Name] = "2024-01-02T15:04:05Z"
+		}
+	}
+	for _, rel := range e.Relationships {
+		if rel.Type == ritual.BelongsTo {
+			valid[toSnakeCase(rel.Entity)+"_id"] = 1
+		}
+	}
+	validJSON, _ := json.Marshal(valid)
+
+	return CRUDTestSpec{
+		Module:   g.module,
+		Layout:   g.layout,
+		Model:    e.Name,
+		Resource: EntityResource(e),
+		Register: "Register" + e.Name + "Routes",
+		Valid:    string(validJSON),
+		Invalid:  invalid,
+		Schema:   g.database.GenerateMigrationSQL("sqlite", g.TableSchemas(e)[0]),
+	}
+}
+
+// entity returns the entity called name
+func (g *EntityGenerator) entity(name string) ritual.Entity {
+	for _, e := range g.entities {
+		if e.Name == name {
+			return e
+		}
+	}
+	return ritual.Entity{Name: name}
+}
+
+// EntityTable returns the table of e: its own, or the snake_case plural of its name
+func EntityTable(e ritual.Entity) string {
+	if e.Table != "" {
+		return e.Table
+	}
+	return toSnakeCase(funcs.Pluralize(e.Name))
+}
+
+// EntityResource returns the path segment of the routes of e, such as blog-posts
+func EntityResource(e ritual.Entity) string {
+	return strings.ReplaceAll(EntityTable(e), "_", "-")
+}
+
+// foreignKey returns the column referencing the table of e
+func foreignKey(e ritual.Entity) ColumnSchema {
+	return ColumnSchema{Name: toSnakeCase(e.Name) + "_id", Type: "int", NotNull: true, References: EntityTable(e)}
+}
+
+// stringSize returns the maximum length of a string field
+func stringSize(f ritual.EntityField) int {
+	if f.Size > 0 {
+		return f.Size
+	}
+	return 255
+}
+
+// goName returns the Go name of a snake_case column, such as AuthorID for author_id
+func goName(column string) string {
+	words := strings.Split(column, "_")
+	for i, word := range words {
+		if initialisms[word] {
+			words[i] = strings.ToUpper(word)
+		} else {
+			words[i] = funcs.Capitalize(word)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// planEntities plans the files of the manifest's entities. Like built-in
+// files, they give way to ritual files with the same destination.
+func (g *FileGenerator) planEntities(plan *generationPlan, entities []ritual.Entity) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	files, err := NewEntityGenerator(moduleName(g.variables), g.variables.GetString("database_type"), entities).Generate()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		source := EntitySourcePrefix + f.Entity
+		plan.files = append(plan.files, &plannedFile{
+			file:       sourceFile{name: f.Path, display: source},
+			dest:       filepath.Join(plan.root, filepath.FromSlash(f.Path)),
+			source:     source,
+			isTemplate: true,
+			opts:       defaultFileOptions(),
+			body:       f.Content,
+			bodyLine:   1,
+			origin:     "entity " + f.Entity,
+			content:    []byte(f.Content),
+			rendered:   true,
+			fallback:   true,
+		})
+	}
+	return nil
+}
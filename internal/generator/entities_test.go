@@ -0,0 +1,195 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// blogEntities are users, tags and blog posts belonging to a user and sharing tags
+var blogEntities = []ritual.Entity{
+	{
+		Name:          "User",
+		Fields:        []ritual.EntityField{{Name: "email", Type: ritual.FieldString, Required: true, Unique: true}},
+		Relationships: []ritual.EntityRelationship{{Type: ritual.HasMany, Entity: "BlogPost"}},
+		Timestamps:    true,
+	},
+	{Name: "Tag", Table: "labels", Fields: []ritual.EntityField{{Name: "name", Type: ritual.FieldString, Size: 40}}},
+	{
+		Name: "BlogPost",
+		Fields: []ritual.EntityField{
+			{Name: "title", Type: ritual.FieldString, Size: 200, Required: true},
+			{Name: "body", Type: ritual.FieldText},
+			{Name: "views", Type: ritual.FieldInt, Required: true, Default: "0"},
+			{Name: "cover_url", Type: ritual.FieldString},
+			{Name: "published_at", Type: ritual.FieldTime},
+		},
+		Relationships: []ritual.EntityRelationship{
+			{Type: ritual.BelongsTo, Entity: "User"},
+			{Type: ritual.ManyToMany, Entity: "Tag"},
+		},
+		Timestamps: true,
+		SoftDelete: true,
+	},
+}
+
+func TestEntityGenerator_Generate(t *testing.T) {
+	files, err := NewEntityGenerator("example.com/blog", "", blogEntities).Generate()
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	generated := make(map[string]string)
+	for _, f := range files {
+		generated[f.Path] = f.Content
+	}
+
+	want := map[string][]string{
+		"internal/models/blog_post.go": {
+			"type BlogPost struct",
+			"Title string `json:\"title\" db:\"title\"`",
+			"Body *string `json:\"body\" db:\"body\"`",
+			"Views int64 `json:\"views\" db:\"views\"`",
+			"CoverURL *string `json:\"cover_url\" db:\"cover_url\"`",
+			"PublishedAt *time.Time",
+			"UserID uint `json:\"user_id\" db:\"user_id\"`",
+			"Tags []Tag `json:\"tags,omitempty\" db:\"-\"`",
+			"DeletedAt *time.Time",
+			`return errors.New("title is required")`,
+			"utf8.RuneCountInString(m.Title) > 200",
+			"m.CoverURL != nil && utf8.RuneCountInString(*m.CoverURL) > 255",
+		},
+		"internal/models/user.go": {"BlogPosts []BlogPost `json:\"blog_posts,omitempty\" db:\"-\"`"},
+		"migrations/001_create_users.sql": {
+			"CREATE TABLE IF NOT EXISTS users (",
+			"email VARCHAR(255) NOT NULL UNIQUE",
+			"created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP",
+		},
+		"migrations/002_create_labels.sql": {"CREATE TABLE IF NOT EXISTS labels (", "name VARCHAR(40)"},
+		"migrations/003_create_blog_posts.sql": {
+			"id SERIAL PRIMARY KEY",
+			"title VARCHAR(200) NOT NULL",
+			"body TEXT,",
+			"views BIGINT NOT NULL DEFAULT 0",
+			"published_at TIMESTAMP,",
+			"user_id INT NOT NULL",
+			"deleted_at TIMESTAMP",
+			"FOREIGN KEY (user_id) REFERENCES users(id)",
+			"CREATE TABLE IF NOT EXISTS blog_post_labels (",
+			"PRIMARY KEY (blog_post_id, tag_id)",
+			"FOREIGN KEY (tag_id) REFERENCES labels(id)",
+		},
+		"internal/repository/blog_post_repository.go": {
+			`"example.com/blog/internal/models"`,
+			"type BlogPostRepository interface",
+			"GetByID(ctx context.Context, id uint) (*models.BlogPost, error)",
+			"List(ctx context.Context, limit, offset int) ([]*models.BlogPost, error)",
+			`var ErrBlogPostNotFound = errors.New("blog post not found")`,
+			"func NewBlogPostRepository(db *sql.DB) *BlogPostRepositoryImpl",
+			`"INSERT INTO blog_posts (title, body, views, cover_url, published_at, user_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id"`,
+			`" FROM blog_posts WHERE blog_posts.id = $1 AND blog_posts.deleted_at IS NULL"`,
+			`"UPDATE blog_posts SET deleted_at = $1 WHERE blog_posts.id = $2 AND blog_posts.deleted_at IS NULL"`,
+			"func (r *BlogPostRepositoryImpl) LoadUser(ctx context.Context, item *models.BlogPost) (*models.User, error)",
+			"JOIN blog_post_labels ON blog_post_labels.tag_id = labels.id WHERE blog_post_labels.blog_post_id IN (",
+		},
+		"internal/repository/user_repository.go": {
+			"func (r *UserRepositoryImpl) LoadBlogPosts(ctx context.Context, items ...*models.User) error",
+			`" FROM blog_posts WHERE blog_posts.user_id IN ("+strings.Join(marks, ", ")+") AND blog_posts.deleted_at IS NULL ORDER BY blog_posts.id"`,
+		},
+		"internal/repository/blog_post_repository_test.go": {
+			"package repository_test",
+			`_ "github.com/mattn/go-sqlite3"`,
+			"id INTEGER PRIMARY KEY",
+			"errors.Is(err, repository.ErrBlogPostNotFound)",
+		},
+		"internal/handlers/blog_post_handler.go": {
+			`"example.com/blog/internal/repository"`,
+			"repo repository.BlogPostRepository",
+			"var item models.BlogPost",
+			"h.repo.GetByID(r.Context(), uint(id))",
+			"errors.Is(err, repository.ErrBlogPostNotFound)",
+			"func (h *BlogPostHandler) ListBlogPosts(",
+		},
+		"internal/routes/blog_post_routes.go": {
+			"func RegisterBlogPostRoutes(router *mux.Router, h *handlers.BlogPostHandler)",
+			`router.HandleFunc("/blog-posts", h.ListBlogPosts).Methods("GET")`,
+			`router.HandleFunc("/blog-posts/{id}", h.DeleteBlogPost).Methods("DELETE")`,
+		},
+		"internal/handlers/blog_post_handler_test.go": {
+			"package handlers_test",
+			"var _ repository.BlogPostRepository = (*memoryBlogPostRepository)(nil)",
+			"routes.RegisterBlogPostRoutes(router, handlers.NewBlogPostHandler(repo))",
+			`"user_id":1`,
+			`{"create invalid", http.MethodPost, "/blog-posts", ` + "`{}`" + `, http.StatusBadRequest}`,
+			`{"list invalid limit", http.MethodGet, "/blog-posts?limit=0", ` + "``" + `, http.StatusBadRequest}`,
+			"return nil, repository.ErrBlogPostNotFound",
+		},
+	}
+	for path, parts := range want {
+		content, ok := generated[path]
+		if !ok {
+			t.Errorf("%s was not generated", path)
+			continue
+		}
+		// Models and handlers are gofmt'ed only once planned, so compare without alignment
+		content = strings.Join(strings.Fields(content), " ")
+		for _, part := range parts {
+			if !strings.Contains(content, strings.Join(strings.Fields(part), " ")) {
+				t.Errorf("%s should contain %s, got:\n%s", path, part, generated[path])
+			}
+		}
+	}
+	if got := len(files); got != 21 {
+		t.Errorf("Generate() returned %d files, want 7 per entity", got)
+	}
+}
+
+func TestEntityGenerator_MySQL(t *testing.T) {
+	schemas := NewEntityGenerator("example.com/blog", "mysql", blogEntities).TableSchemas(blogEntities[2])
+	sql := NewDatabaseGenerator().GenerateMigrationSQL("mysql", schemas[0])
+	for _, part := range []string{"id INT AUTO_INCREMENT PRIMARY KEY", "views BIGINT NOT NULL DEFAULT 0", "FOREIGN KEY (user_id) REFERENCES users(id)"} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("migration should contain %s, got:\n%s", part, sql)
+		}
+	}
+}
+
+func TestDefaultPipeline_Entities(t *testing.T) {
+	run, output := pipelineRun(t)
+	fsys := fstest.MapFS{
+		"app/templates/user.go.tmpl": {Data: []byte("package models\n\n// User is the ritual's own\ntype User struct{}\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run.Source = src
+	run.Manifest.Files.Templates = []ritual.FileMapping{{Source: "user.go.tmpl", Destination: "internal/models/user.go"}}
+	run.Manifest.Entities = blogEntities
+	run.Variables.Set("module_name", "example.com/blog")
+	run.Variables.Set("database_type", "mysql")
+
+	if err := DefaultPipeline().Run(context.Background(), run); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	files := output.Files("/project")
+	if got := string(files["internal/models/user.go"]); !strings.Contains(got, "the ritual's own") {
+		t.Errorf("a ritual file should replace the generated one, got:\n%s", got)
+	}
+	if got := string(files["internal/models/blog_post.go"]); !strings.Contains(got, "\tTitle       string     `json:\"title\" db:\"title\"`\n") {
+		t.Errorf("the generated model should be formatted, got:\n%s", got)
+	}
+	if got := string(files["migrations/003_create_blog_posts.sql"]); !strings.Contains(got, "INT AUTO_INCREMENT") {
+		t.Errorf("migrations should be for database_type, got:\n%s", got)
+	}
+	if got := string(files["go.mod"]); !strings.Contains(got, "github.com/gorilla/mux v1.8.1") || !strings.Contains(got, "github.com/mattn/go-sqlite3 v1.14.22") {
+		t.Errorf("go.mod should require gorilla/mux for the handlers and go-sqlite3 for the repository tests, got:\n%s", got)
+	}
+
+	handler, ok := run.State.GetGeneratedFile("internal/handlers/blog_post_handler.go")
+	if !ok || handler.Source != EntitySourcePrefix+"BlogPost" {
+		t.Errorf("the handler should be recorded as generated from its entity, got %+v", handler)
+	}
+}
@@ -0,0 +1,114 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// defaultForeachVar is the variable holding the current item of a foreach mapping
+const defaultForeachVar = "item"
+
+// foreachItem is one item a foreach mapping is generated for
+type foreachItem struct {
+	key   interface{} // Map key, or list index
+	value interface{}
+	label string // Names the item in errors
+}
+
+// planForeach plans a mapping once per item of its foreach variable.
+// The item is bound to the mapping's `as` name (default "item") and loop holds
+// its position, so the destination and content can differ per item.
+func (g *FileGenerator) planForeach(plan *generationPlan, src *ritual.Source, mapping ritual.FileMapping, kind string) error {
+	value, ok := g.variables.Get(mapping.Foreach)
+	if !ok {
+		return fmt.Errorf("foreach variable %q of %s is not set", mapping.Foreach, mapping.Source)
+	}
+	items, err := foreachItems(value)
+	if err != nil {
+		return fmt.Errorf("cannot generate %s for each %s: %w", mapping.Source, mapping.Foreach, err)
+	}
+
+	as := mapping.As
+	if as == "" {
+		as = defaultForeachVar
+	}
+
+	vars := g.variables
+	defer func() { g.variables = vars }()
+
+	for i, item := range items {
+		g.variables = vars.With(map[string]interface{}{
+			as: item.value,
+			"loop": map[string]interface{}{
+				"key":    item.key,
+				"index":  i + 1,
+				"index0": i,
+				"first":  i == 0,
+				"last":   i == len(items)-1,
+				"length": len(items),
+			},
+		})
+
+		label := fmt.Sprintf("%s item %s", mapping.Foreach, item.label)
+		if err := g.planMapping(plan, src, mapping, kind, label); err != nil {
+			return fmt.Errorf("%s: %w", label, err)
+		}
+	}
+	return nil
+}
+
+// foreachItems lists the items of a list or map. Map items are ordered by key,
+// so generation is deterministic; nil has no items.
+func foreachItems(value interface{}) ([]foreachItem, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]foreachItem, v.Len())
+		for i := range items {
+			elem := v.Index(i).Interface()
+			items[i] = foreachItem{key: i, value: elem, label: itemLabel(elem, i)}
+		}
+		return items, nil
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		items := make([]foreachItem, len(keys))
+		for i, key := range keys {
+			items[i] = foreachItem{
+				key:   key.Interface(),
+				value: v.MapIndex(key).Interface(),
+				label: fmt.Sprintf("%q", fmt.Sprint(key.Interface())),
+			}
+		}
+		return items, nil
+
+	default:
+		return nil, fmt.Errorf("%T is not a list or map", value)
+	}
+}
+
+// itemLabel names a list item in errors: by its value if it is a string or
+// number, by its name if it has one, otherwise by its position
+func itemLabel(item interface{}, index int) string {
+	switch v := item.(type) {
+	case string:
+		return fmt.Sprintf("%q (#%d)", v, index+1)
+	case int, int64, float64, bool:
+		return fmt.Sprintf("%v (#%d)", v, index+1)
+	case map[string]interface{}:
+		if name, ok := v["name"].(string); ok {
+			return fmt.Sprintf("%q (#%d)", name, index+1)
+		}
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// renderForeach generates manifest from a ritual holding files, in memory
+func renderForeach(t *testing.T, engine string, files fstest.MapFS, manifest *ritual.Manifest, values map[string]interface{}) (map[string]string, error) {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, file := range files {
+		fsys["app/"+name] = file
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := NewFileGenerator(engine)
+	vars := NewVariables()
+	for k, v := range values {
+		vars.Set(k, v)
+	}
+	gen.SetVariables(vars)
+
+	out, err := gen.RenderToMap(manifest, src)
+	if _, leaked := gen.variables.Get("entity"); leaked {
+		t.Error("foreach item should not outlive its mapping")
+	}
+	return out, err
+}
+
+func TestGenerateFilesFrom_Foreach(t *testing.T) {
+	files := fstest.MapFS{
+		"templates/model.go.tmpl": {Data: []byte(
+			"package models\n\n// {{ loop.index }}/{{ loop.length }}\ntype {{ entity | pascal }} struct{}\n")},
+		"templates/service.yaml.tmpl":        {Data: []byte("name: {{ loop.key }}\nport: {{ item.port }}\n")},
+		"templates/resource/handler.go.tmpl": {Data: []byte("package {{ item.name }}\n")},
+		"templates/resource/test.go.tmpl": {Data: []byte(
+			"---\noutput: \"{{ item.name }}_test.go\"\ncondition: item.tested\n---\npackage {{ item.name }}_test\n")},
+		"static/icon.svg": {Data: []byte("<svg/>")},
+	}
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{
+					Source: "model.go.tmpl", Destination: "internal/models/{{ entity | snake }}.go",
+					Foreach: "entities", As: "entity", Condition: `entity != "Draft"`,
+				},
+				{Source: "service.yaml.tmpl", Destination: "config/{{ loop.key }}.yaml", Foreach: "services"},
+				{Source: "resource", Destination: "internal/{{ item.name }}", Foreach: "resources"},
+			},
+			Static: []ritual.FileMapping{
+				{Source: "icon.svg", Destination: "public/{{ item }}.svg", Foreach: "icons"},
+			},
+		},
+	}
+	values := map[string]interface{}{
+		"entities": []interface{}{"BlogPost", "Draft", "Comment"},
+		"services": map[string]interface{}{
+			"web": map[string]interface{}{"port": 8080},
+			"api": map[string]interface{}{"port": 8081},
+		},
+		"resources": []interface{}{
+			map[string]interface{}{"name": "posts", "tested": true},
+			map[string]interface{}{"name": "tags", "tested": false},
+		},
+		"icons": []string{"home", "user"},
+	}
+
+	got, err := renderForeach(t, "fith", files, manifest, values)
+	if err != nil {
+		t.Fatalf("GenerateFilesFrom() error = %v", err)
+	}
+
+	want := map[string]string{
+		"internal/models/blog_post.go": "package models\n\n// 1/3\ntype BlogPost struct{}\n",
+		"internal/models/comment.go":   "package models\n\n// 3/3\ntype Comment struct{}\n",
+		"config/api.yaml":              "name: api\nport: 8081\n",
+		"config/web.yaml":              "name: web\nport: 8080\n",
+		"internal/posts/handler.go":    "package posts\n",
+		"internal/posts/posts_test.go": "package posts_test\n",
+		"internal/tags/handler.go":     "package tags\n",
+		"public/home.svg":              "<svg/>",
+		"public/user.svg":              "<svg/>",
+	}
+	for path, content := range want {
+		if got[path] != content {
+			t.Errorf("%s = %q, want %q", path, got[path], content)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("generated %d files, want %d: %v", len(got), len(want), got)
+	}
+}
+
+func TestGenerateFilesFrom_ForeachGoTemplate(t *testing.T) {
+	files := fstest.MapFS{
+		"templates/route.go.tmpl": {Data: []byte(`package routes // [[ .loop.index ]] [[ .item | pascal ]]`)},
+	}
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "route.go.tmpl", Destination: "routes/[[ .item ]].go", Foreach: "routes"},
+			},
+		},
+	}
+
+	got, err := renderForeach(t, "go-template", files, manifest, map[string]interface{}{"routes": []string{"home", "about"}})
+	if err != nil {
+		t.Fatalf("GenerateFilesFrom() error = %v", err)
+	}
+	if got["routes/home.go"] != "package routes // 1 Home\n" || got["routes/about.go"] != "package routes // 2 About\n" {
+		t.Errorf("unexpected output: %v", got)
+	}
+}
+
+func TestGenerateFilesFrom_ForeachErrors(t *testing.T) {
+	files := fstest.MapFS{
+		"templates/model.go.tmpl": {Data: []byte("type {{ entity }} struct{}\n")},
+		"templates/broken.tmpl":   {Data: []byte("{{ entity.missing() }}")},
+	}
+
+	tests := []struct {
+		name    string
+		mapping ritual.FileMapping
+		values  map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "destination ignores the item",
+			mapping: ritual.FileMapping{Source: "model.go.tmpl", Destination: "models.go", Foreach: "entities", As: "entity"},
+			values:  map[string]interface{}{"entities": []interface{}{"Post", "Comment"}},
+			wantErr: `template model.go.tmpl (entities item "Post" (#1)) and template model.go.tmpl (entities item "Comment" (#2)) both write models.go`,
+		},
+		{
+			name:    "duplicate item",
+			mapping: ritual.FileMapping{Source: "model.go.tmpl", Destination: "{{ entity }}.go", Foreach: "entities", As: "entity"},
+			values:  map[string]interface{}{"entities": []interface{}{"Post", "Tag", "Post"}},
+			wantErr: `(entities item "Post" (#1)) and template model.go.tmpl (entities item "Post" (#3)) both write Post.go`,
+		},
+		{
+			name:    "render error names the item",
+			mapping: ritual.FileMapping{Source: "broken.tmpl", Destination: "{{ entity.name }}.go", Foreach: "entities", As: "entity"},
+			values: map[string]interface{}{"entities": []interface{}{
+				map[string]interface{}{"name": "post"},
+			}},
+			wantErr: `entities item "post" (#1): failed to render template`,
+		},
+		{
+			name:    "unset variable",
+			mapping: ritual.FileMapping{Source: "model.go.tmpl", Destination: "{{ entity }}.go", Foreach: "entities", As: "entity"},
+			wantErr: `foreach variable "entities" of model.go.tmpl is not set`,
+		},
+		{
+			name:    "not a list",
+			mapping: ritual.FileMapping{Source: "model.go.tmpl", Destination: "{{ entity }}.go", Foreach: "entities", As: "entity"},
+			values:  map[string]interface{}{"entities": "Post"},
+			wantErr: "cannot generate model.go.tmpl for each entities: string is not a list or map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &ritual.Manifest{Files: ritual.FilesSection{Templates: []ritual.FileMapping{tt.mapping}}}
+			_, err := renderForeach(t, "fith", files, manifest, tt.values)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateFilesFrom_ForeachEmpty(t *testing.T) {
+	files := fstest.MapFS{"templates/model.go.tmpl": {Data: []byte("x")}}
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "model.go.tmpl", Destination: "{{ item }}.go", Foreach: "entities"},
+			},
+		},
+	}
+
+	for _, value := range []interface{}{nil, []interface{}{}, map[string]interface{}{}} {
+		got, err := renderForeach(t, "fith", files, manifest, map[string]interface{}{"entities": value})
+		if err != nil || len(got) != 0 {
+			t.Errorf("entities = %v: got %v, %v; want no files", value, got, err)
+		}
+	}
+}
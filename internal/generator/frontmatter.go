@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// MergeAppend appends a template's output to an existing file, unless the file already contains it
+const MergeAppend = ritual.MergeAppend
+
+// FileOptions are the per-file rules a template declares in YAML frontmatter:
+//
+//	---
+//	output: cmd/{{ app_name }}/main.go
+//	condition: enable_cli
+//	mode: 0755
+//	protected: true
+//	overwrite: false
+//	merge: json
+//	merge_arrays: union
+//	delimiters: ["<<", ">>"]
+//	engine: go-template
+//	---
+//
+// Other keys are ignored. Where the manifest's file mapping says the same
+// thing, the mapping wins.
+type FileOptions struct {
+	Output      string      // Destination, relative to the directory of a directory mapping
+	Condition   string      // Generate the file only if this holds
+	Mode        os.FileMode // File mode; 0 keeps the default
+	Protected   bool        // Never overwrite the file once it exists, and protect it on update
+	Overwrite   bool        // Replace an existing file (default true)
+	Merge       string      // How to combine the output with an existing file
+	MergeArrays string      // How a json or yaml merge combines arrays
+	Delimiters  *Delimiters // Markers the template is written with
+	Engine      string      // Template engine, if not the ritual's
+}
+
+// defaultFileOptions are the options of a template without frontmatter
+func defaultFileOptions() FileOptions {
+	return FileOptions{Overwrite: true}
+}
+
+// parseFileOptions splits a template into its frontmatter options and body.
+// Frontmatter starts with a line holding only "---"; a template whose output
+// begins with "---" needs an empty frontmatter block first.
+func parseFileOptions(name, content string) (FileOptions, string, error) {
+	opts := defaultFileOptions()
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return opts, content, nil
+	}
+
+	meta, body, err := ritual.ParseFrontmatter(content)
+	if err != nil {
+		return opts, "", fmt.Errorf("invalid frontmatter in %s: %w", name, err)
+	}
+	if err := opts.apply(ritual.TemplateFrontmatter(meta)); err != nil {
+		return opts, "", fmt.Errorf("invalid frontmatter in %s: %w", name, err)
+	}
+	return opts, body, nil
+}
+
+// apply reads the known keys of fm
+func (o *FileOptions) apply(fm ritual.TemplateFrontmatter) error {
+	o.Output = fm.GetString("output")
+	o.Condition = fm.GetString("condition")
+	o.Protected = fm.GetBool("protected")
+	if fm.Has("overwrite") {
+		o.Overwrite = fm.GetBool("overwrite")
+	}
+
+	if value, ok := fm.Get("mode"); ok {
+		mode, err := parseMode(value)
+		if err != nil {
+			return err
+		}
+		o.Mode = mode
+	}
+
+	o.Merge, o.MergeArrays = fm.GetString("merge"), fm.GetString("merge_arrays")
+	if err := ritual.ValidateMerge(o.Merge, o.MergeArrays); err != nil {
+		return err
+	}
+
+	switch engine := fm.GetString("engine"); engine {
+	case "", "fith", "go-template":
+		o.Engine = engine
+	default:
+		return fmt.Errorf("unknown template engine %q", engine)
+	}
+
+	if value, ok := fm.Get("delimiters"); ok {
+		delims, err := parseDelimiters(value)
+		if err != nil {
+			return err
+		}
+		o.Delimiters = delims
+	}
+	return nil
+}
+
+// parseMode reads a file mode written as an octal YAML number (0755) or string ("0755")
+func parseMode(value interface{}) (os.FileMode, error) {
+	mode := -1
+	switch v := value.(type) {
+	case int:
+		mode = v
+	case string:
+		if parsed, err := strconv.ParseUint(v, 8, 16); err == nil {
+			mode = int(parsed)
+		}
+	}
+	if mode <= 0 || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode %v: must be octal between 0001 and 0777, like 0644", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// parseDelimiters reads delimiters written as a pair, ["<<", ">>"], for Go
+// actions and Fíth output, or as a map of Fíth output, tag and comment pairs
+func parseDelimiters(value interface{}) (*Delimiters, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		pair, err := delimiterPair("delimiters", v)
+		if err != nil {
+			return nil, err
+		}
+		return &Delimiters{Action: pair}, nil
+	case map[string]interface{}:
+		var delims Delimiters
+		for key, raw := range v {
+			list, ok := raw.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("delimiters.%s must be a pair of strings", key)
+			}
+			pair, err := delimiterPair("delimiters."+key, list)
+			if err != nil {
+				return nil, err
+			}
+			switch key {
+			case "output":
+				delims.Action = pair
+			case "tag":
+				delims.Tag = pair
+			case "comment":
+				delims.Comment = pair
+			default:
+				return nil, fmt.Errorf("unknown delimiters %q, expected output, tag or comment", key)
+			}
+		}
+		return &delims, nil
+	default:
+		return nil, fmt.Errorf("delimiters must be a pair of strings or a map of pairs")
+	}
+}
+
+// delimiterPair reads an opening and closing delimiter
+func delimiterPair(key string, list []interface{}) ([2]string, error) {
+	var pair [2]string
+	if len(list) != 2 {
+		return pair, fmt.Errorf("%s must be a pair of strings", key)
+	}
+	for i, item := range list {
+		s, ok := item.(string)
+		if !ok || s == "" {
+			return pair, fmt.Errorf("%s must be a pair of strings", key)
+		}
+		pair[i] = s
+	}
+	return pair, nil
+}
@@ -0,0 +1,275 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+func TestParseFileOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     FileOptions
+		wantBody string
+		wantErr  string
+	}{
+		{
+			name:     "no frontmatter",
+			content:  "package main\n",
+			want:     FileOptions{Overwrite: true},
+			wantBody: "package main\n",
+		},
+		{
+			name:     "yaml document marker is not frontmatter",
+			content:  "----\nkey: value\n",
+			want:     FileOptions{Overwrite: true},
+			wantBody: "----\nkey: value\n",
+		},
+		{
+			name: "all options",
+			content: `---
+output: cmd/{{ app_name }}/main.go
+condition: enable_cli
+mode: 0755
+protected: true
+overwrite: false
+merge: append
+delimiters: ["<<", ">>"]
+engine: go-template
+description: ignored
+---
+package main
+`,
+			want: FileOptions{
+				Output:     "cmd/{{ app_name }}/main.go",
+				Condition:  "enable_cli",
+				Mode:       0755,
+				Protected:  true,
+				Merge:      MergeAppend,
+				Delimiters: &Delimiters{Action: [2]string{"<<", ">>"}},
+				Engine:     "go-template",
+			},
+			wantBody: "package main\n",
+		},
+		{
+			name:     "mode as string",
+			content:  "---\nmode: \"0640\"\n---\nx",
+			want:     FileOptions{Mode: 0640, Overwrite: true},
+			wantBody: "x",
+		},
+		{
+			name:    "fith delimiter map",
+			content: "---\ndelimiters:\n  output: [\"<<\", \">>\"]\n  tag: [\"<%\", \"%>\"]\n---\nx",
+			want: FileOptions{Overwrite: true, Delimiters: &Delimiters{
+				Action: [2]string{"<<", ">>"},
+				Tag:    [2]string{"<%", "%>"},
+			}},
+			wantBody: "x",
+		},
+		{name: "mode too large", content: "---\nmode: 01777\n---\n", wantErr: "invalid mode"},
+		{name: "mode not octal", content: "---\nmode: \"rwx\"\n---\n", wantErr: "invalid mode"},
+		{name: "unknown merge", content: "---\nmerge: toml\n---\n", wantErr: `unknown merge strategy "toml"`},
+		{name: "unknown merge arrays", content: "---\nmerge: json\nmerge_arrays: zip\n---\n", wantErr: `unknown merge_arrays "zip"`},
+		{name: "unknown engine", content: "---\nengine: jinja\n---\n", wantErr: `unknown template engine "jinja"`},
+		{name: "single delimiter", content: "---\ndelimiters: [\"<<\"]\n---\n", wantErr: "pair of strings"},
+		{name: "unknown delimiter kind", content: "---\ndelimiters:\n  block: [\"<\", \">\"]\n---\n", wantErr: "unknown delimiters"},
+		{name: "unclosed", content: "---\noutput: x\n", wantErr: "invalid frontmatter in t.tmpl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, body, err := parseFileOptions("t.tmpl", tt.content)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseFileOptions() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFileOptions() error = %v", err)
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if (opts.Delimiters == nil) != (tt.want.Delimiters == nil) ||
+				(opts.Delimiters != nil && *opts.Delimiters != *tt.want.Delimiters) {
+				t.Errorf("Delimiters = %v, want %v", opts.Delimiters, tt.want.Delimiters)
+			}
+			opts.Delimiters, tt.want.Delimiters = nil, nil
+			if opts != tt.want {
+				t.Errorf("options = %+v, want %+v", opts, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateFilesFrom_Frontmatter(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/templates/cmd/main.go.tmpl": {Data: []byte(
+			"---\noutput: \"{{ app_name }}/main.go\"\nmode: 0755\n---\npackage main // {{ app_name }}\n")},
+		"app/templates/cmd/extra.go.tmpl": {Data: []byte(
+			"---\ncondition: enable_extra\n---\npackage extra\n")},
+		"app/templates/cmd/App.vue.tmpl": {Data: []byte(
+			"---\ndelimiters: [\"<<\", \">>\"]\n---\n<h1>{{ title }}</h1><!-- << app_name >> -->\n")},
+		"app/templates/cmd/notes.txt.tmpl": {Data: []byte(
+			"---\nengine: go-template\n---\n[[ .app_name | upper ]]\n")},
+		"app/templates/single.tmpl": {Data: []byte(
+			"---\noutput: elsewhere.txt\ncondition: enable_extra\n---\nsingle\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := NewFileGenerator("fith")
+	vars := NewVariables()
+	vars.Set("app_name", "blog")
+	vars.Set("enable_extra", false)
+	gen.SetVariables(vars)
+
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "cmd", Destination: "cmd"},
+				// The mapping's destination and condition win over the frontmatter
+				{Source: "single.tmpl", Destination: "single.txt", Condition: "app_name == \"blog\""},
+			},
+		},
+	}
+	outputDir := t.TempDir()
+	if err := gen.GenerateFilesFrom(manifest, src, outputDir); err != nil {
+		t.Fatalf("GenerateFilesFrom() error = %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"cmd/blog/main.go": "package main // blog\n",
+		"cmd/App.vue":      "<h1>{{ title }}</h1><!-- blog -->\n",
+		"cmd/notes.txt":    "BLOG\n",
+		"single.txt":       "single\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(outputDir, path))
+		if err != nil {
+			t.Errorf("%s not generated: %v", path, err)
+			continue
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+	}
+	for _, path := range []string{"cmd/main.go", "cmd/extra.go", "elsewhere.txt"} {
+		if _, err := os.Stat(filepath.Join(outputDir, path)); !os.IsNotExist(err) {
+			t.Errorf("%s should not be generated", path)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(outputDir, "cmd/blog/main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("main.go mode = %v, want 0755", info.Mode().Perm())
+	}
+}
+
+func TestGenerateFilesFrom_FrontmatterWriteStrategies(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/templates/env.tmpl":       {Data: []byte("---\nprotected: true\n---\nSECRET=generated\n")},
+		"app/templates/config.tmpl":    {Data: []byte("---\noverwrite: false\n---\nport: 8080\n")},
+		"app/templates/gitignore.tmpl": {Data: []byte("---\nmerge: append\n---\nnode_modules/\n")},
+		"app/templates/new.tmpl":       {Data: []byte("---\nprotected: true\n---\nnew\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "env.tmpl", Destination: ".env"},
+				{Source: "config.tmpl", Destination: "config.yaml"},
+				{Source: "gitignore.tmpl", Destination: ".gitignore"},
+				{Source: "new.tmpl", Destination: "new.txt"},
+			},
+		},
+	}
+
+	outputDir := t.TempDir()
+	existing := map[string]string{
+		".env":        "SECRET=mine\n",
+		"config.yaml": "port: 9000\n",
+		".gitignore":  "bin/",
+	}
+	for path, content := range existing {
+		if err := os.WriteFile(filepath.Join(outputDir, path), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Generating twice leaves the same files: appending is idempotent
+	for range 2 {
+		gen := NewFileGenerator("fith")
+		if err := gen.GenerateFilesFrom(manifest, src, outputDir); err != nil {
+			t.Fatalf("GenerateFilesFrom() error = %v", err)
+		}
+	}
+
+	for path, want := range map[string]string{
+		".env":        "SECRET=mine\n",
+		"config.yaml": "port: 9000\n",
+		".gitignore":  "bin/\nnode_modules/\n",
+		"new.txt":     "new\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(outputDir, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+	}
+
+	// A protected file the run created is protected in the project state
+	if err := os.Remove(filepath.Join(outputDir, "new.txt")); err != nil {
+		t.Fatal(err)
+	}
+	gen := NewFileGenerator("fith")
+	if err := gen.GenerateFilesFrom(manifest, src, outputDir); err != nil {
+		t.Fatalf("GenerateFilesFrom() error = %v", err)
+	}
+	state := &storage.State{}
+	if err := RecordGeneratedFiles(state, outputDir, gen.GeneratedFiles()); err != nil {
+		t.Fatalf("RecordGeneratedFiles() error = %v", err)
+	}
+	if !state.IsFileProtected("new.txt") {
+		t.Errorf("new.txt should be protected, got %v", state.ProtectedFiles)
+	}
+	if state.IsFileProtected(".gitignore") {
+		t.Error(".gitignore should not be protected")
+	}
+}
+
+func TestGenerateFilesFrom_FrontmatterOutputStaysInDirectory(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/templates/cmd/main.go.tmpl": {Data: []byte("---\noutput: ../../escape.go\n---\npackage main\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{{Source: "cmd", Destination: "cmd"}},
+		},
+	}
+
+	err = NewFileGenerator("fith").GenerateFilesFrom(manifest, src, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "must stay inside") {
+		t.Errorf("expected the output path to be rejected, got %v", err)
+	}
+}
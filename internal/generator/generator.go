@@ -1,37 +1,146 @@
 package generator
 
 import (
+	"context"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/funcs"
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
 
 // FileGenerator handles file generation from templates
 type FileGenerator struct {
 	engine          TemplateEngine
+	engineType      string
+	engines         map[string]TemplateEngine // Other engines templates asked for in frontmatter
+	delimited       map[string]TemplateEngine // Engines with the delimiters templates asked for
+	workers         int                       // Templates rendered at once
+	strict          bool                      // Variables that were not given are an error
+	env             []string                  // Environment variables templates may read
+	preview         bool                      // Rendering to a map, without the files already in the project
+	ritual          string                    // Name of the ritual being generated, for errors
 	variables       *Variables
 	protected       map[string]bool
 	ritualsBasePath string // Base path for rituals directory (for _shared access)
+	generated       []GeneratedFile
+	journal         *Journal
+	output          OutputFS
+}
+
+// GeneratedFile describes a file written by the generator
+type GeneratedFile struct {
+	Path      string // Destination path as written
+	Source    string // Ritual source the file was produced from
+	Protected bool   // The template asks for the file to be protected on update
+	Injected  bool   // Snippets were injected into a file the run did not generate
 }
 
 // NewFileGenerator creates a new file generator
 func NewFileGenerator(engineType string) *FileGenerator {
+	journal := NewJournal()
 	return &FileGenerator{
-		engine:    NewTemplateEngine(engineType),
-		variables: NewVariables(),
-		protected: make(map[string]bool),
+		engine:     NewTemplateEngine(engineType),
+		engineType: engineType,
+		engines:    make(map[string]TemplateEngine),
+		delimited:  make(map[string]TemplateEngine),
+		workers:    runtime.GOMAXPROCS(0),
+		variables:  NewVariables(),
+		protected:  make(map[string]bool),
+		journal:    journal,
+		output:     NewDiskFS(journal),
 	}
 }
 
+// Journal returns the journal of changes the generator has made on disk
+func (g *FileGenerator) Journal() *Journal {
+	return g.journal
+}
+
+// SetOutput sets the filesystem generated files are written to (default: disk)
+func (g *FileGenerator) SetOutput(output OutputFS) {
+	g.output = output
+}
+
+// Output returns the filesystem generated files are written to
+func (g *FileGenerator) Output() OutputFS {
+	return g.output
+}
+
 // SetVariables sets the variables for template rendering
 func (g *FileGenerator) SetVariables(vars *Variables) {
 	g.variables = vars
 }
 
+// SetTemplateEngine switches the engine used to render templates
+func (g *FileGenerator) SetTemplateEngine(engineType string) {
+	g.engine = NewTemplateEngine(engineType)
+	g.engine.SetStrict(g.strict)
+	g.allowEnv(g.engine)
+	g.engineType = engineType
+	g.engines = make(map[string]TemplateEngine)
+	g.delimited = make(map[string]TemplateEngine)
+}
+
+// SetWorkers sets how many templates are rendered at once (default: one per CPU)
+func (g *FileGenerator) SetWorkers(n int) {
+	g.workers = max(n, 1)
+}
+
+// SetStrict makes templates fail on variables that were not given
+func (g *FileGenerator) SetStrict(strict bool) {
+	g.strict = strict
+	g.engine.SetStrict(strict)
+	for _, engine := range g.engines {
+		engine.SetStrict(strict)
+	}
+	for _, engine := range g.delimited {
+		engine.SetStrict(strict)
+	}
+}
+
+// SetEnv lets templates read the environment variables in names with env
+func (g *FileGenerator) SetEnv(names []string) {
+	if slices.Equal(names, g.env) {
+		return
+	}
+	g.env = slices.Clone(names)
+	g.allowEnv(g.engine)
+	for _, engine := range g.engines {
+		g.allowEnv(engine)
+	}
+	for _, engine := range g.delimited {
+		g.allowEnv(engine)
+	}
+}
+
+// allowEnv gives engine an env function reading the allowed environment variables
+func (g *FileGenerator) allowEnv(engine TemplateEngine) {
+	if registry, ok := engine.(interface{ RegisterFunc(string, interface{}) }); ok && len(g.env) > 0 {
+		registry.RegisterFunc("env", funcs.Env(g.env))
+	}
+}
+
+// useManifestEngine renders with the engine, missing key mode and environment
+// variables the ritual declares, if any, and names the ritual in template errors
+func (g *FileGenerator) useManifestEngine(manifest *ritual.Manifest) {
+	if manifest.Ritual.TemplateEngine != "" {
+		g.SetTemplateEngine(manifest.Ritual.TemplateEngine)
+	}
+	if manifest.Ritual.MissingKey != "" {
+		g.SetStrict(manifest.Ritual.MissingKey == ritual.MissingKeyError)
+	}
+	g.SetEnv(manifest.Ritual.Env)
+	g.ritual = manifest.Ritual.Name
+}
+
 // SetProtectedFiles sets files that should not be overwritten
 func (g *FileGenerator) SetProtectedFiles(files []string) {
 	g.protected = make(map[string]bool)
@@ -46,291 +155,359 @@ func (g *FileGenerator) SetRitualsBasePath(path string) {
 	g.ritualsBasePath = path
 }
 
+// GeneratedFiles returns the files written since the generator was created
+func (g *FileGenerator) GeneratedFiles() []GeneratedFile {
+	return g.generated
+}
+
+// RecordGeneratedFiles records files written into projectPath in the project state,
+// hashing their content as generated
+func RecordGeneratedFiles(state *storage.State, projectPath string, files []GeneratedFile) error {
+	for _, file := range files {
+		relPath, err := filepath.Rel(projectPath, file.Path)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			continue // Written outside the project, nothing to track
+		}
+		if err := state.RecordGeneratedFile(projectPath, relPath, file.Source); err != nil {
+			return err
+		}
+		if file.Protected {
+			state.MarkFileAsProtected(relPath)
+		}
+	}
+	return nil
+}
+
+// RecordState records the files the generator has written into projectPath in
+// the project state, hashing their content as generated. They are read back
+// through the output, so a staged project can be recorded before it is committed.
+// Files that are gone, such as staged files that were discarded, are skipped.
+func (g *FileGenerator) RecordState(state *storage.State, projectPath string) error {
+	for _, file := range g.generated {
+		relPath, err := filepath.Rel(projectPath, file.Path)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			continue // Written outside the project, nothing to track
+		}
+		content, err := g.output.ReadFile(file.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read generated file %s: %w", relPath, err)
+		}
+		info, err := g.output.Stat(file.Path)
+		if err != nil {
+			return fmt.Errorf("failed to stat generated file %s: %w", relPath, err)
+		}
+		source := file.Source
+		if file.Injected {
+			// Keep tracking the file as it was generated, with its new content
+			tracked, ok := state.GetGeneratedFile(relPath)
+			if !ok {
+				continue
+			}
+			source = tracked.Source
+		}
+		state.RecordGeneratedContent(relPath, source, content, info.Mode())
+		if file.Protected {
+			state.MarkFileAsProtected(relPath)
+		}
+	}
+	return nil
+}
+
 // GenerateFile generates a single file from a template
 func (g *FileGenerator) GenerateFile(srcPath, destPath string, isTemplate bool) error {
-	// Normalize destPath for comparison with protected files
-	normalizedDest := filepath.ToSlash(destPath)
+	file := sourceFile{fsys: os.DirFS(filepath.Dir(srcPath)), name: filepath.Base(srcPath), display: srcPath}
+	run := &Run{Generator: g, plan: &generationPlan{}}
+	if err := g.planFile(run.plan, file, fileTarget{path: destPath}, srcPath, isTemplate, srcPath, ""); err != nil {
+		return err
+	}
+	return NewPipeline(RenderStage{}, PostProcessStage{}, WriteStage{}).Run(context.Background(), run)
+}
 
-	// Check if destination is protected and exists
-	for protectedPath := range g.protected {
-		normalizedProtected := filepath.ToSlash(protectedPath)
-		if normalizedDest == normalizedProtected || filepath.Base(normalizedDest) == normalizedProtected {
-			if _, err := os.Stat(destPath); err == nil {
-				// File exists and is protected, skip
-				return nil
-			}
+// sourceFile is a file or directory a mapping reads from a ritual source
+type sourceFile struct {
+	fsys    fs.FS  // nil when the source has no such tree, e.g. no _shared/
+	name    string // Slash-separated path within fsys
+	display string // Path shown in errors
+}
+
+// resolveSource locates a mapping source in a ritual: "_shared:" sources in the
+// shared tree, everything else below kind ("templates" or "static")
+func resolveSource(src *ritual.Source, source, kind string) sourceFile {
+	if shared, ok := strings.CutPrefix(source, "_shared:"); ok {
+		return sourceFile{fsys: src.Shared, name: path.Clean(shared), display: path.Join(ritual.SharedDir, shared)}
+	}
+	name := path.Join(kind, source)
+	return sourceFile{fsys: src.FS, name: name, display: path.Join(filepath.ToSlash(src.Path), name)}
+}
+
+// stat describes the source file
+func (f sourceFile) stat() (fs.FileInfo, error) {
+	if f.fsys == nil {
+		return nil, &fs.PathError{Op: "stat", Path: f.display, Err: fs.ErrNotExist}
+	}
+	return fs.Stat(f.fsys, f.name)
+}
+
+// itemError names the foreach item a failed file was generated for, if any
+func itemError(p *plannedFile, err error) error {
+	if err != nil && p.item != "" {
+		return fmt.Errorf("%s: %w", p.item, err)
+	}
+	return err
+}
+
+// keepsExisting reports whether a file already at the destination of p stays
+// as it is. Snippets are injected into any file that is not protected; a file
+// with a merge strategy merges into any file, only adding to it.
+func (g *FileGenerator) keepsExisting(p *plannedFile) bool {
+	if _, err := g.output.Stat(p.dest); err != nil || p.opts.Merge != "" && p.inject == nil {
+		return false
+	}
+	return g.protects(p) || !p.opts.Overwrite && p.inject == nil
+}
+
+// protects reports whether p is a protected file, the only kind secrets are rendered into
+func (g *FileGenerator) protects(p *plannedFile) bool {
+	return p.opts.Protected || g.isProtected(p.dest)
+}
+
+// render renders a planned template. It only reads from the generator, so
+// templates can render concurrently. Files that are not protected get a
+// placeholder for every secret.
+func (g *FileGenerator) render(p *plannedFile) error {
+	data := p.vars.redacted()
+	if g.protects(p) {
+		data = p.vars.All()
+	}
+	rendered, err := p.engine.RenderNamed(p.file.display, p.body, data)
+	if err != nil {
+		if tmplErr, located := err.(*TemplateError); located {
+			tmplErr.Ritual = g.ritual
+			return fmt.Errorf("failed to render template: %w", err)
 		}
+		return fmt.Errorf("failed to render template %s: %w", p.file.display, err)
+	}
+	p.content, p.rendered = []byte(rendered), true
+	return nil
+}
+
+// postProcess formats rendered Go code and fixes its imports
+func (g *FileGenerator) postProcess(p *plannedFile) error {
+	if filepath.Ext(p.dest) != ".go" {
+		return nil
+	}
+	content, err := formatGo(p.dest, p.content, p.file.display, p.body, p.bodyLine)
+	if err != nil {
+		return err
+	}
+	p.content = content
+	return nil
+}
+
+// write writes a planned file to its destination and records it, unless a
+// file already there is kept. A template no stage rendered is rendered first.
+func (g *FileGenerator) write(p *plannedFile) error {
+	if p.inject != nil {
+		return g.writeInjected(p)
+	}
+	file, opts, destPath := p.file, p.opts, p.dest
+
+	_, statErr := g.output.Stat(destPath)
+	exists := statErr == nil
+	if g.keepsExisting(p) {
+		return nil
 	}
 
 	// Ensure destination directory exists
 	destDir := filepath.Dir(destPath)
-	if err := os.MkdirAll(destDir, 0750); err != nil {
+	if err := g.output.MkdirAll(destDir, 0750); err != nil {
 		return fmt.Errorf("failed to create directory %s: %w", destDir, err)
 	}
 
-	if isTemplate {
-		// Read template file
-		// #nosec G304 - srcPath is from validated ritual template source
-		content, err := os.ReadFile(srcPath)
-		if err != nil {
-			return fmt.Errorf("failed to read template %s: %w", srcPath, err)
+	if p.isTemplate {
+		if !p.rendered {
+			if err := g.render(p); err != nil {
+				return err
+			}
+			if err := g.postProcess(p); err != nil {
+				return err
+			}
 		}
 
-		// Render template
-		rendered, err := g.engine.Render(string(content), g.variables.All())
-		if err != nil {
-			return fmt.Errorf("failed to render template %s: %w", srcPath, err)
+		content := p.content
+		if exists && opts.Merge != "" {
+			var err error
+			if content, err = g.merge(destPath, content, opts); err != nil {
+				return err
+			}
 		}
 
 		// Write rendered content
-		if err := os.WriteFile(destPath, []byte(rendered), 0600); err != nil {
+		mode := opts.Mode
+		if mode == 0 {
+			mode = 0600
+		}
+		if err := g.output.WriteFile(destPath, content, mode); err != nil {
+			return fmt.Errorf("failed to write file %s: %w", destPath, err)
+		}
+		if opts.Mode != 0 {
+			if err := g.output.Chmod(destPath, opts.Mode); err != nil {
+				return fmt.Errorf("failed to set mode of %s: %w", destPath, err)
+			}
+		}
+	} else if exists && opts.Merge != "" {
+		// Merge static file into the existing one, which keeps its mode
+		content, err := fs.ReadFile(file.fsys, file.name)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.display, err)
+		}
+		if content, err = g.merge(destPath, content, opts); err != nil {
+			return err
+		}
+		if err := g.output.WriteFile(destPath, content, 0600); err != nil {
 			return fmt.Errorf("failed to write file %s: %w", destPath, err)
 		}
 	} else {
 		// Copy static file
-		if err := copyFile(srcPath, destPath); err != nil {
-			return fmt.Errorf("failed to copy file %s to %s: %w", srcPath, destPath, err)
+		if err := g.copyFile(file, destPath); err != nil {
+			return fmt.Errorf("failed to copy file %s to %s: %w", file.display, destPath, err)
 		}
 	}
 
+	g.generated = append(g.generated, GeneratedFile{Path: destPath, Source: p.source, Protected: opts.Protected})
 	return nil
 }
 
-// GenerateFiles generates all files from a manifest
-func (g *FileGenerator) GenerateFiles(manifest *ritual.Manifest, ritualPath, outputPath string) error {
-	// Set protected files
-	g.SetProtectedFiles(manifest.Files.Protected)
-
-	// Generate template files
-	for _, tmpl := range manifest.Files.Templates {
-		// Evaluate condition if present
-		if tmpl.Condition != "" {
-			shouldGenerate, err := evaluateCondition(tmpl.Condition, g.variables.All())
-			if err != nil {
-				return fmt.Errorf("failed to evaluate condition for %s: %w", tmpl.Source, err)
-			}
-			if !shouldGenerate {
-				continue // Skip this file
-			}
+// isProtected reports whether the manifest protects destPath, by path or base name
+func (g *FileGenerator) isProtected(destPath string) bool {
+	normalizedDest := filepath.ToSlash(destPath)
+	for protectedPath := range g.protected {
+		normalizedProtected := filepath.ToSlash(protectedPath)
+		if normalizedDest == normalizedProtected || filepath.Base(normalizedDest) == normalizedProtected {
+			return true
 		}
+	}
+	return false
+}
 
-		// Resolve source path (handle _shared: prefix) - templates go in templates/
-		srcPath := g.resolveTemplateSourcePath(tmpl.Source, ritualPath)
-
-		// Render destination path (it may contain template variables)
-		destPathRendered, err := g.engine.Render(tmpl.Destination, g.variables.All())
-		if err != nil {
-			return fmt.Errorf("failed to render destination path %s: %w", tmpl.Destination, err)
-		}
-		destPath := filepath.Join(outputPath, destPathRendered)
+// merge combines content with the file already at path as opts.Merge says
+func (g *FileGenerator) merge(path string, content []byte, opts FileOptions) ([]byte, error) {
+	existing, err := g.output.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge %s: %w", path, err)
+	}
+	merged, err := mergeContent(path, existing, content, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge %s: %w", path, err)
+	}
+	return merged, nil
+}
 
-		// Check if file/directory exists
-		info, err := os.Stat(srcPath)
-		if err != nil {
-			if tmpl.Optional {
-				continue
-			}
-			return fmt.Errorf("template source not found: %s", srcPath)
-		}
-
-		if info.IsDir() {
-			// Generate all files in directory
-			if err := g.generateDirectory(srcPath, destPath, true); err != nil {
-				return err
-			}
-		} else {
-			// Generate single file
-			if err := g.GenerateFile(srcPath, destPath, true); err != nil {
-				return err
-			}
+// engineFor returns the engine a template renders with: the ritual's, unless its
+// frontmatter picks another engine or other delimiters. Partials are only
+// available with the ritual's engine.
+func (g *FileGenerator) engineFor(opts FileOptions) (TemplateEngine, error) {
+	engine := g.engine
+	if opts.Engine != "" && opts.Engine != g.engineType {
+		if g.engines[opts.Engine] == nil {
+			g.engines[opts.Engine] = NewTemplateEngine(opts.Engine)
+			g.engines[opts.Engine].SetStrict(g.strict)
+			g.allowEnv(g.engines[opts.Engine])
 		}
+		engine = g.engines[opts.Engine]
 	}
-
-	// Copy static files
-	for _, static := range manifest.Files.Static {
-		// Evaluate condition if present
-		if static.Condition != "" {
-			shouldGenerate, err := evaluateCondition(static.Condition, g.variables.All())
+	if opts.Delimiters != nil {
+		key := fmt.Sprintf("%s %q", opts.Engine, *opts.Delimiters)
+		if g.delimited[key] == nil {
+			delimited, err := engine.WithDelimiters(*opts.Delimiters)
 			if err != nil {
-				return fmt.Errorf("failed to evaluate condition for %s: %w", static.Source, err)
-			}
-			if !shouldGenerate {
-				continue // Skip this file
+				return nil, err
 			}
+			g.delimited[key] = delimited
 		}
+		return g.delimited[key], nil
+	}
+	return engine, nil
+}
 
-		// Resolve source path (handle _shared: prefix) - static files go in static/
-		srcPath := g.resolveStaticSourcePath(static.Source, ritualPath)
-
-		// Render destination path (it may contain template variables)
-		destPathRendered, err := g.engine.Render(static.Destination, g.variables.All())
-		if err != nil {
-			return fmt.Errorf("failed to render destination path %s: %w", static.Destination, err)
-		}
-		destPath := filepath.Join(outputPath, destPathRendered)
+// recordGenerated remembers a written file for state tracking
+func (g *FileGenerator) recordGenerated(destPath, source string) {
+	g.generated = append(g.generated, GeneratedFile{Path: destPath, Source: source})
+}
 
-		// Check if file/directory exists
-		info, err := os.Stat(srcPath)
-		if err != nil {
-			if static.Optional {
-				continue
-			}
-			return fmt.Errorf("static source not found: %s", srcPath)
-		}
+// GenerateFiles generates all files from a manifest in a ritual directory
+func (g *FileGenerator) GenerateFiles(manifest *ritual.Manifest, ritualPath, outputPath string) error {
+	return g.GenerateFilesFrom(manifest, g.dirSource(ritualPath), outputPath)
+}
 
-		if info.IsDir() {
-			// Copy all files in directory
-			if err := g.generateDirectory(srcPath, destPath, false); err != nil {
-				return err
-			}
-		} else {
-			// Copy single file
-			if err := g.GenerateFile(srcPath, destPath, false); err != nil {
-				return err
-			}
-		}
+// dirSource opens a ritual directory, taking _shared/ from the rituals base path when one is set
+func (g *FileGenerator) dirSource(ritualPath string) *ritual.Source {
+	src := ritual.DirSource(ritualPath)
+	if g.ritualsBasePath != "" {
+		src.Shared = os.DirFS(filepath.Join(g.ritualsBasePath, ritual.SharedDir))
 	}
-
-	return nil
+	return src
 }
 
-// generateDirectory generates all files in a directory
-func (g *FileGenerator) generateDirectory(srcDir, destDir string, isTemplate bool) error {
-	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+// GenerateFilesFrom generates all files from a manifest, reading templates and
+// static files from src wherever it lives: on disk, in a tarball or embedded
+func (g *FileGenerator) GenerateFilesFrom(manifest *ritual.Manifest, src *ritual.Source, outputPath string) error {
+	return g.GenerateFilesFromContext(context.Background(), manifest, src, outputPath)
+}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
+// GenerateFilesFromContext is GenerateFilesFrom, stopping once ctx is done
+func (g *FileGenerator) GenerateFilesFromContext(ctx context.Context, manifest *ritual.Manifest, src *ritual.Source, outputPath string) error {
+	run := &Run{Generator: g, Manifest: manifest, Source: src, OutputPath: outputPath}
+	return NewPipeline(FileStages()...).Run(ctx, run)
+}
 
-		// Calculate relative path
-		relPath, err := filepath.Rel(srcDir, path)
-		if err != nil {
-			return err
-		}
+// RenderToMap generates all files from a manifest without touching the project
+// and returns their contents keyed by slash-separated destination path
+func (g *FileGenerator) RenderToMap(manifest *ritual.Manifest, src *ritual.Source) (map[string]string, error) {
+	output := g.output
+	defer func() { g.output, g.preview = output, false }()
 
-		// Strip .tmpl extension for template files
-		destPath := filepath.Join(destDir, relPath)
-		if isTemplate && strings.HasSuffix(destPath, ".tmpl") {
-			destPath = strings.TrimSuffix(destPath, ".tmpl")
-		}
+	memory := NewMemoryFS()
+	g.output, g.preview = memory, true
+	if err := g.GenerateFilesFrom(manifest, src, "."); err != nil {
+		return nil, err
+	}
 
-		return g.GenerateFile(path, destPath, isTemplate)
-	})
-}
-
-// resolveSourcePath resolves a source path, handling _shared: prefix
-// Deprecated: Use resolveTemplateSourcePath or resolveStaticSourcePath instead
-func (g *FileGenerator) resolveSourcePath(source, ritualPath string) string {
-	// Check if source uses _shared: prefix
-	if strings.HasPrefix(source, "_shared:") {
-		// Extract the path after _shared:
-		sharedPath := strings.TrimPrefix(source, "_shared:")
-		
-		// If ritualsBasePath is set, use it; otherwise try to find it from ritualPath
-		if g.ritualsBasePath != "" {
-			return filepath.Join(g.ritualsBasePath, "_shared", sharedPath)
-		}
-		
-		// Fallback: try to find rituals directory from ritualPath
-		// ritualPath is typically: /path/to/rituals/ritual-name
-		// We need: /path/to/rituals/_shared/...
-		ritualsDir := filepath.Dir(ritualPath) // Go up one level
-		return filepath.Join(ritualsDir, "_shared", sharedPath)
-	}
-	
-	// Regular path - assume it's in the ritual root
-	return filepath.Join(ritualPath, source)
-}
-
-// resolveTemplateSourcePath resolves a template source path, handling _shared: prefix
-func (g *FileGenerator) resolveTemplateSourcePath(source, ritualPath string) string {
-	// Check if source uses _shared: prefix
-	if strings.HasPrefix(source, "_shared:") {
-		// Extract the path after _shared:
-		sharedPath := strings.TrimPrefix(source, "_shared:")
-		
-		// If ritualsBasePath is set, use it; otherwise try to find it from ritualPath
-		if g.ritualsBasePath != "" {
-			return filepath.Join(g.ritualsBasePath, "_shared", sharedPath)
-		}
-		
-		// Fallback: try to find rituals directory from ritualPath
-		ritualsDir := filepath.Dir(ritualPath)
-		return filepath.Join(ritualsDir, "_shared", sharedPath)
-	}
-	
-	// Regular template - prepend templates/ subdirectory
-	return filepath.Join(ritualPath, "templates", source)
-}
-
-// resolveStaticSourcePath resolves a static file source path, handling _shared: prefix
-func (g *FileGenerator) resolveStaticSourcePath(source, ritualPath string) string {
-	// Check if source uses _shared: prefix
-	if strings.HasPrefix(source, "_shared:") {
-		// Extract the path after _shared:
-		sharedPath := strings.TrimPrefix(source, "_shared:")
-		
-		// If ritualsBasePath is set, use it; otherwise try to find it from ritualPath
-		if g.ritualsBasePath != "" {
-			return filepath.Join(g.ritualsBasePath, "_shared", sharedPath)
-		}
-		
-		// Fallback: try to find rituals directory from ritualPath
-		ritualsDir := filepath.Dir(ritualPath)
-		return filepath.Join(ritualsDir, "_shared", sharedPath)
+	files := make(map[string]string)
+	for path, content := range memory.Files(".") {
+		files[path] = string(content)
 	}
-	
-	// Regular static file - prepend static/ subdirectory
-	return filepath.Join(ritualPath, "static", source)
+	return files, nil
 }
 
 // CreateDirectoryStructure creates the directory structure for a project
 func (g *FileGenerator) CreateDirectoryStructure(basePath string, dirs []string) error {
 	for _, dir := range dirs {
 		fullPath := filepath.Join(basePath, dir)
-		if err := os.MkdirAll(fullPath, 0750); err != nil {
+		if err := g.output.MkdirAll(fullPath, 0750); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", fullPath, err)
 		}
 	}
 	return nil
 }
 
-// copyFile copies a file from src to dst
-// #nosec G304 - src is from validated ritual source
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
+// copyFile copies a source file to dst, keeping its permissions. Embedded files
+// are read-only, so the copy is always writable by its owner.
+func (g *FileGenerator) copyFile(file sourceFile, dst string) error {
+	sourceInfo, err := fs.Stat(file.fsys, file.name)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err := sourceFile.Close(); err != nil {
-			// Log but don't fail on close error
-		}
-		// #nosec G304 - dst is a validated destination path
-	}()
-
-	destFile, err := os.Create(dst)
+	content, err := fs.ReadFile(file.fsys, file.name)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		if err := destFile.Close(); err != nil {
-			// Log but don't fail on close error
-		}
-	}()
-
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
-		return err
-	}
-
-	// Copy file permissions
-	sourceInfo, err := os.Stat(src)
-	if err != nil {
+	mode := sourceInfo.Mode().Perm() | 0200
+	if err := g.output.WriteFile(dst, content, mode); err != nil {
 		return err
 	}
-	return os.Chmod(dst, sourceInfo.Mode())
+	return g.output.Chmod(dst, mode)
 }
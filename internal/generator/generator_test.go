@@ -3,8 +3,11 @@ package generator
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
 
@@ -188,7 +191,7 @@ func TestGenerateFiles(t *testing.T) {
 		t.Fatalf("Failed to read main.go: %v", err)
 	}
 
-	expectedMain := "package main\n\nconst AppName = \"my-app\""
+	expectedMain := "package main\n\nconst AppName = \"my-app\"\n"
 	if string(mainContent) != expectedMain {
 		t.Errorf("Expected '%s', got '%s'", expectedMain, string(mainContent))
 	}
@@ -283,3 +286,221 @@ func TestGenerateFiles_OptionalMissingFile(t *testing.T) {
 		t.Errorf("Unexpected error for optional missing file: %v", err)
 	}
 }
+
+func TestGenerateFiles_RecordsGeneratedFiles(t *testing.T) {
+	gen := NewFileGenerator("go-template")
+
+	vars := NewVariables()
+	vars.Set("app_name", "my-app")
+	gen.SetVariables(vars)
+
+	tmpDir := t.TempDir()
+	ritualDir := filepath.Join(tmpDir, "ritual")
+	outputDir := filepath.Join(tmpDir, "output")
+
+	os.MkdirAll(filepath.Join(ritualDir, "templates", "handlers"), 0750)
+	os.WriteFile(filepath.Join(ritualDir, "templates", "main.go.tmpl"), []byte("// [[ .app_name ]]\npackage main\n"), 0600)
+	os.WriteFile(filepath.Join(ritualDir, "templates", "handlers", "home.go.tmpl"), []byte("package handlers"), 0600)
+
+	manifest := &ritual.Manifest{
+		Ritual: ritual.RitualMeta{Version: "1.0.0"},
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "main.go.tmpl", Destination: "main.go"},
+				{Source: "handlers", Destination: "internal/handlers"},
+			},
+		},
+	}
+
+	if err := gen.GenerateFiles(manifest, ritualDir, outputDir); err != nil {
+		t.Fatalf("GenerateFiles failed: %v", err)
+	}
+
+	state := &storage.State{RitualVersion: "1.0.0"}
+	if err := RecordGeneratedFiles(state, outputDir, gen.GeneratedFiles()); err != nil {
+		t.Fatalf("RecordGeneratedFiles failed: %v", err)
+	}
+
+	mainFile, ok := state.GetGeneratedFile("main.go")
+	if !ok {
+		t.Fatal("Expected main.go to be recorded")
+	}
+	if mainFile.Source != "main.go.tmpl" {
+		t.Errorf("main.go source = %s, want main.go.tmpl", mainFile.Source)
+	}
+	if mainFile.SHA256 != storage.HashContent([]byte("// my-app\npackage main\n")) {
+		t.Error("Expected main.go hash of rendered content")
+	}
+
+	homeFile, ok := state.GetGeneratedFile("internal/handlers/home.go")
+	if !ok {
+		t.Fatalf("Expected directory file to be recorded, got %+v", state.GeneratedFiles)
+	}
+	if homeFile.Source != "handlers/home.go.tmpl" {
+		t.Errorf("home.go source = %s, want handlers/home.go.tmpl", homeFile.Source)
+	}
+}
+
+func TestGenerateFilesFrom_FS(t *testing.T) {
+	gen := NewFileGenerator("go-template")
+
+	vars := NewVariables()
+	vars.Set("app_name", "my-app")
+	gen.SetVariables(vars)
+
+	// Embedded files are read-only
+	fsys := fstest.MapFS{
+		"blog/templates/main.go.tmpl":       {Data: []byte("package main // [[ .app_name ]]"), Mode: 0444},
+		"blog/templates/views/home.tmpl":    {Data: []byte("<h1>[[ .app_name ]]</h1>"), Mode: 0444},
+		"blog/static/run.sh":                {Data: []byte("#!/bin/sh\n"), Mode: 0555},
+		"_shared/docker/Dockerfile.tmpl":    {Data: []byte("# [[ .app_name ]]"), Mode: 0444},
+		"_shared/docker/docker-compose.yml": {Data: []byte("services: {}\n"), Mode: 0444},
+	}
+	src, err := ritual.NewSource(fsys, "blog", "embedded:blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "main.go.tmpl", Destination: "main.go"},
+				{Source: "views", Destination: "views"},
+				{Source: "_shared:docker/Dockerfile.tmpl", Destination: "Dockerfile"},
+			},
+			Static: []ritual.FileMapping{
+				{Source: "run.sh", Destination: "run.sh"},
+				{Source: "_shared:docker/docker-compose.yml", Destination: "docker-compose.yml"},
+			},
+		},
+	}
+
+	outputDir := t.TempDir()
+	if err := gen.GenerateFilesFrom(manifest, src, outputDir); err != nil {
+		t.Fatalf("GenerateFilesFrom failed: %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"main.go":            "package main // my-app\n",
+		"views/home":         "<h1>my-app</h1>",
+		"Dockerfile":         "# my-app",
+		"run.sh":             "#!/bin/sh\n",
+		"docker-compose.yml": "services: {}\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(outputDir, path))
+		if err != nil {
+			t.Errorf("%s not generated: %v", path, err)
+			continue
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+	}
+
+	// Copies stay writable by their owner and keep the execute bits
+	info, err := os.Stat(filepath.Join(outputDir, "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("run.sh mode = %v, want %v", info.Mode().Perm(), os.FileMode(0755))
+	}
+}
+
+func TestGenerateFilesFrom_MissingShared(t *testing.T) {
+	gen := NewFileGenerator("go-template")
+
+	src, err := ritual.NewSource(fstest.MapFS{"blog/ritual.yaml": {}}, "blog", "embedded:blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{{Source: "_shared:docker/Dockerfile", Destination: "Dockerfile"}},
+		},
+	}
+
+	err = gen.GenerateFilesFrom(manifest, src, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "_shared/docker/Dockerfile") {
+		t.Errorf("expected missing _shared source error, got %v", err)
+	}
+}
+
+func TestGenerateFilesFrom_RendersDirectoryNames(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/templates/project/[[ .app_name ]]/cmd/[[ .app_name ]]/main.go.tmpl": {Data: []byte("package main")},
+		"app/templates/project/[[ .app_name ]]/README.md.tmpl":                   {Data: []byte("# [[ .app_name ]]")},
+		"app/templates/project/[[ if .with_api ]]api[[ end ]]/routes.go.tmpl":    {Data: []byte("package api")},
+		"app/templates/project/[[ if .with_api ]]openapi.yaml[[ end ]].tmpl":     {Data: []byte("openapi: 3.0.0")},
+		"app/templates/project/[[ .app_name ]].env.tmpl":                         {Data: []byte("APP=[[ .app_name ]]")},
+		"app/static/assets/[[ .app_name ]].txt":                                  {Data: []byte("static names are kept")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := NewFileGenerator("go-template")
+	vars := NewVariables()
+	vars.Set("app_name", "blog")
+	vars.Set("with_api", false)
+	gen.SetVariables(vars)
+
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{{Source: "project", Destination: "."}},
+			Static:    []ritual.FileMapping{{Source: "assets", Destination: "assets"}},
+		},
+	}
+	files, err := gen.RenderToMap(manifest, src)
+	if err != nil {
+		t.Fatalf("RenderToMap() error = %v", err)
+	}
+
+	want := map[string]string{
+		"blog/cmd/blog/main.go":      "package main\n",
+		"blog/README.md":             "# blog",
+		"blog.env":                   "APP=blog",
+		"assets/[[ .app_name ]].txt": "static names are kept",
+	}
+	if len(files) != len(want) {
+		t.Errorf("generated %v, want %v", files, want)
+	}
+	for path, content := range want {
+		if files[path] != content {
+			t.Errorf("%s = %q, want %q", path, files[path], content)
+		}
+	}
+
+	// A generated file is recorded under its source path
+	if got := gen.GeneratedFiles()[0].Source; !strings.HasPrefix(got, "project/[[ .app_name ]]/") {
+		t.Errorf("source = %q, want the unrendered source path", got)
+	}
+}
+
+func TestGenerateFilesFrom_RejectsDirectoryNamesLeavingTheirDirectory(t *testing.T) {
+	for _, name := range []string{"..", "a/b", `a\b`, "."} {
+		t.Run(name, func(t *testing.T) {
+			fsys := fstest.MapFS{
+				"app/templates/project/[[ .name ]]/file.txt.tmpl": {Data: []byte("x")},
+			}
+			src, err := ritual.NewSource(fsys, "app", "embedded:app")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gen := NewFileGenerator("go-template")
+			vars := NewVariables()
+			vars.Set("name", name)
+			gen.SetVariables(vars)
+
+			manifest := &ritual.Manifest{
+				Files: ritual.FilesSection{Templates: []ritual.FileMapping{{Source: "project", Destination: "out"}}},
+			}
+			_, err = gen.RenderToMap(manifest, src)
+			if err == nil || !strings.Contains(err.Error(), "not a single path segment") {
+				t.Errorf("expected %q to be rejected, got %v", name, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stdlibImports are the standard library packages added to a generated Go file
+// that uses them without importing them, by package name
+var stdlibImports = map[string]string{
+	"bufio":    "bufio",
+	"bytes":    "bytes",
+	"context":  "context",
+	"base64":   "encoding/base64",
+	"hex":      "encoding/hex",
+	"json":     "encoding/json",
+	"xml":      "encoding/xml",
+	"csv":      "encoding/csv",
+	"sql":      "database/sql",
+	"embed":    "embed",
+	"errors":   "errors",
+	"filepath": "path/filepath",
+	"fmt":      "fmt",
+	"fs":       "io/fs",
+	"html":     "html",
+	"http":     "net/http",
+	"httptest": "net/http/httptest",
+	"io":       "io",
+	"log":      "log",
+	"slog":     "log/slog",
+	"maps":     "maps",
+	"math":     "math",
+	"mime":     "mime",
+	"net":      "net",
+	"os":       "os",
+	"reflect":  "reflect",
+	"regexp":   "regexp",
+	"signal":   "os/signal",
+	"slices":   "slices",
+	"sort":     "sort",
+	"strconv":  "strconv",
+	"strings":  "strings",
+	"sync":     "sync",
+	"atomic":   "sync/atomic",
+	"syscall":  "syscall",
+	"testing":  "testing",
+	"time":     "time",
+	"unicode":  "unicode",
+	"url":      "net/url",
+	"utf8":     "unicode/utf8",
+}
+
+// ambiguousImports are standard library package names shared by more than one
+// package, such as crypto/rand and math/rand. They are never added, since the
+// name alone cannot tell which package a file means, but an unused import of
+// one of them is removed like any in stdlibImports.
+var ambiguousImports = map[string][]string{
+	"rand":     {"crypto/rand", "math/rand", "math/rand/v2"},
+	"template": {"html/template", "text/template"},
+}
+
+// GoSyntaxError reports a generated Go file that does not parse, located in
+// the template that produced it
+type GoSyntaxError struct {
+	Template     string // Template the file was rendered from
+	Line         int    // Line in the template most likely at fault; 0 if unknown
+	Output       string // Generated file
+	RenderedLine int    // 1-based line in the rendered output
+	Column       int    // 1-based column in the rendered output
+	Snippet      string // The rendered line
+	Message      string
+}
+
+// Error implements the error interface
+func (e *GoSyntaxError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "%s:%d: ", e.Template, e.Line)
+	} else {
+		fmt.Fprintf(&b, "%s: ", e.Template)
+	}
+	fmt.Fprintf(&b, "generated %s is not valid Go at line %d:%d: %s",
+		e.Output, e.RenderedLine, e.Column, e.Message)
+
+	if e.Snippet != "" {
+		writeExcerpt(&b, e.RenderedLine, e.Column, e.Snippet)
+	}
+	return b.String()
+}
+
+// formatGo formats a rendered Go file like gofmt, removing unused standard
+// library and named imports and adding missing standard library ones. name is the generated file; template
+// and body locate a syntax error in the template it was rendered from, whose
+// body starts on line bodyLine.
+func formatGo(name string, src []byte, template, body string, bodyLine int) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+	if err != nil {
+		return nil, syntaxError(err, src, name, template, body, bodyLine)
+	}
+
+	if fixed, changed := fixImports(fset, file, src); changed {
+		src = fixed
+		fset = token.NewFileSet()
+		if file, err = parser.ParseFile(fset, name, src, parser.ParseComments); err != nil {
+			return nil, fmt.Errorf("failed to fix imports of %s: %w", name, err)
+		}
+	}
+
+	ast.SortImports(fset, file)
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, fmt.Errorf("failed to format %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// edit replaces src[start:end] with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// fixImports drops the imports file does not use and adds the standard library
+// packages it uses without importing. Only imports whose package name is
+// certain are dropped; see importName.
+func fixImports(fset *token.FileSet, file *ast.File, src []byte) ([]byte, bool) {
+	// Package names are left unresolved by the parser, as is anything undeclared
+	unresolved := make(map[*ast.Ident]bool)
+	for _, id := range file.Unresolved {
+		unresolved[id] = true
+	}
+	// Unresolved names used as the X of X.Sel, and whether Sel is ever exported
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && unresolved[id] {
+				used[id.Name] = used[id.Name] || sel.Sel.IsExported()
+			}
+		}
+		return true
+	})
+
+	var edits []edit
+	imported := make(map[string]bool)
+	var lastImport *ast.GenDecl
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		lastImport = gen
+
+		var unused []ast.Spec
+		for _, spec := range gen.Specs {
+			name, known := importName(spec.(*ast.ImportSpec))
+			imported[name] = true
+			if _, ok := used[name]; known && !ok {
+				unused = append(unused, spec)
+			}
+		}
+		switch {
+		case len(unused) == 0:
+		case len(unused) == len(gen.Specs):
+			edits = append(edits, lineEdit(fset, src, gen.Pos(), gen.End()))
+			lastImport = nil
+		default:
+			for _, spec := range unused {
+				edits = append(edits, lineEdit(fset, src, spec.Pos(), spec.End()))
+			}
+		}
+	}
+
+	// Only exported names are looked up in a package; anything else is
+	// declared in another file of the package
+	var missing []string
+	for name, exported := range used {
+		if path, ok := stdlibImports[name]; ok && exported && !imported[name] {
+			missing = append(missing, strconv.Quote(path))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		if lastImport != nil && lastImport.Lparen.IsValid() {
+			offset := fset.Position(lastImport.Rparen).Offset
+			text := strings.Join(missing, "\n") + "\n"
+			if before := bytes.TrimRight(src[:offset], " \t"); !bytes.HasSuffix(before, []byte("\n")) {
+				text = "\n" + text
+			}
+			edits = append(edits, edit{offset, offset, text})
+		} else if lastImport != nil {
+			// Turn import "x" into a block
+			spec := lastImport.Specs[0]
+			start, end := fset.Position(spec.Pos()).Offset, fset.Position(spec.End()).Offset
+			text := "(\n" + string(src[start:end]) + "\n" + strings.Join(missing, "\n") + "\n)"
+			edits = append(edits, edit{start, end, text})
+		} else {
+			offset := fset.Position(file.Name.End()).Offset
+			edits = append(edits, edit{offset, offset, "\n\nimport (\n" + strings.Join(missing, "\n") + "\n)"})
+		}
+	}
+
+	if len(edits) == 0 {
+		return src, false
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	out := bytes.Clone(src)
+	for _, e := range edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+	return out, true
+}
+
+// lineEdit removes the source between pos and end, with its line if nothing else is on it
+func lineEdit(fset *token.FileSet, src []byte, pos, end token.Pos) edit {
+	start, stop := fset.Position(pos).Offset, fset.Position(end).Offset
+	lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+	lineEnd := len(src)
+	if i := bytes.IndexByte(src[stop:], '\n'); i >= 0 {
+		lineEnd = stop + i + 1
+	}
+	if len(bytes.TrimSpace(src[lineStart:start])) == 0 && len(bytes.TrimSpace(src[stop:lineEnd])) == 0 {
+		return edit{lineStart, lineEnd, ""}
+	}
+	return edit{start, stop, ""}
+}
+
+// importName returns the name an import is most likely used by, and whether it
+// is known for sure: given explicitly, or a standard library package of
+// stdlibImports or ambiguousImports. A package's name need not match its path, as in
+// "k8s.io/api/core/v1", so any other import is never reported unused; nor are
+// blank, dot and cgo imports.
+func importName(spec *ast.ImportSpec) (string, bool) {
+	importPath, err := strconv.Unquote(spec.Path.Value)
+	if err != nil || importPath == "C" {
+		return "", false
+	}
+	if spec.Name != nil {
+		name := spec.Name.Name
+		return name, name != "_" && name != "."
+	}
+
+	name := path.Base(importPath)
+	if isMajorVersion(name) && strings.Contains(importPath, "/") {
+		name = path.Base(path.Dir(importPath))
+	}
+	return name, stdlibImports[name] == importPath || slices.Contains(ambiguousImports[name], importPath)
+}
+
+// isMajorVersion reports whether a path element is a module major version suffix, like v2
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(elem[1:])
+	return err == nil
+}
+
+// syntaxError turns a parse error of rendered Go into a GoSyntaxError at the
+// first error, locating the template line the rendered line most likely came from
+func syntaxError(err error, src []byte, name, template, body string, bodyLine int) error {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return fmt.Errorf("failed to parse generated %s: %w", name, err)
+	}
+	first := list[0]
+
+	lines := strings.Split(string(src), "\n")
+	snippet := ""
+	if first.Pos.Line >= 1 && first.Pos.Line <= len(lines) {
+		snippet = strings.TrimRight(lines[first.Pos.Line-1], "\r")
+	}
+
+	line := 0
+	if body != "" {
+		if l := templateLine(body, snippet, first.Pos.Line); l > 0 {
+			line = bodyLine + l - 1
+		}
+	}
+
+	return &GoSyntaxError{
+		Template:     template,
+		Line:         line,
+		Output:       name,
+		RenderedLine: first.Pos.Line,
+		Column:       first.Pos.Column,
+		Snippet:      snippet,
+		Message:      first.Msg,
+	}
+}
+
+// templateLine guesses the 1-based line of a template body that rendered to
+// rendered, found on line renderedLine of the output: the line sharing the
+// most words with it, the nearest one on a tie. It returns 0 if none does.
+func templateLine(body, rendered string, renderedLine int) int {
+	words := strings.Fields(rendered)
+	if len(words) == 0 {
+		return 0
+	}
+
+	best, bestScore, bestDistance := 0, 0, 0
+	for i, line := range strings.Split(body, "\n") {
+		score := 0
+		for _, word := range words {
+			if strings.Contains(line, word) {
+				score++
+			}
+		}
+		distance := max(i+1-renderedLine, renderedLine-i-1)
+		if score > bestScore || score == bestScore && score > 0 && distance < bestDistance {
+			best, bestScore, bestDistance = i+1, score, distance
+		}
+	}
+	return best
+}
@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+func TestFormatGo(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "formats",
+			src:  "package main\nfunc main( ) {\nx:=1\n_ = x\n\n\n}",
+			want: "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n\n}\n",
+		},
+		{
+			name: "removes unused imports",
+			src: `package main
+
+import (
+	"fmt"
+	"os"
+	router "github.com/toutaio/toutago-cosan-router"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+func main() { os.Exit(0) }
+`,
+			want: `package main
+
+import (
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"os"
+)
+
+func main() { os.Exit(0) }
+`,
+		},
+		{
+			name: "keeps imports whose name is unknown",
+			src:  "package main\n\nimport \"github.com/toutaio/toutago-cosan-router\"\n\nvar r = cosan.New()\n",
+			want: "package main\n\nimport \"github.com/toutaio/toutago-cosan-router\"\n\nvar r = cosan.New()\n",
+		},
+		{
+			name: "keeps imports named unlike their path",
+			src:  "package main\n\nimport \"k8s.io/api/core/v1\"\n\nvar pod = v1.Pod{}\n",
+			want: "package main\n\nimport \"k8s.io/api/core/v1\"\n\nvar pod = v1.Pod{}\n",
+		},
+		{
+			name: "adds missing standard library imports",
+			src: `package handlers
+
+import "net/http"
+
+func Home(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, strings.ToUpper("home"), json.Valid(nil))
+}
+`,
+			want: `package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func Home(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, strings.ToUpper("home"), json.Valid(nil))
+}
+`,
+		},
+		{
+			name: "adds an import block",
+			src:  "package main\nfunc main() { fmt.Println() }\n",
+			want: "package main\n\nimport (\n\t\"fmt\"\n)\n\nfunc main() { fmt.Println() }\n",
+		},
+		{
+			name: "leaves ambiguous packages to the template",
+			src:  "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(rand.Int(), template.HTML(\"\"))\n}\n",
+			want: "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(rand.Int(), template.HTML(\"\"))\n}\n",
+		},
+		{
+			name: "removes unused ambiguous packages",
+			src:  "package main\n\nimport (\n\t\"crypto/rand\"\n\t\"text/template\"\n\t\"os\"\n)\n\nfunc main() { os.Exit(0) }\n",
+			want: "package main\n\nimport (\n\t\"os\"\n)\n\nfunc main() { os.Exit(0) }\n",
+		},
+		{
+			name: "ignores declared names",
+			src:  "package main\n\nfunc run(log *Logger) {\n\tlog.Print()\n\tsql.ping()\n}\n",
+			want: "package main\n\nfunc run(log *Logger) {\n\tlog.Print()\n\tsql.ping()\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatGo("main.go", []byte(tt.src), "main.go.tmpl", "", 1)
+			if err != nil {
+				t.Fatalf("formatGo() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("formatGo() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateFilesFrom_GoSyntaxError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/templates/main.go.tmpl": {Data: []byte(`---
+mode: 0644
+---
+package main
+
+func main() {
+	name := "{{ app_name }}"
+	{% if verbose %}println(name{% endif %}
+}
+`)},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen := NewFileGenerator("fith")
+	vars := NewVariables()
+	vars.Set("app_name", "blog")
+	vars.Set("verbose", true)
+	gen.SetVariables(vars)
+
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{{Source: "main.go.tmpl", Destination: "main.go"}},
+		},
+	}
+	_, err = gen.RenderToMap(manifest, src)
+
+	var syntaxErr *GoSyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected a GoSyntaxError, got %v", err)
+	}
+	want := GoSyntaxError{
+		Template:     "embedded:app/templates/main.go.tmpl",
+		Line:         8,
+		Output:       "main.go",
+		RenderedLine: 5,
+		Column:       14,
+		Snippet:      "\tprintln(name",
+		Message:      "missing ',' before newline in argument list",
+	}
+	if *syntaxErr != want {
+		t.Errorf("error = %+v, want %+v", *syntaxErr, want)
+	}
+	if !strings.HasSuffix(err.Error(), "\n    5 | \tprintln(name\n        \t            ^") {
+		t.Errorf("error should end with the snippet, got\n%s", err)
+	}
+}
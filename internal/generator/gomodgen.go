@@ -47,11 +47,9 @@ func (g *GoModGenerator) Generate(projectPath string, manifest *ritual.Manifest,
 		deps[name] = version
 	}
 
-	// Add database driver if specified
-	if manifest.Dependencies.Database != nil && len(manifest.Dependencies.Database.Types) > 0 {
-		// Use the first database type specified
-		driver := getDatabaseDriver(manifest.Dependencies.Database.Types[0])
-		if driver != "" {
+	// Add the driver of the database chosen for the project
+	if dbType := selectedDatabase(manifest, vars); dbType != "" {
+		if driver := getDatabaseDriver(dbType); driver != "" {
 			deps[driver] = "latest"
 		}
 	}
@@ -159,6 +157,24 @@ func parsePackageVersion(pkg string) (string, string) {
 	return pkg, ""
 }
 
+// selectedDatabase returns the database type answered for the project if the
+// ritual supports it, or else the first type the ritual lists
+func selectedDatabase(manifest *ritual.Manifest, vars *Variables) string {
+	if manifest.Dependencies.Database == nil || len(manifest.Dependencies.Database.Types) == 0 {
+		return ""
+	}
+	types := manifest.Dependencies.Database.Types
+	for _, name := range []string{"database_type", "database"} {
+		answer := vars.GetString(name)
+		for _, dbType := range types {
+			if answer != "" && strings.EqualFold(answer, dbType) {
+				return dbType
+			}
+		}
+	}
+	return types[0]
+}
+
 // getDatabaseDriver returns the Go package for a database driver
 func getDatabaseDriver(dbType string) string {
 	switch strings.ToLower(dbType) {
@@ -131,6 +131,11 @@ func TestGoModGenerator_AddDatabaseDriver(t *testing.T) {
 			database: "mysql",
 			expected: "github.com/go-sql-driver/mysql",
 		},
+		{
+			name:     "SQLite",
+			database: "sqlite",
+			expected: "github.com/mattn/go-sqlite3",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -174,6 +179,49 @@ func TestGoModGenerator_AddDatabaseDriver(t *testing.T) {
 	}
 }
 
+func TestGoModGenerator_AnsweredDatabaseDriver(t *testing.T) {
+	manifest := &ritual.Manifest{
+		Ritual: ritual.RitualMeta{Name: "test", Version: "1.0.0"},
+		Dependencies: ritual.Dependencies{
+			Database: &ritual.DatabaseRequirement{Types: []string{"postgres", "mysql", "sqlite"}},
+		},
+	}
+
+	testCases := []struct {
+		answer   string
+		expected string
+	}{
+		{"sqlite", "github.com/mattn/go-sqlite3"},
+		{"mysql", "github.com/go-sql-driver/mysql"},
+		{"oracle", "github.com/lib/pq"},
+		{"", "github.com/lib/pq"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.answer, func(t *testing.T) {
+			tempDir := t.TempDir()
+
+			vars := NewVariables()
+			vars.Set("database_type", tc.answer)
+
+			if err := NewGoModGenerator().Generate(tempDir, manifest, vars); err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(tempDir, "go.mod"))
+			if err != nil {
+				t.Fatalf("Failed to read go.mod: %v", err)
+			}
+
+			for _, driver := range []string{"github.com/lib/pq", "github.com/go-sql-driver/mysql", "github.com/mattn/go-sqlite3"} {
+				if strings.Contains(string(content), driver) != (driver == tc.expected) {
+					t.Errorf("go.mod for %q should require only the %s driver, got:\n%s", tc.answer, tc.expected, content)
+				}
+			}
+		})
+	}
+}
+
 func TestGoModGenerator_RunGoModTidy(t *testing.T) {
 	tempDir := t.TempDir()
 
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/funcs"
 )
 
 // HandlerGenerator generates HTTP handlers
@@ -25,6 +28,8 @@ type HandlerConfig struct {
 	Validation  bool
 	Repository  string
 	CustomLogic bool
+	Imports     []string // Packages of Model and Repository when they are qualified
+	NotFound    string   // Error the repository returns for a missing item, such as repository.ErrPostNotFound
 }
 
 // GenerateHandler generates a handler file
@@ -61,16 +66,37 @@ func (g *HandlerGenerator) GenerateHandler(targetPath string, config HandlerConf
 func (g *HandlerGenerator) generateHandlerContent(config HandlerConfig, operations []string) string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf(`package %s
+	// Get, Update and Delete read the id from the path
+	byID := slices.ContainsFunc(operations, func(op string) bool {
+		return op == "Get" || op == "Update" || op == "Delete"
+	})
+	std := []string{"encoding/json"}
+	if config.NotFound != "" && byID {
+		std = append(std, "errors")
+	}
+	std = append(std, "net/http")
+	var others []string
+	if byID || slices.Contains(operations, "List") {
+		std = append(std, "strconv")
+	}
+	if byID {
+		others = append(others, "github.com/gorilla/mux")
+	}
+	others = append(others, config.Imports...)
 
-import (
-	"encoding/json"
-	"net/http"
-	
-	"github.com/gorilla/mux"
-)
+	sb.WriteString(fmt.Sprintf("package %s\n\nimport (\n", config.Package))
+	for _, imp := range std {
+		sb.WriteString(fmt.Sprintf("\t%q\n", imp))
+	}
+	if len(others) > 0 {
+		sb.WriteString("\n")
+		for _, imp := range others {
+			sb.WriteString(fmt.Sprintf("\t%q\n", imp))
+		}
+	}
+	sb.WriteString(")\n\n")
 
-// %sHandler handles %s-related HTTP requests
+	sb.WriteString(fmt.Sprintf(`// %sHandler handles %s-related HTTP requests
 type %sHandler struct {
 	repo %s
 }
@@ -83,7 +109,6 @@ func New%sHandler(repo %s) *%sHandler {
 }
 
 `,
-		config.Package,
 		config.Name,
 		strings.ToLower(config.Name),
 		config.Name,
@@ -109,7 +134,7 @@ func (g *HandlerGenerator) generateMethod(config HandlerConfig, operation string
 
 	// Pluralize List method names
 	if operation == "List" {
-		methodName = operation + config.Name + "s"
+		methodName = operation + funcs.Pluralize(config.Name)
 	}
 
 	switch operation {
@@ -172,14 +197,16 @@ func (h *%sHandler) %s(w http.ResponseWriter, r *http.Request) {
 func (g *HandlerGenerator) generateGetMethod(config HandlerConfig, methodName string) string {
 	return fmt.Sprintf(`// %s retrieves a %s by ID
 func (h *%sHandler) %s(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-	
-	item, err := h.repo.GetByID(r.Context(), id)
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 0)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
+
+	item, err := h.repo.GetByID(r.Context(), uint(id))
+	if err != nil {
+%s		return
+	}
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(item)
@@ -189,13 +216,30 @@ func (h *%sHandler) %s(w http.ResponseWriter, r *http.Request) {
 		strings.ToLower(config.Name),
 		config.Name,
 		methodName,
+		repositoryError(config, "StatusNotFound"),
 	)
 }
 
 func (g *HandlerGenerator) generateListMethod(config HandlerConfig, methodName string) string {
-	return fmt.Sprintf(`// %s retrieves all %ss
+	return fmt.Sprintf(`// %s retrieves a page of %s, given by the limit (default 20,
+// at most 100) and offset query parameters
 func (h *%sHandler) %s(w http.ResponseWriter, r *http.Request) {
-	items, err := h.repo.List(r.Context())
+	limit, offset := 20, 0
+	var err error
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			http.Error(w, "offset must not be negative", http.StatusBadRequest)
+			return
+		}
+	}
+
+	items, err := h.repo.List(r.Context(), limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -206,7 +250,7 @@ func (h *%sHandler) %s(w http.ResponseWriter, r *http.Request) {
 }
 `,
 		methodName,
-		strings.ToLower(config.Name),
+		strings.ToLower(funcs.Pluralize(config.Name)),
 		config.Name,
 		methodName,
 	)
@@ -226,19 +270,21 @@ func (g *HandlerGenerator) generateUpdateMethod(config HandlerConfig, methodName
 
 	return fmt.Sprintf(`// %s updates a %s
 func (h *%sHandler) %s(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-	
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
 	var item %s
 	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 %s
-	updated, err := h.repo.Update(r.Context(), id, &item)
+	updated, err := h.repo.Update(r.Context(), uint(id), &item)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+%s		return
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -251,19 +297,22 @@ func (h *%sHandler) %s(w http.ResponseWriter, r *http.Request) {
 		methodName,
 		config.Model,
 		validation,
+		repositoryError(config, "StatusInternalServerError"),
 	)
 }
 
 func (g *HandlerGenerator) generateDeleteMethod(config HandlerConfig, methodName string) string {
 	return fmt.Sprintf(`// %s deletes a %s
 func (h *%sHandler) %s(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	id := vars["id"]
-	
-	if err := h.repo.Delete(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		http.Error(w, "invalid id", http.StatusBadRequest)
 		return
 	}
+
+	if err := h.repo.Delete(r.Context(), uint(id)); err != nil {
+%s		return
+	}
 	
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -272,9 +321,24 @@ func (h *%sHandler) %s(w http.ResponseWriter, r *http.Request) {
 		strings.ToLower(config.Name),
 		config.Name,
 		methodName,
+		repositoryError(config, "StatusInternalServerError"),
 	)
 }
 
+// repositoryError returns the code answering an error err of the repository
+// about an item: 404 for config.NotFound, otherwise status
+func repositoryError(config HandlerConfig, status string) string {
+	if config.NotFound == "" {
+		return fmt.Sprintf("\t\thttp.Error(w, err.Error(), http.%s)\n", status)
+	}
+	return fmt.Sprintf(`		if errors.Is(err, %s) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+`, config.NotFound)
+}
+
 func (g *HandlerGenerator) generateCustomMethod(config HandlerConfig, methodName, operation string) string {
 	todoComment := ""
 	if config.CustomLogic {
@@ -231,3 +231,50 @@ func TestHandlerGenerator_GenerateWithCustomLogic(t *testing.T) {
 		t.Error("Custom logic handlers should have TODO comments")
 	}
 }
+
+func TestHandlerGenerator_ParsesIDsAndPluralizes(t *testing.T) {
+	gen := NewHandlerGenerator()
+	content := gen.generateHandlerContent(HandlerConfig{
+		Name:       "Category",
+		Package:    "handlers",
+		Model:      "models.Category",
+		Repository: "repository.CategoryRepository",
+		Imports:    []string{"example.com/shop/internal/models", "example.com/shop/internal/repository"},
+	}, []string{"Get", "List"})
+
+	for _, part := range []string{
+		"\t\"strconv\"\n\n\t\"github.com/gorilla/mux\"\n\t\"example.com/shop/internal/models\"\n",
+		`strconv.ParseUint(mux.Vars(r)["id"], 10, 0)`,
+		"h.repo.GetByID(r.Context(), uint(id))",
+		"func (h *CategoryHandler) ListCategories(",
+	} {
+		if !strings.Contains(content, part) {
+			t.Errorf("handler should contain %q, got:\n%s", part, content)
+		}
+	}
+
+	content = gen.generateHandlerContent(HandlerConfig{Name: "Report", Model: "Report", Repository: "ReportRepository"}, []string{"List"})
+	if strings.Contains(content, "gorilla/mux") {
+		t.Errorf("a handler reading no id should not import mux, got:\n%s", content)
+	}
+	if !strings.Contains(content, "h.repo.List(r.Context(), limit, offset)") {
+		t.Errorf("List should read a page of the query, got:\n%s", content)
+	}
+}
+
+func TestHandlerGenerator_NotFound(t *testing.T) {
+	gen := NewHandlerGenerator()
+	content := gen.generateHandlerContent(HandlerConfig{
+		Name:       "Post",
+		Model:      "models.Post",
+		Repository: "repository.PostRepository",
+		NotFound:   "repository.ErrPostNotFound",
+	}, []string{"Get", "Update", "Delete"})
+
+	if got := strings.Count(content, "if errors.Is(err, repository.ErrPostNotFound) {\n\t\t\thttp.Error(w, err.Error(), http.StatusNotFound)"); got != 3 {
+		t.Errorf("Get, Update and Delete should answer 404 for a missing post, %d do:\n%s", got, content)
+	}
+	if !strings.Contains(content, "\t\"errors\"\n") {
+		t.Errorf("handler should import errors, got:\n%s", content)
+	}
+}
@@ -4,7 +4,8 @@ import (
 	"strings"
 )
 
-// DockerImage returns the appropriate Docker image for a database type
+// DockerImage returns the appropriate Docker image for a database type, or
+// "" for one without a server, such as sqlite
 func DockerImage(databaseType string) string {
 	switch databaseType {
 	case "postgres":
@@ -40,6 +41,12 @@ func HealthCheck(databaseType string) string {
 	}
 }
 
+// HasDatabaseService returns true if the database type runs as a separate
+// service; a SQLite database is a file of the app
+func HasDatabaseService(databaseType string) bool {
+	return DockerImage(databaseType) != ""
+}
+
 // HasFrontend returns true if the frontend type requires a separate build service
 func HasFrontend(frontendType string) bool {
 	switch frontendType {
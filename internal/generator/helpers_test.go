@@ -24,6 +24,11 @@ func TestDockerImageHelper(t *testing.T) {
 			databaseType: "mysql",
 			expected:     "mysql:8-alpine",
 		},
+		{
+			name:         "sqlite database",
+			databaseType: "sqlite",
+			expected:     "",
+		},
 		{
 			name:         "empty database",
 			databaseType: "",
@@ -103,6 +108,15 @@ func TestHealthCheckHelper(t *testing.T) {
 	}
 }
 
+// TestHasDatabaseServiceHelper tests hasDatabaseService template function
+func TestHasDatabaseServiceHelper(t *testing.T) {
+	for databaseType, expected := range map[string]bool{"postgres": true, "mysql": true, "sqlite": false, "": false} {
+		t.Run(databaseType, func(t *testing.T) {
+			assert.Equal(t, expected, generator.HasDatabaseService(databaseType))
+		})
+	}
+}
+
 // TestHasFrontendHelper tests hasFrontend template function
 func TestHasFrontendHelper(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,173 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/inject"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// injection is where a planned snippet goes in the file it is injected into
+type injection struct {
+	anchor   inject.Anchor
+	optional bool // A missing file is skipped rather than an error
+	changed  bool // The snippet was inserted, so the file is written
+}
+
+// planInjections plans the snippets of inject mappings. They come after every
+// other file, so they are inserted into files of the same run once written.
+func (g *FileGenerator) planInjections(plan *generationPlan, src *ritual.Source, injections []ritual.Injection) error {
+	for _, inj := range injections {
+		if inj.Condition != "" {
+			ok, err := evaluateCondition(inj.Condition, g.variables.All())
+			if err != nil {
+				return fmt.Errorf("failed to evaluate condition for injection into %s: %w", inj.Into, err)
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		into, err := g.engine.Render(inj.Into, g.variables.All())
+		if err != nil {
+			return fmt.Errorf("failed to render injection path %s: %w", inj.Into, err)
+		}
+		if plan.root != "" && !filepath.IsLocal(into) {
+			return fmt.Errorf("injection path %q must stay inside the project", into)
+		}
+
+		anchor := inject.Anchor{Marker: inj.Before, Regex: inj.Regex}
+		if inj.After != "" {
+			anchor = inject.Anchor{Marker: inj.After, Regex: inj.Regex, After: true}
+		}
+		planned := &plannedFile{
+			file:       sourceFile{display: "inject into " + into},
+			dest:       filepath.Join(plan.root, into),
+			source:     "inject:" + into,
+			isTemplate: true,
+			opts:       defaultFileOptions(),
+			body:       inj.Content,
+			bodyLine:   1,
+			engine:     g.engine,
+			vars:       g.variables,
+			origin:     fmt.Sprintf("injection %s into %s", anchor, into),
+			inject:     &injection{anchor: anchor, optional: inj.Optional},
+		}
+		if inj.Source != "" {
+			planned.file = resolveSource(src, inj.Source, "templates")
+			planned.source = inj.Source
+			planned.origin = fmt.Sprintf("injection of %s into %s", inj.Source, into)
+			if _, err := planned.file.stat(); err != nil {
+				return fmt.Errorf("injection template not found: %s", planned.file.display)
+			}
+			content, err := fs.ReadFile(planned.file.fsys, planned.file.name)
+			if err != nil {
+				return fmt.Errorf("failed to read injection template %s: %w", planned.file.display, err)
+			}
+			planned.body = string(content)
+		}
+		plan.files = append(plan.files, planned)
+	}
+	return nil
+}
+
+// InjectStage inserts the rendered snippets of inject mappings into their
+// files, in memory. A missing marker fails the run before anything is written.
+type InjectStage struct{}
+
+// Name implements Stage
+func (InjectStage) Name() string { return StageInject }
+
+// Run implements Stage
+func (InjectStage) Run(ctx context.Context, run *Run) error {
+	g := run.Generator
+	current := make(map[string][]byte) // Content of each file so far, with the snippets inserted before
+	for i, file := range run.plan.files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if file.inject == nil || !file.rendered {
+			continue
+		}
+		dest := filepath.Clean(file.dest)
+		content, ok := current[dest]
+		if !ok {
+			var err error
+			if content, err = g.injectionBase(run.plan.files[:i], file); err != nil {
+				return err
+			}
+			if content == nil {
+				// A preview has none of the files the run does not write
+				if file.inject.optional || g.preview {
+					continue
+				}
+				return fmt.Errorf("failed to apply %s: %s does not exist", file.origin, run.plan.display(dest))
+			}
+		}
+
+		injected, changed, err := inject.Into(content, string(file.content), file.inject.anchor)
+		if err != nil {
+			return fmt.Errorf("failed to apply %s: %w", file.origin, err)
+		}
+		file.content, file.inject.changed = injected, changed
+		current[dest] = injected
+	}
+	return nil
+}
+
+// injectionBase returns the content a snippet is inserted into: that of the
+// last file earlier in the plan writing the same destination, or else the
+// file already there. It is nil if there is no such file.
+func (g *FileGenerator) injectionBase(earlier []*plannedFile, p *plannedFile) ([]byte, error) {
+	dest := filepath.Clean(p.dest)
+	for j := len(earlier) - 1; j >= 0; j-- {
+		f := earlier[j]
+		if f.inject != nil || filepath.Clean(f.dest) != dest || g.keepsExisting(f) {
+			continue
+		}
+		if f.rendered {
+			return f.content, nil
+		}
+		if !f.isTemplate {
+			content, err := fs.ReadFile(f.file.fsys, f.file.name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", f.file.display, err)
+			}
+			return content, nil
+		}
+	}
+
+	content, err := g.output.ReadFile(p.dest)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", p.dest, err)
+	}
+	return content, nil
+}
+
+// writeInjected writes a file a snippet was inserted into, keeping its mode.
+// The file is only recorded as generated if it is tracked already.
+func (g *FileGenerator) writeInjected(p *plannedFile) error {
+	if !p.inject.changed {
+		return nil
+	}
+	mode := os.FileMode(0600)
+	if info, err := g.output.Stat(p.dest); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := g.output.WriteFile(p.dest, p.content, mode); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", p.dest, err)
+	}
+	if !slices.ContainsFunc(g.generated, func(f GeneratedFile) bool { return f.Path == p.dest }) {
+		g.generated = append(g.generated, GeneratedFile{Path: p.dest, Source: p.source, Injected: true})
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/inject"
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// injectRun is a run of pipelineRun whose ritual also injects into the
+// built-in main.go, its own routes file and a README already in the project
+func injectRun(t *testing.T, injections ...ritual.Injection) (*Run, *MemoryFS) {
+	t.Helper()
+	run, output := pipelineRun(t)
+	fsys := fstest.MapFS{
+		"app/templates/routes.go.tmpl": {Data: []byte("package routes\n\nfunc Setup() {\n\t// ritual:routes\n}\n")},
+		"app/templates/posts.tmpl":     {Data: []byte("mux.HandleFunc(\"/[[ .resource ]]\", nil)\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run.Source = src
+	run.Manifest.Files.Templates = []ritual.FileMapping{{Source: "routes.go.tmpl", Destination: "internal/routes/routes.go"}}
+	run.Manifest.Files.Inject = injections
+	run.Variables.Set("resource", "posts")
+	return run, output
+}
+
+func TestDefaultPipeline_Injects(t *testing.T) {
+	run, output := injectRun(t,
+		ritual.Injection{Source: "posts.tmpl", Into: "cmd/server/main.go", Before: inject.MarkerRoutes},
+		ritual.Injection{Content: "// [[ .resource ]]", Into: "internal/routes/routes.go", Before: inject.MarkerRoutes},
+		ritual.Injection{Content: "- posts", Into: "CHANGES.md", After: `^## Unreleased`, Regex: true},
+		ritual.Injection{Content: "x", Into: "missing.go", Before: "// x", Optional: true},
+	)
+	if err := output.WriteFile("/project/CHANGES.md", []byte("# Changes\n## Unreleased\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DefaultPipeline().Run(context.Background(), run); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	files := output.Files("/project")
+	if got := string(files["cmd/server/main.go"]); !strings.Contains(got, "\tmux.HandleFunc(\"/posts\", nil)\n\t// ritual:routes") {
+		t.Errorf("the snippet should be injected into the built-in main.go, got:\n%s", got)
+	}
+	if got := string(files["internal/routes/routes.go"]); got != "package routes\n\nfunc Setup() {\n\t// posts\n\t// ritual:routes\n}\n" {
+		t.Errorf("the snippet should be injected into the ritual's routes.go, got %q", got)
+	}
+	if got := string(files["CHANGES.md"]); got != "# Changes\n## Unreleased\n- posts\n" {
+		t.Errorf("the snippet should be injected into the existing file, got %q", got)
+	}
+	if _, ok := files["missing.go"]; ok {
+		t.Error("an optional injection into a missing file should be skipped")
+	}
+
+	routes, ok := run.State.GetGeneratedFile("internal/routes/routes.go")
+	if !ok || routes.Source != "routes.go.tmpl" || routes.SHA256 != storage.HashContent(files["internal/routes/routes.go"]) {
+		t.Errorf("routes.go should be recorded with the snippet, as %+v", routes)
+	}
+	if run.State.IsFileGenerated("CHANGES.md") {
+		t.Error("a file the ritual did not generate should not be tracked because of an injection")
+	}
+}
+
+func TestDefaultPipeline_InjectTwiceIsIdempotent(t *testing.T) {
+	injection := ritual.Injection{Content: "- [[ .resource ]]", Into: "CHANGES.md", After: "## Unreleased"}
+	run, output := injectRun(t, injection)
+	if err := output.WriteFile("/project/CHANGES.md", []byte("## Unreleased\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultPipeline().Run(context.Background(), run); err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := injectRun(t, injection)
+	again.Generator.SetOutput(output)
+	if err := NewPipeline(FileStages()...).Run(context.Background(), again); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(output.Files("/project")["CHANGES.md"]); got != "## Unreleased\n- posts\n" {
+		t.Errorf("CHANGES.md = %q, want the snippet once", got)
+	}
+}
+
+func TestDefaultPipeline_InjectMissingMarker(t *testing.T) {
+	run, output := injectRun(t, ritual.Injection{Content: "r.Use(Auth)", Into: "internal/routes/routes.go", Before: "// ritual:middleware"})
+
+	err := DefaultPipeline().Run(context.Background(), run)
+	if !errors.Is(err, inject.ErrMarkerNotFound) || !strings.Contains(err.Error(), "internal/routes/routes.go") {
+		t.Fatalf("Run() error = %v, want the missing marker reported", err)
+	}
+	if files := output.Files("/project"); len(files) != 0 {
+		t.Errorf("nothing should be written, got %v", files)
+	}
+}
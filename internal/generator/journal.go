@@ -0,0 +1,415 @@
+package generator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// JournalAction is a kind of filesystem change recorded in a Journal
+type JournalAction string
+
+const (
+	// JournalCreateDir records a directory that did not exist before
+	JournalCreateDir JournalAction = "create_dir"
+	// JournalCreateFile records a file that did not exist before
+	JournalCreateFile JournalAction = "create_file"
+	// JournalOverwrite records a file that existed, with its previous content and mode
+	JournalOverwrite JournalAction = "overwrite"
+	// JournalWatchDir records a directory that existed, with the paths in it, so
+	// that whatever appears in it later can be removed again
+	JournalWatchDir JournalAction = "watch_dir"
+)
+
+// JournalEntry is one recorded change
+type JournalEntry struct {
+	Action   JournalAction
+	Path     string
+	Previous []byte                 // Content before an overwrite
+	Mode     os.FileMode            // Mode before an overwrite
+	Existing map[string]os.FileInfo // Paths in a watched directory when it was tracked
+}
+
+// Journal records the filesystem changes made while generating a project so
+// that a failed generation can be rolled back to the prior state. Rollback
+// removes what the generation created and restores what it overwrote; it
+// never removes a file or directory that was there before.
+//
+// Only the first change to a path is recorded: it holds the state to restore.
+type Journal struct {
+	mu      sync.Mutex
+	entries []JournalEntry
+	seen    map[string]bool
+}
+
+// NewJournal creates an empty journal
+func NewJournal() *Journal {
+	return &Journal{seen: make(map[string]bool)}
+}
+
+// Entries returns the recorded changes in the order they were made
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// Len returns the number of recorded changes
+func (j *Journal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.entries)
+}
+
+// Commit forgets the recorded changes, keeping everything written so far
+func (j *Journal) Commit() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = nil
+	j.seen = make(map[string]bool)
+}
+
+// record adds an entry unless the path already has one. Callers hold j.mu.
+func (j *Journal) record(entry JournalEntry) {
+	if j.seen[entry.Path] {
+		return
+	}
+	j.seen[entry.Path] = true
+	j.entries = append(j.entries, entry)
+}
+
+// MkdirAll creates a directory and any missing parents, recording each one it creates
+func (j *Journal) MkdirAll(path string, perm os.FileMode) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.mkdirAll(path, perm)
+}
+
+func (j *Journal) mkdirAll(path string, perm os.FileMode) error {
+	path = filepath.Clean(path)
+
+	var missing []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if _, err := os.Lstat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		j.record(JournalEntry{Action: JournalCreateDir, Path: missing[i]})
+	}
+	return nil
+}
+
+// WriteFile writes a file like os.WriteFile, creating its directory and recording
+// whether it was created or overwritten
+func (j *Journal) WriteFile(path string, data []byte, perm os.FileMode) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	path = filepath.Clean(path)
+	if err := j.mkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	if err := j.trackFile(path); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
+
+// Rename moves a file into place, recording whether newpath was created or replaced
+func (j *Journal) Rename(oldpath, newpath string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	newpath = filepath.Clean(newpath)
+	if err := j.mkdirAll(filepath.Dir(newpath), 0750); err != nil {
+		return err
+	}
+	if err := j.trackFile(newpath); err != nil {
+		return err
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+// Track records the current state of a path that is about to be changed by
+// code that does not write through the journal, such as a hook or a directory copy.
+//
+// A missing path is recorded as created. For an existing directory only the
+// paths in it are listed, without reading any file: rollback removes what
+// appears in it later and never removes what was already there. A file that
+// existed and is changed outside the journal cannot be restored; rollback
+// reports it.
+func (j *Journal) Track(path string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	path = filepath.Clean(path)
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		if err := j.mkdirAll(filepath.Dir(path), 0750); err != nil {
+			return err
+		}
+		// Whatever appears here is new; removing it restores the prior state
+		j.record(JournalEntry{Action: JournalCreateDir, Path: path})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return j.trackFile(path)
+	}
+	if j.seen[path] {
+		return nil
+	}
+
+	entry := JournalEntry{Action: JournalWatchDir, Path: path, Existing: make(map[string]os.FileInfo)}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// An unreadable directory is kept as it is, with whatever is in it
+			return skipUnreadable(d)
+		}
+		if p == path {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil // Gone since it was listed
+		}
+		entry.Existing[p] = info
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record %s: %w", path, err)
+	}
+	j.record(entry)
+	return nil
+}
+
+// skipUnreadable lets a walk go on past a path it could not read
+func skipUnreadable(d fs.DirEntry) error {
+	if d != nil && d.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+// trackFile records a file before it is written. Callers hold j.mu.
+func (j *Journal) trackFile(path string) error {
+	if j.seen[path] {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		j.record(JournalEntry{Action: JournalCreateFile, Path: path})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// #nosec G304 - path is a generation target being backed up
+	previous, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to back up %s: %w", path, err)
+	}
+	j.record(JournalEntry{Action: JournalOverwrite, Path: path, Previous: previous, Mode: info.Mode().Perm()})
+	return nil
+}
+
+// Rollback undoes the recorded changes, newest first, and empties the journal.
+// It keeps going after a failed step and returns every error it met.
+func (j *Journal) Rollback() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var errs []error
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		if err := undo(j.entries[i], j.seen); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore %s: %w", j.entries[i].Path, err))
+		}
+	}
+	j.entries = nil
+	j.seen = make(map[string]bool)
+	return errors.Join(errs...)
+}
+
+// undo reverts a single entry. Paths in recorded hold the paths with entries
+// of their own, which a watched directory leaves to them.
+func undo(entry JournalEntry, recorded map[string]bool) error {
+	switch entry.Action {
+	case JournalCreateDir:
+		return os.RemoveAll(entry.Path)
+	case JournalCreateFile:
+		if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	case JournalOverwrite:
+		if err := os.WriteFile(entry.Path, entry.Previous, entry.Mode); err != nil {
+			return err
+		}
+		return os.Chmod(entry.Path, entry.Mode)
+	case JournalWatchDir:
+		return unwatch(entry, recorded)
+	}
+	return fmt.Errorf("unknown journal action %q", entry.Action)
+}
+
+// unwatch removes every path that appeared in a watched directory and reports
+// the files that existed and were changed or removed outside the journal
+func unwatch(entry JournalEntry, recorded map[string]bool) error {
+	var changed []string
+	err := filepath.WalkDir(entry.Path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return skipUnreadable(d)
+		}
+		if p == entry.Path || recorded[p] {
+			return nil
+		}
+		before, existed := entry.Existing[p]
+		if !existed {
+			if err := os.RemoveAll(p); err != nil {
+				return err
+			}
+			return skipUnreadable(d)
+		}
+		if before.Mode().IsRegular() {
+			if info, err := d.Info(); err == nil && (info.Size() != before.Size() || !info.ModTime().Equal(before.ModTime())) {
+				changed = append(changed, p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for p := range entry.Existing {
+		if _, err := os.Lstat(p); os.IsNotExist(err) && !recorded[p] {
+			changed = append(changed, p)
+		}
+	}
+	if len(changed) > 0 {
+		sort.Strings(changed)
+		return fmt.Errorf("%d path(s) changed or removed outside the generator cannot be restored: %s", len(changed), strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// ErrorPolicy decides what happens to a partly generated project when generation fails
+type ErrorPolicy string
+
+const (
+	// OnErrorRollback removes what generation created and restores what it overwrote
+	OnErrorRollback ErrorPolicy = "rollback"
+	// OnErrorAbort stops at the first error and leaves the files written so far
+	OnErrorAbort ErrorPolicy = "abort"
+	// OnErrorLeavePartial leaves the files written so far and records them in the project state
+	OnErrorLeavePartial ErrorPolicy = "leave-partial"
+	// OnErrorAsk asks whether to roll back
+	OnErrorAsk ErrorPolicy = "ask"
+)
+
+// ErrorPolicies lists the accepted --on-error values
+var ErrorPolicies = []ErrorPolicy{OnErrorRollback, OnErrorAbort, OnErrorLeavePartial, OnErrorAsk}
+
+// ParseErrorPolicy parses an --on-error value. An empty value means rollback.
+func ParseErrorPolicy(value string) (ErrorPolicy, error) {
+	if value == "" {
+		return OnErrorRollback, nil
+	}
+	for _, policy := range ErrorPolicies {
+		if ErrorPolicy(value) == policy {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("invalid --on-error value %q (must be one of: rollback, abort, leave-partial, ask)", value)
+}
+
+// Recover applies policy to the journal after generation failed and reports
+// whether the changes were rolled back. An empty policy means OnErrorRollback.
+// For OnErrorAsk, confirm decides; without a confirm function the changes are rolled back.
+func (j *Journal) Recover(policy ErrorPolicy, confirm func() bool) (bool, error) {
+	if policy == "" {
+		policy = OnErrorRollback
+	}
+	rollback := policy == OnErrorRollback || policy == OnErrorAsk && (confirm == nil || confirm())
+	if !rollback {
+		return false, nil
+	}
+	if err := j.Rollback(); err != nil {
+		return false, fmt.Errorf("rollback incomplete: %w", err)
+	}
+	return true, nil
+}
+
+// Recovery applies an ErrorPolicy to a project left partly generated by a failure
+type Recovery struct {
+	Journal *Journal
+	Policy  ErrorPolicy
+	// Confirm is asked whether to roll back under OnErrorAsk
+	Confirm func() bool
+	// SaveState, if set, records the files kept by OnErrorLeavePartial in the project state
+	SaveState func() error
+	// Out, if set, is told what became of the project at Path; otherwise the
+	// returned error says whether the changes were rolled back
+	Out  io.Writer
+	Path string
+}
+
+// Recover handles the project after cause and returns cause, noting any
+// failure to roll back or to save the state
+func (r Recovery) Recover(cause error) error {
+	if r.Policy == OnErrorLeavePartial && r.SaveState != nil {
+		if err := r.SaveState(); err != nil {
+			return fmt.Errorf("%w (%v)", cause, err)
+		}
+		r.Journal.Commit()
+		r.printf("⚠ Partial project left at %s; generated files are recorded in .ritual/state.yaml\n", r.Path)
+		return cause
+	}
+
+	rolledBack, err := r.Journal.Recover(r.Policy, r.Confirm)
+	if err != nil {
+		return fmt.Errorf("%w (%v)", cause, err)
+	}
+	switch {
+	case rolledBack && r.Out == nil:
+		return fmt.Errorf("%w (changes rolled back)", cause)
+	case rolledBack:
+		r.printf("✓ Rolled back all changes to %s\n", r.Path)
+	default:
+		r.printf("⚠ Partial project left at %s\n", r.Path)
+	}
+	return cause
+}
+
+func (r Recovery) printf(format string, args ...interface{}) {
+	if r.Out != nil {
+		_, _ = fmt.Fprintf(r.Out, format, args...)
+	}
+}
+
+// ConfirmRollback asks on in whether to roll back after cause; anything but "n" means yes
+func ConfirmRollback(in io.Reader, out io.Writer, cause error) func() bool {
+	return func() bool {
+		_, _ = fmt.Fprintf(out, "Project generation failed: %v\nRoll back all changes? [Y/n]: ", cause)
+		answer, _ := bufio.NewReader(in).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer != "n" && answer != "no"
+	}
+}
@@ -0,0 +1,307 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournal_RollbackCreated(t *testing.T) {
+	tmpDir := t.TempDir()
+	journal := NewJournal()
+
+	file := filepath.Join(tmpDir, "project", "internal", "app", "main.go")
+	if err := journal.WriteFile(file, []byte("package app"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := journal.MkdirAll(filepath.Join(tmpDir, "project", "docs"), 0750); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+
+	want := []JournalEntry{
+		{Action: JournalCreateDir, Path: filepath.Join(tmpDir, "project")},
+		{Action: JournalCreateDir, Path: filepath.Join(tmpDir, "project", "internal")},
+		{Action: JournalCreateDir, Path: filepath.Join(tmpDir, "project", "internal", "app")},
+		{Action: JournalCreateFile, Path: file},
+		{Action: JournalCreateDir, Path: filepath.Join(tmpDir, "project", "docs")},
+	}
+	entries := journal.Entries()
+	if len(entries) != len(want) {
+		t.Fatalf("Entries() = %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i := range want {
+		if entries[i].Action != want[i].Action || entries[i].Path != want[i].Path {
+			t.Errorf("entry %d = %s %s, want %s %s", i, entries[i].Action, entries[i].Path, want[i].Action, want[i].Path)
+		}
+	}
+
+	if err := journal.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "project")); !os.IsNotExist(err) {
+		t.Errorf("project directory should be removed, stat error = %v", err)
+	}
+	if _, err := os.Stat(tmpDir); err != nil {
+		t.Errorf("pre-existing directory should be kept: %v", err)
+	}
+	if journal.Len() != 0 {
+		t.Errorf("Len() after Rollback() = %d, want 0", journal.Len())
+	}
+}
+
+func TestJournal_RollbackOverwrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.yaml")
+	if err := os.WriteFile(path, []byte("original"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	journal := NewJournal()
+	if err := journal.WriteFile(path, []byte("first"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0700); err != nil {
+		t.Fatal(err)
+	}
+	// Only the first write holds the state to restore
+	if err := journal.WriteFile(path, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if journal.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", journal.Len())
+	}
+
+	if err := journal.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "original" {
+		t.Errorf("content = %q, want %q", content, "original")
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+}
+
+func TestJournal_TrackDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	project := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(filepath.Join(project, "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"sub/keep.txt": "keep", "config.yaml": "original"} {
+		if err := os.WriteFile(filepath.Join(project, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("sub/keep.txt", filepath.Join(project, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	journal := NewJournal()
+	if err := journal.Track(project); err != nil {
+		t.Fatalf("Track() error = %v", err)
+	}
+
+	// Changes made behind the journal's back, as a hook would
+	if err := os.WriteFile(filepath.Join(project, "new.txt"), []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(project, "vendor", "pkg"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	// And through the journal
+	if err := journal.WriteFile(filepath.Join(project, "config.yaml"), []byte("generated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := journal.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	for _, name := range []string{"new.txt", "vendor"} {
+		if _, err := os.Lstat(filepath.Join(project, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed", name)
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(project, "link")); err != nil || target != "sub/keep.txt" {
+		t.Errorf("symlink should be kept, got %q, %v", target, err)
+	}
+	for name, want := range map[string]string{"sub/keep.txt": "keep", "config.yaml": "original"} {
+		if content, _ := os.ReadFile(filepath.Join(project, name)); string(content) != want {
+			t.Errorf("%s = %q, want %q", name, content, want)
+		}
+	}
+}
+
+func TestJournal_TrackDirectoryChangedOutside(t *testing.T) {
+	project := t.TempDir()
+	keep := filepath.Join(project, "keep.txt")
+	if err := os.WriteFile(keep, []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	journal := NewJournal()
+	if err := journal.Track(project); err != nil {
+		t.Fatalf("Track() error = %v", err)
+	}
+	if err := os.WriteFile(keep, []byte("changed by a hook"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The previous content was never read, so the file is kept and reported
+	err := journal.Rollback()
+	if err == nil || !strings.Contains(err.Error(), keep) {
+		t.Errorf("Rollback() error = %v, want it to report %s", err, keep)
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Errorf("keep.txt should not be removed: %v", err)
+	}
+}
+
+func TestJournal_TrackMissing(t *testing.T) {
+	tmpDir := t.TempDir()
+	stateDir := filepath.Join(tmpDir, "project", ".ritual")
+
+	journal := NewJournal()
+	if err := journal.Track(stateDir); err != nil {
+		t.Fatalf("Track() error = %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "state.yaml"), []byte("ritual: x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := journal.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "project")); !os.IsNotExist(err) {
+		t.Error("project directory should be removed")
+	}
+}
+
+func TestJournal_Recover(t *testing.T) {
+	tests := []struct {
+		policy     ErrorPolicy
+		confirm    func() bool
+		rolledBack bool
+	}{
+		{policy: "", rolledBack: true},
+		{policy: OnErrorRollback, rolledBack: true},
+		{policy: OnErrorAbort, rolledBack: false},
+		{policy: OnErrorLeavePartial, rolledBack: false},
+		{policy: OnErrorAsk, rolledBack: true},
+		{policy: OnErrorAsk, confirm: func() bool { return true }, rolledBack: true},
+		{policy: OnErrorAsk, confirm: func() bool { return false }, rolledBack: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.txt")
+			journal := NewJournal()
+			if err := journal.WriteFile(path, []byte("x"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			rolledBack, err := journal.Recover(tt.policy, tt.confirm)
+			if err != nil {
+				t.Fatalf("Recover() error = %v", err)
+			}
+			if rolledBack != tt.rolledBack {
+				t.Errorf("Recover() = %v, want %v", rolledBack, tt.rolledBack)
+			}
+			_, statErr := os.Stat(path)
+			if exists := statErr == nil; exists == tt.rolledBack {
+				t.Errorf("file exists = %v after Recover() = %v", exists, rolledBack)
+			}
+		})
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	cause := errors.New("render failed")
+	tests := []struct {
+		name      string
+		policy    ErrorPolicy
+		answer    string
+		saveState bool
+		exists    bool
+		output    string
+	}{
+		{name: "rollback", policy: OnErrorRollback, output: "Rolled back all changes"},
+		{name: "ask yes", policy: OnErrorAsk, answer: "\n", output: "Rolled back all changes"},
+		{name: "ask no", policy: OnErrorAsk, answer: "n\n", exists: true, output: "Partial project left"},
+		{name: "leave partial", policy: OnErrorLeavePartial, saveState: true, exists: true, output: "recorded in .ritual/state.yaml"},
+		{name: "leave partial without state", policy: OnErrorLeavePartial, exists: true, output: "Partial project left"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "file.txt")
+			journal := NewJournal()
+			if err := journal.WriteFile(path, []byte("x"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			var out bytes.Buffer
+			saved := false
+			recovery := Recovery{
+				Journal: journal,
+				Policy:  tt.policy,
+				Confirm: ConfirmRollback(strings.NewReader(tt.answer), &out, cause),
+				Out:     &out,
+				Path:    filepath.Dir(path),
+			}
+			if tt.saveState {
+				recovery.SaveState = func() error { saved = true; return nil }
+			}
+			if err := recovery.Recover(cause); err != cause {
+				t.Errorf("Recover() error = %v, want %v", err, cause)
+			}
+
+			if _, err := os.Stat(path); (err == nil) != tt.exists {
+				t.Errorf("file exists = %v, want %v", err == nil, tt.exists)
+			}
+			if saved != tt.saveState {
+				t.Errorf("state saved = %v, want %v", saved, tt.saveState)
+			}
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("output should contain %q, got %q", tt.output, out.String())
+			}
+		})
+	}
+
+	t.Run("without output", func(t *testing.T) {
+		journal := NewJournal()
+		if err := journal.WriteFile(filepath.Join(t.TempDir(), "file.txt"), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := Recovery{Journal: journal, Policy: OnErrorRollback}.Recover(cause)
+		if !errors.Is(err, cause) || !strings.Contains(err.Error(), "changes rolled back") {
+			t.Errorf("Recover() error = %v, want cause noting the rollback", err)
+		}
+	})
+}
+
+func TestParseErrorPolicy(t *testing.T) {
+	for _, value := range []string{"rollback", "abort", "leave-partial", "ask"} {
+		policy, err := ParseErrorPolicy(value)
+		if err != nil || string(policy) != value {
+			t.Errorf("ParseErrorPolicy(%q) = %q, %v", value, policy, err)
+		}
+	}
+	if policy, err := ParseErrorPolicy(""); err != nil || policy != OnErrorRollback {
+		t.Errorf("ParseErrorPolicy(\"\") = %q, %v, want rollback", policy, err)
+	}
+	if _, err := ParseErrorPolicy("ignore"); err == nil {
+		t.Error("ParseErrorPolicy(\"ignore\") should fail")
+	}
+}
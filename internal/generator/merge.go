@@ -0,0 +1,341 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"gopkg.in/yaml.v3"
+)
+
+// mergeStrategy resolves the auto strategy from the file name: go.mod,
+// JSON, YAML and ignore files have their own, anything else is appended
+func mergeStrategy(strategy, path string) string {
+	if strategy != ritual.MergeAuto {
+		return strategy
+	}
+	base := filepath.Base(path)
+	switch {
+	case base == "go.mod":
+		return ritual.MergeGoMod
+	case filepath.Ext(base) == ".json":
+		return ritual.MergeJSON
+	case filepath.Ext(base) == ".yml", filepath.Ext(base) == ".yaml":
+		return ritual.MergeYAML
+	case strings.HasSuffix(base, "ignore"):
+		return ritual.MergeLines
+	}
+	return ritual.MergeAppend
+}
+
+// mergeContent combines existing, the content of the file at path, with
+// content as opts.Merge says. An empty existing file gives content.
+func mergeContent(path string, existing, content []byte, opts FileOptions) ([]byte, error) {
+	if len(bytes.TrimSpace(existing)) == 0 {
+		return content, nil
+	}
+	switch mergeStrategy(opts.Merge, path) {
+	case ritual.MergeLines:
+		return mergeLines(existing, content), nil
+	case ritual.MergeGoMod:
+		return mergeGoMod(path, existing, content)
+	case ritual.MergeJSON:
+		return mergeJSON(existing, content, opts.MergeArrays)
+	case ritual.MergeYAML:
+		return mergeYAML(existing, content, opts.MergeArrays)
+	}
+	return appendContent(existing, content), nil
+}
+
+// appendContent returns existing followed by content, on a new line. If
+// existing already contains content it is returned unchanged.
+func appendContent(existing, content []byte) []byte {
+	if bytes.Contains(existing, content) {
+		return existing
+	}
+	if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+		existing = append(existing, '\n')
+	}
+	return append(existing, content...)
+}
+
+// mergeLines adds the lines of content that existing does not have yet, in
+// order, ignoring blank lines and surrounding whitespace
+func mergeLines(existing, content []byte) []byte {
+	present := make(map[string]bool)
+	for _, line := range strings.Split(string(existing), "\n") {
+		present[strings.TrimSpace(line)] = true
+	}
+
+	var added []string
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || present[trimmed] {
+			continue
+		}
+		present[trimmed] = true
+		added = append(added, strings.TrimRight(line, " \t\r"))
+	}
+	if len(added) == 0 {
+		return existing
+	}
+
+	out := bytes.Clone(existing)
+	if !bytes.HasSuffix(out, []byte("\n")) {
+		out = append(out, '\n')
+	}
+	return append(out, strings.Join(added, "\n")+"\n"...)
+}
+
+// mergeGoMod adds the requirements and replacements of content to the go.mod
+// in existing. A module required by both is required at the higher version,
+// as is the go version; a module replaced by both keeps its existing replacement.
+func mergeGoMod(path string, existing, content []byte) ([]byte, error) {
+	ours, err := modfile.Parse(path, existing, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing %s: %w", path, err)
+	}
+	theirs, err := modfile.Parse(path, content, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated %s: %w", path, err)
+	}
+
+	if theirs.Go != nil && (ours.Go == nil || semver.Compare("v"+theirs.Go.Version, "v"+ours.Go.Version) > 0) {
+		if err := ours.AddGoStmt(theirs.Go.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	required := make(map[string]string)
+	for _, req := range ours.Require {
+		required[req.Mod.Path] = req.Mod.Version
+	}
+	for _, req := range theirs.Require {
+		version, ok := required[req.Mod.Path]
+		switch {
+		case !ok:
+			ours.AddNewRequire(req.Mod.Path, req.Mod.Version, req.Indirect)
+		case semver.Compare(req.Mod.Version, version) > 0:
+			if err := ours.AddRequire(req.Mod.Path, req.Mod.Version); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, rep := range theirs.Replace {
+		replaced := false
+		for _, existing := range ours.Replace {
+			if existing.Old.Path == rep.Old.Path && (existing.Old.Version == "" || existing.Old.Version == rep.Old.Version) {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			if err := ours.AddReplace(rep.Old.Path, rep.Old.Version, rep.New.Path, rep.New.Version); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	ours.SortBlocks()
+	ours.Cleanup()
+	return modfile.Format(ours.Syntax), nil
+}
+
+// mergeJSON deep merges the JSON in content into existing, keeping the key
+// order and indentation of existing
+func mergeJSON(existing, content []byte, arrays string) ([]byte, error) {
+	ours, err := parseTree(existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing JSON: %w", err)
+	}
+	theirs, err := parseTree(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated JSON: %w", err)
+	}
+	mergeNodes(ours, theirs, arrays)
+
+	var compact bytes.Buffer
+	if err := writeJSON(&compact, ours); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, compact.Bytes(), "", jsonIndent(existing)); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// mergeYAML deep merges the YAML in content into existing, keeping the key
+// order and comments of existing
+func mergeYAML(existing, content []byte, arrays string) ([]byte, error) {
+	ours, err := parseTree(existing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing YAML: %w", err)
+	}
+	theirs, err := parseTree(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated YAML: %w", err)
+	}
+	mergeNodes(ours, theirs, arrays)
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(yamlIndent(existing))
+	if err := encoder.Encode(ours); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// parseTree parses YAML, or JSON as the YAML it is, into the node of its
+// top-level value
+func parseTree(data []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// mergeNodes merges theirs into ours. Mappings gain the keys they lack and
+// merge those they share; arrays combine as arrays says; on any other
+// difference ours wins.
+func mergeNodes(ours, theirs *yaml.Node, arrays string) {
+	switch {
+	case ours.Kind == yaml.MappingNode && theirs.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(theirs.Content); i += 2 {
+			key, value := theirs.Content[i], theirs.Content[i+1]
+			if existing := mappingValue(ours, key.Value); existing != nil {
+				mergeNodes(existing, value, arrays)
+			} else {
+				ours.Content = append(ours.Content, key, value)
+			}
+		}
+	case ours.Kind == yaml.SequenceNode && theirs.Kind == yaml.SequenceNode:
+		switch arrays {
+		case ritual.MergeArraysReplace:
+			ours.Content = theirs.Content
+		case ritual.MergeArraysAppend:
+			ours.Content = append(ours.Content, theirs.Content...)
+		default:
+			for _, item := range theirs.Content {
+				if !containsNode(ours.Content, item) {
+					ours.Content = append(ours.Content, item)
+				}
+			}
+		}
+	}
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// containsNode reports whether nodes holds a node equal to node
+func containsNode(nodes []*yaml.Node, node *yaml.Node) bool {
+	for _, n := range nodes {
+		if equalNodes(n, node) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalNodes reports whether two nodes hold the same value, whatever their style
+func equalNodes(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.ShortTag() != b.ShortTag() || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !equalNodes(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// writeJSON writes a node parsed from JSON back as compact JSON, in order
+func writeJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(node.Content[i].Value)
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool":
+			buf.WriteString(node.Value)
+		case "!!null":
+			buf.WriteString("null")
+		default:
+			value, _ := json.Marshal(node.Value)
+			buf.Write(value)
+		}
+	default:
+		return fmt.Errorf("cannot write YAML node of kind %d as JSON", node.Kind)
+	}
+	return nil
+}
+
+// jsonIndent returns the indentation of the first indented line of a JSON
+// document, or two spaces
+func jsonIndent(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if trimmed := strings.TrimLeft(line, " \t"); trimmed != line && trimmed != "" {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+// yamlIndent returns the number of spaces the first indented line of a YAML
+// document is indented by, or two
+func yamlIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed != line && trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "- ") {
+			return len(line) - len(trimmed)
+		}
+	}
+	return 2
+}
@@ -0,0 +1,154 @@
+package generator
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+func TestMergeContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		opts     FileOptions
+		existing string
+		content  string
+		want     string
+	}{
+		{
+			name:     "lines",
+			path:     ".gitignore",
+			opts:     FileOptions{Merge: ritual.MergeLines},
+			existing: "# Binaries\nbin/\n.env",
+			content:  "# Binaries\nbin/\n\nnode_modules/\n.env\ndist/\n",
+			want:     "# Binaries\nbin/\n.env\nnode_modules/\ndist/\n",
+		},
+		{
+			name:     "go.mod",
+			path:     "go.mod",
+			opts:     FileOptions{Merge: ritual.MergeGoMod},
+			existing: "module example.com/blog\n\ngo 1.22\n\nrequire (\n\tgithub.com/lib/pq v1.10.9\n\tgolang.org/x/text v0.20.0 // pinned\n)\n\nreplace example.com/old => ../old\n",
+			content:  "module example.com/ritual\n\ngo 1.24.0\n\nrequire (\n\tgithub.com/lib/pq v1.9.0\n\tgolang.org/x/text v0.32.0\n\tgithub.com/google/uuid v1.6.0\n)\n\nreplace example.com/old => ../elsewhere\n\nreplace example.com/new => ../new\n",
+			want:     "module example.com/blog\n\ngo 1.24.0\n\nrequire (\n\tgithub.com/google/uuid v1.6.0\n\tgithub.com/lib/pq v1.10.9\n\tgolang.org/x/text v0.32.0 // pinned\n)\n\nreplace example.com/old => ../old\n\nreplace example.com/new => ../new\n",
+		},
+		{
+			name:     "json keeps existing values, order and indentation",
+			path:     "package.json",
+			opts:     FileOptions{Merge: ritual.MergeAuto},
+			existing: "{\n    \"name\": \"blog\",\n    \"private\": true,\n    \"scripts\": {\"dev\": \"vite\"},\n    \"files\": [\"dist\"]\n}\n",
+			content:  `{"name": "ritual", "version": 1.5, "scripts": {"build": "vite build", "dev": "vite dev"}, "files": ["dist", "types"], "main": null}`,
+			want:     "{\n    \"name\": \"blog\",\n    \"private\": true,\n    \"scripts\": {\n        \"dev\": \"vite\",\n        \"build\": \"vite build\"\n    },\n    \"files\": [\n        \"dist\",\n        \"types\"\n    ],\n    \"version\": 1.5,\n    \"main\": null\n}\n",
+		},
+		{
+			name:     "json arrays appended",
+			path:     "config.json",
+			opts:     FileOptions{Merge: ritual.MergeJSON, MergeArrays: ritual.MergeArraysAppend},
+			existing: `{"tags": ["a", "b"]}`,
+			content:  `{"tags": ["b", "c"]}`,
+			want:     "{\n  \"tags\": [\n    \"a\",\n    \"b\",\n    \"b\",\n    \"c\"\n  ]\n}\n",
+		},
+		{
+			name:     "json arrays replaced",
+			path:     "config.json",
+			opts:     FileOptions{Merge: ritual.MergeJSON, MergeArrays: ritual.MergeArraysReplace},
+			existing: `{"tags": ["a", "b"]}`,
+			content:  `{"tags": ["c"]}`,
+			want:     "{\n  \"tags\": [\n    \"c\"\n  ]\n}\n",
+		},
+		{
+			name:     "yaml keeps comments",
+			path:     "docker-compose.yml",
+			opts:     FileOptions{Merge: ritual.MergeAuto},
+			existing: "# Local services\nservices:\n  app:\n    build: .\n    ports:\n      - \"8080:8080\"\n",
+			content:  "services:\n  app:\n    build: ./docker\n    ports:\n      - \"8080:8080\"\n      - \"2345:2345\"\n  db:\n    image: postgres:16\nvolumes:\n  data: {}\n",
+			want:     "# Local services\nservices:\n  app:\n    build: .\n    ports:\n      - \"8080:8080\"\n      - \"2345:2345\"\n  db:\n    image: postgres:16\nvolumes:\n  data: {}\n",
+		},
+		{
+			name:     "append",
+			path:     "NOTES",
+			opts:     FileOptions{Merge: ritual.MergeAuto},
+			existing: "first",
+			content:  "second\n",
+			want:     "first\nsecond\n",
+		},
+		{
+			name:     "empty existing file",
+			path:     "go.mod",
+			opts:     FileOptions{Merge: ritual.MergeGoMod},
+			existing: "\n",
+			content:  "module example.com/blog\n",
+			want:     "module example.com/blog\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeContent(tt.path, []byte(tt.existing), []byte(tt.content), tt.opts)
+			if err != nil {
+				t.Fatalf("mergeContent() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("mergeContent() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeContent_Invalid(t *testing.T) {
+	if _, err := mergeContent("package.json", []byte("{"), []byte("{}"), FileOptions{Merge: ritual.MergeJSON}); err == nil {
+		t.Error("expected an error merging into invalid JSON")
+	}
+	if _, err := mergeContent("go.mod", []byte("module a\n"), []byte("require\n"), FileOptions{Merge: ritual.MergeGoMod}); err == nil {
+		t.Error("expected an error merging an invalid go.mod")
+	}
+}
+
+func TestDefaultPipeline_MergesIntoExistingProject(t *testing.T) {
+	run, output := pipelineRun(t)
+	fsys := fstest.MapFS{
+		"app/templates/go.mod.tmpl":       {Data: []byte("module [[ .module_path ]]\n\ngo 1.24\n\nrequire github.com/google/uuid v1.6.0\n")},
+		"app/templates/package.json.tmpl": {Data: []byte("---\nmerge: json\n---\n{\"scripts\": {\"build\": \"vite build\"}}\n")},
+		"app/static/gitignore":            {Data: []byte("bin/\nnode_modules/\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	run.Source = src
+	run.Manifest.Files = ritual.FilesSection{
+		Templates: []ritual.FileMapping{
+			{Source: "go.mod.tmpl", Destination: "go.mod", Merge: ritual.MergeAuto},
+			{Source: "package.json.tmpl", Destination: "package.json"},
+		},
+		Static:    []ritual.FileMapping{{Source: "gitignore", Destination: ".gitignore", Merge: ritual.MergeLines}},
+		Protected: []string{"go.mod"},
+	}
+	existing := map[string]string{
+		"/project/go.mod":       "module example.com/demo\n\ngo 1.22\n\nrequire github.com/lib/pq v1.10.9\n",
+		"/project/package.json": "{\n  \"name\": \"demo\"\n}\n",
+		"/project/.gitignore":   "bin/\n.env\n",
+	}
+	for path, content := range existing {
+		if err := output.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := DefaultPipeline().Run(context.Background(), run); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	files := output.Files("/project")
+	if got := string(files["go.mod"]); !strings.Contains(got, "go 1.24") ||
+		!strings.Contains(got, "github.com/lib/pq v1.10.9") || !strings.Contains(got, "github.com/google/uuid v1.6.0") {
+		t.Errorf("the protected go.mod should gain the ritual's requirements, got:\n%s", got)
+	}
+	if got := string(files["package.json"]); got != "{\n  \"name\": \"demo\",\n  \"scripts\": {\n    \"build\": \"vite build\"\n  }\n}\n" {
+		t.Errorf("package.json = %q", got)
+	}
+	if got := string(files[".gitignore"]); got != "bin/\n.env\nnode_modules/\n" {
+		t.Errorf(".gitignore = %q", got)
+	}
+}
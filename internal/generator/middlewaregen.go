@@ -300,7 +300,19 @@ func isOriginAllowed(origin string, allowed []string) bool {
 
 // GenerateCustomMiddleware generates a custom middleware from specification
 func (m *MiddlewareGenerator) GenerateCustomMiddleware(projectPath string, manifest *ritual.Manifest, vars *Variables, spec MiddlewareSpec) error {
-	template := fmt.Sprintf(`package middleware
+	middlewareDir := filepath.Join(projectPath, "internal", "middleware")
+	if err := os.MkdirAll(middlewareDir, 0750); err != nil {
+		return err
+	}
+
+	filename := strings.ToLower(spec.Name) + ".go"
+	middlewarePath := filepath.Join(middlewareDir, filename)
+	return os.WriteFile(middlewarePath, []byte(m.generateCustomContent(spec)), 0600)
+}
+
+// generateCustomContent returns the source of a custom middleware
+func (m *MiddlewareGenerator) generateCustomContent(spec MiddlewareSpec) string {
+	return fmt.Sprintf(`package middleware
 
 import (
 	"net/http"
@@ -315,15 +327,6 @@ func %s(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 `, spec.Name, spec.Description, spec.Name, spec.Logic)
-
-	middlewareDir := filepath.Join(projectPath, "internal", "middleware")
-	if err := os.MkdirAll(middlewareDir, 0750); err != nil {
-		return err
-	}
-
-	filename := strings.ToLower(spec.Name) + ".go"
-	middlewarePath := filepath.Join(middlewareDir, filename)
-	return os.WriteFile(middlewarePath, []byte(template), 0600)
 }
 
 // GenerateAll generates all standard middleware
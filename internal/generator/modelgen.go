@@ -4,7 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/funcs"
 )
 
 // ModelGenerator generates data models
@@ -17,16 +21,21 @@ func NewModelGenerator() *ModelGenerator {
 
 // Field represents a model field
 type Field struct {
-	Name string
-	Type string
-	Tags string
+	Name      string
+	Type      string
+	Tags      string
+	Required  bool // Validate rejects an empty string or a nil pointer
+	MaxLength int  // Validate rejects a longer string
 }
 
 // Relationship represents a model relationship
 type Relationship struct {
-	Name  string
-	Type  string // BelongsTo, HasMany, ManyToMany
-	Model string
+	Name       string
+	Type       string // BelongsTo, HasMany, ManyToMany
+	Model      string
+	Table      string // Table of Model (default: the snake_case plural of Model)
+	JoinTable  string // Table relating both models of a ManyToMany (default: <model>_<table>)
+	SoftDelete bool   // Rows of Model are soft deleted
 }
 
 // ModelConfig configures model generation
@@ -40,6 +49,10 @@ type ModelConfig struct {
 	Validation         bool
 	JSONMethods        bool
 	GenerateRepository bool
+	Module             string // Module path the repository imports the model from
+	Layout             Layout // Directories of the packages in Module; empty ones as in DefaultLayout
+	Table              string // Table of the model (default: the snake_case plural of Name)
+	Dialect            string // SQL the repository runs: postgres (default), mysql or sqlite
 }
 
 // GenerateModel generates a model file
@@ -65,13 +78,14 @@ func (g *ModelGenerator) GenerateModel(targetPath string, config ModelConfig) er
 func (g *ModelGenerator) generateModelContent(config ModelConfig) string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf(`package %s
-
-import (
-	"time"
-)
-
-`, config.Package))
+	sb.WriteString(fmt.Sprintf("package %s\n\n", config.Package))
+	if imports := g.modelImports(config); len(imports) > 0 {
+		sb.WriteString("import (\n")
+		for _, imp := range imports {
+			sb.WriteString(fmt.Sprintf("\t%q\n", imp))
+		}
+		sb.WriteString(")\n\n")
+	}
 
 	// Generate struct
 	sb.WriteString(fmt.Sprintf("// %s represents a %s entity\n", config.Name, strings.ToLower(config.Name)))
@@ -89,14 +103,22 @@ import (
 		sb.WriteString("\n")
 	}
 
-	// Add relationship foreign keys
+	// Add relationship foreign keys and related items
 	for _, rel := range config.Relationships {
-		if rel.Type == "BelongsTo" {
+		switch rel.Type {
+		case "BelongsTo":
 			fkName := rel.Model + "ID"
 			sb.WriteString(fmt.Sprintf("\t%s uint `json:\"%s\" db:\"%s\"`\n",
 				fkName,
-				toSnakeCase(fkName),
-				toSnakeCase(fkName)))
+				toSnakeCase(rel.Model)+"_id",
+				toSnakeCase(rel.Model)+"_id"))
+		case "HasMany", "ManyToMany":
+			name := rel.Name
+			if name == "" {
+				name = funcs.Pluralize(rel.Model)
+			}
+			sb.WriteString(fmt.Sprintf("\t%s []%s `json:\"%s,omitempty\" db:\"-\"`\n",
+				name, rel.Model, toSnakeCase(name)))
 		}
 	}
 
@@ -126,14 +148,69 @@ import (
 	return sb.String()
 }
 
+// modelImports returns the packages the model uses
+func (g *ModelGenerator) modelImports(config ModelConfig) []string {
+	var imports []string
+	if config.Validation && slices.ContainsFunc(config.Fields, func(f Field) bool { return f.Required }) {
+		imports = append(imports, "errors")
+	}
+	if config.Validation && slices.ContainsFunc(config.Fields, func(f Field) bool { return f.MaxLength > 0 }) {
+		imports = append(imports, "fmt")
+	}
+	if config.Timestamps || config.SoftDelete || slices.ContainsFunc(config.Fields, func(f Field) bool {
+		return strings.Contains(f.Type, "time.")
+	}) {
+		imports = append(imports, "time")
+	}
+	if config.Validation && slices.ContainsFunc(config.Fields, func(f Field) bool { return f.MaxLength > 0 }) {
+		imports = append(imports, "unicode/utf8")
+	}
+	return imports
+}
+
 func (g *ModelGenerator) generateValidationMethod(config ModelConfig) string {
+	var checks strings.Builder
+	for _, field := range config.Fields {
+		value := "m." + field.Name
+		pointer := strings.HasPrefix(field.Type, "*")
+		if pointer {
+			value = "*" + value
+		}
+		switch {
+		case field.Required && pointer:
+			checks.WriteString(fmt.Sprintf("\tif m.%s == nil {\n\t\treturn errors.New(\"%s is required\")\n\t}\n",
+				field.Name, jsonName(field)))
+		case field.Required && field.Type == "string":
+			checks.WriteString(fmt.Sprintf("\tif m.%s == \"\" {\n\t\treturn errors.New(\"%s is required\")\n\t}\n",
+				field.Name, jsonName(field)))
+		}
+		if field.MaxLength > 0 {
+			guard := ""
+			if pointer {
+				guard = fmt.Sprintf("m.%s != nil && ", field.Name)
+			}
+			checks.WriteString(fmt.Sprintf("\tif %sutf8.RuneCountInString(%s) > %d {\n\t\treturn fmt.Errorf(\"%s must be at most %%d characters\", %d)\n\t}\n",
+				guard, value, field.MaxLength, jsonName(field), field.MaxLength))
+		}
+	}
+	if checks.Len() == 0 {
+		checks.WriteString("\t// TODO: Add custom validation logic\n")
+	}
+
 	return fmt.Sprintf(`// Validate validates the %s model
 func (m *%s) Validate() error {
-	// TODO: Add custom validation logic
-	return nil
+%s	return nil
 }
 
-`, config.Name, config.Name)
+`, config.Name, config.Name, checks.String())
+}
+
+// jsonName returns the name a field has in JSON, from its tags
+func jsonName(field Field) string {
+	if name, _, _ := strings.Cut(reflect.StructTag(field.Tags).Get("json"), ","); name != "" {
+		return name
+	}
+	return toSnakeCase(field.Name)
 }
 
 func (g *ModelGenerator) generateJSONMethods(config ModelConfig) string {
@@ -152,78 +229,10 @@ func (m *%s) UnmarshalJSON(data []byte) error {
 `, config.Name, config.Name)
 }
 
-// GenerateRepository generates a repository interface
+// GenerateRepository generates a repository interface and its database/sql implementation
 func (g *ModelGenerator) GenerateRepository(targetPath string, config ModelConfig) error {
-	repoName := config.Name + "Repository"
 	fileName := strings.ToLower(config.Name) + "_repository.go"
-
-	content := fmt.Sprintf(`package repository
-
-import (
-	"context"
-	
-	"your-module/internal/models"
-)
-
-// %s defines the interface for %s data access
-type %s interface {
-	Create(ctx context.Context, item *models.%s) (*models.%s, error)
-	GetByID(ctx context.Context, id string) (*models.%s, error)
-	List(ctx context.Context) ([]*models.%s, error)
-	Update(ctx context.Context, id string, item *models.%s) (*models.%s, error)
-	Delete(ctx context.Context, id string) error
-}
-
-// %sImpl implements %s
-type %sImpl struct {
-	// db or storage dependency here
-}
-
-// New%s creates a new %s repository
-func New%s() *%sImpl {
-	return &%sImpl{}
-}
-
-// Create creates a new %s
-func (r *%sImpl) Create(ctx context.Context, item *models.%s) (*models.%s, error) {
-	// TODO: Implement create
-	return item, nil
-}
-
-// GetByID retrieves a %s by ID
-func (r *%sImpl) GetByID(ctx context.Context, id string) (*models.%s, error) {
-	// TODO: Implement get by ID
-	return nil, nil
-}
-
-// List retrieves all %ss
-func (r *%sImpl) List(ctx context.Context) ([]*models.%s, error) {
-	// TODO: Implement list
-	return nil, nil
-}
-
-// Update updates a %s
-func (r *%sImpl) Update(ctx context.Context, id string, item *models.%s) (*models.%s, error) {
-	// TODO: Implement update
-	return item, nil
-}
-
-// Delete deletes a %s
-func (r *%sImpl) Delete(ctx context.Context, id string) error {
-	// TODO: Implement delete
-	return nil
-}
-`,
-		repoName, config.Name, repoName, config.Name, config.Name, config.Name,
-		config.Name, config.Name, config.Name,
-		config.Name, repoName, config.Name,
-		config.Name, config.Name, config.Name, config.Name, config.Name,
-		config.Name, config.Name, config.Name, config.Name,
-		config.Name, config.Name, config.Name,
-		strings.ToLower(config.Name), config.Name, config.Name,
-		config.Name, config.Name, config.Name, config.Name,
-		config.Name, config.Name,
-	)
+	content := g.generateRepositoryContent(config)
 
 	repoDir := filepath.Join(targetPath, "internal", "repository")
 	if err := os.MkdirAll(repoDir, 0750); err != nil {
@@ -0,0 +1,376 @@
+package generator
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutputFS is the filesystem a generated project is written to.
+// Paths are the destination paths the generator computes, rooted at the output path.
+type OutputFS interface {
+	MkdirAll(path string, perm os.FileMode) error
+	WriteFile(path string, data []byte, perm os.FileMode) error
+	Chmod(path string, mode os.FileMode) error
+	Stat(path string) (os.FileInfo, error)
+	ReadFile(path string) ([]byte, error)
+}
+
+// DiskFS writes straight to disk, recording every change in a journal
+type DiskFS struct {
+	journal *Journal
+}
+
+// NewDiskFS creates a disk output that records its changes in journal
+func NewDiskFS(journal *Journal) *DiskFS {
+	return &DiskFS{journal: journal}
+}
+
+// Journal returns the journal of changes written to disk
+func (d *DiskFS) Journal() *Journal {
+	return d.journal
+}
+
+// MkdirAll creates a directory and any missing parents
+func (d *DiskFS) MkdirAll(path string, perm os.FileMode) error {
+	return d.journal.MkdirAll(path, perm)
+}
+
+// WriteFile writes a file, creating it with perm if it does not exist
+func (d *DiskFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	return d.journal.WriteFile(path, data, perm)
+}
+
+// Chmod changes the mode of a file
+func (d *DiskFS) Chmod(path string, mode os.FileMode) error {
+	return os.Chmod(path, mode)
+}
+
+// Stat describes a file on disk
+func (d *DiskFS) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+// ReadFile reads a file from disk
+func (d *DiskFS) ReadFile(path string) ([]byte, error) {
+	// #nosec G304 - path is a destination the generator computed
+	return os.ReadFile(path)
+}
+
+// memFile is a file held by MemoryFS
+type memFile struct {
+	data []byte
+	mode os.FileMode
+}
+
+// MemoryFS keeps a generated project in memory, for dry runs, diffs and tests
+type MemoryFS struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+	dirs  map[string]os.FileMode
+}
+
+// NewMemoryFS creates an empty in-memory output
+func NewMemoryFS() *MemoryFS {
+	return &MemoryFS{
+		files: make(map[string]*memFile),
+		dirs:  make(map[string]os.FileMode),
+	}
+}
+
+// MkdirAll records a directory and its parents
+func (m *MemoryFS) MkdirAll(path string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: fs.ErrExist}
+		}
+		if _, ok := m.dirs[dir]; !ok {
+			m.dirs[dir] = perm
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return nil
+		}
+	}
+}
+
+// WriteFile stores a file, keeping the mode of an existing one like os.WriteFile
+func (m *MemoryFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path = filepath.Clean(path)
+	if _, ok := m.dirs[path]; ok {
+		return &fs.PathError{Op: "open", Path: path, Err: fmt.Errorf("is a directory")}
+	}
+	if existing, ok := m.files[path]; ok {
+		perm = existing.mode
+	}
+	m.files[path] = &memFile{data: append([]byte(nil), data...), mode: perm}
+	return nil
+}
+
+// Chmod changes the mode of a stored file
+func (m *MemoryFS) Chmod(path string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, ok := m.files[filepath.Clean(path)]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: path, Err: fs.ErrNotExist}
+	}
+	file.mode = mode
+	return nil
+}
+
+// Stat describes a stored file or directory
+func (m *MemoryFS) Stat(path string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path = filepath.Clean(path)
+	if file, ok := m.files[path]; ok {
+		return memFileInfo{name: filepath.Base(path), size: int64(len(file.data)), mode: file.mode}, nil
+	}
+	if perm, ok := m.dirs[path]; ok {
+		return memFileInfo{name: filepath.Base(path), mode: fs.ModeDir | perm}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: path, Err: fs.ErrNotExist}
+}
+
+// ReadFile returns the content of a stored file
+func (m *MemoryFS) ReadFile(path string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, ok := m.files[filepath.Clean(path)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+	}
+	return append([]byte(nil), file.data...), nil
+}
+
+// Files returns the stored files below root, keyed by slash-separated relative path
+func (m *MemoryFS) Files(root string) map[string][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	files := make(map[string][]byte)
+	for path, file := range m.files {
+		if rel, ok := relativeTo(root, path); ok {
+			files[rel] = append([]byte(nil), file.data...)
+		}
+	}
+	return files
+}
+
+// Dirs returns the sorted directories below root as slash-separated relative paths
+func (m *MemoryFS) Dirs(root string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var dirs []string
+	for path := range m.dirs {
+		if rel, ok := relativeTo(root, path); ok {
+			dirs = append(dirs, rel)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// relativeTo returns path relative to root, if path is strictly below root
+func relativeTo(root, path string) (string, bool) {
+	rel, err := filepath.Rel(filepath.Clean(root), path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// memFileInfo describes a MemoryFS entry
+type memFileInfo struct {
+	name string
+	size int64
+	mode os.FileMode
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i memFileInfo) ModTime() time.Time { return time.Time{} }
+func (i memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memFileInfo) Sys() interface{}   { return nil }
+
+// StagingFS writes a project into a staging directory next to its destination,
+// or inside it when its parent is not writable. Commit moves the staged files
+// into place with atomic renames, so the destination never holds a half-written file.
+type StagingFS struct {
+	mu    sync.Mutex
+	root  string
+	dir   string
+	dirs  []string // Relative directories, in creation order
+	files []string // Relative files, in first-write order
+	seen  map[string]bool
+}
+
+// NewStagingFS creates a staging directory for a project destined for root, in
+// the parent of root so the renames stay on one filesystem. When the parent is
+// not writable, it falls back to root's .ritual directory; root must then exist.
+func NewStagingFS(root string) (*StagingFS, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", root, err)
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(root), "."+filepath.Base(root)+".staging-*")
+	if err != nil {
+		inside := filepath.Join(root, ".ritual")
+		if mkErr := os.Mkdir(inside, 0750); mkErr != nil && !os.IsExist(mkErr) {
+			return nil, fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		if dir, err = os.MkdirTemp(inside, "staging-*"); err != nil {
+			return nil, fmt.Errorf("failed to create staging directory: %w", err)
+		}
+	}
+	return &StagingFS{root: root, dir: dir, seen: make(map[string]bool)}, nil
+}
+
+// Dir returns the staging directory
+func (s *StagingFS) Dir() string {
+	return s.dir
+}
+
+// staged maps a destination path to its staging path
+func (s *StagingFS) staged(path string) (string, string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+	rel, err := filepath.Rel(s.root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("path %s is outside %s", path, s.root)
+	}
+	return rel, filepath.Join(s.dir, rel), nil
+}
+
+// MkdirAll creates a directory in the staging area
+func (s *StagingFS) MkdirAll(path string, perm os.FileMode) error {
+	rel, staged, err := s.staged(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(staged, perm); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seen[rel] {
+		s.seen[rel] = true
+		s.dirs = append(s.dirs, rel)
+	}
+	return nil
+}
+
+// WriteFile writes a file in the staging area. A file that replaces one at the
+// destination gets that file's mode.
+func (s *StagingFS) WriteFile(path string, data []byte, perm os.FileMode) error {
+	rel, staged, err := s.staged(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(staged), 0750); err != nil {
+		return err
+	}
+	_, statErr := os.Lstat(staged)
+	if err := os.WriteFile(staged, data, perm); err != nil {
+		return err
+	}
+	// Like a write in place, overwriting a file keeps its mode
+	if os.IsNotExist(statErr) {
+		if info, err := os.Stat(filepath.Join(s.root, rel)); err == nil && info.Mode().IsRegular() {
+			if err := os.Chmod(staged, info.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seen[rel] {
+		s.seen[rel] = true
+		s.files = append(s.files, rel)
+	}
+	return nil
+}
+
+// Chmod changes the mode of a staged file
+func (s *StagingFS) Chmod(path string, mode os.FileMode) error {
+	_, staged, err := s.staged(path)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(staged, mode)
+}
+
+// Stat describes a staged file, or the file already at the destination
+func (s *StagingFS) Stat(path string) (os.FileInfo, error) {
+	_, staged, err := s.staged(path)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(staged); err == nil {
+		return info, nil
+	}
+	return os.Stat(path)
+}
+
+// ReadFile reads a staged file, or the file already at the destination
+func (s *StagingFS) ReadFile(path string) ([]byte, error) {
+	_, staged, err := s.staged(path)
+	if err != nil {
+		return nil, err
+	}
+	// #nosec G304 - staged and path are destinations the generator computed
+	if data, err := os.ReadFile(staged); err == nil {
+		return data, nil
+	}
+	return os.ReadFile(path)
+}
+
+// Commit moves every staged directory and file to its destination, recording
+// the changes in journal, then removes the staging directory
+func (s *StagingFS) Commit(journal *Journal) error {
+	s.mu.Lock()
+	dirs := append([]string(nil), s.dirs...)
+	files := append([]string(nil), s.files...)
+	s.mu.Unlock()
+
+	for _, rel := range dirs {
+		info, err := os.Stat(filepath.Join(s.dir, rel))
+		if err != nil {
+			return fmt.Errorf("failed to read staged directory %s: %w", rel, err)
+		}
+		if err := journal.MkdirAll(filepath.Join(s.root, rel), info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", rel, err)
+		}
+	}
+	for _, rel := range files {
+		if err := journal.Rename(filepath.Join(s.dir, rel), filepath.Join(s.root, rel)); err != nil {
+			return fmt.Errorf("failed to commit %s: %w", rel, err)
+		}
+	}
+	return s.Discard()
+}
+
+// Discard removes the staging directory and everything left in it
+func (s *StagingFS) Discard() error {
+	return os.RemoveAll(s.dir)
+}
@@ -0,0 +1,251 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+func TestMemoryFS(t *testing.T) {
+	memory := NewMemoryFS()
+	root := filepath.Join("work", "project")
+
+	if err := memory.MkdirAll(filepath.Join(root, "docs"), 0750); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := memory.WriteFile(filepath.Join(root, "cmd", "main.go"), []byte("package main"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := memory.Chmod(filepath.Join(root, "cmd", "main.go"), 0755); err != nil {
+		t.Fatalf("Chmod() error = %v", err)
+	}
+	// Overwriting keeps the mode, like os.WriteFile
+	if err := memory.WriteFile(filepath.Join(root, "cmd", "main.go"), []byte("package app"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := memory.Stat(filepath.Join(root, "cmd", "main.go"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Mode().Perm() != 0755 || info.Size() != int64(len("package app")) {
+		t.Errorf("Stat() = mode %v size %d", info.Mode(), info.Size())
+	}
+	if _, err := memory.Stat(filepath.Join(root, "missing")); !os.IsNotExist(err) {
+		t.Errorf("Stat(missing) error = %v, want not exist", err)
+	}
+	if err := memory.WriteFile(filepath.Join(root, "docs"), nil, 0600); err == nil {
+		t.Error("WriteFile() over a directory should fail")
+	}
+
+	files := memory.Files(root)
+	if len(files) != 1 || string(files["cmd/main.go"]) != "package app" {
+		t.Errorf("Files() = %v", files)
+	}
+	if dirs := memory.Dirs(root); len(dirs) != 1 || dirs[0] != "docs" {
+		t.Errorf("Dirs() = %v, want [docs]", dirs)
+	}
+	if _, err := os.Stat("work"); !os.IsNotExist(err) {
+		t.Error("MemoryFS should not touch disk")
+	}
+}
+
+func TestStagingFS_Commit(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(root, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "README.md"), []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	staging, err := NewStagingFS(root)
+	if err != nil {
+		t.Fatalf("NewStagingFS() error = %v", err)
+	}
+	if filepath.Dir(staging.Dir()) != tmpDir {
+		t.Errorf("staging directory %s should be next to the project", staging.Dir())
+	}
+
+	if err := staging.WriteFile(filepath.Join(root, "README.md"), []byte("new"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := staging.WriteFile(filepath.Join(root, "cmd", "main.go"), []byte("package main"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := staging.MkdirAll(filepath.Join(root, "docs"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := staging.WriteFile(filepath.Join(tmpDir, "outside.txt"), nil, 0600); err == nil {
+		t.Error("WriteFile() outside the project should fail")
+	}
+
+	// Nothing reaches the project before Commit
+	content, _ := os.ReadFile(filepath.Join(root, "README.md"))
+	if string(content) != "old" {
+		t.Errorf("README.md = %q before Commit, want %q", content, "old")
+	}
+	if _, err := staging.Stat(filepath.Join(root, "cmd", "main.go")); err != nil {
+		t.Errorf("Stat() of a staged file error = %v", err)
+	}
+
+	journal := NewJournal()
+	if err := staging.Commit(journal); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(root, "README.md"))
+	if string(content) != "new" {
+		t.Errorf("README.md = %q after Commit, want %q", content, "new")
+	}
+	for _, path := range []string{"cmd/main.go", "docs"} {
+		if _, err := os.Stat(filepath.Join(root, path)); err != nil {
+			t.Errorf("%s should be committed: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(staging.Dir()); !os.IsNotExist(err) {
+		t.Error("staging directory should be removed after Commit")
+	}
+
+	// Committed changes are journaled like any other write
+	if err := journal.Rollback(); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 {
+		t.Errorf("project should only contain README.md after rollback, got %v", entries)
+	}
+	content, _ = os.ReadFile(filepath.Join(root, "README.md"))
+	if string(content) != "old" {
+		t.Errorf("README.md = %q after rollback, want %q", content, "old")
+	}
+}
+
+func TestStagingFS_ParentNotWritable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to any directory")
+	}
+	parent := filepath.Join(t.TempDir(), "parent")
+	root := filepath.Join(parent, "project")
+	if err := os.MkdirAll(root, 0750); err != nil {
+		t.Fatal(err)
+	}
+	// #nosec G302 - the parent is made read-only for the test
+	if err := os.Chmod(parent, 0550); err != nil {
+		t.Fatal(err)
+	}
+	// #nosec G302 - restored so the temporary directory can be removed
+	t.Cleanup(func() { _ = os.Chmod(parent, 0750) })
+
+	staging, err := NewStagingFS(root)
+	if err != nil {
+		t.Fatalf("NewStagingFS() error = %v", err)
+	}
+	if filepath.Dir(staging.Dir()) != filepath.Join(root, ".ritual") {
+		t.Errorf("staging directory %s should be in the project's .ritual directory", staging.Dir())
+	}
+
+	if err := staging.WriteFile(filepath.Join(root, "cmd", "main.go"), []byte("package main"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := staging.Commit(NewJournal()); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(root, "cmd", "main.go"))
+	if string(content) != "package main" {
+		t.Errorf("cmd/main.go = %q after Commit, want %q", content, "package main")
+	}
+	if _, err := os.Stat(staging.Dir()); !os.IsNotExist(err) {
+		t.Error("staging directory should be removed after Commit")
+	}
+}
+
+func TestOutputFS_OverwriteKeepsMode(t *testing.T) {
+	outputs := map[string]func(root string) (OutputFS, func() error){
+		"disk": func(root string) (OutputFS, func() error) {
+			return NewDiskFS(NewJournal()), func() error { return nil }
+		},
+		"staging": func(root string) (OutputFS, func() error) {
+			staging, err := NewStagingFS(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return staging, func() error { return staging.Commit(NewJournal()) }
+		},
+	}
+
+	for name, newOutput := range outputs {
+		t.Run(name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "project")
+			script := filepath.Join(root, "run.sh")
+			if err := os.MkdirAll(root, 0750); err != nil {
+				t.Fatal(err)
+			}
+			// #nosec G306 - the script is executable
+			if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(script, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			output, commit := newOutput(root)
+			if err := output.WriteFile(script, []byte("#!/bin/sh\necho hi\n"), 0600); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			if err := commit(); err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+
+			info, err := os.Stat(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0755 {
+				t.Errorf("run.sh mode = %v after overwrite, want %v", info.Mode().Perm(), os.FileMode(0755))
+			}
+		})
+	}
+}
+
+func TestProjectScaffolder_GenerateInMemory(t *testing.T) {
+	tmpDir := t.TempDir()
+	ritualPath := filepath.Join(tmpDir, "ritual")
+	projectPath := filepath.Join(tmpDir, "project")
+
+	if err := os.MkdirAll(filepath.Join(ritualPath, "templates"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ritualPath, "templates", "app.txt.tmpl"), []byte("Hello [[ .app_name ]]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &ritual.Manifest{
+		Ritual: ritual.RitualMeta{Name: "memory", Version: "1.0.0", TemplateEngine: "go-template"},
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{{Source: "app.txt.tmpl", Destination: "app.txt"}},
+		},
+	}
+	vars := NewVariables()
+	vars.Set("app_name", "demo")
+
+	memory := NewMemoryFS()
+	scaffolder := NewProjectScaffolder()
+	scaffolder.SetOutput(memory)
+	if err := scaffolder.GenerateFromRitual(projectPath, ritualPath, manifest, vars); err != nil {
+		t.Fatalf("GenerateFromRitual() error = %v", err)
+	}
+
+	files := memory.Files(projectPath)
+	if string(files["app.txt"]) != "Hello demo" {
+		t.Errorf("app.txt = %q, want %q", files["app.txt"], "Hello demo")
+	}
+	if _, ok := files["go.mod"]; !ok {
+		t.Error("built-in go.mod should be rendered in memory")
+	}
+	if _, err := os.Stat(projectPath); !os.IsNotExist(err) {
+		t.Error("rendering in memory should not create the project directory")
+	}
+}
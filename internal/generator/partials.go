@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// PartialsDir is the directory of a ritual, and of _shared, holding partials
+const PartialsDir = "partials"
+
+// Partial is a named template that other templates include or extend.
+// Its name is its path below partials/ without the .tmpl extension.
+type Partial struct {
+	Name    string
+	Path    string // Source path, shown in errors
+	Content string
+}
+
+// LoadPartials reads the partials of a ritual source. _shared partials come
+// first, so a ritual partial with the same name replaces them; they remain
+// available as "_shared/<name>".
+func LoadPartials(src *ritual.Source) ([]Partial, error) {
+	var partials []Partial
+
+	if src.Shared != nil {
+		shared, err := readPartials(src.Shared, ritual.SharedDir)
+		if err != nil {
+			return nil, err
+		}
+		partials = append(partials, shared...)
+		for _, p := range shared {
+			p.Name = ritual.SharedDir + "/" + p.Name
+			partials = append(partials, p)
+		}
+	}
+
+	own, err := readPartials(src.FS, src.Path)
+	if err != nil {
+		return nil, err
+	}
+	return append(partials, own...), nil
+}
+
+// readPartials reads every file below partials/ in fsys, in lexical order
+func readPartials(fsys fs.FS, displayRoot string) ([]Partial, error) {
+	var partials []Partial
+	err := fs.WalkDir(fsys, PartialsDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if name == PartialsDir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll // No partials
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(name, PartialsDir+"/")
+		partials = append(partials, Partial{
+			Name:    strings.TrimSuffix(rel, ".tmpl"),
+			Path:    path.Join(displayRoot, name),
+			Content: string(content),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partials: %w", err)
+	}
+	return partials, nil
+}
+
+// usePartials loads the partials of src into the template engine once for the run
+func (g *FileGenerator) usePartials(src *ritual.Source) error {
+	partials, err := LoadPartials(src)
+	if err != nil {
+		return err
+	}
+	// Engines with other delimiters copied the partials they had
+	clear(g.delimited)
+	return g.engine.SetPartials(partials)
+}
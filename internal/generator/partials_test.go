@@ -0,0 +1,200 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+func TestLoadPartials(t *testing.T) {
+	fsys := fstest.MapFS{
+		"blog/partials/header.tmpl":          {Data: []byte("blog header")},
+		"blog/partials/layouts/base.tmpl":    {Data: []byte("base")},
+		"_shared/partials/header.tmpl":       {Data: []byte("shared header")},
+		"_shared/partials/docker/env.tmpl":   {Data: []byte("env")},
+		"_shared/docker/Dockerfile.tmpl":     {Data: []byte("not a partial")},
+		"blog/templates/main.go.tmpl":        {Data: []byte("not a partial")},
+		"other/partials/ignored.tmpl":        {Data: []byte("other ritual")},
+		"blog/partials/license-snippet.txt":  {Data: []byte("license")},
+		"blog/ritual.yaml":                   {Data: []byte("ritual:\n  name: blog\n")},
+		"blog/static/partials/not-used.tmpl": {Data: []byte("static")},
+	}
+	src, err := ritual.NewSource(fsys, "blog", "embedded:blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	partials, err := LoadPartials(src)
+	if err != nil {
+		t.Fatalf("LoadPartials() error = %v", err)
+	}
+
+	var names []string
+	for _, p := range partials {
+		names = append(names, p.Name+"="+p.Path)
+	}
+	want := []string{
+		"docker/env=_shared/partials/docker/env.tmpl",
+		"header=_shared/partials/header.tmpl",
+		"_shared/docker/env=_shared/partials/docker/env.tmpl",
+		"_shared/header=_shared/partials/header.tmpl",
+		"header=embedded:blog/partials/header.tmpl",
+		"layouts/base=embedded:blog/partials/layouts/base.tmpl",
+		"license-snippet.txt=embedded:blog/partials/license-snippet.txt",
+	}
+	if strings.Join(names, "\n") != strings.Join(want, "\n") {
+		t.Errorf("LoadPartials() =\n%s\nwant\n%s", strings.Join(names, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGoTemplateEngine_Partials(t *testing.T) {
+	engine := NewGoTemplateEngine()
+	err := engine.SetPartials([]Partial{
+		{Name: "license", Path: "partials/license.tmpl", Content: "// (c) [[ .author ]]"},
+		{Name: "layouts/base", Path: "partials/layouts/base.tmpl",
+			Content: "<title>[[ block \"title\" . ]][[ .app ]][[ end ]]</title><main>[[ block \"content\" . ]]empty[[ end ]]</main>"},
+		{Name: "loop", Path: "partials/loop.tmpl", Content: "[[ include \"loop\" . ]]"},
+	})
+	if err != nil {
+		t.Fatalf("SetPartials() error = %v", err)
+	}
+
+	data := map[string]interface{}{"author": "Ada", "app": "blog"}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"template action", `[[ template "license" . ]]`, "// (c) Ada"},
+		{"include pipes", `[[ include "license" . | upper ]]`, "// (C) ADA"},
+		{"layout defaults", `[[ template "layouts/base" . ]]`, "<title>blog</title><main>empty</main>"},
+		{"layout override", `[[ define "content" ]]Hi [[ .author ]][[ end ]][[ template "layouts/base" . ]]`,
+			"<title>blog</title><main>Hi Ada</main>"},
+		// A block redefined by one template does not leak into the next
+		{"layout defaults again", `[[ template "layouts/base" . ]]`, "<title>blog</title><main>empty</main>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := engine.RenderNamed("page.tmpl", tt.template, data)
+			if err != nil {
+				t.Fatalf("RenderNamed() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderNamed() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := engine.RenderNamed("page.tmpl", `[[ include "loop" . ]]`, data); err == nil ||
+		!strings.Contains(err.Error(), "nested more than") {
+		t.Errorf("expected a nesting error for a recursive include, got %v", err)
+	}
+}
+
+func TestGoTemplateEngine_PartialParseError(t *testing.T) {
+	err := NewGoTemplateEngine().SetPartials([]Partial{
+		{Name: "broken", Path: "_shared/partials/broken.tmpl", Content: "[[ if .x ]]"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "_shared/partials/broken.tmpl") {
+		t.Errorf("expected an error naming the partial, got %v", err)
+	}
+}
+
+func TestGenerateFilesFrom_Partials(t *testing.T) {
+	fsys := fstest.MapFS{
+		"_shared/partials/license.tmpl":      {Data: []byte("// Licensed to {{ author }}\n")},
+		"_shared/partials/layouts/go.tmpl":   {Data: []byte("{% include \"license\" %}package {% block package %}main{% endblock %}\n{% block body %}{% endblock %}")},
+		"blog/partials/license.tmpl":         {Data: []byte("{% include \"_shared/license\" %}// Blog\n")},
+		"blog/templates/main.go.tmpl":        {Data: []byte("{% extends \"layouts/go\" %}{% block body %}func main() {}\n{% endblock %}")},
+		"blog/templates/models/post.go.tmpl": {Data: []byte("{% extends \"layouts/go\" %}{% block package %}models{% endblock %}")},
+	}
+	src, err := ritual.NewSource(fsys, "blog", "embedded:blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := NewFileGenerator("fith")
+	vars := NewVariables()
+	vars.Set("author", "Ada")
+	gen.SetVariables(vars)
+
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "main.go.tmpl", Destination: "main.go"},
+				{Source: "models", Destination: "internal/models"},
+			},
+		},
+	}
+	outputDir := t.TempDir()
+	if err := gen.GenerateFilesFrom(manifest, src, outputDir); err != nil {
+		t.Fatalf("GenerateFilesFrom() error = %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"main.go":                 "// Licensed to Ada\n// Blog\npackage main\n\nfunc main() {}\n",
+		"internal/models/post.go": "// Licensed to Ada\n// Blog\npackage models\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(outputDir, path))
+		if err != nil {
+			t.Fatalf("%s not generated: %v", path, err)
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", path, content, want)
+		}
+	}
+
+	// Partials are not generated themselves
+	if _, err := os.Stat(filepath.Join(outputDir, "partials")); !os.IsNotExist(err) {
+		t.Error("partials should not be written to the project")
+	}
+}
+
+// countingEngine counts how often partials are parsed
+type countingEngine struct {
+	TemplateEngine
+	loads int
+}
+
+func (e *countingEngine) SetPartials(partials []Partial) error {
+	e.loads++
+	return e.TemplateEngine.SetPartials(partials)
+}
+
+func TestGenerateFilesFrom_ParsesPartialsOncePerRun(t *testing.T) {
+	fsys := fstest.MapFS{
+		"blog/partials/header.tmpl":  {Data: []byte("// header\n")},
+		"blog/templates/a.go.tmpl":   {Data: []byte(`[[ template "header" ]]package a`)},
+		"blog/templates/b.go.tmpl":   {Data: []byte(`[[ template "header" ]]package b`)},
+		"blog/templates/c/c.go.tmpl": {Data: []byte(`[[ template "header" ]]package c`)},
+		"blog/templates/c/d.go.tmpl": {Data: []byte(`[[ template "header" ]]package d`)},
+	}
+	src, err := ritual.NewSource(fsys, "blog", "embedded:blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gen := NewFileGenerator("go-template")
+	engine := &countingEngine{TemplateEngine: gen.engine}
+	gen.engine = engine
+
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "a.go.tmpl", Destination: "a.go"},
+				{Source: "b.go.tmpl", Destination: "b.go"},
+				{Source: "c", Destination: "c"},
+			},
+		},
+	}
+	if err := gen.GenerateFilesFrom(manifest, src, t.TempDir()); err != nil {
+		t.Fatalf("GenerateFilesFrom() error = %v", err)
+	}
+	if engine.loads != 1 {
+		t.Errorf("partials parsed %d times, want once per run", engine.loads)
+	}
+}
@@ -0,0 +1,239 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// Names of the stages of the default pipeline
+const (
+	StageSecrets     = "secrets"
+	StageScaffold    = "scaffold"
+	StagePlan        = "plan"
+	StageRender      = "render"
+	StagePostProcess = "post-process"
+	StageInject      = "inject"
+	StageWrite       = "write"
+	StageRecordState = "record-state"
+)
+
+// Run is one generation run: the ritual, where it goes and the plan the
+// stages of a pipeline build up and carry out in turn
+type Run struct {
+	Generator  *FileGenerator
+	Manifest   *ritual.Manifest
+	Source     *ritual.Source
+	OutputPath string
+	Variables  *Variables     // Values to render with; nil keeps the generator's
+	State      *storage.State // Receives the written files; nil records nothing
+
+	plan *generationPlan
+}
+
+// Stage is one step of a pipeline
+type Stage interface {
+	Name() string
+	Run(ctx context.Context, run *Run) error
+}
+
+// Pipeline generates files by passing a run through its stages in order
+type Pipeline struct {
+	stages []Stage
+}
+
+// NewPipeline creates a pipeline running stages in order
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{stages: stages}
+}
+
+// FileStages generate the files a ritual maps: secrets, plan, render,
+// post-process, inject and write
+func FileStages() []Stage {
+	return []Stage{SecretsStage{}, PlanStage{}, RenderStage{}, PostProcessStage{}, InjectStage{}, WriteStage{}}
+}
+
+// DefaultStages generate a whole project: the ritual's secrets, the standard
+// layout, the files the ritual maps and the record of them in the project state
+func DefaultStages() []Stage {
+	return []Stage{
+		SecretsStage{}, ScaffoldStage{}, PlanStage{}, RenderStage{},
+		PostProcessStage{}, InjectStage{}, WriteStage{}, RecordStateStage{},
+	}
+}
+
+// DefaultPipeline creates a pipeline running the default stages
+func DefaultPipeline() *Pipeline {
+	return NewPipeline(DefaultStages()...)
+}
+
+// Stages returns the stages of the pipeline in order
+func (p *Pipeline) Stages() []Stage {
+	return slices.Clone(p.stages)
+}
+
+// Replace swaps the stage called name for stage
+func (p *Pipeline) Replace(name string, stage Stage) error {
+	i, err := p.index(name)
+	if err != nil {
+		return err
+	}
+	p.stages[i] = stage
+	return nil
+}
+
+// InsertAfter adds stage right after the stage called name
+func (p *Pipeline) InsertAfter(name string, stage Stage) error {
+	i, err := p.index(name)
+	if err != nil {
+		return err
+	}
+	p.stages = slices.Insert(p.stages, i+1, stage)
+	return nil
+}
+
+// index returns the position of the stage called name
+func (p *Pipeline) index(name string) (int, error) {
+	i := slices.IndexFunc(p.stages, func(s Stage) bool { return s.Name() == name })
+	if i < 0 {
+		return 0, fmt.Errorf("pipeline has no %s stage", name)
+	}
+	return i, nil
+}
+
+// Run passes run through every stage, stopping at the first error or once ctx is done
+func (p *Pipeline) Run(ctx context.Context, run *Run) error {
+	if run.Generator == nil {
+		return fmt.Errorf("run has no generator")
+	}
+	if run.plan == nil {
+		run.plan = &generationPlan{root: run.OutputPath}
+	}
+	if run.Variables != nil {
+		run.Generator.SetVariables(run.Variables)
+	}
+
+	for _, stage := range p.stages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := stage.Run(ctx, run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SecretsStage generates the secrets the ritual's questions and variables ask for
+type SecretsStage struct{}
+
+// Name implements Stage
+func (SecretsStage) Name() string { return StageSecrets }
+
+// Run implements Stage
+func (SecretsStage) Run(_ context.Context, run *Run) error {
+	return GenerateSecrets(run.Manifest, run.Generator.variables)
+}
+
+// PlanStage sets up the ritual's engine, partials and protected files, then
+// plans every file its manifest maps. It fails before anything is written if a
+// destination leaves the project or two files collide; built-in files give way
+// to ritual files with the same destination.
+type PlanStage struct{}
+
+// Name implements Stage
+func (PlanStage) Name() string { return StagePlan }
+
+// Run implements Stage
+func (PlanStage) Run(_ context.Context, run *Run) error {
+	g := run.Generator
+	g.useManifestEngine(run.Manifest)
+	if err := g.usePartials(run.Source); err != nil {
+		return err
+	}
+	g.SetProtectedFiles(run.Manifest.Files.Protected)
+	return g.planManifest(run.plan, run.Source, run.Manifest)
+}
+
+// RenderStage renders the planned templates concurrently, skipping files that
+// already exist and are kept
+type RenderStage struct{}
+
+// Name implements Stage
+func (RenderStage) Name() string { return StageRender }
+
+// Run implements Stage
+func (RenderStage) Run(ctx context.Context, run *Run) error {
+	g := run.Generator
+	var pending []*plannedFile
+	for _, file := range run.plan.files {
+		if file.isTemplate && !file.rendered && !g.keepsExisting(file) {
+			pending = append(pending, file)
+		}
+	}
+	return g.eachFile(ctx, pending, g.render)
+}
+
+// PostProcessStage formats rendered Go files and fixes their imports. Snippets
+// to inject are left as they are.
+type PostProcessStage struct{}
+
+// Name implements Stage
+func (PostProcessStage) Name() string { return StagePostProcess }
+
+// Run implements Stage
+func (PostProcessStage) Run(ctx context.Context, run *Run) error {
+	var pending []*plannedFile
+	for _, file := range run.plan.files {
+		if file.rendered && file.inject == nil && filepath.Ext(file.dest) == ".go" {
+			pending = append(pending, file)
+		}
+	}
+	return run.Generator.eachFile(ctx, pending, run.Generator.postProcess)
+}
+
+// WriteStage creates the planned directories, then writes every file in plan order
+type WriteStage struct{}
+
+// Name implements Stage
+func (WriteStage) Name() string { return StageWrite }
+
+// Run implements Stage
+func (WriteStage) Run(ctx context.Context, run *Run) error {
+	g := run.Generator
+	for _, dir := range run.plan.dirs {
+		if err := g.output.MkdirAll(dir, 0750); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		}
+	}
+	for _, file := range run.plan.files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := itemError(file, g.write(file)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordStateStage records the files written into the project in run.State
+type RecordStateStage struct{}
+
+// Name implements Stage
+func (RecordStateStage) Name() string { return StageRecordState }
+
+// Run implements Stage
+func (RecordStateStage) Run(_ context.Context, run *Run) error {
+	if run.State == nil {
+		return nil
+	}
+	if err := run.Generator.RecordState(run.State, run.OutputPath); err != nil {
+		return fmt.Errorf("failed to record generated files: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// pipelineRun is a run of a ritual writing its own README.md and a handler
+// into memory, with everything recorded in a fresh state
+func pipelineRun(t *testing.T) (*Run, *MemoryFS) {
+	t.Helper()
+	fsys := fstest.MapFS{
+		"app/templates/README.md.tmpl": {Data: []byte("# [[ .project_name ]] from the ritual\n")},
+		"app/templates/hello.go.tmpl":  {Data: []byte("package handlers\nfunc  Hello() {}\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &ritual.Manifest{
+		Ritual: ritual.RitualMeta{Name: "app", Version: "1.0.0"},
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "README.md.tmpl", Destination: "README.md"},
+				{Source: "hello.go.tmpl", Destination: "internal/handlers/hello.go"},
+			},
+		},
+	}
+
+	vars := NewVariables()
+	vars.Set("project_name", "demo")
+	vars.Set("module_path", "example.com/demo")
+
+	output := NewMemoryFS()
+	g := NewFileGenerator("go-template")
+	g.SetOutput(output)
+	return &Run{
+		Generator:  g,
+		Manifest:   manifest,
+		Source:     src,
+		OutputPath: "/project",
+		Variables:  vars,
+		State:      &storage.State{RitualName: "app"},
+	}, output
+}
+
+func TestDefaultPipeline_ScaffoldsAndRecordsProject(t *testing.T) {
+	run, output := pipelineRun(t)
+	if err := DefaultPipeline().Run(context.Background(), run); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	files := output.Files("/project")
+	for _, path := range []string{"go.mod", "cmd/server/main.go", ".gitignore", ".env.example", "internal/handlers/hello.go"} {
+		if _, ok := files[path]; !ok {
+			t.Errorf("expected %s to be generated, got %v", path, output.Files("/project"))
+		}
+	}
+	if got := string(files["README.md"]); got != "# demo from the ritual\n" {
+		t.Errorf("the ritual's README.md should replace the built-in one, got %q", got)
+	}
+	if got := string(files["internal/handlers/hello.go"]); !strings.Contains(got, "func Hello() {}") {
+		t.Errorf("Go files should be formatted, got %q", got)
+	}
+
+	readme, ok := run.State.GetGeneratedFile("README.md")
+	if !ok {
+		t.Fatal("README.md should be recorded in state")
+	}
+	if readme.Source != "README.md.tmpl" || readme.SHA256 != storage.HashContent(files["README.md"]) {
+		t.Errorf("README.md recorded as %+v", readme)
+	}
+	goMod, ok := run.State.GetGeneratedFile("go.mod")
+	if !ok || goMod.Source != BuiltinSourcePrefix+"go.mod" {
+		t.Errorf("go.mod recorded as %+v", goMod)
+	}
+}
+
+// stageFunc is a stage running fn
+type stageFunc struct {
+	name string
+	fn   func(run *Run) error
+}
+
+func (s stageFunc) Name() string                          { return s.name }
+func (s stageFunc) Run(_ context.Context, run *Run) error { return s.fn(run) }
+
+func TestPipeline_InsertAfterAndReplace(t *testing.T) {
+	run, output := pipelineRun(t)
+	pipeline := DefaultPipeline()
+
+	// Stamp every rendered file before it is written
+	stamp := stageFunc{name: "stamp", fn: func(run *Run) error {
+		for _, file := range run.plan.files {
+			if file.rendered {
+				file.content = append([]byte("// stamped\n"), file.content...)
+			}
+		}
+		return nil
+	}}
+	if err := pipeline.InsertAfter(StagePostProcess, stamp); err != nil {
+		t.Fatal(err)
+	}
+	skip := stageFunc{name: StageRecordState, fn: func(*Run) error { return nil }}
+	if err := pipeline.Replace(StageRecordState, skip); err != nil {
+		t.Fatal(err)
+	}
+	if err := pipeline.Replace("lint", skip); err == nil {
+		t.Error("replacing a missing stage should fail")
+	}
+
+	var names []string
+	for _, stage := range pipeline.Stages() {
+		names = append(names, stage.Name())
+	}
+	want := "secrets scaffold plan render post-process stamp inject write record-state"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("stages = %s, want %s", got, want)
+	}
+
+	if err := pipeline.Run(context.Background(), run); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	hello := output.Files("/project")[filepath.Join("internal", "handlers", "hello.go")]
+	if !strings.HasPrefix(string(hello), "// stamped\n") {
+		t.Errorf("custom stage should run before writing, got %q", hello)
+	}
+	if run.State.IsFileGenerated("README.md") {
+		t.Error("the replaced record-state stage should not record anything")
+	}
+}
+
+func TestPipeline_StopsAtFirstError(t *testing.T) {
+	run, output := pipelineRun(t)
+	failure := errors.New("boom")
+	pipeline := NewPipeline(FileStages()...)
+	if err := pipeline.InsertAfter(StagePlan, stageFunc{name: "fail", fn: func(*Run) error { return failure }}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pipeline.Run(context.Background(), run); !errors.Is(err, failure) {
+		t.Fatalf("Run() error = %v, want %v", err, failure)
+	}
+	if files := output.Files("/project"); len(files) != 0 {
+		t.Errorf("nothing should be written, got %v", files)
+	}
+}
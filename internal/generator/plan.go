@@ -0,0 +1,428 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// plannedFile is a file a generation run writes, worked out before anything is written
+type plannedFile struct {
+	file       sourceFile
+	dest       string // Destination path
+	source     string // Source name the file is recorded under
+	isTemplate bool
+	opts       FileOptions
+	body       string         // Template without its frontmatter
+	bodyLine   int            // Line of the template the body starts on
+	engine     TemplateEngine // Engine the template renders with
+	vars       *Variables     // Variables the template renders with
+	origin     string         // The mapping producing the file, for errors
+	item       string         // The foreach item producing the file, if any
+	content    []byte         // Output of the template, once rendered
+	rendered   bool
+	// fallback marks a built-in file, which is left out if another file of
+	// the plan has the same destination
+	fallback bool
+	inject   *injection // Where the snippet goes, for a snippet injected into dest
+}
+
+// generationPlan lists the directories and files of a run in the order they are written
+type generationPlan struct {
+	root  string // Project directory every file must stay inside; "" for none
+	dirs  []string
+	files []*plannedFile
+}
+
+// fileTarget is where the manifest puts a generated file
+type fileTarget struct {
+	path string // Destination path
+	// dir is the destination directory of a directory mapping, below which a
+	// template's frontmatter may choose its own output path. It is empty for a
+	// file the manifest maps itself.
+	dir string
+	// conditional reports that the manifest gives the file its own condition,
+	// which replaces any condition in the frontmatter
+	conditional bool
+	// merge and mergeArrays are how the manifest merges the file into an
+	// existing one, replacing what the frontmatter says
+	merge, mergeArrays string
+}
+
+// planManifest plans the template and static files, entities and injections
+// of a manifest into outputPath. Every file is planned before anything is written, so a
+// destination leaving the project or two mappings writing the same file fail
+// before anything is written.
+func (g *FileGenerator) planManifest(plan *generationPlan, src *ritual.Source, manifest *ritual.Manifest) error {
+	if err := g.planMappings(plan, src, manifest.Files.Templates, "templates"); err != nil {
+		return err
+	}
+	if err := g.planMappings(plan, src, manifest.Files.Static, "static"); err != nil {
+		return err
+	}
+	if err := g.planEntities(plan, manifest.Entities); err != nil {
+		return err
+	}
+	if err := g.planInjections(plan, src, manifest.Files.Inject); err != nil {
+		return err
+	}
+	plan.dropFallbacks()
+	if err := plan.checkCollisions(); err != nil {
+		return err
+	}
+	return g.checkSymlinks(plan)
+}
+
+// eachFile calls fn for files with a bounded pool of workers. The first
+// failure cancels the files not yet started. Files are started in order, so
+// the error returned is always that of the first failing file.
+func (g *FileGenerator) eachFile(ctx context.Context, files []*plannedFile, fn func(*plannedFile) error) error {
+	workCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(files))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(g.workers, len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if errs[i] = itemError(files[i], fn(files[i])); errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range files {
+		select {
+		case jobs <- i:
+		case <-workCtx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// planMappings plans the files of template ("templates") or static ("static") mappings
+func (g *FileGenerator) planMappings(plan *generationPlan, src *ritual.Source, mappings []ritual.FileMapping, kind string) error {
+	for _, mapping := range mappings {
+		var err error
+		if mapping.Foreach != "" {
+			err = g.planForeach(plan, src, mapping, kind)
+		} else {
+			err = g.planMapping(plan, src, mapping, kind, "")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planMapping plans the file or directory of one mapping. item names the
+// foreach item the mapping is planned for, if any.
+func (g *FileGenerator) planMapping(plan *generationPlan, src *ritual.Source, mapping ritual.FileMapping, kind, item string) error {
+	isTemplate := kind == "templates"
+
+	// Evaluate condition if present
+	if mapping.Condition != "" {
+		shouldGenerate, err := evaluateCondition(mapping.Condition, g.variables.All())
+		if err != nil {
+			return fmt.Errorf("failed to evaluate condition for %s: %w", mapping.Source, err)
+		}
+		if !shouldGenerate {
+			return nil // Skip this file
+		}
+	}
+
+	// Resolve source (handle _shared: prefix) - below templates/ or static/
+	file := resolveSource(src, mapping.Source, kind)
+	origin := fmt.Sprintf("static file %s", mapping.Source)
+	if isTemplate {
+		origin = fmt.Sprintf("template %s", mapping.Source)
+	}
+	if item != "" {
+		origin += " (" + item + ")"
+	}
+
+	// Render destination path (it may contain template variables)
+	destPathRendered, err := g.engine.Render(mapping.Destination, g.variables.All())
+	if err != nil {
+		return fmt.Errorf("failed to render destination path %s: %w", mapping.Destination, err)
+	}
+	if plan.root != "" && !filepath.IsLocal(destPathRendered) && filepath.Clean(destPathRendered) != "." {
+		return fmt.Errorf("destination %q of %s must stay inside the project", destPathRendered, origin)
+	}
+	destPath := filepath.Join(plan.root, destPathRendered)
+
+	// Check if file/directory exists
+	info, err := file.stat()
+	if err != nil {
+		if mapping.Optional {
+			return nil
+		}
+		if isTemplate {
+			return fmt.Errorf("template source not found: %s", file.display)
+		}
+		return fmt.Errorf("static source not found: %s", file.display)
+	}
+
+	if info.IsDir() {
+		dir := fileTarget{path: destPath, merge: mapping.Merge, mergeArrays: mapping.MergeArrays}
+		return g.planDirectory(plan, file, dir, mapping.Source, isTemplate, origin, item)
+	}
+	target := fileTarget{
+		path:        destPath,
+		conditional: mapping.Condition != "",
+		merge:       mapping.Merge,
+		mergeArrays: mapping.MergeArrays,
+	}
+	return g.planFile(plan, file, target, mapping.Source, isTemplate, origin, item)
+}
+
+// planDirectory plans all files in a directory, into the directory of target.
+// source is the manifest source of the directory; each file is recorded below it.
+// File and directory names of templates are rendered, so they can hold variables.
+func (g *FileGenerator) planDirectory(plan *generationPlan, dir sourceFile, dirTarget fileTarget, source string, isTemplate bool, origin, item string) error {
+	destDir := dirTarget.path
+	// Rendered path of each directory walked so far, relative to destDir
+	destDirs := map[string]string{dir.name: ""}
+
+	return fs.WalkDir(dir.fsys, dir.name, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == dir.name {
+			return nil
+		}
+
+		// Calculate relative path
+		relPath := strings.TrimPrefix(name, dir.name+"/")
+		display := path.Join(dir.display, relPath)
+
+		segment := d.Name()
+		if isTemplate {
+			// Strip .tmpl extension for template files
+			if !d.IsDir() {
+				segment = strings.TrimSuffix(segment, ".tmpl")
+			}
+			if segment, err = g.renderSegment(segment, display); err != nil {
+				return err
+			}
+			if segment == "" {
+				// Nothing to name it by: leave it out
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+		}
+		destRel := path.Join(destDirs[path.Dir(name)], segment)
+		if d.IsDir() {
+			destDirs[name] = destRel
+			return nil
+		}
+		destPath := filepath.Join(destDir, filepath.FromSlash(destRel))
+
+		file := sourceFile{fsys: dir.fsys, name: name, display: display}
+		target := fileTarget{path: destPath, dir: destDir, merge: dirTarget.merge, mergeArrays: dirTarget.mergeArrays}
+		return g.planFile(plan, file, target, source+"/"+relPath, isTemplate, origin, item)
+	})
+}
+
+// renderSegment renders a file or directory name of a template directory. A name
+// rendering to nothing but whitespace gives ""; one rendering to more than a
+// single path segment is an error.
+func (g *FileGenerator) renderSegment(segment, display string) (string, error) {
+	rendered, err := g.engine.Render(segment, g.variables.All())
+	if err != nil {
+		return "", fmt.Errorf("failed to render file name %s: %w", display, err)
+	}
+	if strings.TrimSpace(rendered) == "" {
+		return "", nil
+	}
+	if rendered == "." || rendered == ".." || strings.ContainsAny(rendered, `/\`) {
+		return "", fmt.Errorf("file name %s renders to %q, which is not a single path segment", display, rendered)
+	}
+	return rendered, nil
+}
+
+// planFile plans a single file, recorded under the given source name.
+// The frontmatter of a template may skip the file, move it or change how it is written.
+func (g *FileGenerator) planFile(plan *generationPlan, file sourceFile, target fileTarget, source string, isTemplate bool, origin, item string) error {
+	planned := &plannedFile{
+		file:       file,
+		dest:       target.path,
+		source:     source,
+		isTemplate: isTemplate,
+		opts:       defaultFileOptions(),
+		engine:     g.engine,
+		vars:       g.variables,
+		origin:     origin,
+		item:       item,
+	}
+
+	if isTemplate {
+		content, err := fs.ReadFile(file.fsys, file.name)
+		if err != nil {
+			return fmt.Errorf("failed to read template %s: %w", file.display, err)
+		}
+		if planned.opts, planned.body, err = parseFileOptions(file.display, string(content)); err != nil {
+			return err
+		}
+		planned.bodyLine = 1 + strings.Count(string(content[:len(content)-len(planned.body)]), "\n")
+		if planned.engine, err = g.engineFor(planned.opts); err != nil {
+			return fmt.Errorf("failed to set up template %s: %w", file.display, err)
+		}
+	}
+	if target.merge != "" {
+		planned.opts.Merge = target.merge
+	}
+	if target.mergeArrays != "" {
+		planned.opts.MergeArrays = target.mergeArrays
+	}
+	opts := planned.opts
+
+	if opts.Condition != "" && !target.conditional {
+		shouldGenerate, err := evaluateCondition(opts.Condition, g.variables.All())
+		if err != nil {
+			return fmt.Errorf("failed to evaluate condition for %s: %w", file.display, err)
+		}
+		if !shouldGenerate {
+			return nil
+		}
+	}
+
+	if opts.Output != "" && target.dir != "" {
+		output, err := planned.engine.Render(opts.Output, g.variables.All())
+		if err != nil {
+			return fmt.Errorf("failed to render output path %s of %s: %w", opts.Output, file.display, err)
+		}
+		if !filepath.IsLocal(output) {
+			return fmt.Errorf("output path %q of %s must stay inside %s", output, file.display, target.dir)
+		}
+		planned.dest = filepath.Join(target.dir, output)
+	}
+
+	plan.files = append(plan.files, planned)
+	return nil
+}
+
+// dropFallbacks leaves out fallback files whose destination another file
+// writes; a snippet injected into a fallback file keeps it
+func (p *generationPlan) dropFallbacks() {
+	written := make(map[string]bool)
+	for _, file := range p.files {
+		if !file.fallback && file.inject == nil {
+			written[filepath.Clean(file.dest)] = true
+		}
+	}
+	p.files = slices.DeleteFunc(p.files, func(file *plannedFile) bool {
+		return file.fallback && written[filepath.Clean(file.dest)]
+	})
+}
+
+// checkCollisions reports two files planned for the same destination, unless
+// the later one merges into the earlier or is a snippet injected into it
+func (p *generationPlan) checkCollisions() error {
+	owners := make(map[string]*plannedFile)
+	for _, file := range p.files {
+		if file.inject != nil {
+			continue
+		}
+		dest := filepath.Clean(file.dest)
+		owner, ok := owners[dest]
+		if !ok {
+			owners[dest] = file
+			continue
+		}
+		if file.opts.Merge != "" {
+			continue
+		}
+		return fmt.Errorf("%s and %s both write %s", owner.origin, file.origin, p.display(dest))
+	}
+	return nil
+}
+
+// display shows a destination relative to the project
+func (p *generationPlan) display(dest string) string {
+	if rel, err := filepath.Rel(p.root, dest); err == nil && p.root != "" {
+		return filepath.ToSlash(rel)
+	}
+	return dest
+}
+
+// checkSymlinks rejects destinations that a symlink already in the project
+// points outside of it. Only outputs on disk have symlinks to follow.
+func (g *FileGenerator) checkSymlinks(plan *generationPlan) error {
+	if _, inMemory := g.output.(*MemoryFS); inMemory || plan.root == "" {
+		return nil
+	}
+
+	root, err := resolvePath(plan.root)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", plan.root, err)
+	}
+	for _, file := range plan.files {
+		resolved, err := resolvePath(file.dest)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s of %s: %w", plan.display(file.dest), file.origin, err)
+		}
+		if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) && rel != "." {
+			return fmt.Errorf("%s of %s leads outside the project through a symlink, to %s",
+				plan.display(file.dest), file.origin, resolved)
+		}
+	}
+	return nil
+}
+
+// resolvePath returns the absolute path p refers to once symlinks in its
+// existing part are followed. A symlink that cannot be followed is an error,
+// since writing through it could create a file anywhere.
+func resolvePath(p string) (string, error) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string // Trailing elements that do not exist yet, innermost first
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				resolved = filepath.Join(resolved, missing[i])
+			}
+			return resolved, nil
+		}
+		if _, lstatErr := os.Lstat(p); lstatErr == nil || !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			return p, nil
+		}
+		missing = append(missing, filepath.Base(p))
+		p = parent
+	}
+}
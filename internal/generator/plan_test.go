@@ -0,0 +1,416 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	embedded "github.com/toutaio/toutago-ritual-grove"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// planSource is a ritual source holding a few templates and static files
+func planSource(t *testing.T) *ritual.Source {
+	t.Helper()
+	fsys := fstest.MapFS{
+		"app/templates/main.go.tmpl":        {Data: []byte("package main\n")},
+		"app/templates/cmd/main.go.tmpl":    {Data: []byte("package cmd\n")},
+		"app/templates/cmd/root.go.tmpl":    {Data: []byte("package cmd\n")},
+		"app/templates/gitignore.tmpl":      {Data: []byte("bin/\n")},
+		"app/templates/gitignore-node.tmpl": {Data: []byte("---\nmerge: append\n---\n.env\nnode_modules/\n")},
+		"app/static/main.go":                {Data: []byte("package static\n")},
+		"app/static/gitignore":              {Data: []byte("*.log\n")},
+		"app/static/public/css/style.css":   {Data: []byte("body{}")},
+		"app/static/public/js/app.js":       {Data: []byte("app()")},
+		"app/templates/public/js/app.js":    {Data: []byte("generated()")},
+		"app/templates/README.md.tmpl":      {Data: []byte("# readme\n")},
+		"app/templates/docs/index.md.tmpl":  {Data: []byte("# docs\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+// assertEmpty fails if anything was written to dir
+func assertEmpty(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("nothing should be written, found %v", entries)
+	}
+}
+
+func TestGenerateFilesFrom_RejectsDestinationsOutsideProject(t *testing.T) {
+	for _, dest := range []string{"../../etc/passwd", "/etc/passwd", "docs/../../escape.md"} {
+		t.Run(dest, func(t *testing.T) {
+			manifest := &ritual.Manifest{
+				Files: ritual.FilesSection{
+					Templates: []ritual.FileMapping{
+						{Source: "README.md.tmpl", Destination: "README.md"},
+						{Source: "main.go.tmpl", Destination: dest},
+					},
+				},
+			}
+			outputDir := t.TempDir()
+			err := NewFileGenerator("fith").GenerateFilesFrom(manifest, planSource(t), outputDir)
+			if err == nil || !strings.Contains(err.Error(), "template main.go.tmpl must stay inside the project") {
+				t.Errorf("expected the destination to be rejected, got %v", err)
+			}
+			assertEmpty(t, outputDir)
+		})
+	}
+}
+
+func TestGenerateFilesFrom_RejectsSymlinkEscapes(t *testing.T) {
+	outside := t.TempDir()
+
+	tests := []struct {
+		name string
+		link string // Symlink created in the project, pointing outside of it
+	}{
+		{name: "linked directory", link: "docs"},
+		{name: "linked file", link: "README.md"},
+		{name: "dangling link", link: "README.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			target := outside
+			switch tt.name {
+			case "linked file":
+				target = filepath.Join(outside, "README.md")
+				if err := os.WriteFile(target, []byte("mine"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			case "dangling link":
+				target = filepath.Join(outside, "missing", "README.md")
+			}
+			if err := os.Symlink(target, filepath.Join(outputDir, tt.link)); err != nil {
+				t.Skipf("symlinks not supported: %v", err)
+			}
+
+			manifest := &ritual.Manifest{
+				Files: ritual.FilesSection{
+					Templates: []ritual.FileMapping{
+						{Source: "README.md.tmpl", Destination: "README.md"},
+						{Source: "docs", Destination: "docs"},
+					},
+				},
+			}
+			err := NewFileGenerator("fith").GenerateFilesFrom(manifest, planSource(t), outputDir)
+			if err == nil {
+				t.Fatal("expected the symlink escape to be rejected")
+			}
+
+			entries, err := os.ReadDir(outside)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if entry.Name() != "README.md" {
+					t.Errorf("%s written outside the project", entry.Name())
+				}
+			}
+			if content, err := os.ReadFile(filepath.Join(outside, "README.md")); err == nil && string(content) != "mine" {
+				t.Errorf("README.md outside the project was overwritten: %q", content)
+			}
+		})
+	}
+}
+
+func TestGenerateFilesFrom_FollowsSymlinksInsideProject(t *testing.T) {
+	outputDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(outputDir, "site"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("site", filepath.Join(outputDir, "docs")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{{Source: "docs", Destination: "docs"}},
+		},
+	}
+	if err := NewFileGenerator("fith").GenerateFilesFrom(manifest, planSource(t), outputDir); err != nil {
+		t.Fatalf("GenerateFilesFrom() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "site", "index.md")); err != nil {
+		t.Errorf("index.md should be written through the link: %v", err)
+	}
+}
+
+func TestGenerateFilesFrom_Collisions(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates []ritual.FileMapping
+		static    []ritual.FileMapping
+		wantErr   string
+	}{
+		{
+			name:      "template and static file",
+			templates: []ritual.FileMapping{{Source: "main.go.tmpl", Destination: "main.go"}},
+			static:    []ritual.FileMapping{{Source: "main.go", Destination: "main.go"}},
+			wantErr:   "template main.go.tmpl and static file main.go both write main.go",
+		},
+		{
+			name: "directory expansion",
+			templates: []ritual.FileMapping{
+				{Source: "cmd", Destination: "cmd"},
+				{Source: "main.go.tmpl", Destination: "cmd/main.go"},
+			},
+			wantErr: "template cmd and template main.go.tmpl both write cmd/main.go",
+		},
+		{
+			name:      "two directory expansions",
+			templates: []ritual.FileMapping{{Source: "public", Destination: "public"}},
+			static:    []ritual.FileMapping{{Source: "public", Destination: "public"}},
+			wantErr:   "template public and static file public both write public/js/app.js",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &ritual.Manifest{Files: ritual.FilesSection{Templates: tt.templates, Static: tt.static}}
+			outputDir := t.TempDir()
+			err := NewFileGenerator("fith").GenerateFilesFrom(manifest, planSource(t), outputDir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+			assertEmpty(t, outputDir)
+		})
+	}
+}
+
+func TestGenerateFilesFrom_AppendIsNotACollision(t *testing.T) {
+	manifest := &ritual.Manifest{
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "gitignore.tmpl", Destination: ".gitignore"},
+				{Source: "gitignore-node.tmpl", Destination: ".gitignore"},
+			},
+			Static: []ritual.FileMapping{{Source: "gitignore", Destination: ".gitignore"}},
+		},
+	}
+	outputDir := t.TempDir()
+	err := NewFileGenerator("fith").GenerateFilesFrom(manifest, planSource(t), outputDir)
+	if err == nil || !strings.Contains(err.Error(), "template gitignore.tmpl and static file gitignore both write .gitignore") {
+		t.Errorf("a file that does not append should collide, got %v", err)
+	}
+
+	// Files appending to one written earlier in the run are fine
+	manifest.Files.Static = nil
+	if err := NewFileGenerator("fith").GenerateFilesFrom(manifest, planSource(t), outputDir); err != nil {
+		t.Fatalf("GenerateFilesFrom() error = %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(outputDir, ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "bin/\n.env\nnode_modules/\n" {
+		t.Errorf(".gitignore = %q", content)
+	}
+}
+
+// pageSource is a ritual source with one template per page, for n pages.
+// Pages listed in broken fail to render.
+func pageSource(t testing.TB, n int, broken ...int) (*ritual.Source, *ritual.Manifest) {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	manifest := &ritual.Manifest{}
+	for i := range n {
+		content := fmt.Sprintf("# {{ app_name }} page %d\n", i)
+		if slices.Contains(broken, i) {
+			content = fmt.Sprintf("{{ missing_%d() }}", i)
+		}
+		name := fmt.Sprintf("page%03d.md.tmpl", i)
+		fsys["app/templates/"+name] = &fstest.MapFile{Data: []byte(content)}
+		manifest.Files.Templates = append(manifest.Files.Templates, ritual.FileMapping{
+			Source: name, Destination: fmt.Sprintf("pages/%03d.md", i),
+		})
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return src, manifest
+}
+
+func TestGenerateFilesFrom_WritesInPlanOrder(t *testing.T) {
+	src, manifest := pageSource(t, 50)
+	outputDir := t.TempDir()
+
+	gen := NewFileGenerator("fith")
+	gen.SetWorkers(8)
+	gen.variables.Set("app_name", "blog")
+	if err := gen.GenerateFilesFrom(manifest, src, outputDir); err != nil {
+		t.Fatalf("GenerateFilesFrom() error = %v", err)
+	}
+
+	generated := gen.GeneratedFiles()
+	if len(generated) != 50 {
+		t.Fatalf("expected 50 files, got %d", len(generated))
+	}
+	for i, file := range generated {
+		if want := fmt.Sprintf("page%03d.md.tmpl", i); file.Source != want {
+			t.Errorf("file %d is %s, want %s", i, file.Source, want)
+		}
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("# blog page %d\n", i); string(content) != want {
+			t.Errorf("%s = %q, want %q", file.Path, content, want)
+		}
+	}
+}
+
+func TestGenerateFilesFrom_ReportsFirstRenderErrorInPlanOrder(t *testing.T) {
+	for _, workers := range []int{1, 4, 32} {
+		t.Run(fmt.Sprint(workers, " workers"), func(t *testing.T) {
+			src, manifest := pageSource(t, 40, 37, 12, 25)
+			outputDir := t.TempDir()
+
+			gen := NewFileGenerator("fith")
+			gen.SetWorkers(workers)
+			err := gen.GenerateFilesFrom(manifest, src, outputDir)
+			if err == nil || !strings.Contains(err.Error(), "page012.md.tmpl") {
+				t.Errorf("expected the error of page 12, got %v", err)
+			}
+			assertEmpty(t, outputDir)
+		})
+	}
+}
+
+func TestGenerateFilesFromContext_Cancelled(t *testing.T) {
+	src, manifest := pageSource(t, 10)
+	outputDir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := NewFileGenerator("fith").GenerateFilesFromContext(ctx, manifest, src, outputDir)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	assertEmpty(t, outputDir)
+}
+
+func TestGoTemplateEngine_ConcurrentIncludes(t *testing.T) {
+	engine := NewGoTemplateEngine()
+	err := engine.SetPartials([]Partial{{Name: "name", Path: "partials/name.tmpl", Content: "[[ .name | upper ]]"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprint("page", i)
+			out, err := engine.RenderNamed("page.tmpl", `[[ include "name" . ]]`, map[string]interface{}{"name": name})
+			if err != nil || out != strings.ToUpper(name) {
+				t.Errorf("RenderNamed() = %q, %v, want %q", out, err, strings.ToUpper(name))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestPlanEmbeddedRituals_EveryChoice plans every embedded ritual once for
+// each choice of each of its choice questions, or once with its defaults if it
+// has none, so that no answer a ritual offers maps two files to the same destination
+func TestPlanEmbeddedRituals_EveryChoice(t *testing.T) {
+	names, err := embedded.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		src, err := ritual.NewSource(embedded.GetFS(), name, "embedded:"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest, err := src.Load()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		answers := map[string][2]string{name + "/defaults": {}}
+		for _, q := range manifest.Questions {
+			if q.Type != ritual.QuestionTypeChoice {
+				continue
+			}
+			delete(answers, name+"/defaults")
+			for _, choice := range q.Choices {
+				answers[name+"/"+q.Name+"="+choice] = [2]string{q.Name, choice}
+			}
+		}
+
+		for label, answer := range answers {
+			t.Run(label, func(t *testing.T) {
+				vars := NewVariables()
+				for _, q := range manifest.Questions {
+					if q.Default != nil {
+						vars.Set(q.Name, q.Default)
+					}
+				}
+				if answer[0] != "" {
+					vars.Set(answer[0], answer[1])
+				}
+				vars.AddComputed()
+
+				run := &Run{Generator: NewFileGenerator("fith"), Manifest: manifest, Source: src, OutputPath: t.TempDir(), Variables: vars}
+				if err := NewPipeline(SecretsStage{}, PlanStage{}).Run(context.Background(), run); err != nil {
+					t.Errorf("plan failed: %v", err)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkRenderEmbeddedRituals renders the embedded rituals with their default answers
+func BenchmarkRenderEmbeddedRituals(b *testing.B) {
+	for _, name := range []string{"blog", "wiki"} {
+		b.Run(name, func(b *testing.B) {
+			src, err := ritual.NewSource(embedded.GetFS(), name, "embedded:"+name)
+			if err != nil {
+				b.Fatal(err)
+			}
+			manifest, err := src.Load()
+			if err != nil {
+				b.Fatal(err)
+			}
+			vars := NewVariables()
+			for _, q := range manifest.Questions {
+				if q.Default != nil {
+					vars.Set(q.Name, q.Default)
+				}
+			}
+			vars.Set("app_name", "Bench")
+			vars.Set("module_path", "example.com/bench")
+			vars.AddComputed()
+
+			gen := NewFileGenerator("fith")
+			gen.SetVariables(vars)
+			b.ResetTimer()
+			for range b.N {
+				if _, err := gen.RenderToMap(manifest, src); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/funcs"
+)
+
+// repositoryColumn is a column of a model's table and the field holding it
+type repositoryColumn struct {
+	name  string
+	field string
+}
+
+// repositorySource writes the repository of a model: the SQL it runs and the
+// names its code uses
+type repositorySource struct {
+	config   ModelConfig
+	dialect  string
+	model    string // Model name, such as BlogPost
+	impl     string // BlogPostRepositoryImpl
+	table    string
+	human    string // blog post, for comments and errors
+	local    string // blogPost, the prefix of unexported names
+	notFound string // ErrBlogPostNotFound
+	columns  []repositoryColumn
+}
+
+// sqlDialect returns the dialect a database type is written in: postgres
+// (the default), mysql or sqlite
+func sqlDialect(dbType string) string {
+	switch strings.ToLower(dbType) {
+	case "mysql", "mariadb":
+		return "mysql"
+	case "sqlite", "sqlite3":
+		return "sqlite"
+	}
+	return "postgres"
+}
+
+// sqlPlaceholders returns the markers of n query parameters in dialect,
+// numbered from first: $1, $2... for postgres, ? for the others
+func sqlPlaceholders(dialect string, first, n int) []string {
+	marks := make([]string, n)
+	for i := range marks {
+		marks[i] = "?"
+		if dialect == "postgres" {
+			marks[i] = fmt.Sprintf("$%d", first+i)
+		}
+	}
+	return marks
+}
+
+// columnName returns the column of a field, from its db tag
+func columnName(field Field) string {
+	if name, _, _ := strings.Cut(reflect.StructTag(field.Tags).Get("db"), ","); name != "" {
+		return name
+	}
+	return toSnakeCase(field.Name)
+}
+
+// modelTable returns the table of a model: its own, or the snake_case plural of its name
+func modelTable(name, table string) string {
+	if table != "" {
+		return table
+	}
+	return toSnakeCase(funcs.Pluralize(name))
+}
+
+func newRepositorySource(config ModelConfig) *repositorySource {
+	r := &repositorySource{
+		config:   config,
+		dialect:  sqlDialect(config.Dialect),
+		model:    config.Name,
+		impl:     config.Name + "RepositoryImpl",
+		table:    modelTable(config.Name, config.Table),
+		human:    strings.ReplaceAll(toSnakeCase(config.Name), "_", " "),
+		local:    toCamelCase(config.Name),
+		notFound: "Err" + config.Name + "NotFound",
+		columns:  []repositoryColumn{{"id", "ID"}},
+	}
+	for _, f := range config.Fields {
+		if column := columnName(f); column != "-" {
+			r.columns = append(r.columns, repositoryColumn{column, f.Name})
+		}
+	}
+	for _, rel := range config.Relationships {
+		if rel.Type == "BelongsTo" {
+			r.columns = append(r.columns, repositoryColumn{toSnakeCase(rel.Model) + "_id", rel.Model + "ID"})
+		}
+	}
+	if config.Timestamps {
+		r.columns = append(r.columns, repositoryColumn{"created_at", "CreatedAt"}, repositoryColumn{"updated_at", "UpdatedAt"})
+	}
+	if config.SoftDelete {
+		r.columns = append(r.columns, repositoryColumn{"deleted_at", "DeletedAt"})
+	}
+	return r
+}
+
+// generateRepositoryContent returns the repository of a model: its interface,
+// with a sentinel error for missing rows, and its database/sql implementation
+// in the dialect of the config, with helpers preloading related models from
+// their own repositories
+func (g *ModelGenerator) generateRepositoryContent(config ModelConfig) string {
+	module := config.Module
+	if module == "" {
+		module = "your-module"
+	}
+	r := newRepositorySource(config)
+
+	var sb strings.Builder
+	sb.WriteString("package repository\n\nimport (\n")
+	for _, imp := range r.imports() {
+		sb.WriteString(fmt.Sprintf("\t%q\n", imp))
+	}
+	sb.WriteString(fmt.Sprintf("\n\t%q\n)\n\n", importPath(module, config.Layout.withDefaults().Models)))
+
+	sb.WriteString(fmt.Sprintf(`// %[3]s is returned when there is no %[4]s with the id asked for
+var %[3]s = errors.New("%[4]s not found")
+
+// %[1]sRepository defines the interface for %[1]s data access
+type %[1]sRepository interface {
+	Create(ctx context.Context, item *models.%[1]s) (*models.%[1]s, error)
+	GetByID(ctx context.Context, id uint) (*models.%[1]s, error)
+	List(ctx context.Context, limit, offset int) ([]*models.%[1]s, error)
+	Update(ctx context.Context, id uint, item *models.%[1]s) (*models.%[1]s, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+// %[2]s implements %[1]sRepository on a %[5]s database
+type %[2]s struct {
+	db *sql.DB
+}
+
+// New%[1]sRepository creates a new %[1]s repository
+func New%[1]sRepository(db *sql.DB) *%[2]s {
+	return &%[2]s{db: db}
+}
+
+// %[6]sColumns are the columns scan%[1]s reads, in order
+const %[6]sColumns = "%[7]s"
+
+// scan%[1]s reads a %[4]s from a row of %[6]sColumns, after the values of
+// any columns selected before them
+func scan%[1]s(row interface{ Scan(...any) error }, before ...any) (*models.%[1]s, error) {
+	var item models.%[1]s
+	if err := row.Scan(append(before, %[8]s)...); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+`, r.model, r.impl, r.notFound, r.human, r.dialect, r.local, r.qualifiedColumns(), r.scanTargets()))
+
+	sb.WriteString(r.create())
+	sb.WriteString(r.getByID())
+	sb.WriteString(r.list())
+	sb.WriteString(r.update())
+	sb.WriteString(r.delete())
+	for _, rel := range config.Relationships {
+		sb.WriteString(r.loader(rel))
+	}
+	return sb.String()
+}
+
+// imports returns the standard library packages the repository uses
+func (r *repositorySource) imports() []string {
+	imports := []string{"context", "database/sql", "errors", "fmt"}
+	batch := slices.ContainsFunc(r.config.Relationships, func(rel Relationship) bool {
+		return rel.Type == "HasMany" || rel.Type == "ManyToMany"
+	})
+	if batch && r.dialect == "postgres" {
+		imports = append(imports, "strconv")
+	}
+	if batch {
+		imports = append(imports, "strings")
+	}
+	if r.config.Timestamps || r.config.SoftDelete {
+		imports = append(imports, "time")
+	}
+	return imports
+}
+
+// qualifiedColumns returns the columns of the table, qualified by its name
+func (r *repositorySource) qualifiedColumns() string {
+	columns := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		columns[i] = r.table + "." + c.name
+	}
+	return strings.Join(columns, ", ")
+}
+
+// scanTargets returns the addresses of the fields of item holding the columns
+func (r *repositorySource) scanTargets() string {
+	targets := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		targets[i] = "&item." + c.field
+	}
+	return strings.Join(targets, ", ")
+}
+
+// writable returns the columns Create or Update set, leaving out the id,
+// deletion time and, if skip says so, others
+func (r *repositorySource) writable(skip ...string) []repositoryColumn {
+	var columns []repositoryColumn
+	for _, c := range r.columns {
+		if c.name != "id" && c.name != "deleted_at" && !slices.Contains(skip, c.name) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// alive returns the condition selecting rows that are not soft deleted,
+// joined to the conditions before it by and
+func (r *repositorySource) alive(and string) string {
+	if !r.config.SoftDelete {
+		return ""
+	}
+	return and + r.table + ".deleted_at IS NULL"
+}
+
+// fieldArgs returns the fields of item holding columns, as query arguments
+func fieldArgs(columns []repositoryColumn) string {
+	args := make([]string, len(columns))
+	for i, c := range columns {
+		args[i] = "item." + c.field
+	}
+	return strings.Join(args, ", ")
+}
+
+func (r *repositorySource) create() string {
+	columns := r.writable()
+	var stamp strings.Builder
+	if r.config.Timestamps {
+		stamp.WriteString("\tnow := time.Now().UTC().Truncate(time.Second)\n\titem.CreatedAt, item.UpdatedAt = now, now\n")
+	}
+	if r.config.SoftDelete {
+		stamp.WriteString("\titem.DeletedAt = nil\n")
+	}
+
+	var query string
+	switch {
+	case len(columns) > 0:
+		names := make([]string, len(columns))
+		for i, c := range columns {
+			names[i] = c.name
+		}
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", r.table, strings.Join(names, ", "),
+			strings.Join(sqlPlaceholders(r.dialect, 1, len(columns)), ", "))
+	case r.dialect == "mysql":
+		query = fmt.Sprintf("INSERT INTO %s () VALUES ()", r.table)
+	default:
+		query = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", r.table)
+	}
+	args := ""
+	if len(columns) > 0 {
+		args = ",\n\t\t" + fieldArgs(columns)
+	}
+
+	// Postgres returns the id of the new row; the others report the last one inserted
+	insert := fmt.Sprintf(`	err := r.db.QueryRowContext(ctx,
+		"%s RETURNING id"%s).Scan(&item.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %%w", err)
+	}
+`, query, args, r.human)
+	if r.dialect != "postgres" {
+		insert = fmt.Sprintf(`	result, err := r.db.ExecContext(ctx,
+		"%[1]s"%[2]s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %[3]s: %%w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the id of the new %[3]s: %%w", err)
+	}
+	item.ID = uint(id)
+`, query, args, r.human)
+	}
+
+	return fmt.Sprintf(`// Create inserts item and returns it with its id
+func (r *%s) Create(ctx context.Context, item *models.%s) (*models.%s, error) {
+%s%s	return item, nil
+}
+
+`, r.impl, r.model, r.model, stamp.String(), insert)
+}
+
+func (r *repositorySource) getByID() string {
+	return fmt.Sprintf(`// GetByID returns the %[1]s with id, or %[2]s
+func (r *%[3]s) GetByID(ctx context.Context, id uint) (*models.%[4]s, error) {
+	item, err := scan%[4]s(r.db.QueryRowContext(ctx,
+		"SELECT "+%[5]sColumns+" FROM %[6]s WHERE %[6]s.id = %[7]s%[8]s", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, %[2]s
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %[1]s %%d: %%w", id, err)
+	}
+	return item, nil
+}
+
+`, r.human, r.notFound, r.impl, r.model, r.local, r.table, sqlPlaceholders(r.dialect, 1, 1)[0], r.alive(" AND "))
+}
+
+func (r *repositorySource) list() string {
+	marks := sqlPlaceholders(r.dialect, 1, 2)
+	plural := strings.ReplaceAll(toSnakeCase(funcs.Pluralize(r.model)), "_", " ")
+	return fmt.Sprintf(`// List returns at most limit %[1]s in id order, after skipping offset
+func (r *%[2]s) List(ctx context.Context, limit, offset int) ([]*models.%[3]s, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+%[4]sColumns+" FROM %[5]s%[6]s ORDER BY %[5]s.id LIMIT %[7]s OFFSET %[8]s", limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %[1]s: %%w", err)
+	}
+	defer rows.Close()
+
+	items := []*models.%[3]s{}
+	for rows.Next() {
+		item, err := scan%[3]s(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %[1]s: %%w", err)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list %[1]s: %%w", err)
+	}
+	return items, nil
+}
+
+`, plural, r.impl, r.model, r.local, r.table, r.alive(" WHERE "), marks[0], marks[1])
+}
+
+func (r *repositorySource) update() string {
+	columns := r.writable("created_at")
+	var exec string
+	if len(columns) > 0 {
+		stamp := ""
+		if r.config.Timestamps {
+			stamp = "\titem.UpdatedAt = time.Now().UTC().Truncate(time.Second)\n"
+		}
+		marks := sqlPlaceholders(r.dialect, 1, len(columns)+1)
+		sets := make([]string, len(columns))
+		for i, c := range columns {
+			sets[i] = c.name + " = " + marks[i]
+		}
+		exec = fmt.Sprintf(`%[1]s	_, err := r.db.ExecContext(ctx,
+		"UPDATE %[2]s SET %[3]s WHERE %[2]s.id = %[4]s%[5]s",
+		%[6]s, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update %[7]s %%d: %%w", id, err)
+	}
+`, stamp, r.table, strings.Join(sets, ", "), marks[len(columns)], r.alive(" AND "), fieldArgs(columns), r.human)
+	}
+
+	return fmt.Sprintf(`// Update replaces the %s with id by item and returns it as stored, or %s
+func (r *%s) Update(ctx context.Context, id uint, item *models.%s) (*models.%s, error) {
+%s	return r.GetByID(ctx, id)
+}
+
+`, r.human, r.notFound, r.impl, r.model, r.model, exec)
+}
+
+func (r *repositorySource) delete() string {
+	marks := sqlPlaceholders(r.dialect, 1, 2)
+	exec := fmt.Sprintf(`r.db.ExecContext(ctx, "DELETE FROM %s WHERE id = %s", id)`, r.table, marks[0])
+	if r.config.SoftDelete {
+		exec = fmt.Sprintf(`r.db.ExecContext(ctx,
+		"UPDATE %[1]s SET deleted_at = %[2]s WHERE %[1]s.id = %[3]s%[4]s", time.Now().UTC().Truncate(time.Second), id)`,
+			r.table, marks[0], marks[1], r.alive(" AND "))
+	}
+
+	return fmt.Sprintf(`// Delete deletes the %[1]s with id, or returns %[2]s
+func (r *%[3]s) Delete(ctx context.Context, id uint) error {
+	result, err := %[4]s
+	if err != nil {
+		return fmt.Errorf("failed to delete %[1]s %%d: %%w", id, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete %[1]s %%d: %%w", id, err)
+	}
+	if deleted == 0 {
+		return %[2]s
+	}
+	return nil
+}
+
+`, r.human, r.notFound, r.impl, exec)
+}
+
+// loader returns the helper preloading the models of a relationship, with
+// the scan function and columns of their repository
+func (r *repositorySource) loader(rel Relationship) string {
+	related := strings.ReplaceAll(toSnakeCase(rel.Model), "_", " ")
+	if rel.Type == "BelongsTo" {
+		return fmt.Sprintf(`// Load%[1]s returns the %[2]s item belongs to
+func (r *%[3]s) Load%[1]s(ctx context.Context, item *models.%[4]s) (*models.%[1]s, error) {
+	return New%[1]sRepository(r.db).GetByID(ctx, item.%[1]sID)
+}
+
+`, rel.Model, related, r.impl, r.model)
+	}
+
+	name := rel.Name
+	if name == "" {
+		name = funcs.Pluralize(rel.Model)
+	}
+	plural := strings.ReplaceAll(toSnakeCase(name), "_", " ")
+	table := modelTable(rel.Model, rel.Table)
+	alive := ""
+	if rel.SoftDelete {
+		alive = " AND " + table + ".deleted_at IS NULL"
+	}
+	mark := `"?"`
+	if r.dialect == "postgres" {
+		mark = `"$"+strconv.Itoa(len(ids))`
+	}
+
+	// Has many: the related rows refer to their owner. Many to many: the rows
+	// of the join table relate both.
+	var query, scan string
+	if rel.Type == "HasMany" {
+		owner := toSnakeCase(r.model) + "_id"
+		query = fmt.Sprintf(`"SELECT "+%[1]sColumns+" FROM %[2]s WHERE %[2]s.%[3]s IN ("+strings.Join(marks, ", ")+")%[4]s ORDER BY %[2]s.id"`,
+			toCamelCase(rel.Model), table, owner, alive)
+		scan = fmt.Sprintf(`		related, err := scan%s(rows)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %%w", err)
+		}
+		owner := byID[related.%sID]
+`, rel.Model, plural, r.model)
+	} else {
+		join := rel.JoinTable
+		if join == "" {
+			join = toSnakeCase(r.model) + "_" + table
+		}
+		owner, theirs := toSnakeCase(r.model)+"_id", toSnakeCase(rel.Model)+"_id"
+		query = fmt.Sprintf(`"SELECT %[1]s.%[2]s, "+%[3]sColumns+" FROM %[4]s JOIN %[1]s ON %[1]s.%[5]s = %[4]s.id WHERE %[1]s.%[2]s IN ("+strings.Join(marks, ", ")+")%[6]s ORDER BY %[4]s.id"`,
+			join, owner, toCamelCase(rel.Model), table, theirs, alive)
+		scan = fmt.Sprintf(`		var ownerID uint
+		related, err := scan%s(rows, &ownerID)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %%w", err)
+		}
+		owner := byID[ownerID]
+`, rel.Model, plural)
+	}
+
+	return fmt.Sprintf(`// Load%[1]s loads the %[2]s of items into their %[1]s, in one query
+func (r *%[3]s) Load%[1]s(ctx context.Context, items ...*models.%[4]s) error {
+	if len(items) == 0 {
+		return nil
+	}
+	byID := make(map[uint]*models.%[4]s, len(items))
+	ids := make([]any, 0, len(items))
+	marks := make([]string, 0, len(items))
+	for _, item := range items {
+		item.%[1]s = nil
+		byID[item.ID] = item
+		ids = append(ids, item.ID)
+		marks = append(marks, %[5]s)
+	}
+
+	rows, err := r.db.QueryContext(ctx,
+		%[6]s, ids...)
+	if err != nil {
+		return fmt.Errorf("failed to load %[2]s: %%w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+%[7]s		owner.%[1]s = append(owner.%[1]s, *related)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to load %[2]s: %%w", err)
+	}
+	return nil
+}
+
+`, name, plural, r.impl, r.model, mark, query, scan)
+}
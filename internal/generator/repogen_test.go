@@ -0,0 +1,62 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestModelGenerator_RepositoryDialects(t *testing.T) {
+	config := ModelConfig{
+		Name:       "OrderItem",
+		Module:     "example.com/shop",
+		Fields:     []Field{{Name: "Sku", Type: "string", Tags: `json:"sku" db:"sku"`}, {Name: "Note", Type: "string", Tags: `db:"-"`}},
+		Timestamps: true,
+	}
+	tests := []struct {
+		dialect string
+		want    []string
+		notWant string
+	}{
+		{"", []string{
+			`"INSERT INTO order_items (sku, created_at, updated_at) VALUES ($1, $2, $3) RETURNING id"`,
+			`" FROM order_items WHERE order_items.id = $1", id`,
+			`LIMIT $1 OFFSET $2", limit, offset`,
+			`"UPDATE order_items SET sku = $1, updated_at = $2 WHERE order_items.id = $3"`,
+		}, "LastInsertId"},
+		{"mysql", []string{
+			`"INSERT INTO order_items (sku, created_at, updated_at) VALUES (?, ?, ?)"`,
+			"id, err := result.LastInsertId()",
+			`LIMIT ? OFFSET ?", limit, offset`,
+		}, "RETURNING"},
+		{"sqlite3", []string{`VALUES (?, ?, ?)"`, "result.LastInsertId()", `"DELETE FROM order_items WHERE id = ?", id`}, "$1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			config.Dialect = tt.dialect
+			content := NewModelGenerator().generateRepositoryContent(config)
+			if _, err := parser.ParseFile(token.NewFileSet(), "repository.go", content, 0); err != nil {
+				t.Fatalf("repository does not parse: %v\n%s", err, content)
+			}
+			for _, part := range append(tt.want, `var ErrOrderItemNotFound = errors.New("order item not found")`) {
+				if !strings.Contains(content, part) {
+					t.Errorf("repository should contain %s, got:\n%s", part, content)
+				}
+			}
+			if strings.Contains(content, tt.notWant) || strings.Contains(content, "note") {
+				t.Errorf("repository should not contain %s or the column of a db:\"-\" field, got:\n%s", tt.notWant, content)
+			}
+		})
+	}
+}
+
+func TestModelGenerator_RepositoryWithoutColumns(t *testing.T) {
+	gen := NewModelGenerator()
+	if content := gen.generateRepositoryContent(ModelConfig{Name: "Ticket"}); !strings.Contains(content, `"INSERT INTO tickets DEFAULT VALUES RETURNING id"`) {
+		t.Errorf("a model of only an id should be inserted with its default values, got:\n%s", content)
+	}
+	if content := gen.generateRepositoryContent(ModelConfig{Name: "Ticket", Dialect: "mysql"}); !strings.Contains(content, `"INSERT INTO tickets () VALUES ()"`) {
+		t.Errorf("MySQL has no DEFAULT VALUES, got:\n%s", content)
+	}
+}
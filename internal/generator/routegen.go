@@ -6,8 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
+	"github.com/toutaio/toutago-ritual-grove/internal/funcs"
 )
 
 // RouteGenerator generates route definitions
@@ -38,12 +37,19 @@ type RouteGroup struct {
 type RouteConfig struct {
 	Package       string
 	Resource      string
+	Model         string // Model the RESTful handler methods are named after; default: the singular of Resource
 	Handler       string
 	RESTful       bool
 	Routes        []Route
 	Groups        []RouteGroup
 	EnableCORS    bool
 	Documentation bool
+	// Register names a function adding the routes to the router it is given,
+	// generated instead of SetupRoutes. Its handler parameter, named Handler,
+	// is of HandlerType.
+	Register    string
+	HandlerType string
+	Imports     []string // Packages the handler comes from
 }
 
 // GenerateRoutes generates route file
@@ -56,7 +62,7 @@ func (g *RouteGenerator) GenerateRoutes(targetPath string, config RouteConfig) e
 
 	// Generate RESTful routes if configured
 	if config.RESTful && config.Resource != "" {
-		routes = g.generateRESTfulRoutes(config.Resource, config.Handler)
+		routes = g.generateRESTfulRoutes(config.Resource, config.Handler, config.Model)
 	} else {
 		routes = config.Routes
 	}
@@ -72,43 +78,50 @@ func (g *RouteGenerator) GenerateRoutes(targetPath string, config RouteConfig) e
 	return os.WriteFile(routePath, []byte(content), 0600)
 }
 
-func (g *RouteGenerator) generateRESTfulRoutes(resource, handler string) []Route {
-	caser := cases.Title(language.English)
+func (g *RouteGenerator) generateRESTfulRoutes(resource, handler, model string) []Route {
+	singular := funcs.Singularize(resource)
+	if model == "" {
+		model = funcs.Capitalize(singular)
+	}
 	return []Route{
 		{
 			Method:      "GET",
 			Path:        "/" + resource,
-			Handler:     handler + ".List" + caser.String(resource),
+			Handler:     handler + ".List" + funcs.Pluralize(model),
 			Description: "List all " + resource,
 		},
 		{
 			Method:      "POST",
 			Path:        "/" + resource,
-			Handler:     handler + ".Create" + caser.String(singular(resource)),
-			Description: "Create a new " + singular(resource),
+			Handler:     handler + ".Create" + model,
+			Description: "Create a new " + singular,
 		},
 		{
 			Method:      "GET",
 			Path:        "/" + resource + "/{id}",
-			Handler:     handler + ".Get" + caser.String(singular(resource)),
-			Description: "Get a " + singular(resource) + " by ID",
+			Handler:     handler + ".Get" + model,
+			Description: "Get a " + singular + " by ID",
 		},
 		{
 			Method:      "PUT",
 			Path:        "/" + resource + "/{id}",
-			Handler:     handler + ".Update" + caser.String(singular(resource)),
-			Description: "Update a " + singular(resource),
+			Handler:     handler + ".Update" + model,
+			Description: "Update a " + singular,
 		},
 		{
 			Method:      "DELETE",
 			Path:        "/" + resource + "/{id}",
-			Handler:     handler + ".Delete" + caser.String(singular(resource)),
-			Description: "Delete a " + singular(resource),
+			Handler:     handler + ".Delete" + model,
+			Description: "Delete a " + singular,
 		},
 	}
 }
 
 func (g *RouteGenerator) generateRouteContent(config RouteConfig, routes []Route) string {
+	if config.Register != "" {
+		return g.generateRegisterContent(config, routes)
+	}
+
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf(`package %s
@@ -158,6 +171,35 @@ import (
 	return sb.String()
 }
 
+// generateRegisterContent generates a function adding the routes to a router
+func (g *RouteGenerator) generateRegisterContent(config RouteConfig, routes []Route) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("package %s\n\nimport (\n\t\"github.com/gorilla/mux\"\n", config.Package))
+	if len(config.Imports) > 0 {
+		sb.WriteString("\n")
+		for _, imp := range config.Imports {
+			sb.WriteString(fmt.Sprintf("\t%q\n", imp))
+		}
+	}
+	sb.WriteString(")\n\n")
+
+	sb.WriteString(fmt.Sprintf("// %s adds the %s routes to router\n", config.Register, config.Resource))
+	sb.WriteString(fmt.Sprintf("func %s(router *mux.Router, %s %s) {\n", config.Register, config.Handler, config.HandlerType))
+	if len(config.Groups) > 0 {
+		for _, group := range config.Groups {
+			sb.WriteString(g.generateGroupRoutes(group, config.Documentation))
+		}
+	} else {
+		for _, route := range routes {
+			sb.WriteString(g.generateRoute(route, config.Documentation, "\t"))
+		}
+	}
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
 func (g *RouteGenerator) generateGroupRoutes(group RouteGroup, withDoc bool) string {
 	var sb strings.Builder
 
@@ -213,11 +255,3 @@ func sanitizePrefix(prefix string) string {
 	sanitized = strings.ReplaceAll(sanitized, "/", "_")
 	return sanitized
 }
-
-func singular(word string) string {
-	// Simple singularization - remove trailing 's'
-	if strings.HasSuffix(word, "s") {
-		return word[:len(word)-1]
-	}
-	return word
-}
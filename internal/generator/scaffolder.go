@@ -1,46 +1,88 @@
 package generator
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/toutaio/toutago-ritual-grove/internal/hooks"
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
 
 // ProjectScaffolder creates project structure and generates files
 type ProjectScaffolder struct {
 	generator *FileGenerator
+	builtins  TemplateEngine // Renders the scaffolder's own templates, whatever the ritual engine
+	onError   ErrorPolicy
+	confirm   func() bool
 }
 
 // NewProjectScaffolder creates a new project scaffolder
 func NewProjectScaffolder() *ProjectScaffolder {
 	return &ProjectScaffolder{
 		generator: NewFileGenerator("go-template"),
+		builtins:  NewGoTemplateEngine(),
+		onError:   OnErrorRollback,
 	}
 }
 
-// CreateStructure creates the standard project directory structure
-func (s *ProjectScaffolder) CreateStructure(projectPath string) error {
-	dirs := []string{
-		"cmd/server",
-		"internal/handlers",
-		"internal/models",
-		"internal/repositories",
-		"internal/middleware",
-		"internal/services",
-		"pkg",
-		"config",
-		"docs",
-		"test",
-		"migrations",
+// SetErrorPolicy sets what GenerateFromRitualWithHooks does with a partly generated
+// project. confirm is asked whether to roll back under OnErrorAsk.
+func (s *ProjectScaffolder) SetErrorPolicy(policy ErrorPolicy, confirm func() bool) {
+	s.onError = policy
+	s.confirm = confirm
+}
+
+// Journal returns the journal of every change the scaffolder has made on disk
+func (s *ProjectScaffolder) Journal() *Journal {
+	return s.generator.Journal()
+}
+
+// SetOutput sets the filesystem the project is written to (default: disk)
+func (s *ProjectScaffolder) SetOutput(output OutputFS) {
+	s.generator.SetOutput(output)
+}
+
+// BuiltinSourcePrefix marks generated files that come from the scaffolder, not the ritual
+const BuiltinSourcePrefix = "builtin:"
+
+// GeneratedFiles returns every file the scaffolder has written
+func (s *ProjectScaffolder) GeneratedFiles() []GeneratedFile {
+	return s.generator.GeneratedFiles()
+}
+
+// writeBuiltin writes a file produced by one of the scaffolder's built-in templates
+func (s *ProjectScaffolder) writeBuiltin(path, name, content string) error {
+	if err := s.generator.output.WriteFile(path, []byte(content), 0600); err != nil {
+		return err
 	}
+	s.generator.recordGenerated(path, BuiltinSourcePrefix+name)
+	return nil
+}
+
+// projectDirs is the standard project directory structure
+var projectDirs = []string{
+	"cmd/server",
+	"internal/handlers",
+	"internal/models",
+	"internal/repositories",
+	"internal/middleware",
+	"internal/services",
+	"pkg",
+	"config",
+	"docs",
+	"test",
+	"migrations",
+}
 
-	for _, dir := range dirs {
+// CreateStructure creates the standard project directory structure
+func (s *ProjectScaffolder) CreateStructure(projectPath string) error {
+	for _, dir := range projectDirs {
 		dirPath := filepath.Join(projectPath, dir)
-		if err := os.MkdirAll(dirPath, 0750); err != nil {
+		if err := s.generator.output.MkdirAll(dirPath, 0750); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
@@ -48,9 +90,8 @@ func (s *ProjectScaffolder) CreateStructure(projectPath string) error {
 	return nil
 }
 
-// GenerateMainGo generates the main.go entry point
-func (s *ProjectScaffolder) GenerateMainGo(projectPath string, vars *Variables) error {
-	template := `package main
+// mainGoTemplate is the built-in entry point, serving a health check
+const mainGoTemplate = `package main
 
 import (
 	"fmt"
@@ -59,6 +100,7 @@ import (
 	"os"
 
 	"[[ .module_name ]]/internal/handlers"
+	// ritual:imports
 )
 
 func main() {
@@ -73,6 +115,7 @@ func main() {
 
 	// Register routes
 	mux.HandleFunc("/health", handlers.HealthCheck)
+	// ritual:routes
 
 	// Start server
 	addr := fmt.Sprintf(":%s", port)
@@ -84,24 +127,8 @@ func main() {
 }
 `
 
-	s.generator.SetVariables(vars)
-
-	content, err := s.generator.engine.Render(template, vars.All())
-	if err != nil {
-		return fmt.Errorf("failed to render main.go: %w", err)
-	}
-
-	mainPath := filepath.Join(projectPath, "cmd", "server", "main.go")
-	if err := os.WriteFile(mainPath, []byte(content), 0600); err != nil {
-		return fmt.Errorf("failed to write main.go: %w", err)
-	}
-
-	// Also generate a basic health check handler
-	return s.generateHealthHandler(projectPath)
-}
-
-func (s *ProjectScaffolder) generateHealthHandler(projectPath string) error {
-	template := `package handlers
+// healthHandler is the handler behind the health check of the built-in main.go
+const healthHandler = `package handlers
 
 import (
 	"encoding/json"
@@ -120,40 +147,75 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 }
 `
 
+// GenerateMainGo generates the main.go entry point
+func (s *ProjectScaffolder) GenerateMainGo(projectPath string, vars *Variables) error {
+	s.generator.SetVariables(vars)
+
+	content, err := s.builtins.Render(mainGoTemplate, vars.redacted())
+	if err != nil {
+		return fmt.Errorf("failed to render main.go: %w", err)
+	}
+
+	mainPath := filepath.Join(projectPath, "cmd", "server", "main.go")
+	if err := s.writeBuiltin(mainPath, "main.go", content); err != nil {
+		return fmt.Errorf("failed to write main.go: %w", err)
+	}
+
+	// Also generate a basic health check handler
 	handlerPath := filepath.Join(projectPath, "internal", "handlers", "health.go")
-	return os.WriteFile(handlerPath, []byte(template), 0600)
+	return s.writeBuiltin(handlerPath, "health.go", healthHandler)
 }
 
 // GenerateGoMod generates the go.mod file with dependencies
 func (s *ProjectScaffolder) GenerateGoMod(projectPath string, manifest *ritual.Manifest, vars *Variables) error {
-	moduleName := vars.GetString("module_name")
-	if moduleName == "" {
-		moduleName = "example.com/app"
-	}
+	goModPath := filepath.Join(projectPath, "go.mod")
+	return s.writeBuiltin(goModPath, "go.mod", goModContent(manifest, vars))
+}
 
+// goModContent is the built-in go.mod, requiring the ritual's packages
+func goModContent(manifest *ritual.Manifest, vars *Variables) string {
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("module %s\n\n", moduleName))
+	sb.WriteString(fmt.Sprintf("module %s\n\n", moduleName(vars)))
 	sb.WriteString("go 1.21\n\n")
 
-	if len(manifest.Dependencies.Packages) > 0 {
+	packages := slices.Clip(manifest.Dependencies.Packages)
+	// The handlers and routes of entities use gorilla/mux, the tests of their
+	// repositories go-sqlite3
+	for _, required := range []string{"github.com/gorilla/mux@v1.8.1", "github.com/mattn/go-sqlite3@v1.14.22"} {
+		path, _, _ := strings.Cut(required, "@")
+		if len(manifest.Entities) > 0 && !slices.ContainsFunc(packages, func(pkg string) bool {
+			return strings.HasPrefix(pkg, path)
+		}) {
+			packages = append(packages, required)
+		}
+	}
+
+	if len(packages) > 0 {
 		sb.WriteString("require (\n")
-		for _, pkg := range manifest.Dependencies.Packages {
+		for _, pkg := range packages {
 			// Add version if not specified (use latest for now)
-			if !strings.Contains(pkg, "@") {
+			if path, version, ok := strings.Cut(pkg, "@"); ok {
+				pkg = path + " " + version
+			} else {
 				pkg = pkg + " v1.0.0"
 			}
 			sb.WriteString(fmt.Sprintf("\t%s\n", pkg))
 		}
 		sb.WriteString(")\n")
 	}
+	return sb.String()
+}
 
-	goModPath := filepath.Join(projectPath, "go.mod")
-	return os.WriteFile(goModPath, []byte(sb.String()), 0600)
+// moduleName returns the module path of the project
+func moduleName(vars *Variables) string {
+	if name := vars.GetString("module_name"); name != "" {
+		return name
+	}
+	return "example.com/app"
 }
 
-// GenerateConfig generates configuration files (.env.example, config files)
-func (s *ProjectScaffolder) GenerateConfig(projectPath string, vars *Variables) error {
-	template := `# Application Configuration
+// envExampleTemplate is the built-in .env.example
+const envExampleTemplate = `# Application Configuration
 APP_NAME=[[ .app_name ]]
 PORT=[[ .port ]]
 ENV=development
@@ -169,19 +231,27 @@ ENV=development
 LOG_LEVEL=info
 `
 
+// GenerateConfig generates configuration files (.env.example, config files)
+func (s *ProjectScaffolder) GenerateConfig(projectPath string, vars *Variables) error {
 	s.generator.SetVariables(vars)
 
-	content, err := s.generator.engine.Render(template, vars.All())
+	content, err := s.builtins.Render(envExampleTemplate, vars.redacted())
 	if err != nil {
 		return fmt.Errorf("failed to render .env.example: %w", err)
 	}
 
 	envPath := filepath.Join(projectPath, ".env.example")
-	return os.WriteFile(envPath, []byte(content), 0600)
+	return s.writeBuiltin(envPath, ".env.example", content)
 }
 
 // GenerateREADME generates a README.md file
 func (s *ProjectScaffolder) GenerateREADME(projectPath string, manifest *ritual.Manifest, vars *Variables) error {
+	readmePath := filepath.Join(projectPath, "README.md")
+	return s.writeBuiltin(readmePath, "README.md", readmeContent(manifest, vars))
+}
+
+// readmeContent is the built-in README.md, describing the standard layout
+func readmeContent(manifest *ritual.Manifest, vars *Variables) string {
 	appName := vars.GetString("app_name")
 	if appName == "" {
 		appName = "Application"
@@ -235,14 +305,11 @@ func (s *ProjectScaffolder) GenerateREADME(projectPath string, manifest *ritual.
 	content += "- `migrations/` - Database migrations\n\n"
 	content += "## License\n\n"
 	content += "MIT\n"
-
-	readmePath := filepath.Join(projectPath, "README.md")
-	return os.WriteFile(readmePath, []byte(content), 0600)
+	return content
 }
 
-// GenerateGitignore generates a .gitignore file
-func (s *ProjectScaffolder) GenerateGitignore(projectPath string) error {
-	content := `# Binaries
+// gitignoreContent is the built-in .gitignore
+const gitignoreContent = `# Binaries
 bin/
 *.exe
 *.dll
@@ -281,213 +348,108 @@ build/
 logs/
 `
 
+// GenerateGitignore generates a .gitignore file
+func (s *ProjectScaffolder) GenerateGitignore(projectPath string) error {
 	gitignorePath := filepath.Join(projectPath, ".gitignore")
-	return os.WriteFile(gitignorePath, []byte(content), 0600)
+	return s.writeBuiltin(gitignorePath, ".gitignore", gitignoreContent)
 }
 
 // ApplyTemplateFiles applies template files from the ritual
 func (s *ProjectScaffolder) ApplyTemplateFiles(projectPath, ritualPath string, manifest *ritual.Manifest, vars *Variables) error {
-	s.generator.SetVariables(vars)
-	
-	// Set rituals base path for _shared template support
-	// ritualPath is /path/to/rituals/ritual-name
-	// We need /path/to/rituals
-	ritualsBasePath := filepath.Dir(ritualPath)
-	s.generator.SetRitualsBasePath(ritualsBasePath)
-
-	templatesDir := filepath.Join(ritualPath, "templates")
-
-	// Process template files
-	for _, fileMapping := range manifest.Files.Templates {
-		// Evaluate condition if present
-		if fileMapping.Condition != "" {
-			shouldGenerate, err := evaluateCondition(fileMapping.Condition, vars.All())
-			if err != nil {
-				return fmt.Errorf("failed to evaluate condition for %s: %w", fileMapping.Source, err)
-			}
-			if !shouldGenerate {
-				continue // Skip this file
-			}
-		}
-		
-		// Check for _shared: prefix
-		var srcPath string
-		if strings.HasPrefix(fileMapping.Source, "_shared:") {
-			// Use _shared template
-			sharedPath := strings.TrimPrefix(fileMapping.Source, "_shared:")
-			srcPath = filepath.Join(ritualsBasePath, "_shared", sharedPath)
-		} else {
-			// Regular ritual template
-			srcPath = filepath.Join(templatesDir, fileMapping.Source)
-		}
-
-		// Render destination path (may contain template variables)
-		destPathRendered, err := s.generator.engine.Render(fileMapping.Destination, vars.All())
-		if err != nil {
-			return fmt.Errorf("failed to render destination path %s: %w", fileMapping.Destination, err)
-		}
-		destPath := filepath.Join(projectPath, destPathRendered)
+	// _shared/ templates sit next to the ritual directory
+	return s.applyTemplateFiles(projectPath, ritual.DirSource(ritualPath), manifest, vars)
+}
 
-		// Check if source exists
-		info, err := os.Stat(srcPath)
-		if os.IsNotExist(err) {
-			if fileMapping.Optional {
-				continue
-			}
-			return fmt.Errorf("template file not found: %s", srcPath)
-		}
-		if err != nil {
-			return fmt.Errorf("failed to stat %s: %w", srcPath, err)
-		}
+// applyTemplateFiles applies the template and static files of a ritual source
+func (s *ProjectScaffolder) applyTemplateFiles(projectPath string, src *ritual.Source, manifest *ritual.Manifest, vars *Variables) error {
+	run := &Run{Generator: s.generator, Manifest: manifest, Source: src, OutputPath: projectPath, Variables: vars}
+	return NewPipeline(FileStages()...).Run(context.Background(), run)
+}
 
-		// Handle directories vs files
-		if info.IsDir() {
-			// Generate all files in directory
-			if err := s.generator.generateDirectory(srcPath, destPath, true); err != nil {
-				return fmt.Errorf("failed to generate directory %s: %w", destPathRendered, err)
-			}
-		} else {
-			// Generate single file (templates are always rendered)
-			if err := s.generator.GenerateFile(srcPath, destPath, true); err != nil {
-				return fmt.Errorf("failed to generate %s: %w", destPathRendered, err)
-			}
-		}
-	}
+// GenerateFromRitual generates a complete project from a ritual directory
+func (s *ProjectScaffolder) GenerateFromRitual(projectPath, ritualPath string, manifest *ritual.Manifest, vars *Variables) error {
+	return s.GenerateFromSource(projectPath, ritual.DirSource(ritualPath), manifest, vars)
+}
 
-	// Process static files
-	staticDir := filepath.Join(ritualPath, "static")
-	for _, fileMapping := range manifest.Files.Static {
-		// Evaluate condition if present
-		if fileMapping.Condition != "" {
-			shouldGenerate, err := evaluateCondition(fileMapping.Condition, vars.All())
-			if err != nil {
-				return fmt.Errorf("failed to evaluate condition for %s: %w", fileMapping.Source, err)
-			}
-			if !shouldGenerate {
-				continue // Skip this file
-			}
-		}
-		
-		// Check for _shared: prefix
-		var srcPath string
-		if strings.HasPrefix(fileMapping.Source, "_shared:") {
-			// Use _shared static file
-			sharedPath := strings.TrimPrefix(fileMapping.Source, "_shared:")
-			srcPath = filepath.Join(ritualsBasePath, "_shared", sharedPath)
-		} else {
-			// Regular ritual static file
-			srcPath = filepath.Join(staticDir, fileMapping.Source)
-		}
+// GenerateFromSource generates a complete project from a ritual source
+func (s *ProjectScaffolder) GenerateFromSource(projectPath string, src *ritual.Source, manifest *ritual.Manifest, vars *Variables) error {
+	run := &Run{Manifest: manifest, Source: src, OutputPath: projectPath, Variables: vars}
+	return s.Generate(context.Background(), run)
+}
 
-		// Render destination path (may contain template variables)
-		destPathRendered, err := s.generator.engine.Render(fileMapping.Destination, vars.All())
-		if err != nil {
-			return fmt.Errorf("failed to render destination path %s: %w", fileMapping.Destination, err)
-		}
-		destPath := filepath.Join(projectPath, destPathRendered)
+// Generate passes run through the default pipeline with the scaffolder's generator
+func (s *ProjectScaffolder) Generate(ctx context.Context, run *Run) error {
+	run.Generator = s.generator
+	return DefaultPipeline().Run(ctx, run)
+}
 
-		// Check if source exists
-		info, err := os.Stat(srcPath)
-		if os.IsNotExist(err) {
-			if fileMapping.Optional {
-				continue
-			}
-			return fmt.Errorf("static file not found: %s", srcPath)
-		}
-		if err != nil {
-			return fmt.Errorf("failed to stat %s: %w", srcPath, err)
-		}
+// RecordState records every file the scaffolder has written into projectPath in the project state
+func (s *ProjectScaffolder) RecordState(state *storage.State, projectPath string) error {
+	return s.generator.RecordState(state, projectPath)
+}
 
-		// Handle directories vs files
-		if info.IsDir() {
-			// Copy all files in directory
-			if err := s.generator.generateDirectory(srcPath, destPath, false); err != nil {
-				return fmt.Errorf("failed to copy directory %s: %w", destPathRendered, err)
-			}
-		} else {
-			// Copy single static file (no template rendering)
-			if err := s.generator.GenerateFile(srcPath, destPath, false); err != nil {
-				return fmt.Errorf("failed to copy %s: %w", destPathRendered, err)
-			}
-		}
-	}
+// ScaffoldStage plans the standard project directories and the built-in files
+// a ritual does not write itself: go.mod, a main.go serving a health check,
+// README.md, .gitignore and .env.example
+type ScaffoldStage struct{}
 
-	return nil
-}
+// Name implements Stage
+func (ScaffoldStage) Name() string { return StageScaffold }
 
-// GenerateFromRitual generates a complete project from a ritual
-func (s *ProjectScaffolder) GenerateFromRitual(projectPath, ritualPath string, manifest *ritual.Manifest, vars *Variables) error {
-	// Create project structure
-	if err := s.CreateStructure(projectPath); err != nil {
-		return err
-	}
+// Run implements Stage
+func (ScaffoldStage) Run(_ context.Context, run *Run) error {
+	vars := run.Generator.variables
+	engine := NewGoTemplateEngine()
 
-	// Generate go.mod
-	if err := s.GenerateGoMod(projectPath, manifest, vars); err != nil {
-		return err
-	}
+	type builtin struct{ name, path, content string }
+	builtins := []builtin{{"go.mod", "go.mod", goModContent(run.Manifest, vars)}}
 
-	// Generate main.go (if not provided by ritual)
-	hasMainGo := false
-	for _, tmpl := range manifest.Files.Templates {
+	// A ritual with its own entry point anywhere gets no second one
+	providesMain := false
+	for _, tmpl := range run.Manifest.Files.Templates {
 		if strings.Contains(tmpl.Destination, "main.go") {
-			hasMainGo = true
+			providesMain = true
 			break
 		}
 	}
-	if !hasMainGo {
-		if err := s.GenerateMainGo(projectPath, vars); err != nil {
-			return err
-		}
-	}
-
-	// Generate README (if not provided by ritual)
-	hasREADME := false
-	for _, tmpl := range manifest.Files.Templates {
-		if strings.ToUpper(tmpl.Destination) == "README.MD" {
-			hasREADME = true
-			break
-		}
-	}
-	if !hasREADME {
-		if err := s.GenerateREADME(projectPath, manifest, vars); err != nil {
-			return err
+	if !providesMain {
+		mainGo, err := engine.Render(mainGoTemplate, vars.redacted())
+		if err != nil {
+			return fmt.Errorf("failed to render main.go: %w", err)
 		}
+		builtins = append(builtins,
+			builtin{"main.go", "cmd/server/main.go", mainGo},
+			builtin{"health.go", "internal/handlers/health.go", healthHandler})
 	}
 
-	// Generate .gitignore (if not provided by ritual)
-	hasGitignore := false
-	for _, tmpl := range manifest.Files.Templates {
-		if tmpl.Destination == ".gitignore" {
-			hasGitignore = true
-			break
-		}
-	}
-	if !hasGitignore {
-		if err := s.GenerateGitignore(projectPath); err != nil {
-			return err
-		}
+	envExample, err := engine.Render(envExampleTemplate, vars.redacted())
+	if err != nil {
+		return fmt.Errorf("failed to render .env.example: %w", err)
 	}
+	builtins = append(builtins,
+		builtin{"README.md", "README.md", readmeContent(run.Manifest, vars)},
+		builtin{".gitignore", ".gitignore", gitignoreContent},
+		builtin{".env.example", ".env.example", envExample})
 
-	// Generate .env.example (if not provided by ritual)
-	hasEnvExample := false
-	for _, tmpl := range manifest.Files.Templates {
-		if tmpl.Destination == ".env.example" {
-			hasEnvExample = true
-			break
-		}
+	for _, dir := range projectDirs {
+		run.plan.dirs = append(run.plan.dirs, filepath.Join(run.OutputPath, filepath.FromSlash(dir)))
 	}
-	if !hasEnvExample {
-		if err := s.GenerateConfig(projectPath, vars); err != nil {
-			return err
-		}
+	for _, b := range builtins {
+		source := BuiltinSourcePrefix + b.name
+		run.plan.files = append(run.plan.files, &plannedFile{
+			file:       sourceFile{name: b.name, display: source},
+			dest:       filepath.Join(run.OutputPath, filepath.FromSlash(b.path)),
+			source:     source,
+			isTemplate: true,
+			opts:       defaultFileOptions(),
+			body:       b.content,
+			bodyLine:   1,
+			origin:     "built-in " + b.name,
+			content:    []byte(b.content),
+			rendered:   true,
+			fallback:   true,
+		})
 	}
-
-	// Apply ritual template files
-	if err := s.ApplyTemplateFiles(projectPath, ritualPath, manifest, vars); err != nil {
-		return err
-	}
-
 	return nil
 }
 
@@ -501,28 +463,45 @@ func (s *ProjectScaffolder) ExecutePostGenerateHooks(projectPath string, hookCom
 	return hookExecutor.ExecutePostInstall(hookCommands)
 }
 
-// GenerateFromRitualWithHooks generates a project and executes hooks
+// GenerateFromRitualWithHooks generates a project and executes hooks.
+// If any step fails, the partly generated project is handled by the error policy;
+// by default every change, including those made by hooks, is rolled back.
 func (s *ProjectScaffolder) GenerateFromRitualWithHooks(projectPath, ritualPath string, manifest *ritual.Manifest, vars *Variables) error {
+	hasHooks := len(manifest.Hooks.PreInstall) > 0 || len(manifest.Hooks.PostInstall) > 0
+	if hasHooks {
+		// Hooks write outside the journal, so record the project directory as a whole
+		if err := s.Journal().Track(projectPath); err != nil {
+			return fmt.Errorf("failed to record project directory: %w", err)
+		}
+	}
+
 	// Execute pre-install hooks
 	if len(manifest.Hooks.PreInstall) > 0 {
 		hookExecutor := hooks.NewHookExecutor(projectPath)
+		hookExecutor.SetVariables(vars.All())
 		if err := hookExecutor.ExecutePreInstall(manifest.Hooks.PreInstall); err != nil {
-			return fmt.Errorf("pre-install hooks failed: %w", err)
+			return s.recover(fmt.Errorf("pre-install hooks failed: %w", err))
 		}
 	}
 
 	// Generate project
 	if err := s.GenerateFromRitual(projectPath, ritualPath, manifest, vars); err != nil {
-		return err
+		return s.recover(err)
 	}
 
 	// Execute post-install hooks
 	if len(manifest.Hooks.PostInstall) > 0 {
 		hookExecutor := hooks.NewHookExecutor(projectPath)
+		hookExecutor.SetVariables(vars.All())
 		if err := hookExecutor.ExecutePostInstall(manifest.Hooks.PostInstall); err != nil {
-			return fmt.Errorf("post-install hooks failed: %w", err)
+			return s.recover(fmt.Errorf("post-install hooks failed: %w", err))
 		}
 	}
 
 	return nil
 }
+
+// recover applies the error policy after a failed generation step
+func (s *ProjectScaffolder) recover(cause error) error {
+	return Recovery{Journal: s.Journal(), Policy: s.onError, Confirm: s.confirm}.Recover(cause)
+}
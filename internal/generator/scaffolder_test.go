@@ -496,3 +496,78 @@ files:
 		t.Error("Post-install hook should have been executed")
 	}
 }
+
+func TestProjectScaffolder_GenerateWithHooks_FailureRollsBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectPath := filepath.Join(tmpDir, "test-project")
+	ritualPath := filepath.Join(tmpDir, "ritual")
+
+	if err := os.MkdirAll(filepath.Join(ritualPath, "templates"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ritualPath, "templates", "test.txt.tmpl"), []byte("Test content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &ritual.Manifest{
+		Ritual: ritual.RitualMeta{Name: "hooks-ritual", Version: "1.0.0", TemplateEngine: "go-template"},
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{{Source: "test.txt.tmpl", Destination: "test.txt"}},
+		},
+		Hooks: ritual.ManifestHooks{
+			PostInstall: []string{"echo 'hook output' > hook.txt", "exit 3"},
+		},
+	}
+
+	t.Run("rollback removes the half-written project", func(t *testing.T) {
+		scaffolder := NewProjectScaffolder()
+		err := scaffolder.GenerateFromRitualWithHooks(projectPath, ritualPath, manifest, NewVariables())
+		if err == nil {
+			t.Fatal("expected post-install hook failure")
+		}
+		if _, err := os.Stat(projectPath); !os.IsNotExist(err) {
+			t.Errorf("project directory should have been rolled back, stat error = %v", err)
+		}
+	})
+
+	t.Run("rollback restores an existing project", func(t *testing.T) {
+		if err := os.MkdirAll(projectPath, 0750); err != nil {
+			t.Fatal(err)
+		}
+		existing := filepath.Join(projectPath, "test.txt")
+		if err := os.WriteFile(existing, []byte("keep me"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		scaffolder := NewProjectScaffolder()
+		if err := scaffolder.GenerateFromRitualWithHooks(projectPath, ritualPath, manifest, NewVariables()); err == nil {
+			t.Fatal("expected post-install hook failure")
+		}
+
+		entries, err := os.ReadDir(projectPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Name() != "test.txt" {
+			t.Errorf("project should only contain test.txt after rollback, got %v", entries)
+		}
+		content, _ := os.ReadFile(existing)
+		if string(content) != "keep me" {
+			t.Errorf("test.txt = %q, want original content", content)
+		}
+	})
+
+	t.Run("abort leaves the partial project", func(t *testing.T) {
+		partialPath := filepath.Join(tmpDir, "partial")
+		scaffolder := NewProjectScaffolder()
+		scaffolder.SetErrorPolicy(OnErrorAbort, nil)
+		if err := scaffolder.GenerateFromRitualWithHooks(partialPath, ritualPath, manifest, NewVariables()); err == nil {
+			t.Fatal("expected post-install hook failure")
+		}
+		for _, name := range []string{"test.txt", "hook.txt", "go.mod"} {
+			if _, err := os.Stat(filepath.Join(partialPath, name)); err != nil {
+				t.Errorf("%s should be left behind: %v", name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// defaultSecretLength is the characters or random bytes of a generated secret
+const defaultSecretLength = 32
+
+// alnum are the characters of a generated alphanumeric secret
+const alnum = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// GenerateSecrets generates the secrets the manifest asks for: a value for
+// every generating question left empty, and every computed variable not
+// already given. Each is stored in vars as a secret, as are values given for
+// generating questions; the public key of an ed25519 pair is not secret.
+func GenerateSecrets(manifest *ritual.Manifest, vars *Variables) error {
+	for _, q := range manifest.Questions {
+		if q.Generate == nil {
+			continue
+		}
+		if err := generateSecret(vars, q.Name, q.Generate); err != nil {
+			return fmt.Errorf("failed to generate %s: %w", q.Name, err)
+		}
+	}
+	for _, v := range manifest.Variables {
+		if v.Generate == nil {
+			continue
+		}
+		if err := generateSecret(vars, v.Name, v.Generate); err != nil {
+			return fmt.Errorf("failed to generate %s: %w", v.Name, err)
+		}
+	}
+	return nil
+}
+
+// generateSecret sets name to a new secret, keeping a value it already has
+func generateSecret(vars *Variables, name string, gen *ritual.Generate) error {
+	if value, ok := vars.Get(name); ok && value != nil && value != "" {
+		vars.SetSecret(name, value)
+		return nil
+	}
+
+	length := gen.Length
+	if length == 0 {
+		length = defaultSecretLength
+	}
+
+	var secret string
+	switch gen.Type {
+	case ritual.GenerateAlnum:
+		var err error
+		if secret, err = randomAlnum(length); err != nil {
+			return err
+		}
+	case ritual.GenerateHex:
+		b, err := randomBytes(length)
+		if err != nil {
+			return err
+		}
+		secret = hex.EncodeToString(b)
+	case ritual.GenerateBase64:
+		b, err := randomBytes(length)
+		if err != nil {
+			return err
+		}
+		secret = base64.StdEncoding.EncodeToString(b)
+	case ritual.GenerateBcrypt:
+		from := vars.GetString(gen.From)
+		if from == "" {
+			return fmt.Errorf("%s has no value to hash", gen.From)
+		}
+		cost := gen.Cost
+		if cost == 0 {
+			cost = bcrypt.DefaultCost
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(from), cost)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", gen.From, err)
+		}
+		secret = string(hash)
+	case ritual.GenerateEd25519:
+		public, private, err := ed25519KeyPair()
+		if err != nil {
+			return err
+		}
+		secret = private
+		vars.Set(name+"_public", public)
+	default:
+		return fmt.Errorf("unknown secret type %q", gen.Type)
+	}
+
+	vars.SetSecret(name, secret)
+	return nil
+}
+
+// randomBytes returns n bytes from crypto/rand
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return b, nil
+}
+
+// randomAlnum returns n random letters and digits, drawn without modulo bias
+func randomAlnum(n int) (string, error) {
+	const limit = 256 - 256%len(alnum)
+	secret := make([]byte, 0, n)
+	for len(secret) < n {
+		b, err := randomBytes(n)
+		if err != nil {
+			return "", err
+		}
+		for _, c := range b {
+			if int(c) < limit && len(secret) < n {
+				secret = append(secret, alnum[int(c)%len(alnum)])
+			}
+		}
+	}
+	return string(secret), nil
+}
+
+// ed25519KeyPair returns a new key pair, PEM-encoded as PKIX and PKCS #8
+func ed25519KeyPair() (public, private string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate ed25519 key: %w", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode public key: %w", err)
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encode private key: %w", err)
+	}
+	public = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+	private = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}))
+	return public, private, nil
+}
@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestGenerateSecrets(t *testing.T) {
+	manifest := &ritual.Manifest{
+		Questions: []ritual.Question{
+			{Name: "db_password", Generate: &ritual.Generate{Type: ritual.GenerateAlnum, Length: 24}},
+			{Name: "admin_password", Generate: &ritual.Generate{Type: ritual.GenerateAlnum}},
+		},
+		Variables: []ritual.Variable{
+			{Name: "session_key", Generate: &ritual.Generate{Type: ritual.GenerateHex, Length: 16}},
+			{Name: "csrf_key", Generate: &ritual.Generate{Type: ritual.GenerateBase64}},
+			{Name: "admin_hash", Generate: &ritual.Generate{Type: ritual.GenerateBcrypt, From: "admin_password", Cost: bcrypt.MinCost}},
+			{Name: "signing_key", Generate: &ritual.Generate{Type: ritual.GenerateEd25519}},
+		},
+	}
+	vars := NewVariables()
+	vars.Set("admin_password", "hunter2")
+
+	if err := GenerateSecrets(manifest, vars); err != nil {
+		t.Fatalf("GenerateSecrets() error = %v", err)
+	}
+
+	password := vars.GetString("db_password")
+	if len(password) != 24 || strings.Trim(password, alnum) != "" {
+		t.Errorf("db_password = %q, want 24 letters and digits", password)
+	}
+	if got := vars.GetString("admin_password"); got != "hunter2" {
+		t.Errorf("an answered question should keep its value, got %q", got)
+	}
+	if key, err := hex.DecodeString(vars.GetString("session_key")); err != nil || len(key) != 16 {
+		t.Errorf("session_key should be 16 hex-encoded bytes, got %d, %v", len(key), err)
+	}
+	if key, err := base64.StdEncoding.DecodeString(vars.GetString("csrf_key")); err != nil || len(key) != defaultSecretLength {
+		t.Errorf("csrf_key should be %d base64-encoded bytes, got %d, %v", defaultSecretLength, len(key), err)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(vars.GetString("admin_hash")), []byte("hunter2")); err != nil {
+		t.Errorf("admin_hash should be a bcrypt hash of admin_password: %v", err)
+	}
+
+	private, _ := pem.Decode([]byte(vars.GetString("signing_key")))
+	if private == nil {
+		t.Fatal("signing_key should be PEM-encoded")
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(private.Bytes); err != nil {
+		t.Errorf("signing_key should be a PKCS #8 private key: %v", err)
+	}
+	public, _ := pem.Decode([]byte(vars.GetString("signing_key_public")))
+	if public == nil {
+		t.Fatal("signing_key_public should be PEM-encoded")
+	}
+	if _, err := x509.ParsePKIXPublicKey(public.Bytes); err != nil {
+		t.Errorf("signing_key_public should be a PKIX public key: %v", err)
+	}
+
+	for _, name := range []string{"db_password", "admin_password", "session_key", "csrf_key", "admin_hash", "signing_key"} {
+		if !vars.IsSecret(name) {
+			t.Errorf("%s should be a secret", name)
+		}
+	}
+	if vars.IsSecret("signing_key_public") {
+		t.Error("the public key should not be a secret")
+	}
+}
+
+func TestGenerateSecrets_DiffersEachTime(t *testing.T) {
+	manifest := &ritual.Manifest{
+		Variables: []ritual.Variable{{Name: "key", Generate: &ritual.Generate{Type: ritual.GenerateAlnum}}},
+	}
+	first, second := NewVariables(), NewVariables()
+	if err := GenerateSecrets(manifest, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateSecrets(manifest, second); err != nil {
+		t.Fatal(err)
+	}
+	if first.GetString("key") == second.GetString("key") {
+		t.Error("two generated secrets should differ")
+	}
+}
+
+func TestGenerateSecrets_BcryptWithoutValue(t *testing.T) {
+	manifest := &ritual.Manifest{
+		Variables: []ritual.Variable{{Name: "hash", Generate: &ritual.Generate{Type: ritual.GenerateBcrypt, From: "password"}}},
+	}
+	if err := GenerateSecrets(manifest, NewVariables()); err == nil {
+		t.Error("expected an error hashing a variable without a value")
+	}
+}
+
+func TestMaskSecrets_GeneratedSecrets(t *testing.T) {
+	vars := NewVariables()
+	vars.Set("app_name", "blog")
+	vars.SetSecret("signing_key", "abc123")
+	vars.AddComputed()
+
+	masked := vars.MaskSecrets(nil)
+	if masked["signing_key"] != "***" || masked["signing_key_upper"] != "***" {
+		t.Errorf("secrets and the values derived from them should be masked, got %v", masked)
+	}
+	if masked["app_name"] != "blog" {
+		t.Errorf("app_name should not be masked, got %v", masked["app_name"])
+	}
+	if got := vars.MaskSecretValues("key=abc123 app=blog"); got != "key=*** app=blog" {
+		t.Errorf("MaskSecretValues() = %q", got)
+	}
+}
+
+func TestDefaultPipeline_SecretsOnlyInProtectedFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/templates/env.tmpl":    {Data: []byte("DB_PASSWORD=[[ .db_password ]]\n")},
+		"app/templates/config.tmpl": {Data: []byte("password: [[ .db_password ]]\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &ritual.Manifest{
+		Ritual: ritual.RitualMeta{Name: "app", Version: "1.0.0"},
+		Questions: []ritual.Question{
+			{Name: "db_password", Type: ritual.QuestionTypePassword, Generate: &ritual.Generate{Type: ritual.GenerateAlnum}},
+		},
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{
+				{Source: "env.tmpl", Destination: ".env"},
+				{Source: "config.tmpl", Destination: "config.yaml"},
+			},
+			Protected: []string{".env"},
+		},
+	}
+	vars := NewVariables()
+	vars.Set("project_name", "demo")
+	vars.Set("module_path", "example.com/demo")
+
+	output := NewMemoryFS()
+	g := NewFileGenerator("go-template")
+	g.SetOutput(output)
+	run := &Run{
+		Generator:  g,
+		Manifest:   manifest,
+		Source:     src,
+		OutputPath: "/project",
+		Variables:  vars,
+		State:      &storage.State{RitualName: "app"},
+	}
+	if err := DefaultPipeline().Run(context.Background(), run); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	password := vars.GetString("db_password")
+	files := output.Files("/project")
+	if got := string(files[".env"]); got != "DB_PASSWORD="+password+"\n" || password == "" {
+		t.Errorf(".env = %q, want the generated password", got)
+	}
+	if got := string(files["config.yaml"]); got != "password: "+secretPlaceholder+"\n" {
+		t.Errorf("config.yaml = %q, want the placeholder", got)
+	}
+	if got := string(files[".env.example"]); strings.Contains(got, password) {
+		t.Errorf(".env.example should not contain the secret, got %q", got)
+	}
+}
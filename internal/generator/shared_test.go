@@ -3,6 +3,7 @@ package generator_test
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -48,7 +49,7 @@ EXPOSE [[.port]]`
 	}
 
 	// Setup generator
-	gen := generator.NewFileGenerator("fith")
+	gen := generator.NewFileGenerator("go-template")
 	vars := generator.NewVariables()
 	vars.Set("go_version", "1.21")
 	vars.Set("port", 8080)
@@ -105,7 +106,7 @@ services:
 			},
 		}
 
-		gen := generator.NewFileGenerator("fith")
+		gen := generator.NewFileGenerator("go-template")
 		vars := generator.NewVariables()
 		vars.Set("use_docker", true)
 		gen.SetVariables(vars)
@@ -133,7 +134,7 @@ services:
 			},
 		}
 
-		gen := generator.NewFileGenerator("fith")
+		gen := generator.NewFileGenerator("go-template")
 		vars := generator.NewVariables()
 		vars.Set("use_docker", false)
 		gen.SetVariables(vars)
@@ -182,7 +183,7 @@ func TestSharedTemplateDirectory(t *testing.T) {
 		},
 	}
 
-	gen := generator.NewFileGenerator("fith")
+	gen := generator.NewFileGenerator("go-template")
 	vars := generator.NewVariables()
 	vars.Set("go_version", "1.21")
 	gen.SetVariables(vars)
@@ -217,7 +218,7 @@ func TestSharedTemplateNotFound(t *testing.T) {
 		},
 	}
 
-	gen := generator.NewFileGenerator("fith")
+	gen := generator.NewFileGenerator("go-template")
 	gen.SetRitualsBasePath(ritualsDir)
 
 	err := gen.GenerateFiles(manifest, testRitualDir, outputDir)
@@ -246,10 +247,41 @@ func TestSharedTemplateOptional(t *testing.T) {
 		},
 	}
 
-	gen := generator.NewFileGenerator("fith")
+	gen := generator.NewFileGenerator("go-template")
 	gen.SetRitualsBasePath(ritualsDir)
 
 	// Should not error even though file doesn't exist
 	err := gen.GenerateFiles(manifest, testRitualDir, outputDir)
 	assert.NoError(t, err)
 }
+
+// TestSharedComposeDatabaseService tests that the shared compose file only
+// runs a database service for databases with a server
+func TestSharedComposeDatabaseService(t *testing.T) {
+	tests := []struct {
+		databaseType string
+		hasService   bool
+	}{
+		{"postgres", true},
+		{"mysql", true},
+		{"sqlite", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.databaseType, func(t *testing.T) {
+			data := map[string]interface{}{"app_name": "My Blog", "port": 8080}
+			if tt.databaseType != "" {
+				data["database_type"] = tt.databaseType
+			}
+
+			out, err := generator.NewGoTemplateEngine().RenderFile("../../rituals/_shared/docker/docker-compose.yml.tmpl", data)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.hasService, strings.Contains(out, "\n  db:\n"), "db service")
+			assert.Equal(t, tt.hasService, strings.Contains(out, "depends_on:"), "app dependency on db")
+			assert.Equal(t, tt.hasService, strings.Contains(out, "db-data:"), "db volume")
+			assert.Equal(t, tt.databaseType == "sqlite", strings.Contains(out, "DB_PATH"), "sqlite file path")
+		})
+	}
+}
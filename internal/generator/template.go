@@ -3,10 +3,16 @@ package generator
 import (
 	"bytes"
 	"fmt"
-	"path/filepath"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"text/template"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/fith"
+	"github.com/toutaio/toutago-ritual-grove/internal/funcs"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
@@ -15,6 +21,74 @@ import (
 type TemplateEngine interface {
 	Render(templateContent string, data map[string]interface{}) (string, error)
 	RenderFile(templatePath string, data map[string]interface{}) (string, error)
+	// RenderNamed renders template content read from elsewhere; name identifies it in errors
+	RenderNamed(name, templateContent string, data map[string]interface{}) (string, error)
+	// SetPartials replaces the partials templates can include, parsing each once
+	SetPartials(partials []Partial) error
+	// WithDelimiters returns an engine sharing this one's functions and partials
+	// that parses templates with other delimiters
+	WithDelimiters(delims Delimiters) (TemplateEngine, error)
+	// SetStrict makes using a variable that was not given an error, instead of
+	// rendering nothing (Fíth) or "<no value>" (Go templates)
+	SetStrict(strict bool)
+}
+
+// Delimiters replaces the markers of a template. Action surrounds Go template
+// actions and Fíth output expressions; Tag and Comment are Fíth's {% %} and
+// {# #}. Pairs left empty keep the engine's defaults.
+type Delimiters struct {
+	Action  [2]string
+	Tag     [2]string
+	Comment [2]string
+}
+
+// maxIncludeDepth limits nested include calls, so a partial including itself fails
+const maxIncludeDepth = 32
+
+// templateKey identifies the content of a template
+type templateKey struct {
+	name    string
+	content string
+}
+
+// templateCache keeps parsed templates, so a template rendered again, for
+// another foreach item or in a later run, is parsed once. Templates that fail to
+// parse are not kept. It is safe for concurrent use.
+type templateCache struct {
+	mu      sync.Mutex
+	entries map[templateKey]interface{}
+}
+
+// newTemplateCache creates an empty template cache
+func newTemplateCache() *templateCache {
+	return &templateCache{entries: make(map[templateKey]interface{})}
+}
+
+// get returns the template parsed for key, calling parse the first time
+func (c *templateCache) get(key templateKey, parse func() (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	tmpl, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[key] = tmpl
+	c.mu.Unlock()
+	return tmpl, nil
+}
+
+// reset drops every template, once the functions, partials or options they
+// were parsed with change
+func (c *templateCache) reset() {
+	c.mu.Lock()
+	clear(c.entries)
+	c.mu.Unlock()
 }
 
 // GoTemplateEngine implements TemplateEngine using Go's text/template
@@ -22,6 +96,12 @@ type GoTemplateEngine struct {
 	funcMap    template.FuncMap
 	leftDelim  string
 	rightDelim string
+	partials   *template.Template // Parsed partials, cloned for every render
+	loaded     []Partial          // Partials as last set, to skip parsing the same again
+	sources    map[string]string  // Content of each partial, for errors
+	paths      map[string]string  // Source path of each partial, for errors
+	strict     bool
+	cache      *templateCache
 }
 
 // NewGoTemplateEngine creates a new Go template engine with default delimiters
@@ -31,8 +111,21 @@ func NewGoTemplateEngine() *GoTemplateEngine {
 
 // NewGoTemplateEngineWithDelimiters creates a new Go template engine with custom delimiters
 func NewGoTemplateEngineWithDelimiters(left, right string) *GoTemplateEngine {
+	funcMap := template.FuncMap(funcs.GoTemplate())
+	maps.Copy(funcMap, helperFuncs())
+	funcMap[optionalFunc] = optional
+	return &GoTemplateEngine{
+		funcMap:    funcMap,
+		leftDelim:  left,
+		rightDelim: right,
+		cache:      newTemplateCache(),
+	}
+}
+
+// helperFuncs returns the naming and Docker helpers shared by both engines
+func helperFuncs() map[string]interface{} {
 	caser := cases.Title(language.English)
-	funcMap := template.FuncMap{
+	return map[string]interface{}{
 		"upper":   strings.ToUpper,
 		"lower":   strings.ToLower,
 		"title":   caser.String,
@@ -43,87 +136,317 @@ func NewGoTemplateEngineWithDelimiters(left, right string) *GoTemplateEngine {
 		"slugify": slugify,
 
 		// Docker helpers
-		"dockerImage": DockerImage,
-		"dockerPort":  DockerPort,
-		"healthCheck": HealthCheck,
-		"hasFrontend": HasFrontend,
-		"dbUser":      DBUser,
-		"dbName":      DBName,
-	}
-
-	return &GoTemplateEngine{
-		funcMap:    funcMap,
-		leftDelim:  left,
-		rightDelim: right,
+		"dockerImage":        DockerImage,
+		"dockerPort":         DockerPort,
+		"healthCheck":        HealthCheck,
+		"hasDatabaseService": HasDatabaseService,
+		"hasFrontend":        HasFrontend,
+		"dbUser":             DBUser,
+		"dbName":             DBName,
 	}
 }
 
 // RegisterFunc adds a custom function to the template engine
 func (e *GoTemplateEngine) RegisterFunc(name string, fn interface{}) {
 	e.funcMap[name] = fn
+	e.cache.reset()
 }
 
-// Render renders a template string with data
-func (e *GoTemplateEngine) Render(templateContent string, data map[string]interface{}) (string, error) {
-	tmpl, err := template.New("template").
+// SetPartials parses the partials into a template set. Each partial is a
+// template named after the partial; {{ block }} and {{ define }} inside them
+// give layouts whose blocks a template redefines before calling the layout.
+// Setting the same partials again keeps them, and the templates parsed with them.
+func (e *GoTemplateEngine) SetPartials(partials []Partial) error {
+	if e.loaded != nil && slices.Equal(partials, e.loaded) {
+		return nil
+	}
+	e.cache.reset()
+	e.loaded = nil
+	e.sources, e.paths = make(map[string]string), make(map[string]string)
+	if len(partials) == 0 {
+		e.partials = nil
+		e.loaded = []Partial{}
+		return nil
+	}
+
+	set := template.New("").
 		Delims(e.leftDelim, e.rightDelim).
 		Funcs(e.funcMap).
-		Parse(templateContent)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse template: %w", err)
+		Funcs(template.FuncMap{"include": includeUnavailable})
+	for _, p := range partials {
+		if err := e.checkFithSyntax(p.Path, p.Content); err != nil {
+			return err
+		}
+		e.sources[p.Name], e.paths[p.Name] = p.Content, p.Path
+		if _, err := set.New(p.Name).Parse(p.Content); err != nil {
+			err = goTemplateError(err, map[string]string{p.Name: p.Content}, e.paths, nil)
+			return fmt.Errorf("failed to parse partial %s: %w", p.Path, err)
+		}
 	}
+	lenientFields(set)
+	e.partials = set
+	e.loaded = append([]Partial{}, partials...)
+	return nil
+}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+// WithDelimiters returns an engine using other action delimiters. Go templates
+// have no separate tag or comment markers.
+func (e *GoTemplateEngine) WithDelimiters(delims Delimiters) (TemplateEngine, error) {
+	if delims.Tag != ([2]string{}) || delims.Comment != ([2]string{}) {
+		return nil, fmt.Errorf("go templates only have action delimiters")
 	}
+	engine := *e
+	if delims.Action != ([2]string{}) {
+		if delims.Action[0] == "" || delims.Action[1] == "" {
+			return nil, fmt.Errorf("delimiters must not be empty")
+		}
+		engine.leftDelim, engine.rightDelim = delims.Action[0], delims.Action[1]
+	}
+	engine.cache = newTemplateCache()
+	return &engine, nil
+}
 
-	return buf.String(), nil
+// SetStrict makes a missing map key an error, like the missingkey=error option.
+// Conditions and default, coalesce and empty still accept undefined variables.
+func (e *GoTemplateEngine) SetStrict(strict bool) {
+	if strict != e.strict {
+		e.strict = strict
+		e.cache.reset()
+	}
+}
+
+// includeUnavailable stands in for include while partials are parsed
+func includeUnavailable(string, interface{}) (string, error) {
+	return "", fmt.Errorf("include is not available here")
+}
+
+// newTemplate creates a template that can use the partials. include only
+// works in a clone passed to withInclude.
+func (e *GoTemplateEngine) newTemplate(name string) (*template.Template, error) {
+	var tmpl *template.Template
+	if e.partials != nil {
+		set, err := e.partials.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy partials: %w", err)
+		}
+		// Partials keep the delimiters they were parsed with
+		tmpl = set.New(name).Delims(e.leftDelim, e.rightDelim)
+	} else {
+		tmpl = template.New(name).
+			Delims(e.leftDelim, e.rightDelim).
+			Funcs(e.funcMap).
+			Funcs(template.FuncMap{"include": includeUnavailable})
+	}
+	if e.strict {
+		tmpl.Option("missingkey=error")
+	}
+	return tmpl, nil
+}
+
+// withInclude gives tmpl an include function for one execution. include
+// renders a partial to a string, so it can be piped like any value.
+func withInclude(tmpl *template.Template) *template.Template {
+	depth := 0
+	return tmpl.Funcs(template.FuncMap{
+		"include": func(partial string, data interface{}) (string, error) {
+			if depth >= maxIncludeDepth {
+				return "", fmt.Errorf("includes nested more than %d levels deep", maxIncludeDepth)
+			}
+			depth++
+			defer func() { depth-- }()
+
+			var buf bytes.Buffer
+			if err := tmpl.ExecuteTemplate(&buf, partial, data); err != nil {
+				return "", err
+			}
+			return buf.String(), nil
+		},
+	})
+}
+
+// Render renders a template string with data
+func (e *GoTemplateEngine) Render(templateContent string, data map[string]interface{}) (string, error) {
+	return e.RenderNamed("template", templateContent, data)
 }
 
 // RenderFile renders a template file with data
 func (e *GoTemplateEngine) RenderFile(templatePath string, data map[string]interface{}) (string, error) {
-	tmpl, err := template.New(filepath.Base(templatePath)).
-		Delims(e.leftDelim, e.rightDelim).
-		Funcs(e.funcMap).
-		ParseFiles(templatePath)
+	content, err := os.ReadFile(templatePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse template file: %w", err)
+		return "", fmt.Errorf("failed to read template file: %w", err)
+	}
+	return e.RenderNamed(templatePath, string(content), data)
+}
+
+// RenderNamed renders template content; name identifies it in errors.
+// It is safe to call concurrently.
+func (e *GoTemplateEngine) RenderNamed(name, templateContent string, data map[string]interface{}) (string, error) {
+	parsed, err := e.cache.get(templateKey{name, templateContent}, func() (interface{}, error) {
+		if err := e.checkFithSyntax(name, templateContent); err != nil {
+			return nil, err
+		}
+		tmpl, err := e.newTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tmpl.Parse(templateContent); err != nil {
+			return nil, err
+		}
+		lenientFields(tmpl)
+		return tmpl, nil
+	})
+	if err != nil {
+		return "", e.templateError(err, name, templateContent, data)
+	}
+
+	// Every execution gets its own include, counting its own depth
+	tmpl, err := parsed.(*template.Template).Clone()
+	if err != nil {
+		return "", fmt.Errorf("failed to copy template %s: %w", name, err)
 	}
 
 	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return "", fmt.Errorf("failed to execute template: %w", err)
+	if err := withInclude(tmpl).Execute(&buf, data); err != nil {
+		return "", e.templateError(err, name, templateContent, data)
 	}
 
 	return buf.String(), nil
 }
 
-// FithTemplateEngine is a placeholder for Fíth integration
-// TODO: Integrate with toutago-fith-renderer when available
+// templateError locates an error in the template called name or in a partial
+func (e *GoTemplateEngine) templateError(err error, name, content string, data map[string]interface{}) error {
+	sources := map[string]string{name: content}
+	for partial, partialContent := range e.sources {
+		sources[partial] = partialContent
+	}
+	return goTemplateError(err, sources, e.paths, data)
+}
+
+// fithBlockTag matches Fíth statements, which text/template would copy to the output untouched
+var fithBlockTag = regexp.MustCompile(`\{%-?\s*(if|for|set|raw|endif|endfor)\b`)
+
+// checkFithSyntax rejects Fíth templates rendered by the go-template engine.
+// Only templates without a single Go action are checked, so go-template files
+// that merely contain {% %} text are unaffected.
+func (e *GoTemplateEngine) checkFithSyntax(name, content string) error {
+	if strings.Contains(content, e.leftDelim) {
+		return nil
+	}
+	loc := fithBlockTag.FindStringIndex(content)
+	if loc == nil {
+		return nil
+	}
+	line := strings.Count(content[:loc[0]], "\n") + 1
+	return fmt.Errorf("%s:%d: found Fíth syntax %q, but this ritual uses the go-template engine; "+
+		"set `template_engine: fith` in ritual.yaml or convert the template to %s %s syntax",
+		name, line, content[loc[0]:loc[1]], e.leftDelim, e.rightDelim)
+}
+
+// FithTemplateEngine implements TemplateEngine using the Fíth template language
 type FithTemplateEngine struct {
-	// Will be implemented when integrating with Fíth
+	engine  *fith.Engine
+	loaded  []Partial         // Partials as last set, to skip parsing the same again
+	sources map[string]string // Content of each partial by path, for errors
+	cache   *templateCache
 }
 
-// NewFithTemplateEngine creates a new Fíth template engine
+// NewFithTemplateEngine creates a new Fíth template engine with the generator helpers
 func NewFithTemplateEngine() *FithTemplateEngine {
-	return &FithTemplateEngine{}
+	engine := fith.New()
+	for name, fn := range funcs.Fith() {
+		engine.RegisterFunc(name, fn)
+	}
+	for name, fn := range helperFuncs() {
+		engine.RegisterFunc(name, fn)
+	}
+	return &FithTemplateEngine{engine: engine, cache: newTemplateCache()}
 }
 
-// Render renders a template string with data (placeholder)
+// RegisterFunc adds a custom function to the template engine
+func (e *FithTemplateEngine) RegisterFunc(name string, fn interface{}) {
+	e.engine.RegisterFunc(name, fn)
+	e.cache.reset()
+}
+
+// Render renders a template string with data
 func (e *FithTemplateEngine) Render(templateContent string, data map[string]interface{}) (string, error) {
-	// TODO: Integrate with toutago-fith-renderer
-	// For now, fallback to Go templates
-	goEngine := NewGoTemplateEngine()
-	return goEngine.Render(templateContent, data)
+	return e.RenderNamed("template", templateContent, data)
 }
 
-// RenderFile renders a template file with data (placeholder)
+// RenderFile renders a template file with data
 func (e *FithTemplateEngine) RenderFile(templatePath string, data map[string]interface{}) (string, error) {
-	// TODO: Integrate with toutago-fith-renderer
-	// For now, fallback to Go templates
-	goEngine := NewGoTemplateEngine()
-	return goEngine.RenderFile(templatePath, data)
+	content, err := os.ReadFile(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template file: %w", err)
+	}
+	return e.RenderNamed(templatePath, string(content), data)
+}
+
+// RenderNamed renders template content; errors carry name, line and column.
+// It is safe to call concurrently.
+func (e *FithTemplateEngine) RenderNamed(name, templateContent string, data map[string]interface{}) (string, error) {
+	tmpl, err := e.cache.get(templateKey{name, templateContent}, func() (interface{}, error) {
+		return e.engine.Parse(name, templateContent)
+	})
+	var out string
+	if err == nil {
+		out, err = tmpl.(*fith.Template).Execute(data)
+	}
+	if err != nil {
+		sources := map[string]string{name: templateContent}
+		for path, content := range e.sources {
+			sources[path] = content
+		}
+		return "", fithTemplateError(err, sources)
+	}
+	return out, nil
+}
+
+// SetPartials parses the partials for {% include %} and {% extends %}. Setting
+// the same partials again keeps them, and the templates parsed with them.
+func (e *FithTemplateEngine) SetPartials(partials []Partial) error {
+	if e.loaded != nil && slices.Equal(partials, e.loaded) {
+		return nil
+	}
+	e.cache.reset()
+	e.loaded = nil
+	e.engine.ClearPartials()
+	e.sources = make(map[string]string)
+	for _, p := range partials {
+		e.sources[p.Path] = p.Content
+		tmpl, err := e.engine.Parse(p.Path, p.Content)
+		if err != nil {
+			return fmt.Errorf("failed to parse partial %s: %w", p.Path, fithTemplateError(err, e.sources))
+		}
+		e.engine.AddPartial(p.Name, tmpl)
+	}
+	e.loaded = append([]Partial{}, partials...)
+	return nil
+}
+
+// WithDelimiters returns an engine using other output, tag or comment delimiters
+func (e *FithTemplateEngine) WithDelimiters(delims Delimiters) (TemplateEngine, error) {
+	fithDelims := fith.DefaultDelimiters
+	if delims.Action != ([2]string{}) {
+		fithDelims.Output = delims.Action
+	}
+	if delims.Tag != ([2]string{}) {
+		fithDelims.Tag = delims.Tag
+	}
+	if delims.Comment != ([2]string{}) {
+		fithDelims.Comment = delims.Comment
+	}
+	engine, err := e.engine.Delims(fithDelims)
+	if err != nil {
+		return nil, err
+	}
+	return &FithTemplateEngine{engine: engine, sources: e.sources, cache: newTemplateCache()}, nil
+}
+
+// SetStrict makes rendering an undefined value an error
+func (e *FithTemplateEngine) SetStrict(strict bool) {
+	e.engine.SetStrict(strict)
+	e.cache.reset()
 }
 
 // NewTemplateEngine creates a template engine based on the specified type
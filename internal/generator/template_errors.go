@@ -0,0 +1,222 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/fith"
+)
+
+// TemplateError is a template that failed to parse or render, located in its source
+type TemplateError struct {
+	Ritual  string // Ritual the template belongs to, if known
+	Path    string // Template source path
+	Line    int    // 1-based line; 0 if unknown
+	Column  int    // 1-based column; 0 if unknown
+	Message string
+	Excerpt string // The source line at Line
+
+	// Key is a variable the template used but was not given, and Available
+	// the names that were defined where it was looked up
+	Key       string
+	Available []string
+}
+
+// Error implements the error interface
+func (e *TemplateError) Error() string {
+	var b strings.Builder
+	if e.Ritual != "" {
+		fmt.Fprintf(&b, "ritual %s: ", e.Ritual)
+	}
+	b.WriteString(e.Path)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d", e.Line)
+		if e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+	}
+	fmt.Fprintf(&b, ": %s", e.Message)
+
+	if e.Excerpt != "" {
+		writeExcerpt(&b, e.Line, e.Column, e.Excerpt)
+	}
+
+	if e.Key != "" {
+		if len(e.Available) == 0 {
+			b.WriteString("\nno variables are available there")
+		} else {
+			fmt.Fprintf(&b, "\navailable: %s", strings.Join(e.Available, ", "))
+			if suggestion := closestName(e.Key, e.Available); suggestion != "" {
+				fmt.Fprintf(&b, "\ndid you mean %q?", suggestion)
+			}
+		}
+	}
+	return b.String()
+}
+
+// writeExcerpt writes a numbered source line and, if column is known, a caret
+// under it
+func writeExcerpt(b *strings.Builder, line, column int, text string) {
+	prefix := fmt.Sprintf("%5d | ", line)
+	fmt.Fprintf(b, "\n%s%s", prefix, text)
+	if column <= 0 {
+		return
+	}
+	b.WriteString("\n" + strings.Repeat(" ", len(prefix)))
+	// Keep tabs, so the caret lines up with the text
+	for _, r := range text[:min(column-1, len(text))] {
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	b.WriteString("^")
+}
+
+// sourceLine returns line n (1-based) of content, or "" if there is none
+func sourceLine(content string, n int) string {
+	lines := strings.Split(content, "\n")
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return strings.TrimRight(lines[n-1], "\r")
+}
+
+// closestName returns the name most like key, if one is close enough to be a likely typo
+func closestName(key string, names []string) string {
+	best, bestDistance := "", 0
+	for _, name := range names {
+		d := editDistance(strings.ToLower(key), strings.ToLower(name))
+		if best == "" || d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	if best == "" || bestDistance > max(2, len(key)/3) {
+		return ""
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+// fithTemplateError locates a Fíth error in sources, the content of each
+// template and partial by name. Other errors are returned unchanged.
+func fithTemplateError(err error, sources map[string]string) error {
+	var fe *fith.Error
+	if !errors.As(err, &fe) {
+		return err
+	}
+	return &TemplateError{
+		Path:      fe.Name,
+		Line:      fe.Line,
+		Column:    fe.Column,
+		Message:   fe.Message,
+		Excerpt:   sourceLine(sources[fe.Name], fe.Line),
+		Key:       fe.Key,
+		Available: fe.Keys,
+	}
+}
+
+var (
+	// goTemplateErrorLine matches the position text/template puts after the
+	// template name in errors: ":LINE: " when parsing, ":LINE:COL: " when executing
+	goTemplateErrorLine = regexp.MustCompile(`^:(\d+)(?::(\d+))?: `)
+	// goMissingKey matches a missing key error of missingkey=error and the field chain it happened in
+	goMissingKey = regexp.MustCompile(`^executing ".*" at <([^>]*)>: map has no entry for key "(.*)"$`)
+)
+
+// goTemplateError locates a text/template error in sources, the content of
+// each template by name; names maps them to the paths shown. A missing map key
+// lists the keys data has at that point. Other errors are returned unchanged.
+func goTemplateError(err error, sources, names map[string]string, data map[string]interface{}) error {
+	msg, ok := strings.CutPrefix(err.Error(), "template: ")
+	if !ok {
+		return err
+	}
+
+	// Template names may contain colons, so match the known names
+	candidates := make([]string, 0, len(sources))
+	for name := range sources {
+		candidates = append(candidates, name)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return len(candidates[i]) > len(candidates[j]) })
+	for _, name := range candidates {
+		rest, ok := strings.CutPrefix(msg, name)
+		if !ok {
+			continue
+		}
+		m := goTemplateErrorLine.FindStringSubmatch(rest)
+		if m == nil {
+			continue
+		}
+
+		tmplErr := &TemplateError{Path: name, Message: rest[len(m[0]):]}
+		if path, ok := names[name]; ok {
+			tmplErr.Path = path
+		}
+		tmplErr.Line, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			// text/template counts columns from 0
+			col, _ := strconv.Atoi(m[2])
+			tmplErr.Column = col + 1
+		}
+		tmplErr.Excerpt = sourceLine(sources[name], tmplErr.Line)
+
+		if missing := goMissingKey.FindStringSubmatch(tmplErr.Message); missing != nil {
+			tmplErr.Key = missing[2]
+			tmplErr.Message = fmt.Sprintf("undefined variable %q", missing[1])
+			tmplErr.Available = mapKeysAt(data, missing[1])
+		}
+		return tmplErr
+	}
+	return err
+}
+
+// mapKeysAt returns the keys of the map holding the last field of a chain like
+// ".db.host", or nil if the chain does not lead through maps from data
+func mapKeysAt(data map[string]interface{}, chain string) []string {
+	fields := strings.Split(strings.TrimPrefix(chain, "."), ".")
+	if !strings.HasPrefix(chain, ".") || len(fields) == 0 {
+		return nil
+	}
+
+	current := data
+	for _, f := range fields[:len(fields)-1] {
+		next, ok := current[f].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = next
+	}
+	keys := make([]string, 0, len(current))
+	for k := range current {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,230 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+func TestTemplateError_GoEngine(t *testing.T) {
+	engine := NewGoTemplateEngine()
+	_, err := engine.RenderNamed("templates/main.go.tmpl", "package main\n\nvar n = [[ len .Count ]]\n", map[string]interface{}{"Count": 3})
+
+	var tmplErr *TemplateError
+	if !errors.As(err, &tmplErr) {
+		t.Fatalf("expected a TemplateError, got %v", err)
+	}
+	if tmplErr.Path != "templates/main.go.tmpl" || tmplErr.Line != 3 || tmplErr.Column != 12 {
+		t.Errorf("location = %s:%d:%d, want templates/main.go.tmpl:3:12", tmplErr.Path, tmplErr.Line, tmplErr.Column)
+	}
+	if tmplErr.Excerpt != "var n = [[ len .Count ]]" {
+		t.Errorf("Excerpt = %q", tmplErr.Excerpt)
+	}
+	if !strings.HasSuffix(err.Error(), "\n    3 | var n = [[ len .Count ]]\n                   ^") {
+		t.Errorf("error should end with the excerpt and a caret, got\n%s", err)
+	}
+}
+
+func TestTemplateError_StrictMissingKey(t *testing.T) {
+	data := map[string]interface{}{
+		"app_name": "blog",
+		"database": map[string]interface{}{"host": "localhost", "port": 5432},
+	}
+
+	tests := []struct {
+		name      string
+		engine    TemplateEngine
+		content   string
+		key       string
+		message   string
+		available []string
+		line      int
+		column    int
+	}{
+		{
+			name:      "go top level",
+			engine:    NewGoTemplateEngine(),
+			content:   "name: [[ .app_nmae ]]",
+			key:       "app_nmae",
+			message:   `undefined variable ".app_nmae"`,
+			available: []string{"app_name", "database"},
+			line:      1,
+			column:    10,
+		},
+		{
+			name:      "go nested",
+			engine:    NewGoTemplateEngine(),
+			content:   "\nhost: [[ .database.hots ]]",
+			key:       "hots",
+			message:   `undefined variable ".database.hots"`,
+			available: []string{"host", "port"},
+			line:      2,
+			column:    19,
+		},
+		{
+			name:      "fith top level",
+			engine:    NewFithTemplateEngine(),
+			content:   "name: {{ app_nmae }}",
+			key:       "app_nmae",
+			message:   `undefined variable "app_nmae"`,
+			available: []string{"app_name", "database"},
+			line:      1,
+			column:    10,
+		},
+		{
+			name:      "fith nested",
+			engine:    NewFithTemplateEngine(),
+			content:   "\nhost: {{ database.hots }}",
+			key:       "hots",
+			message:   `undefined variable "database.hots"`,
+			available: []string{"host", "port"},
+			line:      2,
+			column:    19,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Lenient by default
+			if _, err := tt.engine.RenderNamed("config.tmpl", tt.content, data); err != nil {
+				t.Fatalf("non-strict render failed: %v", err)
+			}
+
+			tt.engine.SetStrict(true)
+			_, err := tt.engine.RenderNamed("config.tmpl", tt.content, data)
+			var tmplErr *TemplateError
+			if !errors.As(err, &tmplErr) {
+				t.Fatalf("expected a TemplateError, got %v", err)
+			}
+			if tmplErr.Key != tt.key || tmplErr.Message != tt.message {
+				t.Errorf("Key, Message = %q, %q, want %q, %q", tmplErr.Key, tmplErr.Message, tt.key, tt.message)
+			}
+			if strings.Join(tmplErr.Available, ",") != strings.Join(tt.available, ",") {
+				t.Errorf("Available = %v, want %v", tmplErr.Available, tt.available)
+			}
+			if tmplErr.Line != tt.line || tmplErr.Column != tt.column {
+				t.Errorf("position = %d:%d, want %d:%d", tmplErr.Line, tmplErr.Column, tt.line, tt.column)
+			}
+			if !strings.Contains(err.Error(), "did you mean") {
+				t.Errorf("error should suggest a name, got\n%s", err)
+			}
+		})
+	}
+}
+
+func TestGoTemplate_StrictUndefinedFallbacks(t *testing.T) {
+	data := map[string]interface{}{
+		"app_name": "blog",
+		"database": map[string]interface{}{"host": "localhost"},
+		"items":    []string{"a"},
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"if", "[[ if .auth ]]auth[[ else ]]none[[ end ]]", "none"},
+		{"else if", "[[ if .auth ]]auth[[ else if .app_name ]]app[[ end ]]", "app"},
+		{"if nested", "[[ if .database.port ]]port[[ end ]]", ""},
+		{"if not", "[[ if not .auth ]]none[[ end ]]", "none"},
+		{"if and", "[[ if and .app_name .auth ]]both[[ else ]]one[[ end ]]", "one"},
+		{"with", "[[ with .auth ]][[ . ]][[ else ]]none[[ end ]]", "none"},
+		{"default piped", `[[ .description | default "" ]]`, ""},
+		{"default argument", `[[ default "pg" .driver ]]`, "pg"},
+		{"default nested", `[[ .database.port | default 5432 ]]`, "5432"},
+		{"default of root in range", `[[ range .items ]][[ $.auth | default "none" ]][[ end ]]`, "none"},
+		{"coalesce", `[[ coalesce .auth .app_name ]]`, "blog"},
+		{"defined", `[[ if .app_name ]][[ .app_name | default "x" ]][[ end ]]`, "blog"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewGoTemplateEngine()
+			engine.SetStrict(true)
+			got, err := engine.RenderNamed("config.tmpl", tt.content, data)
+			if err != nil {
+				t.Fatalf("RenderNamed() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderNamed() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Undefined variables used any other way are still errors
+	for _, content := range []string{"[[ .auth ]]", "[[ .auth | upper ]]", "[[ if eq .auth \"x\" ]][[ end ]]"} {
+		engine := NewGoTemplateEngine()
+		engine.SetStrict(true)
+		if _, err := engine.RenderNamed("config.tmpl", content, data); err == nil {
+			t.Errorf("RenderNamed(%q) should fail in strict mode", content)
+		}
+	}
+
+	// Partials get the same treatment
+	engine := NewGoTemplateEngine()
+	engine.SetStrict(true)
+	if err := engine.SetPartials([]Partial{{Name: "auth", Path: "partials/auth.tmpl", Content: "[[ if .auth ]]auth[[ end ]]"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := engine.RenderNamed("config.tmpl", `[[ include "auth" . ]]`, data); err != nil || got != "" {
+		t.Errorf("RenderNamed() with a partial = %q, %v", got, err)
+	}
+}
+
+func TestGenerateFilesFrom_MissingKeyError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/templates/README.md.tmpl": {Data: []byte("# {{ app_name }}\n\nBy {{ autor }}\n")},
+	}
+	src, err := ritual.NewSource(fsys, "app", "embedded:app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := &ritual.Manifest{
+		Ritual: ritual.RitualMeta{Name: "docs", TemplateEngine: "fith", MissingKey: ritual.MissingKeyError},
+		Files: ritual.FilesSection{
+			Templates: []ritual.FileMapping{{Source: "README.md.tmpl", Destination: "README.md"}},
+		},
+	}
+
+	gen := NewFileGenerator("go-template")
+	vars := NewVariables()
+	vars.Set("app_name", "blog")
+	vars.Set("author", "ada")
+	gen.SetVariables(vars)
+
+	_, err = gen.RenderToMap(manifest, src)
+	var tmplErr *TemplateError
+	if !errors.As(err, &tmplErr) {
+		t.Fatalf("expected a TemplateError, got %v", err)
+	}
+	if tmplErr.Ritual != "docs" || tmplErr.Path != "embedded:app/templates/README.md.tmpl" || tmplErr.Line != 3 {
+		t.Errorf("error = %+v", *tmplErr)
+	}
+	if !strings.HasPrefix(err.Error(), "failed to render template: ritual docs: embedded:app/templates/README.md.tmpl:3:7: undefined variable \"autor\"") {
+		t.Errorf("unexpected message:\n%s", err)
+	}
+	if !strings.Contains(err.Error(), `did you mean "author"?`) {
+		t.Errorf("error should suggest author, got\n%s", err)
+	}
+}
+
+func TestClosestName(t *testing.T) {
+	names := []string{"app_name", "database", "module_path"}
+	tests := map[string]string{
+		"app_nmae":   "app_name",
+		"Database":   "database",
+		"modle_path": "module_path",
+		"frontend":   "",
+		"x":          "",
+		"author":     "",
+	}
+	for key, want := range tests {
+		if got := closestName(key, names); got != want {
+			t.Errorf("closestName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
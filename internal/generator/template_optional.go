@@ -0,0 +1,170 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"text/template"
+	"text/template/parse"
+)
+
+// optionalFunc is the function lenientFields calls in place of a field chain
+const optionalFunc = "optionalField"
+
+// fallbackFuncs take values that may be undefined and give them a fallback
+var fallbackFuncs = map[string]bool{"default": true, "coalesce": true, "empty": true}
+
+// conditionFuncs combine the values an if or with tests
+var conditionFuncs = map[string]bool{"not": true, "and": true, "or": true}
+
+// lenientFields lets conditions and fallbacks use variables that were not
+// given, as in Fíth: with missingkey=error, text/template fails on a missing
+// key before if or default can see it. Field chains tested by if and with,
+// alone or through not, and and or, and those passed to default, coalesce and
+// empty are looked up by optionalFunc, which gives nil for a missing key.
+func lenientFields(tmpl *template.Template) {
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			lenientNode(t.Tree.Root)
+		}
+	}
+}
+
+// lenientNode rewrites the pipelines in node and the nodes under it
+func lenientNode(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			lenientNode(child)
+		}
+	case *parse.ActionNode:
+		lenientPipe(n.Pipe, false)
+	case *parse.IfNode:
+		lenientBranch(&n.BranchNode, true)
+	case *parse.WithNode:
+		lenientBranch(&n.BranchNode, true)
+	case *parse.RangeNode:
+		lenientBranch(&n.BranchNode, false)
+	case *parse.TemplateNode:
+		lenientPipe(n.Pipe, false)
+	}
+}
+
+// lenientBranch rewrites an if, with or range and the nodes under it
+func lenientBranch(branch *parse.BranchNode, condition bool) {
+	lenientPipe(branch.Pipe, condition)
+	lenientNode(branch.List)
+	lenientNode(branch.ElseList)
+}
+
+// lenientPipe rewrites the field chains in pipe that may be undefined. A
+// condition is the pipeline of an if or with.
+func lenientPipe(pipe *parse.PipeNode, condition bool) {
+	if pipe == nil {
+		return
+	}
+	for i, cmd := range pipe.Cmds {
+		name := commandFunc(cmd)
+		switch {
+		case condition && len(pipe.Cmds) == 1 && len(cmd.Args) == 1:
+			cmd.Args[0] = optionalNode(cmd.Args[0])
+		case fallbackFuncs[name] || condition && len(pipe.Cmds) == 1 && conditionFuncs[name]:
+			for j := 1; j < len(cmd.Args); j++ {
+				cmd.Args[j] = optionalNode(cmd.Args[j])
+			}
+			// The value piped into a fallback
+			if i > 0 && fallbackFuncs[name] && len(pipe.Cmds[i-1].Args) == 1 {
+				pipe.Cmds[i-1].Args[0] = optionalNode(pipe.Cmds[i-1].Args[0])
+			}
+		}
+		for _, arg := range cmd.Args {
+			if nested, ok := arg.(*parse.PipeNode); ok {
+				lenientPipe(nested, false)
+			}
+		}
+	}
+}
+
+// commandFunc returns the name of the function cmd calls, if it calls one
+func commandFunc(cmd *parse.CommandNode) string {
+	if len(cmd.Args) == 0 {
+		return ""
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); ok {
+		return ident.Ident
+	}
+	return ""
+}
+
+// optionalNode replaces a field chain such as .db.host or $.db.host with a
+// pipeline calling optionalFunc. Other nodes are returned unchanged.
+func optionalNode(node parse.Node) parse.Node {
+	var start parse.Node
+	var fields []string
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		start, fields = &parse.DotNode{Pos: n.Pos}, n.Ident
+	case *parse.VariableNode:
+		if len(n.Ident) < 2 {
+			return node
+		}
+		start = &parse.VariableNode{NodeType: parse.NodeVariable, Pos: n.Pos, Ident: n.Ident[:1]}
+		fields = n.Ident[1:]
+	default:
+		return node
+	}
+
+	args := []parse.Node{parse.NewIdentifier(optionalFunc).SetPos(node.Position()), start}
+	for _, field := range fields {
+		args = append(args, &parse.StringNode{NodeType: parse.NodeString, Pos: node.Position(), Quoted: strconv.Quote(field), Text: field})
+	}
+	return &parse.PipeNode{
+		NodeType: parse.NodePipe,
+		Pos:      node.Position(),
+		Cmds:     []*parse.CommandNode{{NodeType: parse.NodeCommand, Pos: node.Position(), Args: args}},
+	}
+}
+
+// optional follows fields from value like a template field chain, giving nil
+// where a map has no entry for a field
+func optional(value interface{}, fields ...string) (interface{}, error) {
+	for _, field := range fields {
+		if value == nil {
+			return nil, nil
+		}
+		v := reflect.ValueOf(value)
+		if method := v.MethodByName(field); method.IsValid() && method.Type().NumIn() == 0 && method.Type().NumOut() == 1 {
+			value = method.Call(nil)[0].Interface()
+			continue
+		}
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, nil
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("can't evaluate field %s in type %s", field, v.Type())
+			}
+			entry := v.MapIndex(reflect.ValueOf(field).Convert(v.Type().Key()))
+			if !entry.IsValid() {
+				return nil, nil
+			}
+			value = entry.Interface()
+		case reflect.Struct:
+			entry := v.FieldByName(field)
+			if !entry.IsValid() || !entry.CanInterface() {
+				return nil, fmt.Errorf("can't evaluate field %s in type %s", field, v.Type())
+			}
+			value = entry.Interface()
+		default:
+			return nil, fmt.Errorf("can't evaluate field %s in type %s", field, v.Type())
+		}
+	}
+	return value, nil
+}
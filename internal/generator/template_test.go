@@ -1,8 +1,10 @@
 package generator
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -61,6 +63,13 @@ func TestGoTemplateEngineRender(t *testing.T) {
 			want:     "my-app",
 			wantErr:  false,
 		},
+		{
+			name:     "function library, value last",
+			template: `[[ .title | default "Untitled" ]] [[ singularize .table | pascal ]] [[ dict "a" 1 | toJson ]]`,
+			data:     map[string]interface{}{"table": "blog_posts"},
+			want:     `Untitled BlogPost {"a":1}`,
+			wantErr:  false,
+		},
 		{
 			name:     "invalid template",
 			template: "[[ .missing",
@@ -142,19 +151,166 @@ func TestNewTemplateEngine(t *testing.T) {
 	}
 }
 
-func TestFithTemplateEngineFallback(t *testing.T) {
+func TestFithTemplateEngineRender(t *testing.T) {
+	engine := NewFithTemplateEngine()
+
+	tests := []struct {
+		name     string
+		template string
+		data     map[string]interface{}
+		want     string
+	}{
+		{
+			name:     "variable",
+			template: "Hello {{ name }}!",
+			data:     map[string]interface{}{"name": "World"},
+			want:     "Hello World!",
+		},
+		{
+			name:     "generator helpers as filters",
+			template: "{{ name | pascal }} {{ name | snake }} {{ kebab(name) }}",
+			data:     map[string]interface{}{"name": "my app"},
+			want:     "MyApp my_app my-app",
+		},
+		{
+			name:     "docker helpers",
+			template: "{{ dockerImage(database) }}",
+			data:     map[string]interface{}{"database": "postgres"},
+			want:     DockerImage("postgres"),
+		},
+		{
+			name:     "condition",
+			template: "{% if enable_docker %}docker{% else %}no docker{% endif %}",
+			data:     map[string]interface{}{"enable_docker": false},
+			want:     "no docker",
+		},
+		{
+			name:     "function library, value first",
+			template: "{{ model | pluralize }} {{ admin | ternary(\"on\", \"off\") }} {{ coalesce(title, model) }} {{ version | semverCompare(\">=1\") }}",
+			data:     map[string]interface{}{"model": "category", "admin": true, "version": "1.2.0"},
+			want:     "categories on category true",
+		},
+		{
+			name:     "encoding and indentation",
+			template: "{{ tags | toJson }}\nports:{{ ports | toYaml | nindent(2) }}",
+			data:     map[string]interface{}{"tags": []string{"go"}, "ports": []int{80}},
+			want:     "[\"go\"]\nports:\n  - 80",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := engine.Render(tt.template, tt.data)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("Expected '%s', got '%s'", tt.want, result)
+			}
+		})
+	}
+}
+
+func TestFithTemplateEngineRenderFileError(t *testing.T) {
 	engine := NewFithTemplateEngine()
 
-	template := "Hello [[ .name ]]!"
-	data := map[string]interface{}{"name": "World"}
+	templatePath := filepath.Join(t.TempDir(), "main.go.tmpl")
+	if err := os.WriteFile(templatePath, []byte("package main\n\n{{ name | nope }}\n"), 0600); err != nil {
+		t.Fatalf("Failed to create test template: %v", err)
+	}
+
+	_, err := engine.RenderFile(templatePath, map[string]interface{}{"name": "x"})
+	if err == nil {
+		t.Fatal("Expected error for unknown filter")
+	}
+	want := templatePath + ":3:"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected error to contain %q, got %v", want, err)
+	}
+}
+
+func TestTemplateEngineMismatch(t *testing.T) {
+	_, err := NewFithTemplateEngine().Render("Hello [[ .name ]]!", map[string]interface{}{"name": "World"})
+	if err == nil || !strings.Contains(err.Error(), "template_engine: go-template") {
+		t.Errorf("Expected go-template hint from fith engine, got %v", err)
+	}
 
-	result, err := engine.Render(template, data)
+	_, err = NewGoTemplateEngine().Render("{% if name %}Hello{% endif %}", map[string]interface{}{"name": "World"})
+	if err == nil || !strings.Contains(err.Error(), "template_engine: fith") {
+		t.Errorf("Expected fith hint from go-template engine, got %v", err)
+	}
+}
+
+func TestTemplateEngineWithDelimiters(t *testing.T) {
+	data := map[string]interface{}{"name": "ada"}
+
+	goEngine := NewGoTemplateEngine()
+	if err := goEngine.SetPartials([]Partial{{Name: "hi", Path: "partials/hi.tmpl", Content: "hi [[ .name ]]"}}); err != nil {
+		t.Fatal(err)
+	}
+	custom, err := goEngine.WithDelimiters(Delimiters{Action: [2]string{"<<", ">>"}})
 	if err != nil {
-		t.Errorf("Render failed: %v", err)
+		t.Fatalf("WithDelimiters() error = %v", err)
+	}
+	// Partials keep the delimiters they were written with
+	got, err := custom.RenderNamed("run.sh", `[[ -n "$X" ]] && echo << upper .name >> << template "hi" . >>`, data)
+	if err != nil {
+		t.Fatalf("RenderNamed() error = %v", err)
+	}
+	if want := `[[ -n "$X" ]] && echo ADA hi ada`; got != want {
+		t.Errorf("RenderNamed() = %q, want %q", got, want)
+	}
+	if _, err := goEngine.WithDelimiters(Delimiters{Tag: [2]string{"<%", "%>"}}); err == nil {
+		t.Error("go templates should reject tag delimiters")
 	}
 
-	expected := "Hello World!"
-	if result != expected {
-		t.Errorf("Expected '%s', got '%s'", expected, result)
+	fithEngine, err := NewFithTemplateEngine().WithDelimiters(Delimiters{Action: [2]string{"<<", ">>"}})
+	if err != nil {
+		t.Fatalf("WithDelimiters() error = %v", err)
+	}
+	got, err = fithEngine.Render("{{ name }} << name >>{% if true %}!{% endif %}", data)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if want := "{{ name }} ada!"; got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestGenerateFile_EnvAllowlist(t *testing.T) {
+	t.Setenv("RITUAL_TEST_REGISTRY", "registry.local")
+	t.Setenv("RITUAL_TEST_TOKEN", "secret")
+
+	for _, engine := range []string{"go-template", "fith"} {
+		t.Run(engine, func(t *testing.T) {
+			g := NewFileGenerator(engine)
+			g.SetOutput(NewMemoryFS())
+			g.SetEnv([]string{"RITUAL_TEST_REGISTRY"})
+
+			readEnv := map[string]string{
+				"go-template": `[[ env "%s" ]]`,
+				"fith":        `{{ env("%s") }}`,
+			}[engine]
+			dir := t.TempDir()
+			allowed := filepath.Join(dir, "allowed.tmpl")
+			denied := filepath.Join(dir, "denied.tmpl")
+			if err := os.WriteFile(allowed, []byte(fmt.Sprintf(readEnv, "RITUAL_TEST_REGISTRY")), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(denied, []byte(fmt.Sprintf(readEnv, "RITUAL_TEST_TOKEN")), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := g.GenerateFile(allowed, "/out/allowed.txt", true); err != nil {
+				t.Fatalf("GenerateFile() error = %v", err)
+			}
+			if got, _ := g.Output().ReadFile("/out/allowed.txt"); string(got) != "registry.local" {
+				t.Errorf("env = %q, want registry.local", got)
+			}
+			err := g.GenerateFile(denied, "/out/denied.txt", true)
+			if err == nil || !strings.Contains(err.Error(), "RITUAL_TEST_TOKEN is not allowed") {
+				t.Errorf("reading a variable that is not allowed should fail, got %v", err)
+			}
+		})
 	}
 }
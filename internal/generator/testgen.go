@@ -80,6 +80,7 @@ type MockSpec struct {
 	PackageName   string
 	InterfaceName string
 	Methods       []MockMethod
+	Imports       []string // Import specs the parameter and result types need, such as "context"
 }
 
 // MockMethod represents a method in a mock interface
@@ -89,6 +90,21 @@ type MockMethod struct {
 	Returns    []string
 }
 
+// CRUDTestSpec specifies table-driven tests of a CRUD handler, served through
+// its routes by an in-memory repository. The model, repository, handler and
+// routes are those HandlerGenerator, ModelGenerator and RouteGenerator generate
+// below Module's internal directory.
+type CRUDTestSpec struct {
+	Module   string
+	Layout   Layout // Directories of the packages in Module; empty ones as in DefaultLayout
+	Model    string // Model name, such as Post
+	Resource string // Path of the routes, such as posts
+	Register string // Function registering the routes, such as RegisterPostRoutes
+	Valid    string // JSON of an item Validate accepts
+	Invalid  string // JSON of an item Validate rejects; empty if there is none
+	Schema   string // SQLite statement creating the table of Model, for GenerateRepositoryTest
+}
+
 // GenerateUnitTests generates unit tests for a source file
 func (g *TestGenerator) GenerateUnitTests(projectPath, packagePath, sourceFile string) error {
 	// Parse source file name
@@ -328,11 +344,270 @@ func (g *TestGenerator) GenerateBenchmark(spec BenchmarkSpec) (string, error) {
 	return sb.String(), nil
 }
 
+// GenerateCRUDHandlerTest generates table-driven tests of every operation of
+// a CRUD handler, in the external test package of the handlers
+func (g *TestGenerator) GenerateCRUDHandlerTest(spec CRUDTestSpec) (string, error) {
+	layout := spec.Layout.withDefaults()
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf(`package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"%[3]s"
+	"%[4]s"
+	"%[5]s"
+	"%[6]s"
+)
+
+// memory%[1]sRepository is a repository.%[1]sRepository keeping items in memory
+type memory%[1]sRepository struct {
+	items map[uint]*models.%[1]s
+	next  uint
+}
+
+var _ repository.%[1]sRepository = (*memory%[1]sRepository)(nil)
+
+func (r *memory%[1]sRepository) Create(_ context.Context, item *models.%[1]s) (*models.%[1]s, error) {
+	r.next++
+	item.ID = r.next
+	r.items[item.ID] = item
+	return item, nil
+}
+
+func (r *memory%[1]sRepository) GetByID(_ context.Context, id uint) (*models.%[1]s, error) {
+	item, ok := r.items[id]
+	if !ok {
+		return nil, repository.Err%[1]sNotFound
+	}
+	return item, nil
+}
+
+func (r *memory%[1]sRepository) List(_ context.Context, limit, offset int) ([]*models.%[1]s, error) {
+	items := make([]*models.%[1]s, 0, len(r.items))
+	for id := uint(1); id <= r.next; id++ {
+		if item, ok := r.items[id]; ok {
+			items = append(items, item)
+		}
+	}
+	items = items[min(offset, len(items)):]
+	return items[:min(limit, len(items))], nil
+}
+
+func (r *memory%[1]sRepository) Update(_ context.Context, id uint, item *models.%[1]s) (*models.%[1]s, error) {
+	if _, ok := r.items[id]; !ok {
+		return nil, repository.Err%[1]sNotFound
+	}
+	item.ID = id
+	r.items[id] = item
+	return item, nil
+}
+
+func (r *memory%[1]sRepository) Delete(_ context.Context, id uint) error {
+	if _, ok := r.items[id]; !ok {
+		return repository.Err%[1]sNotFound
+	}
+	delete(r.items, id)
+	return nil
+}
+
+func Test%[1]sHandler(t *testing.T) {
+	router := mux.NewRouter()
+	repo := &memory%[1]sRepository{items: make(map[uint]*models.%[1]s)}
+	routes.%[2]s(router, handlers.New%[1]sHandler(repo))
+
+	// The cases share the repository and run in order
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+`, spec.Model, spec.Register,
+		importPath(spec.Module, layout.Handlers), importPath(spec.Module, layout.Models),
+		importPath(spec.Module, layout.Repository), importPath(spec.Module, layout.Routes)))
+
+	path := "/" + spec.Resource
+	cases := []struct {
+		name, method, path, body, want string
+	}{
+		{"create", "MethodPost", path, spec.Valid, "StatusCreated"},
+		{"create malformed", "MethodPost", path, "{", "StatusBadRequest"},
+		{"create invalid", "MethodPost", path, spec.Invalid, "StatusBadRequest"},
+		{"list", "MethodGet", path, "", "StatusOK"},
+		{"list page", "MethodGet", path + "?limit=1&offset=0", "", "StatusOK"},
+		{"list invalid limit", "MethodGet", path + "?limit=0", "", "StatusBadRequest"},
+		{"get", "MethodGet", path + "/1", "", "StatusOK"},
+		{"get missing", "MethodGet", path + "/42", "", "StatusNotFound"},
+		{"get invalid id", "MethodGet", path + "/abc", "", "StatusBadRequest"},
+		{"update", "MethodPut", path + "/1", spec.Valid, "StatusOK"},
+		{"update invalid", "MethodPut", path + "/1", spec.Invalid, "StatusBadRequest"},
+		{"update missing", "MethodPut", path + "/42", spec.Valid, "StatusNotFound"},
+		{"delete", "MethodDelete", path + "/1", "", "StatusNoContent"},
+		{"get deleted", "MethodGet", path + "/1", "", "StatusNotFound"},
+		{"delete missing", "MethodDelete", path + "/1", "", "StatusNotFound"},
+	}
+	for _, tc := range cases {
+		if strings.HasSuffix(tc.name, " invalid") && spec.Invalid == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\t\t{%q, http.%s, %q, `%s`, http.%s},\n", tc.name, tc.method, tc.path, tc.body, tc.want))
+	}
+
+	sb.WriteString(`	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+`)
+
+	return sb.String(), nil
+}
+
+// GenerateRepositoryTest generates tests of the database/sql repository of a
+// model, run against an in-memory SQLite database created with spec.Schema
+func (g *TestGenerator) GenerateRepositoryTest(spec CRUDTestSpec) (string, error) {
+	layout := spec.Layout.withDefaults()
+	return fmt.Sprintf(`package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"%[3]s"
+	"%[4]s"
+)
+
+const %[2]sSchema = %[5]s
+
+func new%[1]sRepository(t *testing.T) *repository.%[1]sRepositoryImpl {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	db.SetMaxOpenConns(1) // Every connection opens a database of its own
+	if _, err := db.Exec(%[2]sSchema); err != nil {
+		t.Fatalf("failed to create the table: %%v", err)
+	}
+	return repository.New%[1]sRepository(db)
+}
+
+func new%[1]s(t *testing.T) *models.%[1]s {
+	t.Helper()
+	var item models.%[1]s
+	if err := json.Unmarshal([]byte(%[6]s), &item); err != nil {
+		t.Fatal(err)
+	}
+	return &item
+}
+
+func Test%[1]sRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := new%[1]sRepository(t)
+
+	created, err := repo.Create(ctx, new%[1]s(t))
+	if err != nil {
+		t.Fatalf("Create() error = %%v", err)
+	}
+	if created.ID == 0 {
+		t.Fatal("Create() should set the id")
+	}
+
+	got, err := repo.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetByID() error = %%v", err)
+	}
+	want, _ := json.Marshal(created)
+	if have, _ := json.Marshal(got); string(have) != string(want) {
+		t.Errorf("GetByID() = %%s, want %%s", have, want)
+	}
+
+	for _, page := range []struct{ limit, offset, want int }{{10, 0, 1}, {1, 1, 0}} {
+		items, err := repo.List(ctx, page.limit, page.offset)
+		if err != nil {
+			t.Fatalf("List() error = %%v", err)
+		}
+		if len(items) != page.want {
+			t.Errorf("List(%%d, %%d) returned %%d items, want %%d", page.limit, page.offset, len(items), page.want)
+		}
+	}
+
+	updated, err := repo.Update(ctx, created.ID, new%[1]s(t))
+	if err != nil {
+		t.Fatalf("Update() error = %%v", err)
+	}
+	if updated.ID != created.ID {
+		t.Errorf("Update() returned id %%d, want %%d", updated.ID, created.ID)
+	}
+	if _, err := repo.Update(ctx, 42, new%[1]s(t)); !errors.Is(err, repository.Err%[1]sNotFound) {
+		t.Errorf("Update() of a missing item error = %%v, want Err%[1]sNotFound", err)
+	}
+
+	if err := repo.Delete(ctx, created.ID); err != nil {
+		t.Fatalf("Delete() error = %%v", err)
+	}
+	if _, err := repo.GetByID(ctx, created.ID); !errors.Is(err, repository.Err%[1]sNotFound) {
+		t.Errorf("GetByID() of a deleted item error = %%v, want Err%[1]sNotFound", err)
+	}
+	if err := repo.Delete(ctx, created.ID); !errors.Is(err, repository.Err%[1]sNotFound) {
+		t.Errorf("Delete() of a deleted item error = %%v, want Err%[1]sNotFound", err)
+	}
+}
+`, spec.Model, toCamelCase(spec.Model), importPath(spec.Module, layout.Models),
+		importPath(spec.Module, layout.Repository), "`"+spec.Schema+"`", "`"+spec.Valid+"`"), nil
+}
+
 // GenerateMockInterface generates a mock implementation of an interface
 func (g *TestGenerator) GenerateMockInterface(spec MockSpec) (string, error) {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("package %s\n\n", spec.PackageName))
+	if len(spec.Imports) > 0 {
+		// The standard library first, then the rest, as goimports groups them
+		var std, others []string
+		for _, imp := range spec.Imports {
+			importPath := strings.Trim(imp[strings.LastIndex(imp, " ")+1:], `"`)
+			if first, _, _ := strings.Cut(importPath, "/"); strings.Contains(first, ".") {
+				others = append(others, imp)
+			} else {
+				std = append(std, imp)
+			}
+		}
+		sb.WriteString("import (\n")
+		for i, group := range [][]string{std, others} {
+			if i > 0 && len(std) > 0 && len(others) > 0 {
+				sb.WriteString("\n")
+			}
+			for _, imp := range group {
+				sb.WriteString(fmt.Sprintf("\t%s\n", imp))
+			}
+		}
+		sb.WriteString(")\n\n")
+	}
 
 	// Generate mock struct
 	mockName := "Mock" + spec.InterfaceName
@@ -356,13 +631,7 @@ func (g *TestGenerator) GenerateMockInterface(spec MockSpec) (string, error) {
 				if i > 0 {
 					sb.WriteString(", ")
 				}
-				if strings.Contains(ret, "error") {
-					sb.WriteString("nil")
-				} else if strings.HasPrefix(ret, "*") {
-					sb.WriteString("nil")
-				} else {
-					sb.WriteString("\"\"") // Default string, adjust as needed
-				}
+				sb.WriteString(zeroValue(ret))
 			}
 			sb.WriteString("\n")
 		}
@@ -372,3 +641,23 @@ func (g *TestGenerator) GenerateMockInterface(spec MockSpec) (string, error) {
 
 	return sb.String(), nil
 }
+
+// zeroValue returns an expression of the zero value of the Go type typ
+func zeroValue(typ string) string {
+	switch {
+	case typ == "error", typ == "any", strings.HasPrefix(typ, "*"), strings.HasPrefix(typ, "[]"),
+		strings.HasPrefix(typ, "map["), strings.HasPrefix(typ, "chan "), strings.HasPrefix(typ, "chan<-"), strings.HasPrefix(typ, "<-chan"),
+		strings.HasPrefix(typ, "func("), strings.HasPrefix(typ, "interface{"):
+		return "nil"
+	case typ == "string":
+		return `""`
+	case typ == "bool":
+		return "false"
+	}
+	switch typ {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64",
+		"uintptr", "byte", "rune", "float32", "float64", "complex64", "complex128":
+		return "0"
+	}
+	return "*new(" + typ + ")"
+}
@@ -2,6 +2,7 @@ package generator
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"strings"
 	"time"
@@ -10,15 +11,20 @@ import (
 	"golang.org/x/text/language"
 )
 
+// secretPlaceholder stands in for secrets in files that are not protected
+const secretPlaceholder = "change-me"
+
 // Variables manages template variables and substitutions
 type Variables struct {
-	data map[string]interface{}
+	data    map[string]interface{}
+	secrets map[string]bool
 }
 
 // NewVariables creates a new variables manager
 func NewVariables() *Variables {
 	return &Variables{
-		data: make(map[string]interface{}),
+		data:    make(map[string]interface{}),
+		secrets: make(map[string]bool),
 	}
 }
 
@@ -27,6 +33,18 @@ func (v *Variables) Set(key string, value interface{}) {
 	v.data[key] = value
 }
 
+// SetSecret sets a variable whose value is a secret. Secrets render only
+// into protected files and are masked by MaskSecrets.
+func (v *Variables) SetSecret(key string, value interface{}) {
+	v.data[key] = value
+	v.secrets[key] = true
+}
+
+// IsSecret reports whether key holds a secret
+func (v *Variables) IsSecret(key string) bool {
+	return v.secrets[key]
+}
+
 // Get gets a variable value
 func (v *Variables) Get(key string) (interface{}, bool) {
 	val, ok := v.data[key]
@@ -104,13 +122,21 @@ func (v *Variables) AddComputed() {
 
 		// Add case transformations
 		caser := cases.Title(language.English)
-		v.data[key+"_upper"] = strings.ToUpper(strValue)
-		v.data[key+"_lower"] = strings.ToLower(strValue)
-		v.data[key+"_title"] = caser.String(strValue)
-		v.data[key+"_pascal"] = toPascalCase(strValue)
-		v.data[key+"_camel"] = toCamelCase(strValue)
-		v.data[key+"_snake"] = toSnakeCase(strValue)
-		v.data[key+"_kebab"] = toKebabCase(strValue)
+		transformed := map[string]string{
+			key + "_upper":  strings.ToUpper(strValue),
+			key + "_lower":  strings.ToLower(strValue),
+			key + "_title":  caser.String(strValue),
+			key + "_pascal": toPascalCase(strValue),
+			key + "_camel":  toCamelCase(strValue),
+			key + "_snake":  toSnakeCase(strValue),
+			key + "_kebab":  toKebabCase(strValue),
+		}
+		for name, text := range transformed {
+			v.data[name] = text
+			if v.secrets[key] {
+				v.secrets[name] = true
+			}
+		}
 	}
 }
 
@@ -123,7 +149,17 @@ func (v *Variables) All() map[string]interface{} {
 	return result
 }
 
-// MaskSecrets returns a copy with secrets masked for logging
+// With returns a copy of the variables with values added or replaced
+func (v *Variables) With(values map[string]interface{}) *Variables {
+	copied := &Variables{data: v.All(), secrets: maps.Clone(v.secrets)}
+	for k, val := range values {
+		copied.data[k] = val
+	}
+	return copied
+}
+
+// MaskSecrets returns a copy with secrets masked for logging: the keys given,
+// every secret set with SetSecret and names that look like secrets
 func (v *Variables) MaskSecrets(secretKeys []string) map[string]interface{} {
 	result := make(map[string]interface{})
 	secretSet := make(map[string]bool)
@@ -132,7 +168,7 @@ func (v *Variables) MaskSecrets(secretKeys []string) map[string]interface{} {
 	}
 
 	for k, val := range v.data {
-		if secretSet[k] || strings.Contains(strings.ToLower(k), "password") ||
+		if secretSet[k] || v.secrets[k] || strings.Contains(strings.ToLower(k), "password") ||
 			strings.Contains(strings.ToLower(k), "secret") ||
 			strings.Contains(strings.ToLower(k), "token") {
 			result[k] = "***"
@@ -143,6 +179,29 @@ func (v *Variables) MaskSecrets(secretKeys []string) map[string]interface{} {
 	return result
 }
 
+// MaskSecretValues masks every secret set with SetSecret where it appears in
+// text, such as generated file contents shown in a log
+func (v *Variables) MaskSecretValues(text string) string {
+	for k := range v.secrets {
+		if value := v.GetString(k); value != "" {
+			text = strings.ReplaceAll(text, value, "***")
+		}
+	}
+	return text
+}
+
+// redacted returns all variables with every secret replaced by a placeholder,
+// for files that are not protected
+func (v *Variables) redacted() map[string]interface{} {
+	result := v.All()
+	for k := range v.secrets {
+		if _, ok := result[k]; ok {
+			result[k] = secretPlaceholder
+		}
+	}
+	return result
+}
+
 // Case conversion helpers
 
 func toPascalCase(s string) string {
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/inject"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
 
@@ -259,7 +260,28 @@ func CORS(next http.HandlerFunc) http.HandlerFunc {
 	return os.WriteFile(middlewarePath, []byte(template), 0600)
 }
 
-// updateMainWithWiring updates main.go to use the wired components
+// mainWiring are the snippets wiring the container and router into main.go,
+// next to the markers of the built-in main.go
+var mainWiring = []struct {
+	snippet string
+	anchor  inject.Anchor
+}{
+	{
+		snippet: `"[[ .module_name ]]/internal/container"
+"[[ .module_name ]]/internal/router"`,
+		anchor: inject.Anchor{Marker: inject.MarkerImports},
+	},
+	{
+		snippet: `// Wire up dependencies and their routes
+c := container.NewContainer(nil) // Pass the database once the project opens one
+defer c.Close()
+mux.Handle("/", router.Setup(c))`,
+		anchor: inject.Anchor{Marker: inject.MarkerRoutes},
+	},
+}
+
+// updateMainWithWiring injects the wired components into main.go, next to its
+// // ritual:imports and // ritual:routes markers. A missing marker is an error.
 func (w *ComponentWiring) updateMainWithWiring(projectPath string, vars *Variables) error {
 	mainPath := filepath.Join(projectPath, "cmd", "server", "main.go")
 
@@ -281,71 +303,23 @@ func (w *ComponentWiring) updateMainWithWiring(projectPath string, vars *Variabl
 		return nil // Already wired
 	}
 
-	// Generate new main.go with wiring
-	template := `package main
-
-import (
-	"database/sql"
-	"fmt"
-	"log"
-	"net/http"
-	"os"
-
-	"[[ .module_name ]]/internal/container"
-	"[[ .module_name ]]/internal/router"
-	
-	_ "github.com/lib/pq" // PostgreSQL driver
-)
+	w.generator.SetVariables(vars)
 
-func main() {
-	// Get configuration from environment
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "[[ .port ]]"
-	}
-	
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = "localhost"
-	}
-	
-	// Initialize database connection (if configured)
-	var db *sql.DB
-	var err error
-	dbConnStr := os.Getenv("DATABASE_URL")
-	if dbConnStr != "" {
-		db, err = sql.Open("postgres", dbConnStr)
+	changed := false
+	for _, wiring := range mainWiring {
+		snippet, err := w.generator.engine.Render(wiring.snippet, vars.All())
 		if err != nil {
-			log.Printf("Warning: Could not connect to database: %v", err)
-		} else {
-			defer db.Close()
-			log.Println("Database connection established")
+			return err
 		}
+		var inserted bool
+		if content, inserted, err = inject.Into(content, snippet, wiring.anchor); err != nil {
+			return err
+		}
+		changed = changed || inserted
 	}
-	
-	// Initialize dependency container
-	c := container.NewContainer(db)
-	defer c.Close()
-	
-	// Setup routes
-	mux := router.Setup(c)
-	
-	// Start server
-	addr := fmt.Sprintf(":%s", port)
-	log.Printf("Starting [[ .app_name ]] on %s", addr)
-	
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		log.Fatal(err)
-	}
-}
-`
-
-	w.generator.SetVariables(vars)
-
-	newContent, err := w.generator.engine.Render(template, vars.All())
-	if err != nil {
-		return err
+	if !changed {
+		return nil
 	}
 
-	return os.WriteFile(mainPath, []byte(newContent), 0600)
+	return os.WriteFile(mainPath, content, 0600)
 }
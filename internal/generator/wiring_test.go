@@ -1,11 +1,13 @@
 package generator
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/inject"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
 
@@ -186,14 +188,18 @@ func TestUpdateMainWithWiring(t *testing.T) {
 	initialMain := `package main
 
 import (
-	"fmt"
 	"log"
 	"net/http"
+	// ritual:imports
 )
 
 func main() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(http.ResponseWriter, *http.Request) {})
+	// ritual:routes
+
 	log.Println("Starting server...")
-	http.ListenAndServe(":8080", nil)
+	http.ListenAndServe(":8080", mux)
 }
 `
 	mainPath := filepath.Join(mainDir, "main.go")
@@ -228,6 +234,37 @@ func main() {
 	if !strings.Contains(contentStr, "github.com/test/myapp/internal/container") {
 		t.Error("Updated main.go should import container package")
 	}
+	if !strings.Contains(contentStr, `mux.HandleFunc("/health"`) {
+		t.Error("Updated main.go should keep its own routes")
+	}
+	if !strings.Contains(contentStr, "\tmux.Handle(\"/\", router.Setup(c))\n\t// ritual:routes") {
+		t.Errorf("Wiring should be inserted before the routes marker, got:\n%s", contentStr)
+	}
+}
+
+func TestUpdateMainWithWiring_MissingMarker(t *testing.T) {
+	tempDir := t.TempDir()
+	mainDir := filepath.Join(tempDir, "cmd", "server")
+	if err := os.MkdirAll(mainDir, 0750); err != nil {
+		t.Fatalf("Failed to create main directory: %v", err)
+	}
+	initialMain := "package main\n\nimport (\n\t// ritual:imports\n)\n\nfunc main() {}\n"
+	mainPath := filepath.Join(mainDir, "main.go")
+	if err := os.WriteFile(mainPath, []byte(initialMain), 0600); err != nil {
+		t.Fatalf("Failed to write initial main.go: %v", err)
+	}
+
+	vars := NewVariables()
+	vars.Set("module_name", "github.com/test/myapp")
+
+	err := NewComponentWiring().updateMainWithWiring(tempDir, vars)
+	if !errors.Is(err, inject.ErrMarkerNotFound) || !strings.Contains(err.Error(), inject.MarkerRoutes) {
+		t.Fatalf("Expected the missing routes marker to be reported, got %v", err)
+	}
+	content, _ := os.ReadFile(mainPath)
+	if string(content) != initialMain {
+		t.Error("Main.go should not be changed when a marker is missing")
+	}
 }
 
 func TestUpdateMainWithWiring_NoMainFile(t *testing.T) {
@@ -413,3 +413,31 @@ func init() {
 	// Tasks are auto-registered via init() in their packages
 	// Imports above ensure registration happens
 }
+
+func TestHookExecutor_TaskCondition(t *testing.T) {
+	tmpDir := t.TempDir()
+	executor := NewHookExecutor(tmpDir)
+	executor.SetVariables(map[string]interface{}{"database": "sqlite"})
+
+	skipped, _ := json.Marshal(map[string]interface{}{
+		"type":      "mkdir",
+		"path":      filepath.Join(tmpDir, "pgdata"),
+		"condition": "database == 'postgres'",
+	})
+	run, _ := json.Marshal(map[string]interface{}{
+		"type":      "mkdir",
+		"path":      filepath.Join(tmpDir, "data"),
+		"condition": "database in ['sqlite', 'duckdb']",
+	})
+
+	if err := executor.ExecutePostInstall([]string{string(skipped), string(run)}); err != nil {
+		t.Fatalf("Failed to execute task hooks: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "pgdata")); !os.IsNotExist(err) {
+		t.Error("Expected task with false condition to be skipped")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "data")); err != nil {
+		t.Errorf("Expected task with true condition to run: %v", err)
+	}
+}
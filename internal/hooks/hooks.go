@@ -9,16 +9,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/condition"
 	"github.com/toutaio/toutago-ritual-grove/internal/hooks/tasks"
 )
 
 // HookExecutor executes lifecycle hooks
 type HookExecutor struct {
-	workDir string
-	timeout time.Duration
-	dryRun  bool
-	env     map[string]string
-	output  bytes.Buffer
+	workDir   string
+	timeout   time.Duration
+	dryRun    bool
+	env       map[string]string
+	variables map[string]interface{}
+	output    bytes.Buffer
 }
 
 // NewHookExecutor creates a new hook executor
@@ -45,6 +47,11 @@ func (e *HookExecutor) SetEnv(key, value string) {
 	e.env[key] = value
 }
 
+// SetVariables sets the variables that task conditions are evaluated against
+func (e *HookExecutor) SetVariables(vars map[string]interface{}) {
+	e.variables = vars
+}
+
 // GetOutput returns the captured output from hooks
 func (e *HookExecutor) GetOutput() string {
 	return e.output.String()
@@ -117,6 +124,17 @@ func (e *HookExecutor) executeTask(taskJSON, phase string, index, total int) err
 		return fmt.Errorf("task object missing 'type' field")
 	}
 
+	if expr, ok := taskData["condition"].(string); ok {
+		run, err := condition.Evaluate(expr, e.variables)
+		if err != nil {
+			return fmt.Errorf("task '%s': %w", taskType, err)
+		}
+		if !run {
+			e.output.WriteString(fmt.Sprintf("[%s %d/%d] Skipped task: %s (condition %q is false)\n", phase, index, total, taskType, expr))
+			return nil
+		}
+	}
+
 	if e.dryRun {
 		e.output.WriteString(fmt.Sprintf("[DRY RUN] %s task %d/%d: %s\n", phase, index, total, taskType))
 		return nil
@@ -259,6 +277,17 @@ func parseTaskObject(taskJSON string) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("task object must have a 'type' field")
 	}
 
+	// An optional "condition" decides whether the task runs
+	if expr, ok := taskData["condition"]; ok {
+		exprStr, isString := expr.(string)
+		if !isString {
+			return nil, fmt.Errorf("task 'condition' must be a string")
+		}
+		if err := condition.Validate(exprStr); err != nil {
+			return nil, err
+		}
+	}
+
 	return taskData, nil
 }
 
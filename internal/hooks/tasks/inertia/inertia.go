@@ -11,7 +11,9 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/funcs"
 	"github.com/toutaio/toutago-ritual-grove/internal/hooks/tasks"
+	"github.com/toutaio/toutago-ritual-grove/internal/inject"
 )
 
 // SetupInertiaMiddlewareTask adds Inertia middleware to main.go.
@@ -182,7 +184,7 @@ func %[1]sDelete(ctx *cosan.Context) error {
 	return ctx.Inertia().Redirect("/%[2]s")
 }
 `,
-		capitalize(resourceName), resourceName,
+		funcs.Capitalize(resourceName), resourceName,
 	)
 
 	return os.WriteFile(handlerFile, []byte(template), 0600)
@@ -329,9 +331,12 @@ func (t *GenerateTypeScriptTypesTask) Validate() error {
 }
 
 // UpdateRoutesForInertiaTask updates route definitions for Inertia.
+// The routes are injected before a marker in internal/routes/routes.go,
+// "// ritual:routes" unless Marker says otherwise.
 type UpdateRoutesForInertiaTask struct {
 	ProjectDir string
 	Resource   string
+	Marker     string
 }
 
 func (t *UpdateRoutesForInertiaTask) Name() string {
@@ -351,6 +356,11 @@ func (t *UpdateRoutesForInertiaTask) Execute(ctx context.Context, taskCtx *tasks
 		}
 	}
 
+	marker := t.Marker
+	if marker == "" {
+		marker = inject.MarkerRoutes
+	}
+
 	routesFile := filepath.Join(projectDir, "internal", "routes", "routes.go")
 
 	content, err := os.ReadFile(routesFile)
@@ -358,25 +368,29 @@ func (t *UpdateRoutesForInertiaTask) Execute(ctx context.Context, taskCtx *tasks
 		return fmt.Errorf("failed to read routes file: %w", err)
 	}
 
-	// Add routes before the closing brace of Setup function
-	routes := fmt.Sprintf(`
-	// %s routes
-	router.GET("/%s", handlers.%sIndex)
-	router.GET("/%s/:id", handlers.%sShow)
-	router.POST("/%s", handlers.%sCreate)
-	router.PUT("/%s/:id", handlers.%sUpdate)
-	router.DELETE("/%s/:id", handlers.%sDelete)
-`,
-		capitalize(resourceName),
-		resourceName, capitalize(resourceName),
-		resourceName, capitalize(resourceName),
-		resourceName, capitalize(resourceName),
-		resourceName, capitalize(resourceName),
-		resourceName, capitalize(resourceName),
+	resource := funcs.Capitalize(resourceName)
+	routes := fmt.Sprintf(`// %s routes
+router.GET("/%s", handlers.%sIndex)
+router.GET("/%s/:id", handlers.%sShow)
+router.POST("/%s", handlers.%sCreate)
+router.PUT("/%s/:id", handlers.%sUpdate)
+router.DELETE("/%s/:id", handlers.%sDelete)`,
+		resource,
+		resourceName, resource,
+		resourceName, resource,
+		resourceName, resource,
+		resourceName, resource,
+		resourceName, resource,
 	)
 
-	modified := strings.Replace(string(content), "// Existing routes", "// Existing routes"+routes, 1)
-	return os.WriteFile(routesFile, []byte(modified), 0600)
+	modified, changed, err := inject.Into(content, routes, inject.Anchor{Marker: marker})
+	if err != nil {
+		return fmt.Errorf("failed to add %s routes to %s: %w", resourceName, routesFile, err)
+	}
+	if !changed {
+		return nil
+	}
+	return os.WriteFile(routesFile, modified, 0600)
 }
 
 func (t *UpdateRoutesForInertiaTask) Validate() error {
@@ -389,7 +403,7 @@ func (t *UpdateRoutesForInertiaTask) Validate() error {
 // Helper functions
 
 func generateSharedDataFunc(name string) string {
-	return fmt.Sprintf(`		"%s": Get%s,`, name, capitalize(name))
+	return fmt.Sprintf(`		"%s": Get%s,`, name, funcs.Capitalize(name))
 }
 
 func generateSharedDataHelpers(sharedData []string) string {
@@ -399,7 +413,7 @@ func generateSharedDataHelpers(sharedData []string) string {
 func Get%s(ctx *cosan.Context) interface{} {
 	// TODO: Implement %s retrieval
 	return nil
-}`, capitalize(data), data, capitalize(data), data)
+}`, funcs.Capitalize(data), data, funcs.Capitalize(data), data)
 		helpers = append(helpers, helper)
 	}
 	return strings.Join(helpers, "\n\n")
@@ -460,13 +474,6 @@ func goTypeToTS(expr ast.Expr) string {
 	}
 }
 
-func capitalize(s string) string {
-	if len(s) == 0 {
-		return s
-	}
-	return strings.ToUpper(s[:1]) + s[1:]
-}
-
 // Register all Inertia tasks.
 func init() {
 	tasks.Register("setup-inertia-middleware", func(config map[string]interface{}) (tasks.Task, error) {
@@ -500,6 +507,7 @@ func init() {
 	tasks.Register("update-routes-for-inertia", func(config map[string]interface{}) (tasks.Task, error) {
 		projectDir, _ := config["project_dir"].(string)
 		resource, _ := config["resource"].(string)
-		return &UpdateRoutesForInertiaTask{ProjectDir: projectDir, Resource: resource}, nil
+		marker, _ := config["marker"].(string)
+		return &UpdateRoutesForInertiaTask{ProjectDir: projectDir, Resource: resource, Marker: marker}, nil
 	})
 }
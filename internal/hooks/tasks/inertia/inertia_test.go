@@ -2,6 +2,7 @@ package inertia_test
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/toutaio/toutago-ritual-grove/internal/hooks/tasks"
 	"github.com/toutaio/toutago-ritual-grove/internal/hooks/tasks/inertia"
+	"github.com/toutaio/toutago-ritual-grove/internal/inject"
 )
 
 func TestSetupInertiaMiddleware(t *testing.T) {
@@ -186,7 +188,8 @@ func TestUpdateRoutesForInertia(t *testing.T) {
 import "github.com/toutaio/toutago/cosan"
 
 func Setup(router *cosan.Router) {
-	// Existing routes
+	router.GET("/", handlers.Home)
+	// ritual:routes
 }
 `
 		err = os.WriteFile(routesFile, []byte(content), 0644)
@@ -211,5 +214,24 @@ func Setup(router *cosan.Router) {
 		assert.Contains(t, string(modified), "POST")
 		assert.Contains(t, string(modified), "PUT")
 		assert.Contains(t, string(modified), "DELETE")
+		assert.Contains(t, string(modified), "\trouter.DELETE(\"/posts/:id\", handlers.PostsDelete)\n\t// ritual:routes")
+
+		// Running again adds nothing
+		err = task.Execute(context.Background(), taskCtx)
+		require.NoError(t, err)
+		again, err := os.ReadFile(routesFile)
+		require.NoError(t, err)
+		assert.Equal(t, string(modified), string(again))
+	})
+
+	t.Run("reports a missing marker", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		routesFile := filepath.Join(tmpDir, "internal", "routes", "routes.go")
+		require.NoError(t, os.MkdirAll(filepath.Dir(routesFile), 0755))
+		require.NoError(t, os.WriteFile(routesFile, []byte("package routes\n\nfunc Setup() {}\n"), 0644))
+
+		task := &inertia.UpdateRoutesForInertiaTask{ProjectDir: tmpDir, Resource: "posts"}
+		err := task.Execute(context.Background(), tasks.NewTaskContext())
+		assert.True(t, errors.Is(err, inject.ErrMarkerNotFound), "got %v", err)
 	})
 }
@@ -0,0 +1,133 @@
+// Package inject inserts snippets into existing files next to an anchor: a
+// marker comment such as "// ritual:routes" or a regular expression. Inserting
+// is idempotent, so a snippet already in the file is left alone.
+package inject
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Markers rituals put in generated files for snippets to be injected next to
+const (
+	MarkerImports = "// ritual:imports"
+	MarkerRoutes  = "// ritual:routes"
+)
+
+// ErrMarkerNotFound is returned when a file has no line matching the anchor
+var ErrMarkerNotFound = errors.New("marker not found")
+
+// Anchor is the line a snippet is inserted next to
+type Anchor struct {
+	Marker string // Text the line contains, or the expression it matches
+	Regex  bool   // Marker is a regular expression
+	After  bool   // Insert after the line rather than before it
+}
+
+// String describes the anchor for errors
+func (a Anchor) String() string {
+	position := "before"
+	if a.After {
+		position = "after"
+	}
+	if a.Regex {
+		return fmt.Sprintf("%s /%s/", position, a.Marker)
+	}
+	return fmt.Sprintf("%s %q", position, a.Marker)
+}
+
+// Into inserts snippet into content on its own lines next to the first line
+// matching anchor, indented like that line. It reports whether content
+// changed: a snippet whose lines are already in content, ignoring
+// indentation, is not inserted again. A missing anchor is ErrMarkerNotFound.
+func Into(content []byte, snippet string, anchor Anchor) ([]byte, bool, error) {
+	snippet = strings.Trim(snippet, "\r\n")
+	if strings.TrimSpace(snippet) == "" {
+		return content, false, nil
+	}
+	lines := strings.Split(string(content), "\n")
+	snippetLines := strings.Split(snippet, "\n")
+	if contains(lines, snippetLines) {
+		return content, false, nil
+	}
+
+	at, err := find(string(content), lines, anchor)
+	if err != nil {
+		return nil, false, err
+	}
+	indent := lines[at][:len(lines[at])-len(strings.TrimLeft(lines[at], " \t"))]
+	inserted := make([]string, len(snippetLines))
+	for i, line := range snippetLines {
+		if strings.TrimSpace(line) != "" {
+			line = indent + line
+		}
+		inserted[i] = line
+	}
+	if anchor.After {
+		at++
+	}
+
+	out := make([]string, 0, len(lines)+len(inserted))
+	out = append(out, lines[:at]...)
+	out = append(out, inserted...)
+	out = append(out, lines[at:]...)
+	return []byte(strings.Join(out, "\n")), true, nil
+}
+
+// find returns the index of the line anchor points at: the first line
+// containing the marker, or for an expression the line its first match
+// starts on, or ends on when inserting after it
+func find(content string, lines []string, anchor Anchor) (int, error) {
+	if !anchor.Regex {
+		for i, line := range lines {
+			if strings.Contains(line, anchor.Marker) {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("%w: %s", ErrMarkerNotFound, anchor.Marker)
+	}
+
+	re, err := regexp.Compile("(?m)" + anchor.Marker)
+	if err != nil {
+		return 0, fmt.Errorf("invalid anchor %s: %w", anchor, err)
+	}
+	match := re.FindStringIndex(content)
+	if match == nil {
+		return 0, fmt.Errorf("%w: /%s/", ErrMarkerNotFound, anchor.Marker)
+	}
+	offset := match[0]
+	if anchor.After && match[1] > match[0] {
+		offset = match[1] - 1
+	}
+	return strings.Count(content[:offset], "\n"), nil
+}
+
+// contains reports whether the non-blank lines of snippet appear in lines in
+// a row, ignoring indentation and blank lines
+func contains(lines, snippet []string) bool {
+	want := significant(snippet)
+	have := significant(lines)
+	if len(want) == 0 {
+		return true
+	}
+	for i := 0; i+len(want) <= len(have); i++ {
+		if slices.Equal(have[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
+
+// significant returns the non-blank lines, trimmed
+func significant(lines []string) []string {
+	var out []string
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
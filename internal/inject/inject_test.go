@@ -0,0 +1,94 @@
+package inject
+
+import (
+	"errors"
+	"testing"
+)
+
+const routes = `package routes
+
+func Setup(r *Router) {
+	r.GET("/", Home)
+	// ritual:routes
+}
+`
+
+func TestInto(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		anchor  Anchor
+		want    string
+	}{
+		{
+			name:    "before marker, indented like it",
+			snippet: "r.GET(\"/posts\", Posts)\n",
+			anchor:  Anchor{Marker: MarkerRoutes},
+			want:    "package routes\n\nfunc Setup(r *Router) {\n\tr.GET(\"/\", Home)\n\tr.GET(\"/posts\", Posts)\n\t// ritual:routes\n}\n",
+		},
+		{
+			name:    "after marker",
+			snippet: "r.GET(\"/posts\", Posts)",
+			anchor:  Anchor{Marker: MarkerRoutes, After: true},
+			want:    "package routes\n\nfunc Setup(r *Router) {\n\tr.GET(\"/\", Home)\n\t// ritual:routes\n\tr.GET(\"/posts\", Posts)\n}\n",
+		},
+		{
+			name:    "after regex",
+			snippet: "// Routes are set up below",
+			anchor:  Anchor{Marker: `^func Setup\(`, Regex: true, After: true},
+			want:    "package routes\n\nfunc Setup(r *Router) {\n// Routes are set up below\n\tr.GET(\"/\", Home)\n\t// ritual:routes\n}\n",
+		},
+		{
+			name:    "before regex",
+			snippet: "import \"net/http\"\n",
+			anchor:  Anchor{Marker: `^func `, Regex: true},
+			want:    "package routes\n\nimport \"net/http\"\nfunc Setup(r *Router) {\n\tr.GET(\"/\", Home)\n\t// ritual:routes\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed, err := Into([]byte(routes), tt.snippet, tt.anchor)
+			if err != nil {
+				t.Fatalf("Into() error = %v", err)
+			}
+			if !changed || string(got) != tt.want {
+				t.Errorf("Into() = %v, %q, want %q", changed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInto_Idempotent(t *testing.T) {
+	snippet := "// Posts\nr.GET(\"/posts\", Posts)\n\nr.POST(\"/posts\", CreatePost)"
+	anchor := Anchor{Marker: MarkerRoutes}
+
+	once, changed, err := Into([]byte(routes), snippet, anchor)
+	if err != nil || !changed {
+		t.Fatalf("Into() = %v, %v", changed, err)
+	}
+	twice, changed, err := Into(once, snippet, anchor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || string(twice) != string(once) {
+		t.Errorf("a snippet already present should not be inserted again, got %q", twice)
+	}
+
+	// Present with other indentation
+	if _, changed, _ := Into([]byte(routes), "  r.GET(\"/\", Home)", anchor); changed {
+		t.Error("a snippet present with other indentation should not be inserted")
+	}
+}
+
+func TestInto_MissingMarker(t *testing.T) {
+	for _, anchor := range []Anchor{{Marker: "// ritual:middleware"}, {Marker: `^type \w+`, Regex: true}} {
+		_, _, err := Into([]byte(routes), "r.Use(Logger)", anchor)
+		if !errors.Is(err, ErrMarkerNotFound) {
+			t.Errorf("Into(%s) error = %v, want ErrMarkerNotFound", anchor, err)
+		}
+	}
+	if _, _, err := Into([]byte(routes), "x", Anchor{Marker: "(", Regex: true}); err == nil || errors.Is(err, ErrMarkerNotFound) {
+		t.Errorf("an invalid expression should be reported as such, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package questionnaire
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -54,6 +55,10 @@ func (a *CLIAdapter) Run() (map[string]interface{}, error) {
 		// Ask the question with retry on error
 		for {
 			answer, err := a.askQuestion(question)
+			if errors.Is(err, io.EOF) {
+				// The input ended: asking again would never get an answer
+				return nil, fmt.Errorf("no answer for %s: %w", question.Name, err)
+			}
 			if err != nil {
 				// Show error and retry
 				_, _ = fmt.Fprintf(a.writer, "Error: %v\n", err)
@@ -93,6 +98,8 @@ func (a *CLIAdapter) askQuestion(q *ritual.Question) (interface{}, error) {
 		prompt := q.Prompt
 		if q.Default != nil {
 			prompt = fmt.Sprintf("%s [%v]", prompt, q.Default)
+		} else if q.Generate != nil {
+			prompt = fmt.Sprintf("%s [generate]", prompt)
 		}
 		_, _ = fmt.Fprintf(a.writer, "%s: ", prompt)
 	}
@@ -1,6 +1,8 @@
 package questionnaire
 
 import (
+	"errors"
+	"io"
 	"strings"
 	"testing"
 
@@ -53,6 +55,25 @@ func TestCLIAdapter_RequiredQuestion(t *testing.T) {
 	}
 }
 
+func TestCLIAdapter_InputEnds(t *testing.T) {
+	questions := []ritual.Question{
+		{
+			Name:     "email",
+			Prompt:   "Email:",
+			Type:     ritual.QuestionTypeEmail,
+			Required: true,
+		},
+	}
+
+	// An invalid answer is asked again, but the end of the input is an error
+	adapter := NewCLIAdapter(questions, strings.NewReader("\n"))
+	adapter.SetWriter(io.Discard)
+
+	if _, err := adapter.Run(); !errors.Is(err, io.EOF) {
+		t.Errorf("Run() error = %v, want io.EOF", err)
+	}
+}
+
 func TestCLIAdapter_ChoiceQuestion(t *testing.T) {
 	questions := []ritual.Question{
 		{
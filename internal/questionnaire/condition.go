@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/condition"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
 
@@ -93,102 +94,9 @@ func (ce *ConditionEvaluator) compareValues(actual, expected interface{}) (bool,
 	return actualStr == expectedStr, nil
 }
 
-// evaluateExpression evaluates a simple expression like "database_type == 'postgres'"
+// evaluateExpression evaluates an expression like "database_type == 'postgres' && port > 1024"
 func (ce *ConditionEvaluator) evaluateExpression(expr string, answers map[string]interface{}) (bool, error) {
-	expr = strings.TrimSpace(expr)
-
-	// Handle equality: field == value
-	if strings.Contains(expr, "==") {
-		parts := strings.SplitN(expr, "==", 2)
-		if len(parts) != 2 {
-			return false, fmt.Errorf("invalid expression: %s", expr)
-		}
-
-		field := strings.TrimSpace(parts[0])
-		expectedVal := strings.Trim(strings.TrimSpace(parts[1]), "'\"")
-
-		actualVal, exists := answers[field]
-		if !exists {
-			return false, nil
-		}
-
-		return ce.compareValues(actualVal, expectedVal)
-	}
-
-	// Handle inequality: field != value
-	if strings.Contains(expr, "!=") {
-		parts := strings.SplitN(expr, "!=", 2)
-		if len(parts) != 2 {
-			return false, fmt.Errorf("invalid expression: %s", expr)
-		}
-
-		field := strings.TrimSpace(parts[0])
-		expectedVal := strings.Trim(strings.TrimSpace(parts[1]), "'\"")
-
-		actualVal, exists := answers[field]
-		if !exists {
-			return true, nil
-		}
-
-		result, err := ce.compareValues(actualVal, expectedVal)
-		if err != nil {
-			return false, err
-		}
-		return !result, nil
-	}
-
-	// Handle simple boolean field reference
-	if matched, _ := regexp.MatchString(`^[a-zA-Z_][a-zA-Z0-9_]*$`, expr); matched {
-		val, exists := answers[expr]
-		if !exists {
-			return false, nil
-		}
-
-		// Convert to boolean
-		return ce.toBool(val), nil
-	}
-
-	// Handle AND operator
-	if strings.Contains(expr, " && ") || strings.Contains(expr, " AND ") {
-		sep := " && "
-		if strings.Contains(expr, " AND ") {
-			sep = " AND "
-		}
-
-		parts := strings.Split(expr, sep)
-		for _, part := range parts {
-			result, err := ce.evaluateExpression(part, answers)
-			if err != nil {
-				return false, err
-			}
-			if !result {
-				return false, nil
-			}
-		}
-		return true, nil
-	}
-
-	// Handle OR operator
-	if strings.Contains(expr, " || ") || strings.Contains(expr, " OR ") {
-		sep := " || "
-		if strings.Contains(expr, " OR ") {
-			sep = " OR "
-		}
-
-		parts := strings.Split(expr, sep)
-		for _, part := range parts {
-			result, err := ce.evaluateExpression(part, answers)
-			if err != nil {
-				return false, err
-			}
-			if result {
-				return true, nil
-			}
-		}
-		return false, nil
-	}
-
-	return false, fmt.Errorf("unsupported expression: %s", expr)
+	return condition.Evaluate(expr, answers)
 }
 
 func (ce *ConditionEvaluator) toBool(val interface{}) bool {
@@ -409,14 +409,58 @@ func TestConditionEvaluator_Expression_ComplexLogic(t *testing.T) {
 	}
 }
 
+func TestConditionEvaluator_Expression_Operators(t *testing.T) {
+	eval := NewConditionEvaluator()
+	answers := map[string]interface{}{
+		"a":        1,
+		"b":        true,
+		"field":    false,
+		"port":     "5432",
+		"database": "postgres",
+		"features": []string{"auth", "search"},
+	}
+
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{"!field", true},
+		{"a > 5", false},
+		{"a < 10", true},
+		{"(a && b)", true},
+		{"port >= 1024", true},
+		{"!(field || a == 2) && b", true},
+		{"database in ['postgres', 'mysql']", true},
+		{"'auth' in features && !('admin' in features)", true},
+		{"length(features) == 2 and startswith(database, 'post')", true},
+		{"b == true", true},
+		{"b == 'true'", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			condition := &ritual.QuestionCondition{
+				Expression: tt.expression,
+			}
+			result, err := eval.Evaluate(condition, answers)
+			if err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
 func TestConditionEvaluator_Expression_InvalidSyntax(t *testing.T) {
 	eval := NewConditionEvaluator()
 
 	tests := []string{
-		"!field",   // NOT operator not supported
-		"a > 5",    // Greater than not supported
-		"a < 10",   // Less than not supported
-		"(a && b)", // Parentheses not supported
+		"a &&",          // Missing operand
+		"(a && b",       // Unbalanced parentheses
+		"a == = 5",      // Stray operator
+		"unknown_fn(a)", // Unknown function
 	}
 
 	for _, expr := range tests {
@@ -425,7 +469,7 @@ func TestConditionEvaluator_Expression_InvalidSyntax(t *testing.T) {
 		}
 		_, err := eval.Evaluate(condition, map[string]interface{}{"a": 1, "b": true, "field": false})
 		if err == nil {
-			t.Errorf("Expected error for unsupported expression: %s", expr)
+			t.Errorf("Expected error for invalid expression: %s", expr)
 		}
 	}
 }
@@ -115,6 +115,33 @@ func (p *AnswerPersistence) LoadWithSecrets(secretEnvMapping map[string]string)
 	return answers, nil
 }
 
+// LoadRestoringSecrets loads answers and restores each masked secret from its
+// environment variable. Unlike LoadWithSecrets, a secret whose variable is not
+// set stays masked rather than being dropped.
+func (p *AnswerPersistence) LoadRestoringSecrets() (map[string]interface{}, error) {
+	answers, err := p.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range answers {
+		if !IsMaskedSecret(value) {
+			continue
+		}
+		if secret, exists := os.LookupEnv(toEnvVarName(key)); exists {
+			answers[key] = secret
+		}
+	}
+
+	return answers, nil
+}
+
+// IsMaskedSecret reports whether a loaded answer is a secret masked by SaveWithSecrets
+func IsMaskedSecret(value interface{}) bool {
+	text, ok := value.(string)
+	return ok && strings.HasPrefix(text, SecretPlaceholder)
+}
+
 // Exists checks if the answers file exists
 func (p *AnswerPersistence) Exists() bool {
 	_, err := os.Stat(p.filePath)
@@ -34,13 +34,13 @@ func (v *Validator) RegisterCustomValidator(name string, fn ValidationFunc) {
 
 // ValidateAnswer validates an answer against question constraints
 func (v *Validator) ValidateAnswer(question *ritual.Question, value interface{}) error {
-	// Check required
-	if question.Required && v.isEmpty(value) {
+	// Check required; a generated answer may be left empty
+	if question.Required && question.Generate == nil && v.isEmpty(value) {
 		return fmt.Errorf("answer is required")
 	}
 
 	// Skip further validation if empty and not required
-	if !question.Required && v.isEmpty(value) {
+	if v.isEmpty(value) {
 		return nil
 	}
 
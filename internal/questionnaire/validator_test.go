@@ -427,3 +427,19 @@ func TestValidateWritablePath(t *testing.T) {
 		})
 	}
 }
+
+func TestValidator_RequiredGeneratedField(t *testing.T) {
+	validator := NewValidator()
+
+	question := &ritual.Question{
+		Name:     "db_password",
+		Required: true,
+		Type:     ritual.QuestionTypePassword,
+		Generate: &ritual.Generate{Type: ritual.GenerateAlnum},
+	}
+
+	// Empty value is generated later
+	if err := validator.ValidateAnswer(question, ""); err != nil {
+		t.Errorf("Unexpected error for a generated field: %v", err)
+	}
+}
@@ -1,12 +1,10 @@
 package registry
 
 import (
-	"archive/tar"
-	"compress/gzip"
 	"fmt"
-	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -33,11 +31,20 @@ type RitualMetadata struct {
 	Description   string
 	Author        string
 	Tags          []string
-	Path          string
+	Path          string         // Ritual directory, tarball, or "embedded:<name>"
+	Files         *ritual.Source // Reads the ritual's files in place
 	Source        Source
 	Compatibility *ritual.Compatibility
 }
 
+// RitualSource returns the ritual's files, falling back to the directory at Path
+func (m *RitualMetadata) RitualSource() *ritual.Source {
+	if m.Files != nil {
+		return m.Files
+	}
+	return ritual.DirSource(m.Path)
+}
+
 // Registry manages ritual discovery and loading
 type Registry struct {
 	searchPaths []string
@@ -64,13 +71,8 @@ func (r *Registry) AddSearchPath(path string) {
 
 // Scan discovers all available rituals in search paths
 func (r *Registry) Scan() error {
-	// Ensure cache directory exists
-	if err := os.MkdirAll(r.cacheDir, 0750); err != nil {
-		return fmt.Errorf("failed to create cache directory: %w", err)
-	}
-
-	// First, scan embedded rituals
-	if err := r.scanEmbedded(); err != nil {
+	// First, index embedded rituals; they are read straight from the binary
+	if err := r.indexEmbedded(embedded.GetFS()); err != nil {
 		// Log but don't fail on embedded ritual errors
 		_ = err
 	}
@@ -100,7 +102,7 @@ func (r *Registry) Scan() error {
 				}
 			} else if strings.HasSuffix(entry.Name(), ".tar.gz") || strings.HasSuffix(entry.Name(), ".tgz") {
 				// Handle tarball
-				if err := r.handleTarball(entryPath); err != nil {
+				if err := r.indexTarball(entryPath); err != nil {
 					// Log but don't fail
 					continue
 				}
@@ -111,55 +113,27 @@ func (r *Registry) Scan() error {
 	return nil
 }
 
-// scanEmbedded scans and indexes embedded rituals
-func (r *Registry) scanEmbedded() error {
-	ritualNames, err := embedded.List()
+// indexEmbedded indexes the rituals in an embedded filesystem without extracting
+// them, so they always match the binary and nothing is written to disk
+func (r *Registry) indexEmbedded(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return fmt.Errorf("failed to list embedded rituals: %w", err)
 	}
 
-	// Extract embedded rituals to cache if not already present or outdated
-	embeddedDir := filepath.Join(r.cacheDir, "embedded")
-	
-	// Extract _shared directory if it exists in embedded files
-	sharedPath := filepath.Join(embeddedDir, "_shared")
-	if _, err := os.Stat(filepath.Join(sharedPath, "docker")); os.IsNotExist(err) {
-		// Extract _shared directory
-		if err := r.extractEmbeddedShared(embeddedDir); err != nil {
-			// Log but don't fail if _shared can't be extracted
-			_ = err
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == ritual.SharedDir {
+			continue
 		}
-	}
-
-	for _, name := range ritualNames {
-		ritualPath := filepath.Join(embeddedDir, name)
-
-		// Check if need to extract/re-extract
-		needsExtraction := false
-
-		ritualFile := filepath.Join(ritualPath, "ritual.yaml")
-		if _, err := os.Stat(ritualFile); os.IsNotExist(err) {
-			// Doesn't exist, need to extract
-			needsExtraction = true
-		} else {
-			// Exists, check if version matches embedded version
-			needsExtraction = r.shouldReExtract(ritualPath, name)
+		if _, err := fs.Stat(fsys, path.Join(entry.Name(), "ritual.yaml")); err != nil {
+			continue // Not a ritual
 		}
 
-		if needsExtraction {
-			// Remove old version if exists
-			if err := os.RemoveAll(ritualPath); err != nil && !os.IsNotExist(err) {
-				continue
-			}
-
-			// Extract this ritual
-			if err := r.extractEmbeddedRitual(name, embeddedDir); err != nil {
-				continue // Skip rituals that fail to extract
-			}
+		src, err := ritual.NewSource(fsys, entry.Name(), "embedded:"+entry.Name())
+		if err != nil {
+			continue
 		}
-
-		// Index the ritual
-		if err := r.indexRitual(ritualPath, SourceEmbedded); err != nil {
+		if err := r.indexSource(src, SourceEmbedded); err != nil {
 			continue // Skip rituals that fail to index
 		}
 	}
@@ -167,264 +141,52 @@ func (r *Registry) scanEmbedded() error {
 	return nil
 }
 
-// extractEmbeddedRitual extracts a single embedded ritual to the cache
-func (r *Registry) extractEmbeddedRitual(name, destDir string) error {
-	ritualFS := embedded.GetFS()
-	ritualPath := filepath.Join(destDir, name)
-
-	// Ensure destination exists
-	if err := os.MkdirAll(ritualPath, 0750); err != nil {
-		return fmt.Errorf("failed to create ritual directory: %w", err)
-	}
-
-	// Walk the embedded ritual files
-	return fs.WalkDir(ritualFS, name, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		destPath := filepath.Join(destDir, path)
-
-		if d.IsDir() {
-			return os.MkdirAll(destPath, 0750)
-		}
-
-		// Create parent directory
-		if err := os.MkdirAll(filepath.Dir(destPath), 0750); err != nil {
-			return err
-		}
-
-		// Copy file
-		srcFile, err := ritualFS.Open(path)
-		if err != nil {
-			return err
-		}
-		defer func() {
-			if cerr := srcFile.Close(); cerr != nil && err == nil {
-				err = cerr
-			}
-		}()
-
-		// #nosec G304 - destPath is validated above
-		destFile, err := os.Create(destPath)
-		if err != nil {
-			return err
-		}
-		defer func() {
-			if cerr := destFile.Close(); cerr != nil && err == nil {
-				err = cerr
-			}
-		}()
-
-		_, err = io.Copy(destFile, srcFile)
-		return err
-	})
-}
-
-// extractEmbeddedShared extracts the _shared directory from embedded files
-func (r *Registry) extractEmbeddedShared(destDir string) error {
-	ritualFS := embedded.GetFS()
-	sharedPath := filepath.Join(destDir, "_shared")
-	
-	// Check if _shared exists in embedded FS
-	_, err := fs.Stat(ritualFS, "_shared")
+// indexTarball indexes a ritual tarball from its ritual.yaml alone. The rest
+// is read into memory when the ritual's files are first used.
+// The ritual may sit at the root of the tarball or in a top-level directory.
+func (r *Registry) indexTarball(tarballPath string) error {
+	found, err := scanTarball(tarballPath)
 	if err != nil {
-		// _shared doesn't exist in embedded files
-		return nil
-	}
-	
-	// Ensure destination exists
-	if err := os.MkdirAll(sharedPath, 0750); err != nil {
-		return fmt.Errorf("failed to create _shared directory: %w", err)
+		return fmt.Errorf("failed to read tarball %s: %w", tarballPath, err)
 	}
-	
-	// Walk the _shared directory
-	return fs.WalkDir(ritualFS, "_shared", func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-		
-		destPath := filepath.Join(destDir, path)
-		
-		if d.IsDir() {
-			return os.MkdirAll(destPath, 0750)
-		}
-		
-		// Create parent directory
-		if err := os.MkdirAll(filepath.Dir(destPath), 0750); err != nil {
-			return err
-		}
-		
-		// Copy file
-		srcFile, err := ritualFS.Open(path)
-		if err != nil {
-			return err
-		}
-		defer func() {
-			if cerr := srcFile.Close(); cerr != nil && err == nil {
-				err = cerr
-			}
-		}()
-		
-		// #nosec G304 - destPath is validated above
-		destFile, err := os.Create(destPath)
-		if err != nil {
-			return err
-		}
-		defer func() {
-			if cerr := destFile.Close(); cerr != nil && err == nil {
-				err = cerr
-			}
-		}()
-		
-		_, err = io.Copy(destFile, srcFile)
-		return err
-	})
-}
-
-// handleTarball extracts and indexes a ritual tarball
-func (r *Registry) handleTarball(tarballPath string) error {
-	// Extract tarball
-	extractedPath, err := r.extractTarball(tarballPath)
+	manifest, err := ritual.LoadFromBytes(found.data)
 	if err != nil {
-		return fmt.Errorf("failed to extract tarball: %w", err)
+		return fmt.Errorf("%s: %w", tarballPath, err)
 	}
 
-	// Index the extracted ritual
-	return r.indexRitual(extractedPath, SourceTarball)
-}
-
-// extractTarball extracts a tarball to the cache directory
-func (r *Registry) extractTarball(tarballPath string) (string, error) {
-	// Open tarball file
-	// #nosec G304 - tarballPath is from validated ritual source
-	file, err := os.Open(tarballPath)
+	tarball := &lazyTarFS{path: tarballPath}
+	root, err := fs.Sub(tarball, found.dir)
 	if err != nil {
-		return "", fmt.Errorf("failed to open tarball: %w", err)
+		return fmt.Errorf("failed to open ritual %s: %w", tarballPath, err)
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			// Log but don't fail on close error
+	src := &ritual.Source{FS: root, Path: tarballPath}
+	if found.shared {
+		if src.Shared, err = fs.Sub(tarball, ritual.SharedDir); err != nil {
+			return fmt.Errorf("failed to open %s for ritual %s: %w", ritual.SharedDir, tarballPath, err)
 		}
-	}()
-
-	// Create gzip reader
-	gzReader, err := gzip.NewReader(file)
-	if err != nil {
-		return "", fmt.Errorf("failed to create gzip reader: %w", err)
 	}
-	defer func() {
-		if err := gzReader.Close(); err != nil {
-			// Log but don't fail on close error
-		}
-	}()
-
-	// Create tar reader
-	tarReader := tar.NewReader(gzReader)
-
-	// Determine extraction directory
-	baseName := filepath.Base(tarballPath)
-	baseName = strings.TrimSuffix(baseName, ".tar.gz")
-	baseName = strings.TrimSuffix(baseName, ".tgz")
-	extractDir := filepath.Join(r.cacheDir, baseName)
-
-	// Check if already extracted
-	if _, err := os.Stat(extractDir); err == nil {
-		// Already extracted, check if it has ritual.yaml
-		ritualFile := filepath.Join(extractDir, "ritual.yaml")
-		if _, err := os.Stat(ritualFile); err == nil {
-			return extractDir, nil
-		}
-	}
-
-	// Create extraction directory
-	if err := os.MkdirAll(extractDir, 0750); err != nil {
-		return "", fmt.Errorf("failed to create extraction directory: %w", err)
-	}
-
-	// Extract all files
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return "", fmt.Errorf("failed to read tar header: %w", err)
-		}
-
-		// Build target path
-		// #nosec G305 - Path traversal is prevented by HasPrefix check below
-		target := filepath.Join(extractDir, header.Name)
-
-		// Ensure target is within extractDir (prevent path traversal)
-		if !strings.HasPrefix(target, extractDir) {
-			continue
-		}
-
-		switch header.Typeflag {
-		case tar.TypeDir:
-			if err := os.MkdirAll(target, 0750); err != nil {
-				return "", fmt.Errorf("failed to create directory: %w", err)
-			}
-		case tar.TypeReg:
-			// Ensure parent directory exists
-			if err := os.MkdirAll(filepath.Dir(target), 0750); err != nil {
-				return "", fmt.Errorf("failed to create parent directory: %w", err)
-			}
-
-			// Create file
-			// #nosec G304 - Path is validated above with HasPrefix check
-			outFile, err := os.Create(target)
-			if err != nil {
-				return "", fmt.Errorf("failed to create file: %w", err)
-			}
-
-			// Limit extraction size to prevent decompression bombs
-			const maxFileSize = 100 * 1024 * 1024 // 100MB per file
-			limitedReader := io.LimitReader(tarReader, maxFileSize)
-
-			// #nosec G110 - Size limited to 100MB to prevent decompression bombs
-			if _, err := io.Copy(outFile, limitedReader); err != nil {
-				_ = outFile.Close()
-				return "", fmt.Errorf("failed to write file: %w", err)
-			}
-
-			if err := outFile.Close(); err != nil {
-				return "", fmt.Errorf("failed to close file: %w", err)
-			}
-		}
-	}
-
-	// Check if ritual.yaml is in a subdirectory (nested tarball)
-	ritualFile := filepath.Join(extractDir, "ritual.yaml")
-	if _, err := os.Stat(ritualFile); os.IsNotExist(err) {
-		// Look for ritual.yaml in subdirectories (one level deep)
-		entries, err := os.ReadDir(extractDir)
-		if err == nil {
-			for _, entry := range entries {
-				if entry.IsDir() {
-					nestedPath := filepath.Join(extractDir, entry.Name(), "ritual.yaml")
-					if _, err := os.Stat(nestedPath); err == nil {
-						// Found in nested directory, use that as extract dir
-						return filepath.Join(extractDir, entry.Name()), nil
-					}
-				}
-			}
-		}
-	}
-
-	return extractDir, nil
+	r.indexManifest(manifest, src, SourceTarball)
+	return nil
 }
 
-// indexRitual loads and caches metadata for a ritual
+// indexRitual loads and caches metadata for a ritual directory
 func (r *Registry) indexRitual(path string, source Source) error {
+	return r.indexSource(ritual.DirSource(path), source)
+}
+
+// indexSource loads and caches metadata for a ritual source
+func (r *Registry) indexSource(src *ritual.Source, source Source) error {
 	// Load ritual manifest
-	loader := ritual.NewLoader(path)
-	manifest, err := loader.Load(path)
+	manifest, err := src.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load ritual: %w", err)
 	}
+	r.indexManifest(manifest, src, source)
+	return nil
+}
 
+// indexManifest caches the metadata of a loaded ritual
+func (r *Registry) indexManifest(manifest *ritual.Manifest, src *ritual.Source, source Source) {
 	// Create metadata
 	meta := &RitualMetadata{
 		Name:          manifest.Ritual.Name,
@@ -432,15 +194,14 @@ func (r *Registry) indexRitual(path string, source Source) error {
 		Description:   manifest.Ritual.Description,
 		Author:        manifest.Ritual.Author,
 		Tags:          manifest.Ritual.Tags,
-		Path:          path,
+		Path:          src.Path,
+		Files:         src,
 		Source:        source,
 		Compatibility: &manifest.Compatibility,
 	}
 
 	// Add to registry
 	r.rituals[meta.Name] = meta
-
-	return nil
 }
 
 // Get retrieves metadata for a specific ritual
@@ -459,8 +220,7 @@ func (r *Registry) Load(name string) (*ritual.Manifest, error) {
 		return nil, err
 	}
 
-	loader := ritual.NewLoader(meta.Path)
-	return loader.Load(meta.Path)
+	return meta.RitualSource().Load()
 }
 
 // List returns all available rituals
@@ -610,7 +370,8 @@ return fmt.Errorf("failed to recreate cache directory: %w", err)
 return nil
 }
 
-// ClearEmbeddedCache removes only the embedded ritual cache
+// ClearEmbeddedCache removes embedded rituals extracted by older versions,
+// which read them from the cache instead of the binary
 func (r *Registry) ClearEmbeddedCache() error {
 embeddedDir := filepath.Join(r.cacheDir, "embedded")
 
@@ -642,45 +403,6 @@ return 0, fmt.Errorf("failed to calculate cache size: %w", err)
 return size, nil
 }
 
-// shouldReExtract checks if cached ritual should be re-extracted
-func (r *Registry) shouldReExtract(cachedPath, ritualName string) bool {
-// Load cached manifest
-loader := ritual.NewLoader(cachedPath)
-cachedManifest, err := loader.Load(cachedPath)
-if err != nil {
-// Can't load cached version, re-extract
-return true
-}
-
-// Load embedded manifest (from memory)
-ritualFS := embedded.GetFS()
-manifestPath := filepath.Join(ritualName, "ritual.yaml")
-
-manifestFile, err := ritualFS.Open(manifestPath)
-if err != nil {
-// Can't read embedded version, keep cached
-return false
-}
-defer manifestFile.Close()
-
-// Read manifest data
-manifestData, err := io.ReadAll(manifestFile)
-if err != nil {
-// Can't read embedded version, keep cached
-return false
-}
-
-embeddedManifest, err := ritual.LoadFromBytes(manifestData)
-if err != nil {
-// Can't parse embedded version, keep cached
-return false
-}
-
-// Compare versions - re-extract if different
-return cachedManifest.Ritual.Version != embeddedManifest.Ritual.Version
-}
-
-
 // GetCachePath returns the cache directory path
 func (r *Registry) GetCachePath() string {
 return r.cacheDir
@@ -1,10 +1,12 @@
 package registry
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
@@ -286,3 +288,71 @@ func createTestRitual(dir, name, version string) error {
 
 	return os.WriteFile(ritualFile, yamlContent, 0600)
 }
+
+func TestIndexEmbedded_ReadsInPlace(t *testing.T) {
+	fsys := fstest.MapFS{
+		"blog/ritual.yaml":          {Data: []byte("ritual:\n  name: blog\n  version: 2.0.0\n")},
+		"blog/templates/main.tmpl":  {Data: []byte("package main\n")},
+		"notes/README.md":           {Data: []byte("not a ritual")},
+		"_shared/docker/Dockerfile": {Data: []byte("FROM golang\n")},
+	}
+
+	tmpDir := t.TempDir()
+	reg := &Registry{
+		rituals:  make(map[string]*RitualMetadata),
+		cacheDir: filepath.Join(tmpDir, "ritual-cache"),
+	}
+	if err := reg.indexEmbedded(fsys); err != nil {
+		t.Fatalf("indexEmbedded failed: %v", err)
+	}
+
+	if len(reg.rituals) != 1 {
+		t.Fatalf("expected 1 ritual, got %d", len(reg.rituals))
+	}
+	meta, err := reg.Get("blog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Source != SourceEmbedded || meta.Path != "embedded:blog" {
+		t.Errorf("unexpected metadata: source %s, path %s", meta.Source, meta.Path)
+	}
+	if _, err := fs.Stat(meta.Files.Shared, "docker/Dockerfile"); err != nil {
+		t.Errorf("expected _shared to be available: %v", err)
+	}
+
+	manifest, err := reg.Load("blog")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if manifest.Ritual.Version != "2.0.0" {
+		t.Errorf("expected version 2.0.0, got %s", manifest.Ritual.Version)
+	}
+
+	// Nothing is extracted to the cache
+	if _, err := os.Stat(reg.cacheDir); !os.IsNotExist(err) {
+		t.Error("cache directory should not be created")
+	}
+}
+
+func TestScan_DoesNotWriteCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	reg := &Registry{
+		rituals:  make(map[string]*RitualMetadata),
+		cacheDir: filepath.Join(tmpDir, "ritual-cache"),
+	}
+
+	if err := reg.Scan(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(reg.rituals) == 0 {
+		t.Fatal("expected embedded rituals after scan")
+	}
+	for _, meta := range reg.rituals {
+		if meta.Source == SourceEmbedded && meta.Files.Dir() != "" {
+			t.Errorf("embedded ritual %s served from disk at %s", meta.Name, meta.Files.Dir())
+		}
+	}
+	if _, err := os.Stat(reg.cacheDir); !os.IsNotExist(err) {
+		t.Error("Scan should not create the cache directory")
+	}
+}
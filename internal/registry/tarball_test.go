@@ -3,6 +3,7 @@ package registry
 import (
 	"archive/tar"
 	"compress/gzip"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"testing"
@@ -20,12 +21,12 @@ func TestTarballDiscovery(t *testing.T) {
 	reg := NewRegistry()
 	reg.searchPaths = []string{tmpDir}
 
-	// Scan should discover and extract the tarball
+	// Scan should discover and read the tarball
 	if err := reg.Scan(); err != nil {
 		t.Fatalf("Scan failed: %v", err)
 	}
 
-	// Check that the ritual was extracted and cached
+	// Check that the ritual was indexed
 	meta, err := reg.Get("test-ritual")
 	if err != nil {
 		t.Errorf("Expected ritual to be discovered from tarball: %v", err)
@@ -99,7 +100,7 @@ func TestInvalidTarball(t *testing.T) {
 	}
 }
 
-func TestTarballExtraction(t *testing.T) {
+func TestTarballReadInPlace(t *testing.T) {
 	tmpDir := t.TempDir()
 	tarballPath := filepath.Join(tmpDir, "extract-test.tar.gz")
 
@@ -112,22 +113,30 @@ func TestTarballExtraction(t *testing.T) {
 	cacheDir := filepath.Join(tmpDir, "cache")
 	reg.cacheDir = cacheDir
 
-	// Extract the tarball
-	extractedPath, err := reg.extractTarball(tarballPath)
+	if err := reg.indexTarball(tarballPath); err != nil {
+		t.Fatalf("indexTarball failed: %v", err)
+	}
+
+	meta, err := reg.Get("complex-ritual")
 	if err != nil {
-		t.Fatalf("extractTarball failed: %v", err)
+		t.Fatal(err)
+	}
+	if meta.Path != tarballPath {
+		t.Errorf("Expected path %s, got %s", tarballPath, meta.Path)
 	}
 
-	// Verify ritual.yaml exists in extracted location
-	ritualFile := filepath.Join(extractedPath, "ritual.yaml")
-	if _, err := os.Stat(ritualFile); os.IsNotExist(err) {
-		t.Error("ritual.yaml not found in extracted directory")
+	// Templates are read from the tarball itself
+	content, err := fs.ReadFile(meta.Files.FS, "templates/main.tmpl")
+	if err != nil {
+		t.Fatalf("template not readable from tarball: %v", err)
+	}
+	if string(content) != "# Template\nHello {{ .Name }}" {
+		t.Errorf("unexpected template content %q", content)
 	}
 
-	// Verify templates directory exists
-	templatesDir := filepath.Join(extractedPath, "templates")
-	if _, err := os.Stat(templatesDir); os.IsNotExist(err) {
-		t.Error("templates directory not found in extracted directory")
+	// Nothing is extracted
+	if _, err := os.Stat(cacheDir); !os.IsNotExist(err) {
+		t.Error("cache directory should not be created")
 	}
 }
 
@@ -144,21 +153,20 @@ func TestTarballCaching(t *testing.T) {
 	reg.cacheDir = cacheDir
 	reg.searchPaths = []string{tmpDir}
 
-	// First scan - should extract
+	// First scan - reads the tarball
 	if err := reg.Scan(); err != nil {
 		t.Fatalf("First scan failed: %v", err)
 	}
 
-	// Get extracted path
+	// The ritual is served from the tarball itself
 	meta, _ := reg.Get("cached-ritual")
 	extractedPath := meta.Path
 
-	// Verify extracted directory exists
-	if _, err := os.Stat(extractedPath); os.IsNotExist(err) {
-		t.Error("Extracted directory should exist after first scan")
+	if extractedPath != tarballPath {
+		t.Errorf("Expected path %s, got %s", tarballPath, extractedPath)
 	}
 
-	// Second scan - should use cached version
+	// Second scan - reads the tarball again
 	if err := reg.Scan(); err != nil {
 		t.Fatalf("Second scan failed: %v", err)
 	}
@@ -184,23 +192,120 @@ func TestTarballWithNestedDirectory(t *testing.T) {
 	}
 
 	reg := NewRegistry()
-	cacheDir := filepath.Join(tmpDir, "cache")
-	reg.cacheDir = cacheDir
+	if err := reg.indexTarball(tarballPath); err != nil {
+		t.Fatalf("indexTarball failed: %v", err)
+	}
+
+	manifest, err := reg.Load("nested-ritual")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if manifest.Ritual.Version != "1.0.0" {
+		t.Errorf("Expected version 1.0.0, got %s", manifest.Ritual.Version)
+	}
+}
+
+func TestTarballSkipsTraversal(t *testing.T) {
+	tmpDir := t.TempDir()
+	tarballPath := filepath.Join(tmpDir, "evil.tar.gz")
+
+	file, err := os.Create(tarballPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzWriter)
+	for name, content := range map[string]string{
+		"ritual.yaml":       "ritual:\n  name: evil\n  version: 1.0.0\n",
+		"../escape.txt":     "outside",
+		"/etc/absolute.txt": "absolute",
+	} {
+		if err := addFileToTar(tarWriter, name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = tarWriter.Close()
+	_ = gzWriter.Close()
+	_ = file.Close()
+
+	fsys, err := readTarball(tarballPath)
+	if err != nil {
+		t.Fatalf("readTarball failed: %v", err)
+	}
+
+	var names []string
+	if err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			names = append(names, name)
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "ritual.yaml" {
+		t.Errorf("Expected only ritual.yaml, got %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "escape.txt")); !os.IsNotExist(err) {
+		t.Error("traversal entry must not be written")
+	}
+}
 
-	extractedPath, err := reg.extractTarball(tarballPath)
+func TestTarballScanReadsOnlyManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	tarballPath := filepath.Join(tmpDir, "lazy-ritual.tar.gz")
+	if err := createComplexTarball(tarballPath, "lazy-ritual", "1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := NewRegistry()
+	reg.searchPaths = []string{tmpDir}
+	if err := reg.Scan(); err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	meta, err := reg.Get("lazy-ritual")
 	if err != nil {
-		t.Fatalf("extractTarball failed: %v", err)
+		t.Fatal(err)
 	}
+	if meta.Version != "1.0.0" {
+		t.Errorf("Expected version 1.0.0, got %s", meta.Version)
+	}
+
+	// The templates are only read once the ritual is used
+	if err := os.Remove(tarballPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.ReadFile(meta.Files.FS, "templates/main.tmpl"); err == nil {
+		t.Error("Expected the tarball to be read when its files are used, not by Scan")
+	}
+}
 
-	// Should find ritual.yaml somewhere in the extracted structure
-	ritualFile := filepath.Join(extractedPath, "ritual.yaml")
-	if _, err := os.Stat(ritualFile); os.IsNotExist(err) {
-		// Try in nested directory
-		ritualFile = filepath.Join(extractedPath, "nested-ritual", "ritual.yaml")
-		if _, err := os.Stat(ritualFile); os.IsNotExist(err) {
-			t.Error("ritual.yaml not found in extracted structure")
+func TestReadTarball_TotalSizeLimit(t *testing.T) {
+	tarballPath := filepath.Join(t.TempDir(), "big.tar.gz")
+	file, err := os.Create(tarballPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzWriter)
+	for _, name := range []string{"ritual.yaml", "templates/a.tmpl", "templates/b.tmpl"} {
+		if err := addFileToTar(tarWriter, name, []byte("0123456789")); err != nil {
+			t.Fatal(err)
 		}
 	}
+	_ = tarWriter.Close()
+	_ = gzWriter.Close()
+	_ = file.Close()
+
+	defer func(limit int) { maxTarballSize = limit }(maxTarballSize)
+	maxTarballSize = 25
+
+	if _, err := readTarball(tarballPath); err == nil {
+		t.Error("Expected an error for a tarball over the total size limit")
+	}
+	// Scanning reads ritual.yaml alone
+	if _, err := scanTarball(tarballPath); err != nil {
+		t.Errorf("scanTarball failed: %v", err)
+	}
 }
 
 // Helper: Create a simple tarball with ritual.yaml
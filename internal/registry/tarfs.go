@@ -0,0 +1,290 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// maxTarballFileSize limits each file read from a tarball, against decompression bombs
+const maxTarballFileSize = 100 * 1024 * 1024 // 100MB
+
+// maxTarballSize limits all the files read from a tarball together
+var maxTarballSize = 256 * 1024 * 1024 // 256MB
+
+// tarFS is a read-only filesystem holding the contents of a ritual tarball in memory
+type tarFS struct {
+	entries map[string]*tarEntry
+}
+
+// tarEntry is a file or directory in a tarFS
+type tarEntry struct {
+	name    string
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+	// children lists the names of a directory's entries, sorted
+	children []string
+}
+
+// walkTarball calls fn for each entry of a gzipped tarball, with its cleaned
+// name. Entries with absolute paths or ".." elements are skipped.
+func walkTarball(tarballPath string, fn func(name string, header *tar.Header, content io.Reader) error) error {
+	// #nosec G304 - tarballPath is from a ritual search path
+	file, err := os.Open(tarballPath)
+	if err != nil {
+		return fmt.Errorf("failed to open tarball: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	defer func() { _ = gzReader.Close() }()
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar header: %w", err)
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		if err := fn(name, header, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// readTarEntry reads the content of a regular file in a tarball, up to maxTarballFileSize
+func readTarEntry(name string, content io.Reader) ([]byte, error) {
+	// #nosec G110 - Size limited to prevent decompression bombs
+	data, err := io.ReadAll(io.LimitReader(content, maxTarballFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxTarballFileSize {
+		return nil, fmt.Errorf("file %s exceeds %d bytes", name, maxTarballFileSize)
+	}
+	return data, nil
+}
+
+// readTarball reads a gzipped ritual tarball into memory, up to maxTarballSize in all
+func readTarball(tarballPath string) (*tarFS, error) {
+	fsys := &tarFS{entries: map[string]*tarEntry{
+		".": {name: ".", mode: fs.ModeDir | 0555},
+	}}
+
+	total := 0
+	err := walkTarball(tarballPath, func(name string, header *tar.Header, content io.Reader) error {
+		switch header.Typeflag {
+		case tar.TypeDir:
+			fsys.addDir(name, header.FileInfo().Mode().Perm(), header.ModTime)
+		case tar.TypeReg:
+			data, err := readTarEntry(name, content)
+			if err != nil {
+				return err
+			}
+			if total += len(data); total > maxTarballSize {
+				return fmt.Errorf("tarball contents exceed %d bytes", maxTarballSize)
+			}
+			fsys.addDir(path.Dir(name), 0, header.ModTime)
+			fsys.link(name)
+			fsys.entries[name] = &tarEntry{
+				name:    name,
+				data:    data,
+				mode:    header.FileInfo().Mode().Perm(),
+				modTime: header.ModTime,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range fsys.entries {
+		sort.Strings(entry.children)
+	}
+	return fsys, nil
+}
+
+// tarballManifest is the ritual.yaml of a tarball, found without reading the rest into memory
+type tarballManifest struct {
+	dir    string // Directory holding ritual.yaml: "." or a top-level directory
+	data   []byte // Content of ritual.yaml
+	shared bool   // Whether the tarball has a _shared directory
+}
+
+// scanTarball finds the ritual.yaml of a tarball, at its root or else in the
+// first top-level directory holding one, reading no other file
+func scanTarball(tarballPath string) (*tarballManifest, error) {
+	var found *tarballManifest
+	shared := false
+	err := walkTarball(tarballPath, func(name string, header *tar.Header, content io.Reader) error {
+		if name == ritual.SharedDir || strings.HasPrefix(name, ritual.SharedDir+"/") {
+			shared = true
+		}
+		if header.Typeflag != tar.TypeReg || path.Base(name) != "ritual.yaml" {
+			return nil
+		}
+		dir := path.Dir(name)
+		if strings.Contains(dir, "/") {
+			return nil
+		}
+		// The root comes first, then top-level directories in name order
+		if found != nil && dir != "." && (found.dir == "." || found.dir < dir) {
+			return nil
+		}
+		data, err := readTarEntry(name, content)
+		if err != nil {
+			return err
+		}
+		found = &tarballManifest{dir: dir, data: data}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no ritual.yaml found")
+	}
+	found.shared = shared
+	return found, nil
+}
+
+// lazyTarFS reads a tarball into memory the first time a file in it is opened
+type lazyTarFS struct {
+	path string
+	once sync.Once
+	fsys *tarFS
+	err  error
+}
+
+// Open opens a file or directory, reading the tarball if it has not been read yet
+func (l *lazyTarFS) Open(name string) (fs.File, error) {
+	l.once.Do(func() { l.fsys, l.err = readTarball(l.path) })
+	if l.err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: l.err}
+	}
+	return l.fsys.Open(name)
+}
+
+// addDir adds a directory and its missing parents. A zero perm marks a directory
+// implied by its contents; it gets 0555 until the tarball lists it.
+func (t *tarFS) addDir(name string, perm fs.FileMode, modTime time.Time) {
+	if entry, ok := t.entries[name]; ok {
+		if entry.mode.IsDir() && perm != 0 {
+			entry.mode = fs.ModeDir | perm
+		}
+		return
+	}
+	t.addDir(path.Dir(name), 0, modTime)
+	t.link(name)
+	if perm == 0 {
+		perm = 0555
+	}
+	t.entries[name] = &tarEntry{name: name, mode: fs.ModeDir | perm, modTime: modTime}
+}
+
+// link lists name in its parent directory
+func (t *tarFS) link(name string) {
+	if _, ok := t.entries[name]; ok {
+		return
+	}
+	parent := t.entries[path.Dir(name)]
+	parent.children = append(parent.children, path.Base(name))
+}
+
+// Open opens a file or directory
+func (t *tarFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := t.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if entry.mode.IsDir() {
+		return &tarDir{fsys: t, entry: entry}, nil
+	}
+	return &tarFile{entry: entry, Reader: bytes.NewReader(entry.data)}, nil
+}
+
+// info describes an entry
+func (e *tarEntry) info() fs.FileInfo {
+	return tarFileInfo{e}
+}
+
+// tarFileInfo implements fs.FileInfo for a tarEntry
+type tarFileInfo struct {
+	entry *tarEntry
+}
+
+func (i tarFileInfo) Name() string       { return path.Base(i.entry.name) }
+func (i tarFileInfo) Size() int64        { return int64(len(i.entry.data)) }
+func (i tarFileInfo) Mode() fs.FileMode  { return i.entry.mode }
+func (i tarFileInfo) ModTime() time.Time { return i.entry.modTime }
+func (i tarFileInfo) IsDir() bool        { return i.entry.mode.IsDir() }
+func (i tarFileInfo) Sys() interface{}   { return nil }
+
+// tarFile is an open regular file
+type tarFile struct {
+	entry *tarEntry
+	*bytes.Reader
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.entry.info(), nil }
+func (f *tarFile) Close() error               { return nil }
+
+// tarDir is an open directory
+type tarDir struct {
+	fsys   *tarFS
+	entry  *tarEntry
+	offset int
+}
+
+func (d *tarDir) Stat() (fs.FileInfo, error) { return d.entry.info(), nil }
+func (d *tarDir) Close() error               { return nil }
+
+func (d *tarDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: errors.New("is a directory")}
+}
+
+// ReadDir returns the directory's entries in name order
+func (d *tarDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entry.children[d.offset:]
+	if n > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && len(remaining) > n {
+		remaining = remaining[:n]
+	}
+	d.offset += len(remaining)
+
+	entries := make([]fs.DirEntry, 0, len(remaining))
+	for _, child := range remaining {
+		entry := d.fsys.entries[path.Join(d.entry.name, child)]
+		entries = append(entries, fs.FileInfoToDirEntry(entry.info()))
+	}
+	return entries, nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileStatus describes how a generated file compares to what the ritual produced.
+type FileStatus string
+
+const (
+	// FileUnchanged means the file still matches its recorded hash.
+	FileUnchanged FileStatus = "unchanged"
+	// FileModified means the user changed the file's content or mode.
+	FileModified FileStatus = "modified"
+	// FileDeleted means the file no longer exists in the project.
+	FileDeleted FileStatus = "deleted"
+	// FileUnknown means no hash was recorded, so changes cannot be detected.
+	FileUnknown FileStatus = "unknown"
+)
+
+// FileDrift is the status of a single generated file.
+type FileDrift struct {
+	Path          string     `json:"path"`
+	Source        string     `json:"source,omitempty"`
+	RitualVersion string     `json:"ritual_version,omitempty"`
+	Status        FileStatus `json:"status"`
+	ModeChanged   bool       `json:"mode_changed,omitempty"`
+}
+
+// DriftReport lists how each generated file has drifted from the ritual output.
+type DriftReport struct {
+	Files []FileDrift `json:"files"`
+}
+
+// DetectDrift compares the project's files with the hashes recorded in state.
+func DetectDrift(projectPath string, state *State) (*DriftReport, error) {
+	report := &DriftReport{}
+
+	for _, file := range state.GeneratedFiles {
+		drift := FileDrift{
+			Path:          file.Path,
+			Source:        file.Source,
+			RitualVersion: file.RitualVersion,
+		}
+
+		fullPath := filepath.Join(projectPath, filepath.FromSlash(file.Path))
+		info, err := os.Stat(fullPath)
+		switch {
+		case os.IsNotExist(err):
+			drift.Status = FileDeleted
+		case err != nil:
+			return nil, fmt.Errorf("failed to stat %s: %w", file.Path, err)
+		case file.SHA256 == "":
+			drift.Status = FileUnknown
+		default:
+			// #nosec G304 - fullPath is a generated file recorded in state
+			content, err := os.ReadFile(fullPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", file.Path, err)
+			}
+			drift.Status = FileUnchanged
+			if HashContent(content) != file.SHA256 {
+				drift.Status = FileModified
+			}
+			if mode := file.FileMode(); mode != 0 && info.Mode().Perm() != mode {
+				drift.ModeChanged = true
+				drift.Status = FileModified
+			}
+		}
+
+		report.Files = append(report.Files, drift)
+	}
+
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].Path < report.Files[j].Path
+	})
+
+	return report, nil
+}
+
+// WithStatus returns the files with the given status.
+func (r *DriftReport) WithStatus(status FileStatus) []FileDrift {
+	var files []FileDrift
+	for _, f := range r.Files {
+		if f.Status == status {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// HasDrift reports whether any generated file was modified or deleted.
+func (r *DriftReport) HasDrift() bool {
+	for _, f := range r.Files {
+		if f.Status == FileModified || f.Status == FileDeleted {
+			return true
+		}
+	}
+	return false
+}
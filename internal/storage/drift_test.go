@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectDrift(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"main.go":      "package main\n",
+		"config.yaml":  "port: 8080\n",
+		"deleted.go":   "package gone\n",
+		"script.sh":    "#!/bin/sh\n",
+		"untracked.md": "# legacy\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	state := &State{RitualVersion: "1.0.0"}
+	for _, name := range []string{"main.go", "config.yaml", "deleted.go", "script.sh"} {
+		if err := state.RecordGeneratedFile(tmpDir, name, name+".tmpl"); err != nil {
+			t.Fatalf("RecordGeneratedFile(%s) error = %v", name, err)
+		}
+	}
+	state.MarkFileAsGenerated("untracked.md")
+
+	// Simulate user changes
+	if err := os.WriteFile(filepath.Join(tmpDir, "config.yaml"), []byte("port: 9000\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "deleted.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(tmpDir, "script.sh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := DetectDrift(tmpDir, state)
+	if err != nil {
+		t.Fatalf("DetectDrift() error = %v", err)
+	}
+
+	want := map[string]FileStatus{
+		"main.go":      FileUnchanged,
+		"config.yaml":  FileModified,
+		"deleted.go":   FileDeleted,
+		"script.sh":    FileModified,
+		"untracked.md": FileUnknown,
+	}
+	if len(report.Files) != len(want) {
+		t.Fatalf("Expected %d files, got %d", len(want), len(report.Files))
+	}
+	for _, f := range report.Files {
+		if f.Status != want[f.Path] {
+			t.Errorf("%s status = %s, want %s", f.Path, f.Status, want[f.Path])
+		}
+	}
+
+	if !report.HasDrift() {
+		t.Error("Expected drift to be reported")
+	}
+	if got := report.WithStatus(FileModified); len(got) != 2 {
+		t.Errorf("Expected 2 modified files, got %d", len(got))
+	}
+	for _, f := range report.WithStatus(FileModified) {
+		if f.Path == "script.sh" && !f.ModeChanged {
+			t.Error("Expected script.sh to report a mode change")
+		}
+	}
+}
+
+func TestDetectDrift_NoChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "main.go"), []byte("package main\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &State{}
+	if err := state.RecordGeneratedFile(tmpDir, "main.go", "main.go.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := DetectDrift(tmpDir, state)
+	if err != nil {
+		t.Fatalf("DetectDrift() error = %v", err)
+	}
+	if report.HasDrift() {
+		t.Errorf("Expected no drift, got %+v", report.Files)
+	}
+}
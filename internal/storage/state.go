@@ -1,9 +1,12 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -11,13 +14,47 @@ import (
 
 // State represents the deployment state of a ritual-generated project.
 type State struct {
-	RitualName        string      `yaml:"ritual_name"`
-	RitualVersion     string      `yaml:"ritual_version"`
-	InstalledAt       time.Time   `yaml:"installed_at"`
-	UpdatedAt         time.Time   `yaml:"updated_at,omitempty"`
-	AppliedMigrations []Migration `yaml:"applied_migrations"`
-	GeneratedFiles    []string    `yaml:"generated_files"`
-	ProtectedFiles    []string    `yaml:"protected_files"`
+	RitualName        string          `yaml:"ritual_name"`
+	RitualVersion     string          `yaml:"ritual_version"`
+	InstalledAt       time.Time       `yaml:"installed_at"`
+	UpdatedAt         time.Time       `yaml:"updated_at,omitempty"`
+	AppliedMigrations []Migration     `yaml:"applied_migrations"`
+	GeneratedFiles    []GeneratedFile `yaml:"generated_files"`
+	ProtectedFiles    []string        `yaml:"protected_files"`
+}
+
+// GeneratedFile records a file produced by the ritual and what it looked like when generated.
+type GeneratedFile struct {
+	Path          string `yaml:"path"`
+	Source        string `yaml:"source,omitempty"`
+	SHA256        string `yaml:"sha256,omitempty"`
+	Mode          string `yaml:"mode,omitempty"`
+	RitualVersion string `yaml:"ritual_version,omitempty"`
+}
+
+// UnmarshalYAML accepts both entries and the plain paths written by older versions.
+func (f *GeneratedFile) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		f.Path = value.Value
+		return nil
+	}
+	type plain GeneratedFile
+	return value.Decode((*plain)(f))
+}
+
+// FileMode returns the recorded file mode, or 0 if none was recorded.
+func (f *GeneratedFile) FileMode() os.FileMode {
+	mode, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil {
+		return 0
+	}
+	return os.FileMode(mode)
+}
+
+// HashContent returns the hex-encoded SHA-256 of content.
+func HashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
 }
 
 // Migration represents a database or code migration that has been applied.
@@ -85,14 +122,73 @@ func (s *State) IsMigrationApplied(version string) bool {
 // MarkFileAsGenerated marks a file as generated by the ritual.
 func (s *State) MarkFileAsGenerated(file string) {
 	// Check if already marked
-	for _, f := range s.GeneratedFiles {
-		if f == file {
+	if s.IsFileGenerated(file) {
+		return
+	}
+	s.GeneratedFiles = append(s.GeneratedFiles, GeneratedFile{Path: file})
+}
+
+// RecordGeneratedFile hashes a file that was just generated and records it,
+// replacing any previous entry for the same path. file is relative to projectPath.
+func (s *State) RecordGeneratedFile(projectPath, file, source string) error {
+	fullPath := filepath.Join(projectPath, file)
+	// #nosec G304 - fullPath is a file the ritual just generated
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to read generated file %s: %w", file, err)
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat generated file %s: %w", file, err)
+	}
+
+	s.RecordGeneratedContent(file, source, content, info.Mode())
+	return nil
+}
+
+// RecordGeneratedContent records a generated file, relative to the project,
+// from the content and mode it was generated with.
+func (s *State) RecordGeneratedContent(file, source string, content []byte, mode os.FileMode) {
+	s.SetGeneratedFile(GeneratedFile{
+		Path:          filepath.ToSlash(file),
+		Source:        source,
+		SHA256:        HashContent(content),
+		Mode:          fmt.Sprintf("%04o", mode.Perm()),
+		RitualVersion: s.RitualVersion,
+	})
+}
+
+// SetGeneratedFile adds or replaces the entry for a generated file.
+func (s *State) SetGeneratedFile(file GeneratedFile) {
+	for i := range s.GeneratedFiles {
+		if s.GeneratedFiles[i].Path == file.Path {
+			s.GeneratedFiles[i] = file
 			return
 		}
 	}
 	s.GeneratedFiles = append(s.GeneratedFiles, file)
 }
 
+// RemoveGeneratedFile drops a file from the generated files list.
+func (s *State) RemoveGeneratedFile(file string) {
+	for i := range s.GeneratedFiles {
+		if s.GeneratedFiles[i].Path == file {
+			s.GeneratedFiles = append(s.GeneratedFiles[:i], s.GeneratedFiles[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetGeneratedFile returns the entry recorded for a generated file.
+func (s *State) GetGeneratedFile(file string) (*GeneratedFile, bool) {
+	for i := range s.GeneratedFiles {
+		if s.GeneratedFiles[i].Path == file {
+			return &s.GeneratedFiles[i], true
+		}
+	}
+	return nil, false
+}
+
 // MarkFileAsProtected marks a file as protected (should not be overwritten).
 func (s *State) MarkFileAsProtected(file string) {
 	// Check if already marked
@@ -106,12 +202,8 @@ func (s *State) MarkFileAsProtected(file string) {
 
 // IsFileGenerated checks if a file was generated by the ritual.
 func (s *State) IsFileGenerated(file string) bool {
-	for _, f := range s.GeneratedFiles {
-		if f == file {
-			return true
-		}
-	}
-	return false
+	_, ok := s.GetGeneratedFile(file)
+	return ok
 }
 
 // IsFileProtected checks if a file is marked as protected.
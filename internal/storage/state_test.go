@@ -18,7 +18,7 @@ func TestState_Save(t *testing.T) {
 		AppliedMigrations: []Migration{
 			{Version: "1.0.0", AppliedAt: time.Now()},
 		},
-		GeneratedFiles: []string{"main.go", "config.yaml"},
+		GeneratedFiles: []GeneratedFile{{Path: "main.go"}, {Path: "config.yaml"}},
 		ProtectedFiles: []string{"custom.go"},
 	}
 
@@ -46,7 +46,7 @@ func TestState_Load(t *testing.T) {
 		AppliedMigrations: []Migration{
 			{Version: "1.0.0", AppliedAt: time.Now().Round(time.Second)},
 		},
-		GeneratedFiles: []string{"main.go"},
+		GeneratedFiles: []GeneratedFile{{Path: "main.go"}},
 		ProtectedFiles: []string{"custom.go"},
 	}
 
@@ -134,7 +134,7 @@ func TestState_IsMigrationApplied(t *testing.T) {
 
 func TestState_MarkFileAsGenerated(t *testing.T) {
 	state := &State{
-		GeneratedFiles: []string{},
+		GeneratedFiles: []GeneratedFile{},
 	}
 
 	state.MarkFileAsGenerated("main.go")
@@ -171,7 +171,7 @@ func TestState_MarkFileAsProtected(t *testing.T) {
 
 func TestState_IsFileGenerated(t *testing.T) {
 	state := &State{
-		GeneratedFiles: []string{"main.go", "config.yaml"},
+		GeneratedFiles: []GeneratedFile{{Path: "main.go"}, {Path: "config.yaml"}},
 	}
 
 	tests := []struct {
@@ -214,3 +214,69 @@ func TestState_IsFileProtected(t *testing.T) {
 		})
 	}
 }
+
+func TestState_RecordGeneratedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "cmd"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "cmd", "main.go"), []byte("package main\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &State{RitualVersion: "1.2.0"}
+	if err := state.RecordGeneratedFile(tmpDir, filepath.Join("cmd", "main.go"), "main.go.tmpl"); err != nil {
+		t.Fatalf("RecordGeneratedFile() error = %v", err)
+	}
+
+	file, ok := state.GetGeneratedFile("cmd/main.go")
+	if !ok {
+		t.Fatal("Expected cmd/main.go to be recorded")
+	}
+	if file.Source != "main.go.tmpl" {
+		t.Errorf("Source = %s, want main.go.tmpl", file.Source)
+	}
+	if file.SHA256 != HashContent([]byte("package main\n")) {
+		t.Errorf("SHA256 = %s", file.SHA256)
+	}
+	if file.FileMode() != 0640 {
+		t.Errorf("FileMode() = %o, want 640", file.FileMode())
+	}
+	if file.RitualVersion != "1.2.0" {
+		t.Errorf("RitualVersion = %s, want 1.2.0", file.RitualVersion)
+	}
+
+	// Recording again replaces the entry
+	if err := state.RecordGeneratedFile(tmpDir, filepath.Join("cmd", "main.go"), "other.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.GeneratedFiles) != 1 {
+		t.Errorf("Expected 1 generated file, got %d", len(state.GeneratedFiles))
+	}
+
+	state.RemoveGeneratedFile("cmd/main.go")
+	if state.IsFileGenerated("cmd/main.go") {
+		t.Error("Expected cmd/main.go to be removed")
+	}
+}
+
+func TestLoadState_LegacyGeneratedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	stateDir := filepath.Join(tmpDir, ".ritual")
+	if err := os.MkdirAll(stateDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := "ritual_name: blog\nritual_version: 1.0.0\ngenerated_files:\n  - main.go\n  - config.yaml\n"
+	if err := os.WriteFile(filepath.Join(stateDir, "state.yaml"), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := LoadState(tmpDir)
+	if err != nil {
+		t.Fatalf("LoadState() error = %v", err)
+	}
+	if !state.IsFileGenerated("main.go") || !state.IsFileGenerated("config.yaml") {
+		t.Errorf("Expected legacy paths to load, got %+v", state.GeneratedFiles)
+	}
+}
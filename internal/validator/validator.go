@@ -1,12 +1,14 @@
 package validator
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/toutaio/toutago-ritual-grove/internal/condition"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
 
@@ -43,6 +45,10 @@ func (v *Validator) Validate(manifest *ritual.Manifest) error {
 		return fmt.Errorf("files validation failed: %w", err)
 	}
 
+	if err := v.validateHooks(manifest); err != nil {
+		return fmt.Errorf("hooks validation failed: %w", err)
+	}
+
 	if err := v.validateMigrations(manifest); err != nil {
 		return fmt.Errorf("migrations validation failed: %w", err)
 	}
@@ -78,6 +84,13 @@ func (v *Validator) validateMetadata(manifest *ritual.Manifest) error {
 		}
 	}
 
+	switch manifest.Ritual.MissingKey {
+	case "", ritual.MissingKeyDefault, ritual.MissingKeyError:
+	default:
+		return fmt.Errorf("invalid missing_key: %s (must be one of: %s, %s)",
+			manifest.Ritual.MissingKey, ritual.MissingKeyDefault, ritual.MissingKeyError)
+	}
+
 	return nil
 }
 
@@ -105,6 +118,7 @@ func (v *Validator) validateCompatibility(manifest *ritual.Manifest) error {
 
 func (v *Validator) validateQuestions(manifest *ritual.Manifest) error {
 	questionNames := make(map[string]bool)
+	declared := declaredNames(manifest)
 
 	for i, q := range manifest.Questions {
 		if q.Name == "" {
@@ -135,11 +149,9 @@ func (v *Validator) validateQuestions(manifest *ritual.Manifest) error {
 
 		// Validate conditions reference existing questions
 		if q.Condition != nil {
-			if q.Condition.Field == "" {
-				return fmt.Errorf("question %s: condition field is required", q.Name)
+			if err := validateQuestionCondition(q.Condition, declared); err != nil {
+				return fmt.Errorf("question %s: %w", q.Name, err)
 			}
-			// Note: We can't validate if field exists yet since questions are processed in order
-			// This would need a second pass
 		}
 
 		// Validate validation rules
@@ -155,7 +167,38 @@ func (v *Validator) validateQuestions(manifest *ritual.Manifest) error {
 	return nil
 }
 
+// validateQuestionCondition checks that a question condition has something to
+// evaluate and that it reads only declared variables
+func validateQuestionCondition(cond *ritual.QuestionCondition, declared map[string]bool) error {
+	if cond.Field == "" && cond.Expression == "" && len(cond.And) == 0 && len(cond.Or) == 0 && cond.Not == nil {
+		return fmt.Errorf("condition field or expression is required")
+	}
+
+	if cond.Field != "" && declared != nil && !declared[cond.Field] {
+		return fmt.Errorf("condition field %q is not a question or variable", cond.Field)
+	}
+	if err := condition.ValidateNames(cond.Expression, declared); err != nil {
+		return err
+	}
+	for i := range cond.And {
+		if err := validateQuestionCondition(&cond.And[i], declared); err != nil {
+			return err
+		}
+	}
+	for i := range cond.Or {
+		if err := validateQuestionCondition(&cond.Or[i], declared); err != nil {
+			return err
+		}
+	}
+	if cond.Not != nil {
+		return validateQuestionCondition(cond.Not, declared)
+	}
+	return nil
+}
+
 func (v *Validator) validateFiles(manifest *ritual.Manifest) error {
+	declared := declaredNames(manifest)
+
 	// Validate template mappings
 	for i, tmpl := range manifest.Files.Templates {
 		if tmpl.Source == "" {
@@ -164,6 +207,12 @@ func (v *Validator) validateFiles(manifest *ritual.Manifest) error {
 		if tmpl.Destination == "" {
 			return fmt.Errorf("template %d: destination is required", i)
 		}
+		if err := condition.ValidateNames(tmpl.Condition, foreachNames(declared, tmpl)); err != nil {
+			return fmt.Errorf("template %s: %w", tmpl.Source, err)
+		}
+		if err := validateForeach(tmpl); err != nil {
+			return fmt.Errorf("template %s: %w", tmpl.Source, err)
+		}
 	}
 
 	// Validate static file mappings
@@ -174,6 +223,103 @@ func (v *Validator) validateFiles(manifest *ritual.Manifest) error {
 		if static.Destination == "" {
 			return fmt.Errorf("static file %d: destination is required", i)
 		}
+		if err := condition.ValidateNames(static.Condition, foreachNames(declared, static)); err != nil {
+			return fmt.Errorf("static file %s: %w", static.Source, err)
+		}
+		if err := validateForeach(static); err != nil {
+			return fmt.Errorf("static file %s: %w", static.Source, err)
+		}
+	}
+
+	return nil
+}
+
+// builtinVariables are set for every ritual besides its questions and variables
+var builtinVariables = []string{"project_name", "module_path", "ritual_name", "ritual_version", "app_name"}
+
+// declaredNames returns the variables conditions may read: the questions and
+// variables of the ritual and the built-in ones. It returns nil for a ritual
+// with a parent, whose questions are not known here.
+func declaredNames(manifest *ritual.Manifest) map[string]bool {
+	if manifest.Parent != nil {
+		return nil
+	}
+	declared := make(map[string]bool)
+	for _, name := range builtinVariables {
+		declared[name] = true
+	}
+	for _, q := range manifest.Questions {
+		declared[q.Name] = true
+	}
+	for _, variable := range manifest.Variables {
+		declared[variable.Name] = true
+	}
+	return declared
+}
+
+// foreachNames adds the item and loop variables of a foreach mapping to declared
+func foreachNames(declared map[string]bool, mapping ritual.FileMapping) map[string]bool {
+	if declared == nil || mapping.Foreach == "" {
+		return declared
+	}
+	names := make(map[string]bool, len(declared)+2)
+	for name := range declared {
+		names[name] = true
+	}
+	names["loop"] = true
+	if mapping.As != "" {
+		names[mapping.As] = true
+	} else {
+		names["item"] = true
+	}
+	return names
+}
+
+// variableName matches the names foreach and as may hold
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateForeach checks the foreach variable and item name of a mapping
+func validateForeach(mapping ritual.FileMapping) error {
+	if mapping.As != "" && mapping.Foreach == "" {
+		return fmt.Errorf("as %q needs foreach", mapping.As)
+	}
+	for _, name := range []string{mapping.Foreach, mapping.As} {
+		if name != "" && !variableName.MatchString(name) {
+			return fmt.Errorf("%q is not a variable name", name)
+		}
+	}
+	return nil
+}
+
+// validateHooks checks the conditions of declarative task hooks
+func (v *Validator) validateHooks(manifest *ritual.Manifest) error {
+	declared := declaredNames(manifest)
+	phases := []struct {
+		name  string
+		hooks []string
+	}{
+		{"pre_install", manifest.Hooks.PreInstall},
+		{"post_install", manifest.Hooks.PostInstall},
+		{"pre_update", manifest.Hooks.PreUpdate},
+		{"post_update", manifest.Hooks.PostUpdate},
+		{"pre_deploy", manifest.Hooks.PreDeploy},
+		{"post_deploy", manifest.Hooks.PostDeploy},
+	}
+
+	for _, phase := range phases {
+		for i, hook := range phase.hooks {
+			var task map[string]interface{}
+			if json.Unmarshal([]byte(strings.TrimSpace(hook)), &task) != nil {
+				continue // Shell command, nothing to check
+			}
+			expr, ok := task["condition"].(string)
+			if !ok {
+				continue
+			}
+			if err := condition.ValidateNames(expr, declared); err != nil {
+				return fmt.Errorf("%s hook %d: %w", phase.name, i+1, err)
+			}
+		}
 	}
 
 	return nil
@@ -74,6 +74,28 @@ func TestValidateMetadata(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "strict missing keys",
+			manifest: &ritual.Manifest{
+				Ritual: ritual.RitualMeta{
+					Name:       "test-app",
+					Version:    "1.0.0",
+					MissingKey: ritual.MissingKeyError,
+				},
+			},
+			wantError: false,
+		},
+		{
+			name: "invalid missing key mode",
+			manifest: &ritual.Manifest{
+				Ritual: ritual.RitualMeta{
+					Name:       "test-app",
+					Version:    "1.0.0",
+					MissingKey: "zero",
+				},
+			},
+			wantError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -366,6 +388,119 @@ func TestValidator_validateFiles_Comprehensive(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid conditions",
+			manifest: &ritual.Manifest{
+				Questions: []ritual.Question{{Name: "enable_comments"}, {Name: "theme"}},
+				Files: ritual.FilesSection{
+					Templates: []ritual.FileMapping{
+						{Source: "comment.go.tmpl", Destination: "comment.go", Condition: "enable_comments == true"},
+						{Source: "legacy.go.tmpl", Destination: "legacy.go", Condition: `[[ eq .frontend_type "htmx" ]]`},
+					},
+					Static: []ritual.FileMapping{
+						{Source: "logo.png", Destination: "logo.png", Condition: "!(theme in ['none', 'minimal'])"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "template condition parse error",
+			manifest: &ritual.Manifest{
+				Files: ritual.FilesSection{
+					Templates: []ritual.FileMapping{
+						{Source: "comment.go.tmpl", Destination: "comment.go", Condition: "enable_comments =="},
+					},
+				},
+			},
+			wantErr:   true,
+			errString: "template comment.go.tmpl: invalid condition",
+		},
+		{
+			name: "static condition parse error",
+			manifest: &ritual.Manifest{
+				Files: ritual.FilesSection{
+					Static: []ritual.FileMapping{
+						{Source: "logo.png", Destination: "logo.png", Condition: "(a && b"},
+					},
+				},
+			},
+			wantErr:   true,
+			errString: "static file logo.png: invalid condition",
+		},
+		{
+			name: "condition with an unquoted string",
+			manifest: &ritual.Manifest{
+				Questions: []ritual.Question{{Name: "db"}},
+				Files: ritual.FilesSection{
+					Templates: []ritual.FileMapping{
+						{Source: "pg.go.tmpl", Destination: "pg.go", Condition: "db == postgres"},
+					},
+				},
+			},
+			wantErr:   true,
+			errString: `template pg.go.tmpl: condition "db == postgres" reads unknown variable "postgres"`,
+		},
+		{
+			name: "condition on built-in and foreach variables",
+			manifest: &ritual.Manifest{
+				Questions: []ritual.Question{{Name: "entities"}},
+				Files: ritual.FilesSection{
+					Templates: []ritual.FileMapping{
+						{Source: "main.go.tmpl", Destination: "main.go", Condition: "startswith(module_path, 'github.com/')"},
+						{Source: "model.go.tmpl", Destination: "{{ entity }}.go", Foreach: "entities", As: "entity", Condition: "entity != 'user' && !loop.first"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "condition of a ritual with a parent",
+			manifest: &ritual.Manifest{
+				Parent: &ritual.ParentRitual{Name: "base-app"},
+				Files: ritual.FilesSection{
+					Templates: []ritual.FileMapping{
+						{Source: "comment.go.tmpl", Destination: "comment.go", Condition: "enable_comments"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid foreach",
+			manifest: &ritual.Manifest{
+				Files: ritual.FilesSection{
+					Templates: []ritual.FileMapping{
+						{Source: "model.go.tmpl", Destination: "internal/models/{{ entity }}.go", Foreach: "entities", As: "entity"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "as without foreach",
+			manifest: &ritual.Manifest{
+				Files: ritual.FilesSection{
+					Templates: []ritual.FileMapping{
+						{Source: "model.go.tmpl", Destination: "model.go", As: "entity"},
+					},
+				},
+			},
+			wantErr:   true,
+			errString: `as "entity" needs foreach`,
+		},
+		{
+			name: "foreach expression",
+			manifest: &ritual.Manifest{
+				Files: ritual.FilesSection{
+					Static: []ritual.FileMapping{
+						{Source: "icon.png", Destination: "{{ item }}.png", Foreach: "icons | sort"},
+					},
+				},
+			},
+			wantErr:   true,
+			errString: `static file icon.png: "icons | sort" is not a variable name`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -382,6 +517,54 @@ func TestValidator_validateFiles_Comprehensive(t *testing.T) {
 	}
 }
 
+func TestValidator_validateConditionExpressions(t *testing.T) {
+	v := NewValidator()
+
+	manifest := &ritual.Manifest{
+		Ritual: ritual.RitualMeta{Name: "test-app", Version: "1.0.0"},
+		Questions: []ritual.Question{
+			{Name: "use_db", Prompt: "Use a database?", Type: ritual.QuestionTypeBoolean},
+			{Name: "db_type", Prompt: "Database?", Type: ritual.QuestionTypeChoice, Choices: []string{"postgres", "sqlite"}},
+			{
+				Name:      "db_port",
+				Prompt:    "Port?",
+				Type:      ritual.QuestionTypeNumber,
+				Condition: &ritual.QuestionCondition{Expression: "use_db && db_type != 'sqlite'"},
+			},
+		},
+	}
+	if err := v.Validate(manifest); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	manifest.Questions[2].Condition = &ritual.QuestionCondition{Field: "use_database", Equals: true}
+	err := v.Validate(manifest)
+	if err == nil || !strings.Contains(err.Error(), `condition field "use_database" is not a question or variable`) {
+		t.Errorf("Validate() error = %v, want unknown condition field", err)
+	}
+
+	manifest.Questions[2].Condition = &ritual.QuestionCondition{
+		Or: []ritual.QuestionCondition{{Expression: "use_db &&"}},
+	}
+	err = v.Validate(manifest)
+	if err == nil || !strings.Contains(err.Error(), "question db_port: invalid condition") {
+		t.Errorf("Validate() error = %v, want question condition parse error", err)
+	}
+
+	manifest.Questions[2].Condition = nil
+	manifest.Hooks.PostInstall = []string{`{"type": "go-mod-tidy", "condition": "use_db ==="}`}
+	err = v.Validate(manifest)
+	if err == nil || !strings.Contains(err.Error(), "post_install hook 1: invalid condition") {
+		t.Errorf("Validate() error = %v, want hook condition parse error", err)
+	}
+
+	manifest.Hooks.PostInstall = []string{`{"type": "go-mod-tidy", "condition": "db_type == sqlite"}`}
+	err = v.Validate(manifest)
+	if err == nil || !strings.Contains(err.Error(), `post_install hook 1: condition "db_type == sqlite" reads unknown variable "sqlite"`) {
+		t.Errorf("Validate() error = %v, want hook condition with an unknown variable", err)
+	}
+}
+
 func TestValidator_validateCompatibility_Comprehensive(t *testing.T) {
 	v := NewValidator()
 
@@ -1,20 +1,25 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
 	"github.com/toutaio/toutago-ritual-grove/internal/commands"
+	"github.com/toutaio/toutago-ritual-grove/internal/deployment"
 	"github.com/toutaio/toutago-ritual-grove/internal/generator"
 	"github.com/toutaio/toutago-ritual-grove/internal/questionnaire"
 	"github.com/toutaio/toutago-ritual-grove/internal/registry"
+	"github.com/toutaio/toutago-ritual-grove/internal/storage"
+	"github.com/toutaio/toutago-ritual-grove/internal/validator"
 	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
 )
 
@@ -45,6 +50,9 @@ Use rituals to create:
 	cmd.AddCommand(migrateCommand())
 	cmd.AddCommand(commands.NewBackupCommand())
 	cmd.AddCommand(commands.NewCleanCommand())
+	cmd.AddCommand(commands.NewStatusCommand())
+	cmd.AddCommand(commands.NewDiffCommand())
+	cmd.AddCommand(commands.NewGenerateCommand())
 
 	return cmd
 }
@@ -55,6 +63,7 @@ func initCommand() *cobra.Command {
 	var skipQuestions bool
 	var initGit bool
 	var configFile string
+	var onError string
 
 	cmd := &cobra.Command{
 		Use:   "init <ritual-name>",
@@ -68,14 +77,19 @@ Example:
   touta ritual init basic-site
   touta ritual init blog --output ./my-blog
   touta ritual init blog --git --output ./my-blog
-  touta ritual init blog --config answers.yaml`,
+  touta ritual init blog --config answers.yaml
+  touta ritual init blog --on-error=leave-partial`,
 		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			ritualName := args[0]
 			if outputPath == "" {
 				outputPath = "."
 			}
-			return initRitual(ritualName, outputPath, skipQuestions, initGit, configFile)
+			policy, err := generator.ParseErrorPolicy(onError)
+			if err != nil {
+				return err
+			}
+			return initRitual(ritualName, outputPath, skipQuestions, initGit, configFile, policy)
 		},
 	}
 
@@ -83,6 +97,7 @@ Example:
 	cmd.Flags().BoolVar(&skipQuestions, "yes", false, "Skip questions and use defaults")
 	cmd.Flags().BoolVar(&initGit, "git", false, "Initialize git repository after creation")
 	cmd.Flags().StringVarP(&configFile, "config", "c", "", "Load answers from config file (YAML or JSON)")
+	cmd.Flags().StringVar(&onError, "on-error", "rollback", "What to do if generation fails: rollback, abort, leave-partial or ask")
 
 	return cmd
 }
@@ -205,7 +220,7 @@ func planCommand() *cobra.Command {
 }
 
 // initRitual initializes a project from a ritual
-func initRitual(ritualName, outputPath string, skipQuestions bool, initGit bool, configFile string) error {
+func initRitual(ritualName, outputPath string, skipQuestions bool, initGit bool, configFile string, onError generator.ErrorPolicy) error {
 	// Create registry
 	reg := registry.NewRegistry()
 
@@ -287,24 +302,54 @@ func initRitual(ritualName, outputPath string, skipQuestions bool, initGit bool,
 	}
 
 	// Generate files
-	gen := generator.NewFileGenerator("go")
+	gen := generator.NewFileGenerator(manifest.Ritual.TemplateEngine)
 	vars := generator.NewVariables()
 	for k, v := range variables {
 		vars.Set(k, v)
 	}
 	gen.SetVariables(vars)
-	
-	// Set rituals base path for _shared template support
-	// ritualMeta.Path is /path/to/rituals/ritual-name
-	// We need /path/to/rituals
-	ritualsBasePath := filepath.Dir(ritualMeta.Path)
-	gen.SetRitualsBasePath(ritualsBasePath)
+
+	// Record the ritual, its version and the generated files so the project can be updated later
+	state := &storage.State{
+		RitualName:    manifest.Ritual.Name,
+		RitualVersion: manifest.Ritual.Version,
+		InstalledAt:   time.Now(),
+	}
+	saveState := func() error {
+		if err := gen.RecordState(state, outputPath); err != nil {
+			return fmt.Errorf("failed to record generated files: %w", err)
+		}
+		if err := state.Save(outputPath); err != nil {
+			return fmt.Errorf("failed to save state: %w", err)
+		}
+		return nil
+	}
+
+	journal := gen.Journal()
+	if err := journal.Track(filepath.Join(outputPath, ".ritual")); err != nil {
+		return fmt.Errorf("failed to record project state directory: %w", err)
+	}
 
 	fmt.Printf("📝 Generating project files...\n")
-	if err := gen.GenerateFiles(manifest, ritualMeta.Path, outputPath); err != nil {
-		return fmt.Errorf("failed to generate files: %w", err)
+	run := &generator.Run{
+		Generator:  gen,
+		Manifest:   manifest,
+		Source:     ritualMeta.RitualSource(),
+		OutputPath: outputPath,
+		State:      state,
+	}
+	if err := generator.DefaultPipeline().Run(context.Background(), run); err != nil {
+		return recoverInit(journal, onError, outputPath, saveState, fmt.Errorf("failed to generate files: %w", err))
 	}
 
+	if err := state.Save(outputPath); err != nil {
+		return recoverInit(journal, onError, outputPath, nil, fmt.Errorf("failed to save state: %w", err))
+	}
+	if err := deployment.SaveSnapshotFrom(outputPath, ritualMeta.RitualSource(), manifest, variables); err != nil {
+		return recoverInit(journal, onError, outputPath, nil, fmt.Errorf("failed to save ritual snapshot: %w", err))
+	}
+	journal.Commit()
+
 	// Initialize git repository if requested
 	if initGit {
 		if err := initGitRepository(outputPath); err != nil {
@@ -332,6 +377,19 @@ func initRitual(ritualName, outputPath string, skipQuestions bool, initGit bool,
 	return nil
 }
 
+// recoverInit handles a partly initialized project according to onError.
+// saveState, if set, records the partial project for leave-partial.
+func recoverInit(journal *generator.Journal, onError generator.ErrorPolicy, outputPath string, saveState func() error, cause error) error {
+	return generator.Recovery{
+		Journal:   journal,
+		Policy:    onError,
+		Confirm:   generator.ConfirmRollback(os.Stdin, os.Stdout, cause),
+		SaveState: saveState,
+		Out:       os.Stdout,
+		Path:      outputPath,
+	}.Recover(cause)
+}
+
 // initGitRepository initializes a git repository (duplicated from internal/cli/create.go for now)
 func initGitRepository(targetPath string) error {
 	cmd := exec.Command("git", "init")
@@ -494,11 +552,15 @@ func validateRitual(ritualPath string) error {
 		return fmt.Errorf("failed to load ritual.yaml: %w", err)
 	}
 
-	// Validate
+	// Validate structure, then conditions and the rest of the manifest
 	if err := manifest.Validate(); err != nil {
 		fmt.Printf("❌ Validation failed:\n\n")
 		return err
 	}
+	if err := validator.NewValidator().Validate(manifest); err != nil {
+		fmt.Printf("❌ Validation failed:\n\n")
+		return err
+	}
 
 	fmt.Printf("✅ Ritual is valid!\n\n")
 	fmt.Printf("Name:    %s\n", manifest.Ritual.Name)
@@ -624,6 +686,7 @@ Example:
 // updateCommand updates a project to a new ritual version
 func updateCommand() *cobra.Command {
 	var toVersion string
+	var ritualPath string
 	var dryRun bool
 	var force bool
 
@@ -635,18 +698,24 @@ func updateCommand() *cobra.Command {
 This command will:
   - Check the current ritual version
   - Run migrations if needed
+  - Regenerate files and three-way merge them with your changes
   - Create backups before updating
   - Rollback on error (unless --force)
 
+Files you changed and the ritual changed in different places are merged.
+Overlapping changes are written with conflict markers and listed at the end.
+
 Example:
   touta ritual update --to 1.2.0
-  touta ritual update --to 1.2.0 --dry-run`,
+  touta ritual update --to 1.2.0 --dry-run
+  touta ritual update --to 1.2.0 --ritual ./rituals/blog`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return updateProject(".", toVersion, dryRun, force)
+			return updateProject(".", toVersion, ritualPath, dryRun, force)
 		},
 	}
 
 	cmd.Flags().StringVar(&toVersion, "to", "", "Target version to update to (required)")
+	cmd.Flags().StringVar(&ritualPath, "ritual", "", "Path to the new ritual version (default: from registry)")
 	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would happen without making changes")
 	cmd.Flags().BoolVar(&force, "force", false, "Force update even if migrations fail")
 	if err := cmd.MarkFlagRequired("to"); err != nil {
@@ -718,13 +787,14 @@ func searchRituals(query string) error {
 }
 
 // updateProject updates a project to a new ritual version
-func updateProject(projectPath, toVersion string, dryRun, force bool) error {
+func updateProject(projectPath, toVersion, ritualPath string, dryRun, force bool) error {
 	handler := commands.NewUpdateHandler()
 
 	opts := commands.UpdateOptions{
-		ToVersion: toVersion,
-		DryRun:    dryRun,
-		Force:     force,
+		ToVersion:  toVersion,
+		RitualPath: ritualPath,
+		DryRun:     dryRun,
+		Force:      force,
 	}
 
 	return handler.Execute(projectPath, opts)
@@ -0,0 +1,175 @@
+package ritual
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Entity is a domain object of the generated project. Its model, migration,
+// repository, CRUD handler, routes and handler tests are generated from it,
+// all with the same names and types.
+type Entity struct {
+	Name          string               `yaml:"name"`            // Go type name, such as BlogPost
+	Table         string               `yaml:"table,omitempty"` // Default: the snake_case plural of name
+	Fields        []EntityField        `yaml:"fields"`
+	Relationships []EntityRelationship `yaml:"relationships,omitempty"`
+	Timestamps    bool                 `yaml:"timestamps,omitempty"`  // created_at and updated_at columns
+	SoftDelete    bool                 `yaml:"soft_delete,omitempty"` // A deleted_at column
+}
+
+// EntityField is a column of an entity. Its Go field is the PascalCase of its name.
+type EntityField struct {
+	Name     string    `yaml:"name"` // snake_case column name
+	Type     FieldType `yaml:"type"`
+	Size     int       `yaml:"size,omitempty"`     // Maximum length of a string (default 255)
+	Required bool      `yaml:"required,omitempty"` // NOT NULL and checked by Validate; otherwise the Go field is a pointer
+	Unique   bool      `yaml:"unique,omitempty"`
+	Default  string    `yaml:"default,omitempty"` // SQL expression, such as 0 or 'draft'
+}
+
+// FieldType is the type of an entity field
+type FieldType string
+
+// Entity field types
+const (
+	FieldString FieldType = "string" // string, VARCHAR(size)
+	FieldText   FieldType = "text"   // string, TEXT
+	FieldInt    FieldType = "int"    // int64, BIGINT
+	FieldFloat  FieldType = "float"  // float64, DOUBLE PRECISION
+	FieldBool   FieldType = "bool"   // bool, BOOLEAN
+	FieldTime   FieldType = "time"   // time.Time, TIMESTAMP
+)
+
+// EntityRelationship relates an entity to another one
+type EntityRelationship struct {
+	Type   RelationshipType `yaml:"type"`
+	Entity string           `yaml:"entity"` // Name of the related entity
+}
+
+// RelationshipType is the kind of an entity relationship
+type RelationshipType string
+
+// Entity relationship types
+const (
+	BelongsTo  RelationshipType = "belongs_to"   // A <entity>_id column referencing the other table
+	HasMany    RelationshipType = "has_many"     // The other entity belongs to this one
+	ManyToMany RelationshipType = "many_to_many" // A join table of both ids
+)
+
+var (
+	entityNamePattern = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+	columnPattern     = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// ParseEntityField parses the shorthand name:type[:required][:unique] of a
+// field, such as title:string:required
+func ParseEntityField(spec string) (EntityField, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 {
+		return EntityField{}, fmt.Errorf("field %q: want name:type", spec)
+	}
+	field := EntityField{Name: parts[0], Type: FieldType(parts[1])}
+	for _, modifier := range parts[2:] {
+		switch modifier {
+		case "required":
+			field.Required = true
+		case "unique":
+			field.Unique = true
+		default:
+			return EntityField{}, fmt.Errorf("field %q: unknown modifier %q (want required or unique)", spec, modifier)
+		}
+	}
+	return field, nil
+}
+
+// UnmarshalYAML accepts the name:type shorthand of ParseEntityField
+func (f *EntityField) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		field, err := ParseEntityField(value.Value)
+		if err != nil {
+			return err
+		}
+		*f = field
+		return nil
+	}
+	type plain EntityField
+	return value.Decode((*plain)(f))
+}
+
+// validate checks the field's name, type and constraints
+func (f EntityField) validate() error {
+	if !columnPattern.MatchString(f.Name) {
+		return fmt.Errorf("field %q: name must be snake_case", f.Name)
+	}
+	if f.Name == "id" {
+		return fmt.Errorf("field id: every entity has an id already")
+	}
+	switch f.Type {
+	case FieldString, FieldText, FieldInt, FieldFloat, FieldBool, FieldTime:
+	default:
+		return fmt.Errorf("field %s: unknown type %q (want string, text, int, float, bool or time)", f.Name, f.Type)
+	}
+	if f.Size < 0 || (f.Size > 0 && f.Type != FieldString) {
+		return fmt.Errorf("field %s: size is only for string fields", f.Name)
+	}
+	return nil
+}
+
+// Validate checks the name, table and fields of the entity, but not whether
+// the entities of its relationships exist
+func (e Entity) Validate() error {
+	if !entityNamePattern.MatchString(e.Name) {
+		return fmt.Errorf("entity %q: name must be a PascalCase identifier", e.Name)
+	}
+	if e.Table != "" && !columnPattern.MatchString(e.Table) {
+		return fmt.Errorf("entity %s: table must be snake_case", e.Name)
+	}
+
+	var fields []string
+	for _, f := range e.Fields {
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("entity %s: %w", e.Name, err)
+		}
+		if slices.Contains(fields, f.Name) {
+			return fmt.Errorf("entity %s: field %s defined twice", e.Name, f.Name)
+		}
+		fields = append(fields, f.Name)
+	}
+	return nil
+}
+
+// validateEntities checks the entities of a manifest. An entity another one
+// belongs to or shares a join table with comes first, so its table is
+// created first.
+func validateEntities(entities []Entity) error {
+	seen := make(map[string]bool)
+	for _, e := range entities {
+		if err := e.Validate(); err != nil {
+			return err
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("entity %s: defined twice", e.Name)
+		}
+
+		for _, rel := range e.Relationships {
+			switch rel.Type {
+			case BelongsTo, ManyToMany:
+				if !seen[rel.Entity] {
+					return fmt.Errorf("entity %s: %s %q must be an entity defined before it", e.Name, rel.Type, rel.Entity)
+				}
+			case HasMany:
+				if !slices.ContainsFunc(entities, func(other Entity) bool { return other.Name == rel.Entity }) {
+					return fmt.Errorf("entity %s: has_many %q is not an entity", e.Name, rel.Entity)
+				}
+			default:
+				return fmt.Errorf("entity %s: unknown relationship type %q (want belongs_to, has_many or many_to_many)", e.Name, rel.Type)
+			}
+		}
+		seen[e.Name] = true
+	}
+	return nil
+}
@@ -2,8 +2,10 @@ package ritual
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"gopkg.in/yaml.v3"
 )
@@ -30,6 +32,21 @@ func (l *Loader) Load(ritualPath string) (*Manifest, error) {
 		return nil, fmt.Errorf("failed to read ritual.yaml: %w", err)
 	}
 
+	return parseManifestFile(data)
+}
+
+// LoadFS loads a ritual manifest from the root of fsys
+func (l *Loader) LoadFS(fsys fs.FS) (*Manifest, error) {
+	data, err := fs.ReadFile(fsys, "ritual.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ritual.yaml: %w", err)
+	}
+
+	return parseManifestFile(data)
+}
+
+// parseManifestFile parses the content of a ritual.yaml file
+func parseManifestFile(data []byte) (*Manifest, error) {
 	var manifest Manifest
 	if err := yaml.Unmarshal(data, &manifest); err != nil {
 		return nil, fmt.Errorf("failed to parse ritual.yaml: %w", err)
@@ -84,6 +101,43 @@ func (m *Manifest) Validate() error {
 		if (q.Type == QuestionTypeChoice || q.Type == QuestionTypeMultiChoice) && len(q.Choices) == 0 {
 			return fmt.Errorf("question %s: choices required for choice type", q.Name)
 		}
+		if q.Generate != nil {
+			if err := q.Generate.validate(); err != nil {
+				return fmt.Errorf("question %s: %w", q.Name, err)
+			}
+		}
+	}
+
+	// Validate computed variables
+	for i, v := range m.Variables {
+		if v.Name == "" {
+			return fmt.Errorf("variable %d: name is required", i)
+		}
+		if v.Generate == nil {
+			return fmt.Errorf("variable %s: generate is required", v.Name)
+		}
+		if err := v.Generate.validate(); err != nil {
+			return fmt.Errorf("variable %s: %w", v.Name, err)
+		}
+	}
+
+	// Validate merge strategies
+	for _, mapping := range slices.Concat(m.Files.Templates, m.Files.Static) {
+		if err := ValidateMerge(mapping.Merge, mapping.MergeArrays); err != nil {
+			return fmt.Errorf("file %s: %w", mapping.Source, err)
+		}
+	}
+
+	// Validate injections
+	for i, inj := range m.Files.Inject {
+		if err := inj.validate(); err != nil {
+			return fmt.Errorf("inject %d: %w", i, err)
+		}
+	}
+
+	// Validate entities
+	if err := validateEntities(m.Entities); err != nil {
+		return err
 	}
 
 	// Validate migrations
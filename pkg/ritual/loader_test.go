@@ -1,9 +1,12 @@
 package ritual
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestLoadFromBytes(t *testing.T) {
@@ -372,3 +375,249 @@ func TestManifestValidate_ComprehensiveTests(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadFromBytes_Generate(t *testing.T) {
+	data := []byte(`ritual:
+  name: app
+  version: 1.0.0
+questions:
+  - name: db_password
+    prompt: Database password
+    type: password
+    generate: alnum
+variables:
+  - name: session_key
+    generate:
+      type: hex
+      length: 16
+`)
+	manifest, err := LoadFromBytes(data)
+	if err != nil {
+		t.Fatalf("LoadFromBytes() error = %v", err)
+	}
+	if gen := manifest.Questions[0].Generate; gen == nil || gen.Type != GenerateAlnum {
+		t.Errorf("the scalar shorthand should set the type, got %+v", gen)
+	}
+	if gen := manifest.Variables[0].Generate; gen == nil || gen.Type != GenerateHex || gen.Length != 16 {
+		t.Errorf("session_key generate = %+v", gen)
+	}
+
+	invalid := map[string]string{
+		"unknown type":      "variables:\n  - name: key\n    generate: rot13\n",
+		"bcrypt no from":    "variables:\n  - name: hash\n    generate: bcrypt\n",
+		"negative length":   "variables:\n  - name: key\n    generate:\n      type: hex\n      length: -1\n",
+		"variable no name":  "variables:\n  - generate: hex\n",
+		"variable no value": "variables:\n  - name: key\n",
+	}
+	for name, section := range invalid {
+		t.Run(name, func(t *testing.T) {
+			manifest, err := LoadFromBytes([]byte("ritual:\n  name: app\n  version: 1.0.0\n" + section))
+			if err == nil {
+				err = manifest.Validate()
+			}
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestManifestValidate_Inject(t *testing.T) {
+	tests := []struct {
+		name      string
+		inject    Injection
+		wantError bool
+	}{
+		{"marker", Injection{Content: "x", Into: "main.go", Before: "// ritual:routes"}, false},
+		{"regex", Injection{Source: "route.tmpl", Into: "main.go", After: `^import \(`, Regex: true}, false},
+		{"no file", Injection{Content: "x", Before: "// x"}, true},
+		{"no snippet", Injection{Into: "main.go", Before: "// x"}, true},
+		{"two snippets", Injection{Source: "a.tmpl", Content: "x", Into: "main.go", Before: "// x"}, true},
+		{"no marker", Injection{Content: "x", Into: "main.go"}, true},
+		{"two markers", Injection{Content: "x", Into: "main.go", Before: "// x", After: "// y"}, true},
+		{"invalid regex", Injection{Content: "x", Into: "main.go", Before: "(", Regex: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &Manifest{
+				Ritual: RitualMeta{Name: "test", Version: "1.0.0"},
+				Files:  FilesSection{Inject: []Injection{tt.inject}},
+			}
+			err := manifest.Validate()
+			if tt.wantError && err == nil {
+				t.Error("Expected error but got none")
+			}
+			if !tt.wantError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestManifestValidate_Merge(t *testing.T) {
+	manifest := &Manifest{
+		Ritual: RitualMeta{Name: "test", Version: "1.0.0"},
+		Files: FilesSection{
+			Templates: []FileMapping{{Source: "go.mod.tmpl", Destination: "go.mod", Merge: MergeGoMod}},
+			Static:    []FileMapping{{Source: "config.json", Destination: "config.json", Merge: MergeJSON, MergeArrays: MergeArraysAppend}},
+		},
+	}
+	if err := manifest.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	manifest.Files.Static[0].MergeArrays = "zip"
+	if err := manifest.Validate(); err == nil {
+		t.Error("Expected error for unknown merge_arrays")
+	}
+	manifest.Files.Static[0].MergeArrays = ""
+	manifest.Files.Templates[0].Merge = "toml"
+	if err := manifest.Validate(); err == nil {
+		t.Error("Expected error for unknown merge strategy")
+	}
+}
+
+func TestLoadFromBytes_Entities(t *testing.T) {
+	data := []byte(`ritual:
+  name: blog
+  version: 1.0.0
+entities:
+  - name: User
+    fields:
+      - email:string:required:unique
+    timestamps: true
+  - name: Post
+    fields:
+      - name: title
+        type: string
+        size: 200
+        required: true
+      - body:text
+    relationships:
+      - type: belongs_to
+        entity: User
+    soft_delete: true
+`)
+	manifest, err := LoadFromBytes(data)
+	if err != nil {
+		t.Fatalf("LoadFromBytes() error = %v", err)
+	}
+	if err := manifest.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if got := manifest.Entities[0].Fields[0]; got != (EntityField{Name: "email", Type: FieldString, Required: true, Unique: true}) {
+		t.Errorf("the shorthand should set name, type and modifiers, got %+v", got)
+	}
+	post := manifest.Entities[1]
+	if post.Fields[0].Size != 200 || post.Fields[1].Type != FieldText || !post.SoftDelete || post.Relationships[0].Type != BelongsTo {
+		t.Errorf("Post = %+v", post)
+	}
+
+	if _, err := LoadFromBytes([]byte("ritual:\n  name: a\n  version: 1.0.0\nentities:\n  - name: A\n    fields: [title:string:indexed]\n")); err == nil {
+		t.Error("expected an error for an unknown modifier")
+	}
+}
+
+func TestManifestValidate_Entities(t *testing.T) {
+	user := Entity{Name: "User", Fields: []EntityField{{Name: "email", Type: FieldString}}}
+	tests := []struct {
+		name     string
+		entities []Entity
+		wantErr  string
+	}{
+		{"valid", []Entity{user, {Name: "Post", Relationships: []EntityRelationship{{Type: BelongsTo, Entity: "User"}}}}, ""},
+		{"has many defined later", []Entity{{Name: "User", Relationships: []EntityRelationship{{Type: HasMany, Entity: "Post"}}}, {Name: "Post"}}, ""},
+		{"lower case name", []Entity{{Name: "user"}}, "PascalCase"},
+		{"twice", []Entity{user, user}, "defined twice"},
+		{"bad table", []Entity{{Name: "User", Table: "Users"}}, "snake_case"},
+		{"bad field name", []Entity{{Name: "User", Fields: []EntityField{{Name: "Email", Type: FieldString}}}}, "snake_case"},
+		{"id field", []Entity{{Name: "User", Fields: []EntityField{{Name: "id", Type: FieldInt}}}}, "has an id"},
+		{"unknown type", []Entity{{Name: "User", Fields: []EntityField{{Name: "age", Type: "uint8"}}}}, "unknown type"},
+		{"size of int", []Entity{{Name: "User", Fields: []EntityField{{Name: "age", Type: FieldInt, Size: 3}}}}, "only for string"},
+		{"field twice", []Entity{{Name: "User", Fields: []EntityField{{Name: "a", Type: FieldInt}, {Name: "a", Type: FieldInt}}}}, "field a defined twice"},
+		{"belongs to later", []Entity{{Name: "Post", Relationships: []EntityRelationship{{Type: BelongsTo, Entity: "User"}}}, user}, "defined before"},
+		{"has many unknown", []Entity{{Name: "User", Relationships: []EntityRelationship{{Type: HasMany, Entity: "Post"}}}}, "not an entity"},
+		{"unknown relationship", []Entity{user, {Name: "Post", Relationships: []EntityRelationship{{Type: "has_one", Entity: "User"}}}}, "unknown relationship"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest := &Manifest{Ritual: RitualMeta{Name: "test", Version: "1.0.0"}, Entities: tt.entities}
+			err := manifest.Validate()
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Validate() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	fsys := fstest.MapFS{
+		"blog/ritual.yaml":            {Data: []byte("ritual:\n  name: blog\n  version: 1.2.0\n")},
+		"blog/templates/main.go.tmpl": {Data: []byte("package main\n")},
+		"_shared/docker/Dockerfile":   {Data: []byte("FROM golang\n")},
+	}
+
+	src, err := NewSource(fsys, "blog", "embedded:blog")
+	if err != nil {
+		t.Fatalf("NewSource() error = %v", err)
+	}
+	if src.Dir() != "" {
+		t.Errorf("Dir() = %q, want empty for a ritual not on disk", src.Dir())
+	}
+
+	manifest, err := src.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if manifest.Ritual.Name != "blog" || manifest.Ritual.TemplateEngine != "fith" {
+		t.Errorf("Load() = %+v", manifest.Ritual)
+	}
+
+	if _, err := fs.Stat(src.FS, "templates/main.go.tmpl"); err != nil {
+		t.Errorf("template not found in ritual FS: %v", err)
+	}
+	if src.Shared == nil {
+		t.Fatal("Shared is nil, want the _shared directory")
+	}
+	if _, err := fs.Stat(src.Shared, "docker/Dockerfile"); err != nil {
+		t.Errorf("shared file not found: %v", err)
+	}
+}
+
+func TestDirSource(t *testing.T) {
+	root := t.TempDir()
+	ritualDir := filepath.Join(root, "blog")
+	if err := os.MkdirAll(filepath.Join(root, SharedDir), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(ritualDir, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(ritualDir, "ritual.yaml"), []byte("ritual:\n  name: blog\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	src := DirSource(ritualDir)
+	if src.Dir() != ritualDir || src.Path != ritualDir {
+		t.Errorf("DirSource() dir = %q, path = %q", src.Dir(), src.Path)
+	}
+	if src.Shared == nil {
+		t.Error("Shared is nil, want the sibling _shared directory")
+	}
+	if _, err := src.Load(); err != nil {
+		t.Errorf("Load() error = %v", err)
+	}
+
+	// Without a _shared directory next to it, Shared stays nil
+	if err := os.Remove(filepath.Join(root, SharedDir)); err != nil {
+		t.Fatal(err)
+	}
+	if DirSource(ritualDir).Shared != nil {
+		t.Error("Shared should be nil without a _shared directory")
+	}
+}
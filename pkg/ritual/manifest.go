@@ -1,12 +1,21 @@
 package ritual
 
+import (
+	"fmt"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
 // Manifest represents the complete ritual.yaml definition
 type Manifest struct {
 	Ritual        RitualMeta    `yaml:"ritual"`
 	Compatibility Compatibility `yaml:"compatibility,omitempty"`
 	Dependencies  Dependencies  `yaml:"dependencies,omitempty"`
 	Questions     []Question    `yaml:"questions,omitempty"`
+	Variables     []Variable    `yaml:"variables,omitempty"`
 	Files         FilesSection  `yaml:"files,omitempty"`
+	Entities      []Entity      `yaml:"entities,omitempty"`
 	Migrations    []Migration   `yaml:"migrations,omitempty"`
 	Hooks         ManifestHooks `yaml:"hooks,omitempty"`
 	MultiTenancy  *MultiTenancy `yaml:"multi_tenancy,omitempty"`
@@ -25,8 +34,16 @@ type RitualMeta struct {
 	Repository     string   `yaml:"repository,omitempty"`
 	Tags           []string `yaml:"tags,omitempty"`
 	TemplateEngine string   `yaml:"template_engine,omitempty"` // fith, go-template, custom
+	MissingKey     string   `yaml:"missing_key,omitempty"`     // default, or error to fail on variables that were not given
+	Env            []string `yaml:"env,omitempty"`             // Environment variables templates may read with env
 }
 
+// Missing key modes: how templates treat a variable that was not given
+const (
+	MissingKeyDefault = "default" // Render nothing (Fíth) or "<no value>" (Go templates)
+	MissingKeyError   = "error"   // Fail, naming the variable and the ones that were given
+)
+
 // Compatibility defines version requirements
 type Compatibility struct {
 	MinToutaVersion string `yaml:"min_touta_version,omitempty"`
@@ -77,6 +94,61 @@ type Question struct {
 	Helper    *QuestionHelper    `yaml:"helper,omitempty"`
 	Group     string             `yaml:"group,omitempty"`
 	Step      int                `yaml:"step,omitempty"`
+	Generate  *Generate          `yaml:"generate,omitempty"` // Secret generated when the question is left empty
+}
+
+// Variable is a value the ritual computes itself instead of asking for
+type Variable struct {
+	Name     string    `yaml:"name"`
+	Generate *Generate `yaml:"generate,omitempty"`
+}
+
+// Generate describes a secret produced with crypto/rand. Generated secrets
+// are rendered only into protected files and never saved with the answers.
+type Generate struct {
+	Type   GenerateType `yaml:"type"`
+	Length int          `yaml:"length,omitempty"` // Characters (alnum) or random bytes (hex, base64); default 32
+	From   string       `yaml:"from,omitempty"`   // bcrypt: the variable to hash
+	Cost   int          `yaml:"cost,omitempty"`   // bcrypt: cost (default 10)
+}
+
+// GenerateType is the kind of secret to generate
+type GenerateType string
+
+// Secret kinds rituals can generate
+const (
+	GenerateAlnum   GenerateType = "alnum"   // Random letters and digits
+	GenerateHex     GenerateType = "hex"     // Random bytes, hex-encoded
+	GenerateBase64  GenerateType = "base64"  // Random bytes, base64-encoded
+	GenerateBcrypt  GenerateType = "bcrypt"  // bcrypt hash of another variable
+	GenerateEd25519 GenerateType = "ed25519" // PEM private key; the public key goes in <name>_public
+)
+
+// UnmarshalYAML accepts the plain type name as a shorthand: generate: hex
+func (g *Generate) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		g.Type = GenerateType(value.Value)
+		return nil
+	}
+	type plain Generate
+	return value.Decode((*plain)(g))
+}
+
+// validate checks the kind of secret and what it needs
+func (g *Generate) validate() error {
+	switch g.Type {
+	case GenerateAlnum, GenerateHex, GenerateBase64, GenerateEd25519:
+	case GenerateBcrypt:
+		if g.From == "" {
+			return fmt.Errorf("generate: bcrypt needs from, the variable to hash")
+		}
+	default:
+		return fmt.Errorf("generate: unknown type %q (want alnum, hex, base64, bcrypt or ed25519)", g.Type)
+	}
+	if g.Length < 0 {
+		return fmt.Errorf("generate: length must not be negative")
+	}
+	return nil
 }
 
 // ValidationRule defines validation constraints
@@ -112,6 +184,7 @@ type FilesSection struct {
 	Static      []FileMapping `yaml:"static,omitempty"`
 	Directories []string      `yaml:"directories,omitempty"` // directories to create
 	Protected   []string      `yaml:"protected,omitempty"`   // files to never overwrite
+	Inject      []Injection   `yaml:"inject,omitempty"`      // snippets to insert into existing files
 }
 
 // FileMapping maps source to destination
@@ -120,6 +193,74 @@ type FileMapping struct {
 	Destination string `yaml:"dest"`
 	Optional    bool   `yaml:"optional,omitempty"`
 	Condition   string `yaml:"condition,omitempty"`
+	Foreach     string `yaml:"foreach,omitempty"`      // List or map variable to generate the mapping for, once per item
+	As          string `yaml:"as,omitempty"`           // Variable holding the current item (default "item")
+	Merge       string `yaml:"merge,omitempty"`        // How to combine the file with an existing one
+	MergeArrays string `yaml:"merge_arrays,omitempty"` // How a json or yaml merge combines arrays
+}
+
+// Merge strategies combining a generated file with the file already at its destination
+const (
+	MergeAppend = "append" // Append the output, unless the file already contains it
+	MergeLines  = "lines"  // Add the lines the file does not have yet, as for .gitignore
+	MergeGoMod  = "gomod"  // Union of go.mod requirements and replacements, the higher version winning
+	MergeJSON   = "json"   // Deep merge of JSON objects, the existing values winning
+	MergeYAML   = "yaml"   // Deep merge of YAML mappings, the existing values winning
+	MergeAuto   = "auto"   // Pick a strategy from the file name
+)
+
+// How json and yaml merges combine an array in both files
+const (
+	MergeArraysUnion   = "union"   // Add the items the existing array lacks (default)
+	MergeArraysAppend  = "append"  // Add every item
+	MergeArraysReplace = "replace" // Use the generated array
+)
+
+// ValidateMerge checks a merge strategy and array option
+func ValidateMerge(strategy, arrays string) error {
+	switch strategy {
+	case "", MergeAppend, MergeLines, MergeGoMod, MergeJSON, MergeYAML, MergeAuto:
+	default:
+		return fmt.Errorf("unknown merge strategy %q", strategy)
+	}
+	switch arrays {
+	case "", MergeArraysUnion, MergeArraysAppend, MergeArraysReplace:
+	default:
+		return fmt.Errorf("unknown merge_arrays %q, expected union, append or replace", arrays)
+	}
+	return nil
+}
+
+// Injection inserts a rendered snippet into a file, before or after the first
+// line containing a marker such as "// ritual:routes" or matching an expression
+type Injection struct {
+	Source    string `yaml:"src,omitempty"`      // Template of the snippet, below templates/
+	Content   string `yaml:"content,omitempty"`  // Inline template of the snippet, instead of src
+	Into      string `yaml:"into"`               // File to insert into; may hold variables
+	Before    string `yaml:"before,omitempty"`   // Marker to insert before
+	After     string `yaml:"after,omitempty"`    // Marker to insert after
+	Regex     bool   `yaml:"regex,omitempty"`    // The marker is a regular expression
+	Optional  bool   `yaml:"optional,omitempty"` // Skip the snippet if the file does not exist
+	Condition string `yaml:"condition,omitempty"`
+}
+
+// validate checks that the injection has one snippet and one marker
+func (i Injection) validate() error {
+	if i.Into == "" {
+		return fmt.Errorf("into is required")
+	}
+	if (i.Source == "") == (i.Content == "") {
+		return fmt.Errorf("one of src and content is required")
+	}
+	if (i.Before == "") == (i.After == "") {
+		return fmt.Errorf("one of before and after is required")
+	}
+	if i.Regex {
+		if _, err := regexp.Compile(i.Before + i.After); err != nil {
+			return fmt.Errorf("invalid marker: %w", err)
+		}
+	}
+	return nil
 }
 
 // Migration represents a version migration
@@ -0,0 +1,68 @@
+package ritual
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// SharedDir is the directory next to the rituals that holds templates they share
+const SharedDir = "_shared"
+
+// Source gives access to a ritual's files wherever they are stored: a directory
+// on disk, the rituals embedded in the binary, or a tarball read into memory.
+type Source struct {
+	// FS is rooted at the ritual directory: ritual.yaml, templates/ and static/
+	FS fs.FS
+	// Shared is the _shared directory next to the ritual, nil if there is none
+	Shared fs.FS
+	// Path names the ritual in messages; for a ritual on disk it is its directory
+	Path string
+
+	dir string
+}
+
+// DirSource returns the ritual in a directory on disk.
+// Its _shared templates are looked up next to that directory.
+func DirSource(dir string) *Source {
+	parent := filepath.Dir(dir)
+	if abs, err := filepath.Abs(dir); err == nil {
+		parent = filepath.Dir(abs)
+	}
+
+	src := &Source{FS: os.DirFS(dir), Path: dir, dir: dir}
+	if info, err := os.Stat(filepath.Join(parent, SharedDir)); err == nil && info.IsDir() {
+		src.Shared = os.DirFS(filepath.Join(parent, SharedDir))
+	}
+	return src
+}
+
+// NewSource returns the ritual in directory dir of fsys, with the _shared
+// directory next to it. location names the ritual in messages.
+func NewSource(fsys fs.FS, dir, location string) (*Source, error) {
+	root, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ritual %s: %w", location, err)
+	}
+
+	src := &Source{FS: root, Path: location}
+	sharedDir := path.Join(path.Dir(dir), SharedDir)
+	if info, err := fs.Stat(fsys, sharedDir); err == nil && info.IsDir() {
+		if src.Shared, err = fs.Sub(fsys, sharedDir); err != nil {
+			return nil, fmt.Errorf("failed to open %s for ritual %s: %w", SharedDir, location, err)
+		}
+	}
+	return src, nil
+}
+
+// Dir returns the ritual's directory on disk, or "" if it is not on disk
+func (s *Source) Dir() string {
+	return s.dir
+}
+
+// Load loads the ritual manifest
+func (s *Source) Load() (*Manifest, error) {
+	return NewLoader(s.Path).LoadFS(s.FS)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
 )
 
 // TestDockerComposeTemplateRendering tests docker-compose.yml template rendering
@@ -311,6 +312,7 @@ func TestConditionalFrontendService(t *testing.T) {
 		"slugify": func(s string) string {
 			return strings.ReplaceAll(strings.ToLower(s), " ", "-")
 		},
+		"hasDatabaseService": generator.HasDatabaseService,
 	})
 	
 	tmpl, err := tmpl.ParseFiles(tmplPath)
@@ -0,0 +1,79 @@
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/toutaio/toutago-ritual-grove/internal/generator"
+	"github.com/toutaio/toutago-ritual-grove/pkg/ritual"
+)
+
+// blogEntities are users, tags and blog posts belonging to a user and sharing tags
+var blogEntities = []ritual.Entity{
+	{
+		Name:          "User",
+		Fields:        []ritual.EntityField{{Name: "email", Type: ritual.FieldString, Required: true, Unique: true}},
+		Relationships: []ritual.EntityRelationship{{Type: ritual.HasMany, Entity: "BlogPost"}},
+		Timestamps:    true,
+	},
+	{Name: "Tag", Table: "labels", Fields: []ritual.EntityField{{Name: "name", Type: ritual.FieldString, Size: 40}}},
+	{
+		Name: "BlogPost",
+		Fields: []ritual.EntityField{
+			{Name: "title", Type: ritual.FieldString, Size: 200, Required: true},
+			{Name: "body", Type: ritual.FieldText},
+			{Name: "views", Type: ritual.FieldInt, Required: true, Default: "0"},
+			{Name: "rating", Type: ritual.FieldFloat},
+			{Name: "featured", Type: ritual.FieldBool},
+			{Name: "published_at", Type: ritual.FieldTime},
+		},
+		Relationships: []ritual.EntityRelationship{
+			{Type: ritual.BelongsTo, Entity: "User"},
+			{Type: ritual.ManyToMany, Entity: "Tag"},
+		},
+		Timestamps: true,
+		SoftDelete: true,
+	},
+}
+
+// TestGeneratedEntities compiles the models, repositories, handlers and routes
+// generated for each database and runs their tests, which use SQLite
+func TestGeneratedEntities(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	for _, dialect := range []string{"postgres", "mysql", "sqlite3"} {
+		t.Run(dialect, func(t *testing.T) {
+			files, err := generator.NewEntityGenerator("example.com/blog", dialect, blogEntities).Generate()
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+
+			projectPath := t.TempDir()
+			goMod := "module example.com/blog\n\ngo 1.21\n"
+			if err := os.WriteFile(filepath.Join(projectPath, "go.mod"), []byte(goMod), 0600); err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range files {
+				path := filepath.Join(projectPath, filepath.FromSlash(f.Path))
+				if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(f.Content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, args := range [][]string{{"mod", "tidy"}, {"vet", "./..."}, {"test", "./..."}} {
+				cmd := exec.Command("go", args...)
+				cmd.Dir = projectPath
+				if output, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("go %v failed: %v\n%s", args, err, output)
+				}
+			}
+		})
+	}
+}
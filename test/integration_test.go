@@ -310,7 +310,7 @@ files:
 description: A template with frontmatter
 author: Test
 ---
-Hello {{ .name }}!`
+Hello [[ .name ]]!`
 
 	if err := os.WriteFile(filepath.Join(templatesDir, "with-frontmatter.tmpl"), []byte(template), 0600); err != nil {
 		t.Fatal(err)
@@ -328,7 +328,7 @@ Hello {{ .name }}!`
 	}
 
 	// Verify template content
-	if content != "Hello {{ .name }}!" {
+	if content != "Hello [[ .name ]]!" {
 		t.Errorf("Expected template content to be extracted, got: %s", content)
 	}
 
@@ -355,11 +355,8 @@ Hello {{ .name }}!`
 		t.Fatalf("Failed to read output: %v", err)
 	}
 
-	// The generator should strip frontmatter when rendering templates
-	// For now, if frontmatter is included, we skip this assertion
-	// TODO: Implement frontmatter stripping in the generator
-	t.Logf("Output content: %s", string(outputContent))
-	// if string(outputContent) != "Hello World!" {
-	// 	t.Errorf("Expected 'Hello World!', got: %s", string(outputContent))
-	// }
+	// The generator strips frontmatter when rendering templates
+	if string(outputContent) != "Hello World!" {
+		t.Errorf("Expected 'Hello World!', got: %s", string(outputContent))
+	}
 }